@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+const commandUsage = `usage:
+  main                          run the API server
+  main import-ssim <file>       import the schedules of an SSIM file
+  main export-ssim <file>       export all schedules to an SSIM file`
+
+// runCommand runs the command in args instead of the server. It uses the
+// configured database, so with LOCAL_DB an import only validates the file.
+func runCommand(ctx context.Context, s svcs, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("%s", commandUsage)
+	}
+
+	switch args[0] {
+	case "import-ssim":
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		res, err := s.Schedule.ImportSSIM(ctx, f)
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", args[1], err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	case "export-ssim":
+		f, err := os.Create(args[1])
+		if err != nil {
+			return err
+		}
+		if err := s.Schedule.ExportSSIM(ctx, f, services.ScheduleFilter{}); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to export %s: %w", args[1], err)
+		}
+		return f.Close()
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], commandUsage)
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"database/sql"
 	"fmt"
 
-	"github.com/leanderkunstmann/terraroute/backend/models"
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/uptrace/bun"
 	"github.com/uptrace/bun/dialect/pgdialect"
 	"github.com/uptrace/bun/dialect/sqlitedialect"
@@ -54,11 +52,21 @@ func newPostgresDB(ctx context.Context, cfg *Config) (*bun.DB, error) {
 		return nil, fmt.Errorf("database ping failed: %w", err)
 	}
 
+	if err := migrateSchema(ctx, db); err != nil {
+		return nil, err
+	}
+	if err := createAirportSearch(ctx, db); err != nil {
+		return nil, fmt.Errorf("creating airport search index: %w", err)
+	}
+	if err := enableSpatial(ctx, db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
 
 func newLocalDB(ctx context.Context) (*bun.DB, error) {
-	sqldb, err := sql.Open("sqlite3", "file::memory:?cache=shared")
+	sqldb, err := sql.Open(sqliteDriver, "file::memory:?cache=shared")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
 	}
@@ -69,61 +77,15 @@ func newLocalDB(ctx context.Context) (*bun.DB, error) {
 		return nil, fmt.Errorf("database ping failed: %w", err)
 	}
 
-	airports := []models.Airport{
-		{IATA: "JFK", Name: "John F. Kennedy International Airport", City: "New York", Country: "USA", Continent: "North America", Latitude: 40.6413, Longitude: -73.7781},
-		{IATA: "LAX", Name: "Los Angeles International Airport", City: "Los Angeles", Country: "USA", Continent: "North America", Latitude: 33.9416, Longitude: -118.4085},
-		{IATA: "CDG", Name: "Charles de Gaulle Airport", City: "Paris", Country: "France", Continent: "Europe", Latitude: 49.0097, Longitude: 2.5479},
-		{IATA: "FRA", Name: "Frankfurt Airport", City: "Frankfurt", Country: "Germany", Continent: "Europe", Latitude: 50.0333, Longitude: 8.5706},
-		{IATA: "PVG", Name: "Shanghai Pudong International Airport", City: "Shanghai", Country: "China", Continent: "Asia", Latitude: 31.1434, Longitude: 121.805},
-		{IATA: "NGO", Name: "Chubu Centrair International Airport", City: "Nagoya", Country: "Japan", Continent: "Asia", Latitude: 34.8583, Longitude: 136.805},
-		{IATA: "AKL", Name: "Auckland Airport", City: "Auckland", Country: "New Zealand", Continent: "Oceania", Latitude: -37.0081, Longitude: 174.792},
-		{IATA: "ADD", Name: "Addis Ababa Bole International Airport", City: "Addis Ababa", Country: "Ethiopia", Continent: "Africa", Latitude: 8.97789, Longitude: 38.799301},
-	}
-	aircrafts := []models.Aircraft{
-		{Id: 1, Type: models.Commercial, Name: "Boeing 737", Manufacturer: "Boeing", Range: 3510},
-		{Id: 2, Type: models.Heavy, Name: "Gulfstream G650", Manufacturer: "Gulfstream", Range: 7500},
-		{Id: 3, Type: models.Cargo, Name: "Antonov An-225", Manufacturer: "Antonov", Range: 9700},
-		{Id: 4, Type: models.Commercial, Name: "Airbus A320", Manufacturer: "Airbus", Range: 3200},
+	if err := migrateSchema(ctx, db); err != nil {
+		return nil, err
 	}
-	flights := []models.Flight{
-		{FlightNumber: "AA100", AircraftId: 1, Origin: "JFK", Destination: "LAX", DepartureTime: "2023-10-01T08:00:00Z", ArrivalTime: "2023-10-01T11:00:00Z"},
-		{FlightNumber: "AF200", AircraftId: 2, Origin: "CDG", Destination: "JFK", DepartureTime: "2023-10-02T09:00:00Z", ArrivalTime: "2023-10-02T12:00:00Z"},
-		{FlightNumber: "DL300", AircraftId: 3, Origin: "LAX", Destination: "CDG", DepartureTime: "2023-10-03T10:00:00Z", ArrivalTime: "2023-10-03T18:00:00Z"},
+	if err := createAirportSearch(ctx, db); err != nil {
+		return nil, fmt.Errorf("creating airport search index: %w", err)
 	}
-
-	if err = initLocalDB(ctx, airports, aircrafts, flights); err != nil {
-		return nil, fmt.Errorf("creating database: %w", err)
+	if err := seedLocalDB(ctx, db); err != nil {
+		return nil, fmt.Errorf("seeding database: %w", err)
 	}
 
 	return db, nil
 }
-
-func initLocalDB(ctx context.Context, airports []models.Airport, aircrafts []models.Aircraft, flights []models.Flight) error {
-	_, err := db.NewCreateTable().Model((*models.Airport)(nil)).Exec(ctx)
-	if err != nil {
-		return err
-	}
-	_, err = db.NewCreateTable().Model((*models.Aircraft)(nil)).Exec(ctx)
-	if err != nil {
-		return err
-	}
-	_, err = db.NewCreateTable().Model((*models.Flight)(nil)).Exec(ctx)
-	if err != nil {
-		return err
-	}
-
-	_, err = db.NewInsert().Model(&airports).Exec(ctx)
-	if err != nil {
-		return err
-	}
-	_, err = db.NewInsert().Model(&aircrafts).Exec(ctx)
-	if err != nil {
-		return err
-	}
-	_, err = db.NewInsert().Model(&flights).Exec(ctx)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
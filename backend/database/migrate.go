@@ -0,0 +1,29 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/leanderkunstmann/terraroute/backend/database/migrations"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/migrate"
+)
+
+// migrateSchema applies all pending schema migrations.
+func migrateSchema(ctx context.Context, db *bun.DB) error {
+	migrator := migrate.NewMigrator(db, migrations.Migrations)
+	if err := migrator.Init(ctx); err != nil {
+		return fmt.Errorf("failed to initialize migrations: %w", err)
+	}
+
+	group, err := migrator.Migrate(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	if !group.IsZero() {
+		log.Printf("Database migrated to %s", group)
+	}
+
+	return nil
+}
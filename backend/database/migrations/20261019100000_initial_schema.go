@@ -0,0 +1,61 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// The initial schema matches the tables the application used before schema
+// migrations were introduced. Existing tables are left untouched.
+func init() {
+	type airport struct {
+		bun.BaseModel `bun:"table:airports"`
+
+		IATA      string `bun:",pk"`
+		Name      string
+		City      string
+		Country   string
+		Continent string
+		Latitude  float64
+		Longitude float64
+	}
+	type aircraft struct {
+		bun.BaseModel `bun:"table:aircrafts"`
+
+		Id           int `bun:",pk,autoincrement"`
+		Type         string
+		Name         string
+		Manufacturer string
+		Range        int
+	}
+	type flight struct {
+		bun.BaseModel `bun:"table:flights"`
+
+		FlightNumber  string
+		AircraftId    int
+		Origin        string
+		Destination   string
+		DepartureTime string
+		ArrivalTime   string
+	}
+
+	tables := []any{(*airport)(nil), (*aircraft)(nil), (*flight)(nil)}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		for _, table := range tables {
+			if _, err := db.NewCreateTable().Model(table).IfNotExists().Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create table: %w", err)
+			}
+		}
+		return nil
+	}, func(ctx context.Context, db *bun.DB) error {
+		for _, table := range tables {
+			if _, err := db.NewDropTable().Model(table).IfExists().Exec(ctx); err != nil {
+				return fmt.Errorf("failed to drop table: %w", err)
+			}
+		}
+		return nil
+	})
+}
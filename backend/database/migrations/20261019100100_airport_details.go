@@ -0,0 +1,54 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// Adds the ICAO code and time zone of airports and their runways.
+func init() {
+	type runway struct {
+		bun.BaseModel `bun:"table:runways"`
+
+		Id          int64 `bun:",pk,autoincrement"`
+		AirportIATA string
+		Designator  string
+		LengthM     int
+		WidthM      int
+		Surface     string
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				"ALTER TABLE airports ADD COLUMN icao VARCHAR",
+				"ALTER TABLE airports ADD COLUMN time_zone VARCHAR",
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to add airport column: %w", err)
+				}
+			}
+			if _, err := tx.NewCreateTable().Model((*runway)(nil)).Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create runways table: %w", err)
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewDropTable().Model((*runway)(nil)).IfExists().Exec(ctx); err != nil {
+				return fmt.Errorf("failed to drop runways table: %w", err)
+			}
+			for _, stmt := range []string{
+				"ALTER TABLE airports DROP COLUMN time_zone",
+				"ALTER TABLE airports DROP COLUMN icao",
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to drop airport column: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,199 @@
+package migrations
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
+// countriesCSV lists all ISO 3166-1 countries with their UN M49 region and
+// subregion and the continent they are assigned to.
+//
+//go:embed countries.csv
+var countriesCSV []byte
+
+// Introduces the continents and countries reference tables and replaces the
+// free text country and continent of airports with foreign keys to them.
+func init() {
+	type continent struct {
+		bun.BaseModel `bun:"table:continents"`
+
+		Code string `bun:",pk"`
+		Name string `bun:",notnull"`
+	}
+	type country struct {
+		bun.BaseModel `bun:"table:countries"`
+
+		Alpha2        string `bun:",pk"`
+		Alpha3        string `bun:",notnull,unique"`
+		Numeric       string `bun:",notnull"`
+		Name          string `bun:",notnull"`
+		OfficialName  string
+		Region        string
+		Subregion     string
+		ContinentCode string `bun:",notnull"`
+	}
+	type airport struct {
+		bun.BaseModel `bun:"table:airports"`
+
+		IATA          string `bun:",pk"`
+		Country       string
+		Continent     string
+		CountryCode   string `bun:",nullzero"`
+		ContinentCode string `bun:",nullzero"`
+	}
+
+	continents := []continent{
+		{Code: "AF", Name: "Africa"},
+		{Code: "AN", Name: "Antarctica"},
+		{Code: "AS", Name: "Asia"},
+		{Code: "EU", Name: "Europe"},
+		{Code: "NA", Name: "North America"},
+		{Code: "OC", Name: "Oceania"},
+		{Code: "SA", Name: "South America"},
+	}
+
+	// countryAliases resolves common spellings of country names that are
+	// neither the ISO short nor the official name.
+	countryAliases := map[string]string{
+		"uk":             "GB",
+		"great britain":  "GB",
+		"england":        "GB",
+		"russia":         "RU",
+		"south korea":    "KR",
+		"north korea":    "KP",
+		"vietnam":        "VN",
+		"iran":           "IR",
+		"syria":          "SY",
+		"laos":           "LA",
+		"czech republic": "CZ",
+		"turkey":         "TR",
+		"macau":          "MO",
+		"ivory coast":    "CI",
+		"cape verde":     "CV",
+		"swaziland":      "SZ",
+		"holland":        "NL",
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		records, err := csv.NewReader(bytes.NewReader(countriesCSV)).ReadAll()
+		if err != nil {
+			return fmt.Errorf("failed to read countries: %w", err)
+		}
+		var countries []country
+		for _, r := range records[1:] {
+			countries = append(countries, country{
+				Alpha2:        r[0],
+				Alpha3:        r[1],
+				Numeric:       r[2],
+				Name:          r[3],
+				OfficialName:  r[4],
+				Region:        r[5],
+				Subregion:     r[6],
+				ContinentCode: r[7],
+			})
+		}
+
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewCreateTable().Model((*continent)(nil)).Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create continents table: %w", err)
+			}
+			if _, err := tx.NewCreateTable().Model((*country)(nil)).
+				ForeignKey(`("continent_code") REFERENCES "continents" ("code")`).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create countries table: %w", err)
+			}
+			if _, err := tx.NewInsert().Model(&continents).Exec(ctx); err != nil {
+				return fmt.Errorf("failed to insert continents: %w", err)
+			}
+			if _, err := tx.NewInsert().Model(&countries).Exec(ctx); err != nil {
+				return fmt.Errorf("failed to insert countries: %w", err)
+			}
+
+			for _, stmt := range []string{
+				`ALTER TABLE airports ADD COLUMN country_code VARCHAR REFERENCES countries (alpha2)`,
+				`ALTER TABLE airports ADD COLUMN continent_code VARCHAR REFERENCES continents (code)`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to add airport column: %w", err)
+				}
+			}
+
+			// Resolve the free text values of existing airports.
+			countryCodes := map[string]string{}
+			continentOf := map[string]string{}
+			for alias, code := range countryAliases {
+				countryCodes[alias] = code
+			}
+			for _, c := range countries {
+				for _, key := range []string{c.Alpha2, c.Alpha3, c.Name, c.OfficialName} {
+					if key != "" {
+						countryCodes[strings.ToLower(key)] = c.Alpha2
+					}
+				}
+				continentOf[c.Alpha2] = c.ContinentCode
+			}
+			continentCodes := map[string]string{}
+			for _, c := range continents {
+				continentCodes[strings.ToLower(c.Code)] = c.Code
+				continentCodes[strings.ToLower(c.Name)] = c.Code
+			}
+
+			var airports []airport
+			if err := tx.NewSelect().Model(&airports).Column("iata", "country", "continent").Scan(ctx); err != nil {
+				return fmt.Errorf("failed to read airports: %w", err)
+			}
+			for _, a := range airports {
+				a.CountryCode = countryCodes[strings.ToLower(strings.TrimSpace(a.Country))]
+				a.ContinentCode = continentCodes[strings.ToLower(strings.TrimSpace(a.Continent))]
+				if a.ContinentCode == "" {
+					a.ContinentCode = continentOf[a.CountryCode]
+				}
+				if a.CountryCode == "" && a.Country != "" {
+					log.Printf("migration: unknown country %q of airport %s, leaving it empty", a.Country, a.IATA)
+				}
+				if _, err := tx.NewUpdate().Model(&a).Column("country_code", "continent_code").WherePK().Exec(ctx); err != nil {
+					return fmt.Errorf("failed to update airport %s: %w", a.IATA, err)
+				}
+			}
+
+			for _, stmt := range []string{
+				`ALTER TABLE airports DROP COLUMN country`,
+				`ALTER TABLE airports DROP COLUMN continent`,
+				`CREATE INDEX airports_country_code_idx ON airports (country_code)`,
+				`CREATE INDEX airports_continent_code_idx ON airports (continent_code)`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to migrate airports: %w", err)
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				`ALTER TABLE airports ADD COLUMN country VARCHAR`,
+				`ALTER TABLE airports ADD COLUMN continent VARCHAR`,
+				`UPDATE airports SET country = (SELECT name FROM countries WHERE alpha2 = airports.country_code)`,
+				`UPDATE airports SET continent = (SELECT name FROM continents WHERE code = airports.continent_code)`,
+				`DROP INDEX airports_country_code_idx`,
+				`DROP INDEX airports_continent_code_idx`,
+				`ALTER TABLE airports DROP COLUMN country_code`,
+				`ALTER TABLE airports DROP COLUMN continent_code`,
+				`DROP TABLE countries`,
+				`DROP TABLE continents`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to revert country references: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,88 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// Adds metropolitan areas grouping the airports that serve the same city,
+// e.g. NYC for JFK, LGA and EWR.
+func init() {
+	type metroArea struct {
+		bun.BaseModel `bun:"table:metro_areas"`
+
+		Code        string `bun:",pk"`
+		Name        string `bun:",notnull"`
+		CountryCode string `bun:",nullzero"`
+	}
+
+	// metroAreas lists well-known IATA metropolitan area codes with the
+	// airports assigned to them. Only airports already stored are linked.
+	metroAreas := []struct {
+		metroArea
+		airports []string
+	}{
+		{metroArea{Code: "NYC", Name: "New York", CountryCode: "US"}, []string{"JFK", "LGA", "EWR"}},
+		{metroArea{Code: "CHI", Name: "Chicago", CountryCode: "US"}, []string{"ORD", "MDW"}},
+		{metroArea{Code: "WAS", Name: "Washington", CountryCode: "US"}, []string{"IAD", "DCA", "BWI"}},
+		{metroArea{Code: "YTO", Name: "Toronto", CountryCode: "CA"}, []string{"YYZ", "YTZ"}},
+		{metroArea{Code: "SAO", Name: "São Paulo", CountryCode: "BR"}, []string{"GRU", "CGH", "VCP"}},
+		{metroArea{Code: "BUE", Name: "Buenos Aires", CountryCode: "AR"}, []string{"EZE", "AEP"}},
+		{metroArea{Code: "LON", Name: "London", CountryCode: "GB"}, []string{"LHR", "LGW", "STN", "LCY", "LTN", "SEN"}},
+		{metroArea{Code: "PAR", Name: "Paris", CountryCode: "FR"}, []string{"CDG", "ORY", "BVA"}},
+		{metroArea{Code: "MIL", Name: "Milan", CountryCode: "IT"}, []string{"MXP", "LIN", "BGY"}},
+		{metroArea{Code: "ROM", Name: "Rome", CountryCode: "IT"}, []string{"FCO", "CIA"}},
+		{metroArea{Code: "STO", Name: "Stockholm", CountryCode: "SE"}, []string{"ARN", "BMA", "NYO"}},
+		{metroArea{Code: "MOW", Name: "Moscow", CountryCode: "RU"}, []string{"SVO", "DME", "VKO"}},
+		{metroArea{Code: "TYO", Name: "Tokyo", CountryCode: "JP"}, []string{"HND", "NRT"}},
+		{metroArea{Code: "OSA", Name: "Osaka", CountryCode: "JP"}, []string{"KIX", "ITM", "UKB"}},
+		{metroArea{Code: "BJS", Name: "Beijing", CountryCode: "CN"}, []string{"PEK", "PKX"}},
+		{metroArea{Code: "SEL", Name: "Seoul", CountryCode: "KR"}, []string{"ICN", "GMP"}},
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewCreateTable().Model((*metroArea)(nil)).
+				ForeignKey(`("country_code") REFERENCES "countries" ("alpha2")`).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create metro_areas table: %w", err)
+			}
+			for _, stmt := range []string{
+				`ALTER TABLE airports ADD COLUMN metro_code VARCHAR REFERENCES metro_areas (code)`,
+				`CREATE INDEX airports_metro_code_idx ON airports (metro_code)`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to add airport metro code: %w", err)
+				}
+			}
+
+			for _, m := range metroAreas {
+				if _, err := tx.NewInsert().Model(&m.metroArea).Exec(ctx); err != nil {
+					return fmt.Errorf("failed to insert metro area %s: %w", m.Code, err)
+				}
+				if _, err := tx.NewUpdate().Table("airports").
+					Set("metro_code = ?", m.Code).
+					Where("iata IN (?)", bun.In(m.airports)).
+					Exec(ctx); err != nil {
+					return fmt.Errorf("failed to link airports of metro area %s: %w", m.Code, err)
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				`DROP INDEX airports_metro_code_idx`,
+				`ALTER TABLE airports DROP COLUMN metro_code`,
+				`DROP TABLE metro_areas`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to revert metro areas: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// Gives the aircraft range an explicit unit and adds performance and capacity
+// figures. Existing ranges were entered in nautical miles.
+func init() {
+	columns := []string{
+		"cruise_speed_kts",
+		"cruise_altitude_ft",
+		"mtow_kg",
+		"mlw_kg",
+		"oew_kg",
+		"fuel_capacity_kg",
+		"seats",
+		"cargo_capacity_kg",
+		"engines",
+		"etops_minutes",
+		"takeoff_runway_m",
+		"landing_runway_m",
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.ExecContext(ctx, `ALTER TABLE aircrafts RENAME COLUMN "range" TO range_nm`); err != nil {
+				return fmt.Errorf("failed to rename aircraft range: %w", err)
+			}
+			for _, c := range columns {
+				if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE aircrafts ADD COLUMN %s INTEGER", c)); err != nil {
+					return fmt.Errorf("failed to add aircraft column %s: %w", c, err)
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, c := range columns {
+				if _, err := tx.ExecContext(ctx, fmt.Sprintf("ALTER TABLE aircrafts DROP COLUMN %s", c)); err != nil {
+					return fmt.Errorf("failed to drop aircraft column %s: %w", c, err)
+				}
+			}
+			if _, err := tx.ExecContext(ctx, `ALTER TABLE aircrafts RENAME COLUMN range_nm TO "range"`); err != nil {
+				return fmt.Errorf("failed to rename aircraft range: %w", err)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// Adds the corner points of the payload-range diagrams of aircraft.
+func init() {
+	type payloadRangePoint struct {
+		bun.BaseModel `bun:"table:payload_range_points"`
+
+		Id         int64   `bun:",pk,autoincrement"`
+		AircraftId int     `bun:",notnull"`
+		RangeNm    float64 `bun:",notnull"`
+		PayloadKg  float64 `bun:",notnull"`
+		Label      string
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewCreateTable().Model((*payloadRangePoint)(nil)).
+				ForeignKey(`("aircraft_id") REFERENCES "aircrafts" ("id") ON DELETE CASCADE`).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create payload_range_points table: %w", err)
+			}
+			if _, err := tx.NewCreateIndex().Model((*payloadRangePoint)(nil)).
+				Index("payload_range_points_aircraft_id_idx").
+				Column("aircraft_id").
+				Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create payload_range_points index: %w", err)
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropTable().Model((*payloadRangePoint)(nil)).IfExists().Exec(ctx); err != nil {
+			return fmt.Errorf("failed to drop payload_range_points table: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// Adds the registry of individual airframes and lets flights reference the
+// airframe operating them.
+func init() {
+	type airframe struct {
+		bun.BaseModel `bun:"table:airframes"`
+
+		Id           int    `bun:",pk,autoincrement"`
+		Registration string `bun:",notnull,unique"`
+		AircraftId   int    `bun:",notnull"`
+		Operator     string
+		SerialNumber string
+		HomeBase     string `bun:",nullzero"`
+		Status       string `bun:",notnull"`
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewCreateTable().Model((*airframe)(nil)).
+				ForeignKey(`("aircraft_id") REFERENCES "aircrafts" ("id")`).
+				ForeignKey(`("home_base") REFERENCES "airports" ("iata")`).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create airframes table: %w", err)
+			}
+			for _, stmt := range []string{
+				`CREATE INDEX airframes_aircraft_id_idx ON airframes (aircraft_id)`,
+				`ALTER TABLE flights ADD COLUMN airframe_id INTEGER REFERENCES airframes (id)`,
+				`CREATE INDEX flights_airframe_id_idx ON flights (airframe_id)`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to migrate airframes: %w", err)
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				`DROP INDEX flights_airframe_id_idx`,
+				`ALTER TABLE flights DROP COLUMN airframe_id`,
+				`DROP TABLE airframes`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to revert airframes: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// Moves the aircraft manufacturers from code into a catalog table. Unknown
+// manufacturers already used by aircraft are added to it as well.
+func init() {
+	type manufacturer struct {
+		bun.BaseModel `bun:"table:manufacturers"`
+
+		Name        string `bun:",pk"`
+		CountryCode string `bun:",nullzero"`
+	}
+
+	manufacturers := []manufacturer{
+		{Name: "Airbus", CountryCode: "FR"},
+		{Name: "Antonov", CountryCode: "UA"},
+		{Name: "Boeing", CountryCode: "US"},
+		{Name: "Bombardier", CountryCode: "CA"},
+		{Name: "Gulfstream", CountryCode: "US"},
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewCreateTable().Model((*manufacturer)(nil)).
+				ForeignKey(`("country_code") REFERENCES "countries" ("alpha2")`).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create manufacturers table: %w", err)
+			}
+			if _, err := tx.NewInsert().Model(&manufacturers).Exec(ctx); err != nil {
+				return fmt.Errorf("failed to insert manufacturers: %w", err)
+			}
+
+			var used []string
+			if err := tx.NewSelect().Table("aircrafts").
+				Distinct().
+				Column("manufacturer").
+				Where("manufacturer NOT IN (SELECT name FROM manufacturers)").
+				Where("manufacturer != ''").
+				Scan(ctx, &used); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to read aircraft manufacturers: %w", err)
+			}
+			for _, name := range used {
+				if _, err := tx.NewInsert().Model(&manufacturer{Name: name}).Exec(ctx); err != nil {
+					return fmt.Errorf("failed to insert manufacturer %s: %w", name, err)
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.NewDropTable().Model((*manufacturer)(nil)).IfExists().Exec(ctx); err != nil {
+			return fmt.Errorf("failed to drop manufacturers table: %w", err)
+		}
+		return nil
+	})
+}
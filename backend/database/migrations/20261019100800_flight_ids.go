@@ -0,0 +1,88 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Gives flights a surrogate primary key, since a flight number is operated on
+// many days, and indexes the columns flights are filtered by.
+func init() {
+	const flightColumns = "flight_number, aircraft_id, airframe_id, origin, destination, departure_time, arrival_time"
+
+	indexes := []string{
+		`CREATE INDEX flights_origin_idx ON flights (origin)`,
+		`CREATE INDEX flights_destination_idx ON flights (destination)`,
+		`CREATE INDEX flights_departure_time_idx ON flights (departure_time)`,
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			stmts := []string{`ALTER TABLE flights ADD COLUMN id BIGSERIAL PRIMARY KEY`}
+			// SQLite cannot add a primary key to an existing table, so the
+			// table is rebuilt instead.
+			if db.Dialect().Name() == dialect.SQLite {
+				stmts = []string{
+					`CREATE TABLE flights_new (
+						id INTEGER PRIMARY KEY AUTOINCREMENT,
+						flight_number VARCHAR,
+						aircraft_id BIGINT,
+						airframe_id INTEGER REFERENCES airframes (id),
+						origin VARCHAR,
+						destination VARCHAR,
+						departure_time VARCHAR,
+						arrival_time VARCHAR
+					)`,
+					`INSERT INTO flights_new (` + flightColumns + `) SELECT ` + flightColumns + ` FROM flights ORDER BY departure_time`,
+					`DROP TABLE flights`,
+					`ALTER TABLE flights_new RENAME TO flights`,
+					`CREATE INDEX flights_airframe_id_idx ON flights (airframe_id)`,
+				}
+			}
+
+			for _, stmt := range append(stmts, indexes...) {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to add flight ids: %w", err)
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			stmts := []string{
+				`DROP INDEX flights_origin_idx`,
+				`DROP INDEX flights_destination_idx`,
+				`DROP INDEX flights_departure_time_idx`,
+			}
+			if db.Dialect().Name() == dialect.SQLite {
+				stmts = append(stmts,
+					`CREATE TABLE flights_old (
+						flight_number VARCHAR,
+						aircraft_id BIGINT,
+						airframe_id INTEGER REFERENCES airframes (id),
+						origin VARCHAR,
+						destination VARCHAR,
+						departure_time VARCHAR,
+						arrival_time VARCHAR
+					)`,
+					`INSERT INTO flights_old SELECT `+flightColumns+` FROM flights`,
+					`DROP TABLE flights`,
+					`ALTER TABLE flights_old RENAME TO flights`,
+					`CREATE INDEX flights_airframe_id_idx ON flights (airframe_id)`,
+				)
+			} else {
+				stmts = append(stmts, `ALTER TABLE flights DROP COLUMN id`)
+			}
+
+			for _, stmt := range stmts {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to remove flight ids: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
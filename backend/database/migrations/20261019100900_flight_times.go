@@ -0,0 +1,113 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Stores departure and arrival times as timestamps instead of free-form
+// strings and guards the schedule with check constraints.
+func init() {
+	const (
+		createSQLite = `CREATE TABLE flights_new (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			flight_number VARCHAR NOT NULL,
+			aircraft_id BIGINT NOT NULL REFERENCES aircrafts (id),
+			airframe_id INTEGER REFERENCES airframes (id),
+			origin VARCHAR NOT NULL REFERENCES airports (iata),
+			destination VARCHAR NOT NULL REFERENCES airports (iata),
+			departure_time %[1]s NOT NULL,
+			arrival_time %[1]s NOT NULL,
+			CONSTRAINT flights_airports_check CHECK (origin <> destination),
+			CONSTRAINT flights_times_check CHECK (arrival_time > departure_time)
+		)`
+		// bun writes timestamps to SQLite in this layout, in UTC.
+		sqliteTime = `strftime('%%Y-%%m-%%d %%H:%%M:%%S', %s) || '+00:00'`
+	)
+
+	indexes := []string{
+		`CREATE INDEX flights_airframe_id_idx ON flights (airframe_id)`,
+		`CREATE INDEX flights_origin_idx ON flights (origin)`,
+		`CREATE INDEX flights_destination_idx ON flights (destination)`,
+		`CREATE INDEX flights_departure_time_idx ON flights (departure_time)`,
+	}
+
+	// rebuildSQLite replaces the flights table, SQLite can neither change
+	// column types nor add constraints to an existing table.
+	rebuildSQLite := func(ctx context.Context, tx bun.Tx, timeType, departure, arrival string) error {
+		stmts := []string{
+			fmt.Sprintf(createSQLite, timeType),
+			`INSERT INTO flights_new (id, flight_number, aircraft_id, airframe_id, origin, destination, departure_time, arrival_time)
+				SELECT id, flight_number, aircraft_id, airframe_id, origin, destination, ` + departure + `, ` + arrival + ` FROM flights`,
+			`DROP TABLE flights`,
+			`ALTER TABLE flights_new RENAME TO flights`,
+		}
+		for _, stmt := range append(stmts, indexes...) {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if db.Dialect().Name() != dialect.SQLite {
+				for _, stmt := range []string{
+					`ALTER TABLE flights ALTER COLUMN departure_time TYPE TIMESTAMPTZ USING departure_time::timestamptz`,
+					`ALTER TABLE flights ALTER COLUMN arrival_time TYPE TIMESTAMPTZ USING arrival_time::timestamptz`,
+					`ALTER TABLE flights ADD CONSTRAINT flights_airports_check CHECK (origin <> destination)`,
+					`ALTER TABLE flights ADD CONSTRAINT flights_times_check CHECK (arrival_time > departure_time)`,
+				} {
+					if _, err := tx.ExecContext(ctx, stmt); err != nil {
+						return fmt.Errorf("failed to migrate flight times: %w", err)
+					}
+				}
+				return nil
+			}
+
+			// Refuse to guess: rows SQLite cannot parse have to be fixed by hand.
+			var invalid int
+			if err := tx.QueryRowContext(ctx, `SELECT count(*) FROM flights
+				WHERE strftime('%s', departure_time) IS NULL OR strftime('%s', arrival_time) IS NULL`).Scan(&invalid); err != nil {
+				return fmt.Errorf("failed to check flight times: %w", err)
+			}
+			if invalid > 0 {
+				return fmt.Errorf("failed to migrate flight times: %d flights have unparsable times", invalid)
+			}
+
+			departure := fmt.Sprintf(sqliteTime, "departure_time")
+			arrival := fmt.Sprintf(sqliteTime, "arrival_time")
+			if err := rebuildSQLite(ctx, tx, "TIMESTAMP", departure, arrival); err != nil {
+				return fmt.Errorf("failed to migrate flight times: %w", err)
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if db.Dialect().Name() != dialect.SQLite {
+				for _, stmt := range []string{
+					`ALTER TABLE flights DROP CONSTRAINT flights_times_check`,
+					`ALTER TABLE flights DROP CONSTRAINT flights_airports_check`,
+					`ALTER TABLE flights ALTER COLUMN departure_time TYPE VARCHAR USING to_char(departure_time AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')`,
+					`ALTER TABLE flights ALTER COLUMN arrival_time TYPE VARCHAR USING to_char(arrival_time AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')`,
+				} {
+					if _, err := tx.ExecContext(ctx, stmt); err != nil {
+						return fmt.Errorf("failed to revert flight times: %w", err)
+					}
+				}
+				return nil
+			}
+
+			departure := `strftime('%Y-%m-%dT%H:%M:%SZ', departure_time)`
+			arrival := `strftime('%Y-%m-%dT%H:%M:%SZ', arrival_time)`
+			if err := rebuildSQLite(ctx, tx, "VARCHAR", departure, arrival); err != nil {
+				return fmt.Errorf("failed to revert flight times: %w", err)
+			}
+			return nil
+		})
+	})
+}
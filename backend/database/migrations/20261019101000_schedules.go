@@ -0,0 +1,62 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// Adds recurring schedules and lets flights reference the schedule they were
+// materialized from.
+func init() {
+	type schedule struct {
+		bun.BaseModel `bun:"table:schedules"`
+
+		Id             int    `bun:",pk,autoincrement"`
+		FlightNumber   string `bun:",notnull"`
+		AircraftId     int    `bun:",notnull"`
+		Origin         string `bun:",notnull"`
+		Destination    string `bun:",notnull"`
+		DaysOfWeek     string `bun:",notnull"`
+		DepartureLocal string `bun:",notnull"`
+		BlockMinutes   int    `bun:",notnull"`
+		EffectiveFrom  string `bun:",notnull"`
+		EffectiveTo    string `bun:",notnull"`
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.NewCreateTable().Model((*schedule)(nil)).
+				ForeignKey(`("aircraft_id") REFERENCES "aircrafts" ("id")`).
+				ForeignKey(`("origin") REFERENCES "airports" ("iata")`).
+				ForeignKey(`("destination") REFERENCES "airports" ("iata")`).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create schedules table: %w", err)
+			}
+			for _, stmt := range []string{
+				`CREATE INDEX schedules_flight_number_idx ON schedules (flight_number)`,
+				`ALTER TABLE flights ADD COLUMN schedule_id INTEGER REFERENCES schedules (id)`,
+				`CREATE INDEX flights_schedule_id_idx ON flights (schedule_id)`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to migrate schedules: %w", err)
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				`DROP INDEX flights_schedule_id_idx`,
+				`ALTER TABLE flights DROP COLUMN schedule_id`,
+				`DROP TABLE schedules`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to revert schedules: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
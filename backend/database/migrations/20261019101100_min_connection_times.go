@@ -0,0 +1,51 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// minConnectionTimes are the published minimum connection times of some hubs,
+// in minutes.
+var minConnectionTimes = map[string]int{
+	"JFK": 75,
+	"LAX": 90,
+	"CDG": 60,
+	"FRA": 45,
+	"LHR": 60,
+	"PVG": 80,
+}
+
+// SetMinConnectionTimes sets the published minimum connection times of the
+// stored airports. The migration sets them on the airports present when it
+// runs, databases filled afterwards call it once their airports are stored.
+func SetMinConnectionTimes(ctx context.Context, db bun.IDB) error {
+	for iata, minutes := range minConnectionTimes {
+		if _, err := db.NewUpdate().Table("airports").
+			Set("min_connection_minutes = ?", minutes).
+			Where("iata = ?", iata).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("failed to set minimum connection time of %s: %w", iata, err)
+		}
+	}
+	return nil
+}
+
+// Adds the minimum connection time of airports used by the connection search.
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.ExecContext(ctx, `ALTER TABLE airports ADD COLUMN min_connection_minutes INTEGER`); err != nil {
+				return fmt.Errorf("failed to add minimum connection times: %w", err)
+			}
+			return SetMinConnectionTimes(ctx, tx)
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		if _, err := db.ExecContext(ctx, `ALTER TABLE airports DROP COLUMN min_connection_minutes`); err != nil {
+			return fmt.Errorf("failed to remove minimum connection times: %w", err)
+		}
+		return nil
+	})
+}
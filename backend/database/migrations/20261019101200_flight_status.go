@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Adds the operational status of flights and the history of its changes.
+func init() {
+	type flightEvent struct {
+		bun.BaseModel `bun:"table:flight_events"`
+
+		Id                     int64     `bun:",pk,autoincrement"`
+		FlightId               int64     `bun:",notnull"`
+		Status                 string    `bun:",notnull"`
+		PreviousStatus         string    `bun:",notnull"`
+		Time                   time.Time `bun:",notnull"`
+		EstimatedDepartureTime time.Time `bun:",nullzero"`
+		DivertedTo             string    `bun:",nullzero"`
+		Remark                 string    `bun:",nullzero"`
+		RecordedAt             time.Time `bun:",notnull"`
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		timestamp := "TIMESTAMPTZ"
+		if db.Dialect().Name() == dialect.SQLite {
+			timestamp = "TIMESTAMP"
+		}
+
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				`ALTER TABLE flights ADD COLUMN status VARCHAR NOT NULL DEFAULT 'scheduled'`,
+				`ALTER TABLE flights ADD COLUMN estimated_departure_time ` + timestamp,
+				`ALTER TABLE flights ADD COLUMN actual_departure_time ` + timestamp,
+				`ALTER TABLE flights ADD COLUMN actual_arrival_time ` + timestamp,
+				`ALTER TABLE flights ADD COLUMN diverted_to VARCHAR REFERENCES airports (iata)`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to add flight status: %w", err)
+				}
+			}
+
+			if _, err := tx.NewCreateTable().Model((*flightEvent)(nil)).
+				ForeignKey(`("flight_id") REFERENCES "flights" ("id") ON DELETE CASCADE`).
+				ForeignKey(`("diverted_to") REFERENCES "airports" ("iata")`).
+				Exec(ctx); err != nil {
+				return fmt.Errorf("failed to create flight events table: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, `CREATE INDEX flight_events_flight_id_idx ON flight_events (flight_id)`); err != nil {
+				return fmt.Errorf("failed to index flight events: %w", err)
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				`DROP TABLE flight_events`,
+				`ALTER TABLE flights DROP COLUMN diverted_to`,
+				`ALTER TABLE flights DROP COLUMN actual_arrival_time`,
+				`ALTER TABLE flights DROP COLUMN actual_departure_time`,
+				`ALTER TABLE flights DROP COLUMN estimated_departure_time`,
+				`ALTER TABLE flights DROP COLUMN status`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to revert flight status: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
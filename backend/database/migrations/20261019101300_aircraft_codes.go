@@ -0,0 +1,48 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// Adds the IATA aircraft type code SSIM schedule files identify aircraft by.
+func init() {
+	// aircraftCodes seeds the codes of known aircraft by name. Only aircraft
+	// already stored are updated.
+	aircraftCodes := map[string]string{
+		"Boeing 737":  "737",
+		"Airbus A320": "320",
+	}
+
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.ExecContext(ctx, `ALTER TABLE aircrafts ADD COLUMN iata_code VARCHAR`); err != nil {
+				return fmt.Errorf("failed to add aircraft IATA codes: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, `CREATE UNIQUE INDEX aircrafts_iata_code_idx ON aircrafts (iata_code)`); err != nil {
+				return fmt.Errorf("failed to index aircraft IATA codes: %w", err)
+			}
+			for name, code := range aircraftCodes {
+				if _, err := tx.NewUpdate().Table("aircrafts").
+					Set("iata_code = ?", code).
+					Where("name = ?", name).
+					Exec(ctx); err != nil {
+					return fmt.Errorf("failed to set IATA code of %s: %w", name, err)
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.ExecContext(ctx, `DROP INDEX aircrafts_iata_code_idx`); err != nil {
+				return fmt.Errorf("failed to drop aircraft IATA code index: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, `ALTER TABLE aircrafts DROP COLUMN iata_code`); err != nil {
+				return fmt.Errorf("failed to remove aircraft IATA codes: %w", err)
+			}
+			return nil
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+)
+
+// SetAirportPlaceNames copies the names of the country and the continent of
+// every stored airport from the reference tables, so that airports can be
+// read without joining them. Databases filled after the migration ran call it
+// once their airports are stored.
+func SetAirportPlaceNames(ctx context.Context, db bun.IDB) error {
+	for _, stmt := range []string{
+		`UPDATE airports SET country = (SELECT name FROM countries WHERE alpha2 = airports.country_code)`,
+		`UPDATE airports SET continent = (SELECT name FROM continents WHERE code = airports.continent_code)`,
+	} {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to set airport place names: %w", err)
+		}
+	}
+	return nil
+}
+
+// Restores the country and continent names of airports next to their codes.
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				`ALTER TABLE airports ADD COLUMN country VARCHAR`,
+				`ALTER TABLE airports ADD COLUMN continent VARCHAR`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to add airport place names: %w", err)
+				}
+			}
+			return SetAirportPlaceNames(ctx, tx)
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				`ALTER TABLE airports DROP COLUMN country`,
+				`ALTER TABLE airports DROP COLUMN continent`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to remove airport place names: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
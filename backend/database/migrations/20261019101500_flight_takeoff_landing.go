@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// Adds the actual takeoff and landing times of flights, recorded by the
+// airborne and landed events.
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		timestamp := "TIMESTAMPTZ"
+		if db.Dialect().Name() == dialect.SQLite {
+			timestamp = "TIMESTAMP"
+		}
+
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				`ALTER TABLE flights ADD COLUMN actual_takeoff_time ` + timestamp,
+				`ALTER TABLE flights ADD COLUMN actual_landing_time ` + timestamp,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to add flight takeoff and landing times: %w", err)
+				}
+			}
+			return nil
+		})
+	}, func(ctx context.Context, db *bun.DB) error {
+		return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			for _, stmt := range []string{
+				`ALTER TABLE flights DROP COLUMN actual_landing_time`,
+				`ALTER TABLE flights DROP COLUMN actual_takeoff_time`,
+			} {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return fmt.Errorf("failed to revert flight takeoff and landing times: %w", err)
+				}
+			}
+			return nil
+		})
+	})
+}
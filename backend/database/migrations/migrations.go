@@ -0,0 +1,12 @@
+// Package migrations holds the schema migrations applied to the database at
+// startup. Every file named <timestamp>_<name>.go registers one migration.
+//
+// Migrations must not use the structs of the models package: those always
+// describe the latest schema. Each migration declares the table layout it
+// works with instead.
+package migrations
+
+import "github.com/uptrace/bun/migrate"
+
+// Migrations lists all schema migrations in the order they are applied.
+var Migrations = migrate.NewMigrations()
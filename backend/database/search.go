@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const (
+	// AirportSearchTable is the name of the SQLite FTS5 table indexing airports.
+	AirportSearchTable = "airport_search"
+	// SearchUnaccent is the name of the PostgreSQL function removing accents
+	// from the searchable text columns. unaccent itself is only STABLE and
+	// cannot be used in an index.
+	SearchUnaccent = "search_unaccent"
+)
+
+// createAirportSearch prepares the dialect specific full-text search support
+// used by the airport autocomplete.
+//
+// On SQLite an external content FTS5 table is kept in sync with the airports
+// table by triggers. FTS5 is only compiled into go-sqlite3 with the
+// sqlite_fts5 build tag, so a missing module is not an error: the search
+// service falls back to ranking a bounded scan of the table instead.
+//
+// On PostgreSQL the pg_trgm and unaccent extensions are enabled together with
+// trigram indexes on the searchable text columns. The indexed expressions are
+// the ones the search compares with, SearchUnaccent(lower(column)).
+func createAirportSearch(ctx context.Context, db *bun.DB) error {
+	var stmts []string
+	switch db.Dialect().Name() {
+	case dialect.SQLite:
+		if _, err := db.ExecContext(ctx, `CREATE VIRTUAL TABLE IF NOT EXISTS `+AirportSearchTable+
+			` USING fts5(iata, icao, name, city, content='airports', tokenize='unicode61 remove_diacritics 2')`); err != nil {
+			if strings.Contains(err.Error(), "no such module") {
+				return nil
+			}
+			return fmt.Errorf("failed to create airport search table: %w", err)
+		}
+		stmts = []string{
+			`CREATE TRIGGER IF NOT EXISTS airports_search_ai AFTER INSERT ON airports BEGIN
+				INSERT INTO airport_search(rowid, iata, icao, name, city) VALUES (new.rowid, new.iata, new.icao, new.name, new.city);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS airports_search_ad AFTER DELETE ON airports BEGIN
+				INSERT INTO airport_search(airport_search, rowid, iata, icao, name, city) VALUES ('delete', old.rowid, old.iata, old.icao, old.name, old.city);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS airports_search_au AFTER UPDATE ON airports BEGIN
+				INSERT INTO airport_search(airport_search, rowid, iata, icao, name, city) VALUES ('delete', old.rowid, old.iata, old.icao, old.name, old.city);
+				INSERT INTO airport_search(rowid, iata, icao, name, city) VALUES (new.rowid, new.iata, new.icao, new.name, new.city);
+			END`,
+			`INSERT INTO airport_search(airport_search) VALUES ('rebuild')`,
+		}
+	case dialect.PG:
+		stmts = []string{
+			`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+			`CREATE EXTENSION IF NOT EXISTS unaccent`,
+			// The dictionary is named so that the result does not depend on
+			// the search path, which is what makes the function IMMUTABLE.
+			`CREATE OR REPLACE FUNCTION ` + SearchUnaccent + `(text) RETURNS text
+				LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+				AS $$ SELECT public.unaccent('public.unaccent'::regdictionary, $1) $$`,
+			`DROP INDEX IF EXISTS airports_name_trgm_idx`,
+			`DROP INDEX IF EXISTS airports_city_trgm_idx`,
+			`CREATE INDEX IF NOT EXISTS airports_name_unaccent_trgm_idx ON airports USING gin (` + SearchUnaccent + `(lower(name)) gin_trgm_ops)`,
+			`CREATE INDEX IF NOT EXISTS airports_city_unaccent_trgm_idx ON airports USING gin (` + SearchUnaccent + `(lower(city)) gin_trgm_ops)`,
+		}
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to set up airport search: %w", err)
+		}
+	}
+
+	return nil
+}
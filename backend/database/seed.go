@@ -0,0 +1,98 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/database/migrations"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+// seedLocalDB fills the local development database with sample data.
+func seedLocalDB(ctx context.Context, db *bun.DB) error {
+	airports := []models.Airport{
+		{IATA: "JFK", ICAO: "KJFK", Name: "John F. Kennedy International Airport", City: "New York", CountryCode: "US", ContinentCode: "NA", MetroCode: "NYC", Latitude: 40.6413, Longitude: -73.7781, TimeZone: "America/New_York"},
+		{IATA: "LAX", ICAO: "KLAX", Name: "Los Angeles International Airport", City: "Los Angeles", CountryCode: "US", ContinentCode: "NA", Latitude: 33.9416, Longitude: -118.4085, TimeZone: "America/Los_Angeles"},
+		{IATA: "CDG", ICAO: "LFPG", Name: "Charles de Gaulle Airport", City: "Paris", CountryCode: "FR", ContinentCode: "EU", MetroCode: "PAR", Latitude: 49.0097, Longitude: 2.5479, TimeZone: "Europe/Paris"},
+		{IATA: "FRA", ICAO: "EDDF", Name: "Frankfurt Airport", City: "Frankfurt", CountryCode: "DE", ContinentCode: "EU", Latitude: 50.0333, Longitude: 8.5706, TimeZone: "Europe/Berlin"},
+		{IATA: "PVG", ICAO: "ZSPD", Name: "Shanghai Pudong International Airport", City: "Shanghai", CountryCode: "CN", ContinentCode: "AS", Latitude: 31.1434, Longitude: 121.805, TimeZone: "Asia/Shanghai"},
+		{IATA: "NGO", ICAO: "RJGG", Name: "Chubu Centrair International Airport", City: "Nagoya", CountryCode: "JP", ContinentCode: "AS", Latitude: 34.8583, Longitude: 136.805, TimeZone: "Asia/Tokyo"},
+		{IATA: "AKL", ICAO: "NZAA", Name: "Auckland Airport", City: "Auckland", CountryCode: "NZ", ContinentCode: "OC", Latitude: -37.0081, Longitude: 174.792, TimeZone: "Pacific/Auckland"},
+		{IATA: "ADD", ICAO: "HAAB", Name: "Addis Ababa Bole International Airport", City: "Addis Ababa", CountryCode: "ET", ContinentCode: "AF", Latitude: 8.97789, Longitude: 38.799301, TimeZone: "Africa/Addis_Ababa"},
+		{IATA: "ZRH", ICAO: "LSZH", Name: "Zürich Airport", City: "Zürich", CountryCode: "CH", ContinentCode: "EU", Latitude: 47.4581, Longitude: 8.5555, TimeZone: "Europe/Zurich"},
+	}
+	aircrafts := []models.Aircraft{
+		{Id: 1, Type: models.Commercial, Name: "Boeing 737", Manufacturer: "Boeing", IATACode: "737", RangeNm: 3510, CruiseSpeedKts: 453, CruiseAltitudeFt: 35000, MTOWKg: 79016, MLWKg: 66361, OEWKg: 41413, FuelCapacityKg: 20894, Seats: 189, CargoCapacityKg: 3900, Engines: 2, ETOPSMinutes: 180, TakeoffRunwayM: 2300, LandingRunwayM: 1600},
+		{Id: 2, Type: models.Heavy, Name: "Gulfstream G650", Manufacturer: "Gulfstream", RangeNm: 7500, CruiseSpeedKts: 488, CruiseAltitudeFt: 45000, MTOWKg: 45178, MLWKg: 38028, OEWKg: 24494, FuelCapacityKg: 20094, Seats: 18, Engines: 2, TakeoffRunwayM: 1786, LandingRunwayM: 920},
+		{Id: 3, Type: models.Cargo, Name: "Antonov An-225", Manufacturer: "Antonov", RangeNm: 9700, CruiseSpeedKts: 432, CruiseAltitudeFt: 33000, MTOWKg: 640000, MLWKg: 591700, OEWKg: 285000, FuelCapacityKg: 300000, CargoCapacityKg: 250000, Engines: 6, TakeoffRunwayM: 3500, LandingRunwayM: 2500},
+		{Id: 4, Type: models.Commercial, Name: "Airbus A320", Manufacturer: "Airbus", IATACode: "320", RangeNm: 3200, CruiseSpeedKts: 447, CruiseAltitudeFt: 35000, MTOWKg: 78000, MLWKg: 66000, OEWKg: 42600, FuelCapacityKg: 18730, Seats: 180, CargoCapacityKg: 3700, Engines: 2, ETOPSMinutes: 180, TakeoffRunwayM: 2100, LandingRunwayM: 1500},
+	}
+	runways := []models.Runway{
+		{AirportIATA: "JFK", Designator: "04L/22R", LengthM: 3682, WidthM: 61, Surface: "asphalt"},
+		{AirportIATA: "JFK", Designator: "04R/22L", LengthM: 2560, WidthM: 61, Surface: "asphalt"},
+		{AirportIATA: "JFK", Designator: "13L/31R", LengthM: 3048, WidthM: 61, Surface: "asphalt"},
+		{AirportIATA: "JFK", Designator: "13R/31L", LengthM: 4423, WidthM: 61, Surface: "concrete"},
+		{AirportIATA: "LAX", Designator: "06L/24R", LengthM: 2721, WidthM: 46, Surface: "concrete"},
+		{AirportIATA: "LAX", Designator: "06R/24L", LengthM: 3135, WidthM: 46, Surface: "concrete"},
+		{AirportIATA: "LAX", Designator: "07L/25R", LengthM: 3685, WidthM: 46, Surface: "concrete"},
+		{AirportIATA: "LAX", Designator: "07R/25L", LengthM: 3382, WidthM: 61, Surface: "concrete"},
+		{AirportIATA: "CDG", Designator: "08L/26R", LengthM: 4215, WidthM: 45, Surface: "asphalt"},
+		{AirportIATA: "CDG", Designator: "08R/26L", LengthM: 2700, WidthM: 60, Surface: "asphalt"},
+		{AirportIATA: "CDG", Designator: "09L/27R", LengthM: 2700, WidthM: 60, Surface: "asphalt"},
+		{AirportIATA: "CDG", Designator: "09R/27L", LengthM: 4200, WidthM: 45, Surface: "asphalt"},
+		{AirportIATA: "FRA", Designator: "07C/25C", LengthM: 4000, WidthM: 60, Surface: "asphalt"},
+		{AirportIATA: "FRA", Designator: "07L/25R", LengthM: 2800, WidthM: 45, Surface: "asphalt"},
+		{AirportIATA: "FRA", Designator: "07R/25L", LengthM: 4000, WidthM: 45, Surface: "asphalt"},
+		{AirportIATA: "FRA", Designator: "18", LengthM: 4000, WidthM: 45, Surface: "asphalt"},
+		{AirportIATA: "PVG", Designator: "16L/34R", LengthM: 3800, WidthM: 60, Surface: "asphalt"},
+		{AirportIATA: "PVG", Designator: "17L/35R", LengthM: 4000, WidthM: 60, Surface: "asphalt"},
+		{AirportIATA: "NGO", Designator: "18/36", LengthM: 3500, WidthM: 60, Surface: "asphalt"},
+		{AirportIATA: "AKL", Designator: "05R/23L", LengthM: 3635, WidthM: 45, Surface: "asphalt"},
+		{AirportIATA: "ADD", Designator: "07L/25R", LengthM: 3700, WidthM: 45, Surface: "asphalt"},
+		{AirportIATA: "ADD", Designator: "07R/25L", LengthM: 3800, WidthM: 45, Surface: "asphalt"},
+		{AirportIATA: "ZRH", Designator: "10/28", LengthM: 2500, WidthM: 60, Surface: "asphalt"},
+		{AirportIATA: "ZRH", Designator: "14/32", LengthM: 3300, WidthM: 60, Surface: "asphalt"},
+		{AirportIATA: "ZRH", Designator: "16/34", LengthM: 3700, WidthM: 60, Surface: "asphalt"},
+	}
+	payloadRange := []models.PayloadRangePoint{
+		{AircraftId: 1, RangeNm: 2900, PayloadKg: 3900, Label: "mtow"},
+		{AircraftId: 1, RangeNm: 3400, PayloadKg: 2500, Label: "fuel"},
+		{AircraftId: 1, RangeNm: 3990, PayloadKg: 0, Label: "ferry"},
+		{AircraftId: 2, RangeNm: 6500, PayloadKg: 2948, Label: "mtow"},
+		{AircraftId: 2, RangeNm: 7500, PayloadKg: 800, Label: "fuel"},
+		{AircraftId: 2, RangeNm: 7900, PayloadKg: 0, Label: "ferry"},
+		{AircraftId: 3, RangeNm: 1350, PayloadKg: 250000, Label: "mtow"},
+		{AircraftId: 3, RangeNm: 2200, PayloadKg: 200000, Label: "fuel"},
+		{AircraftId: 3, RangeNm: 9700, PayloadKg: 0, Label: "ferry"},
+		{AircraftId: 4, RangeNm: 2700, PayloadKg: 3700, Label: "mtow"},
+		{AircraftId: 4, RangeNm: 3200, PayloadKg: 2400, Label: "fuel"},
+		{AircraftId: 4, RangeNm: 3800, PayloadKg: 0, Label: "ferry"},
+	}
+	airframes := []models.Airframe{
+		{Id: 1, Registration: "N921AN", AircraftId: 1, Operator: "American Airlines", SerialNumber: "29503", HomeBase: "JFK", Status: models.AirframeActive},
+		{Id: 2, Registration: "N650GD", AircraftId: 2, Operator: "Gulfstream Aerospace", SerialNumber: "6001", HomeBase: "LAX", Status: models.AirframeActive},
+		{Id: 3, Registration: "UR-82060", AircraftId: 3, Operator: "Antonov Airlines", SerialNumber: "19530503763", Status: models.AirframeRetired},
+		{Id: 4, Registration: "D-AIZA", AircraftId: 4, Operator: "Lufthansa", SerialNumber: "4565", HomeBase: "FRA", Status: models.AirframeActive},
+	}
+	flights := []models.Flight{
+		{FlightNumber: "AA100", AircraftId: 1, AirframeId: 1, Origin: "JFK", Destination: "LAX", DepartureTime: time.Date(2023, 10, 1, 8, 0, 0, 0, time.UTC), ArrivalTime: time.Date(2023, 10, 1, 11, 0, 0, 0, time.UTC)},
+		{FlightNumber: "AF200", AircraftId: 2, AirframeId: 2, Origin: "CDG", Destination: "JFK", DepartureTime: time.Date(2023, 10, 2, 9, 0, 0, 0, time.UTC), ArrivalTime: time.Date(2023, 10, 2, 12, 0, 0, 0, time.UTC)},
+		{FlightNumber: "DL300", AircraftId: 3, Origin: "LAX", Destination: "CDG", DepartureTime: time.Date(2023, 10, 3, 10, 0, 0, 0, time.UTC), ArrivalTime: time.Date(2023, 10, 3, 18, 0, 0, 0, time.UTC)},
+	}
+
+	for _, rows := range []any{&airports, &runways, &aircrafts, &payloadRange, &airframes, &flights} {
+		if _, err := db.NewInsert().Model(rows).Exec(ctx); err != nil {
+			return err
+		}
+	}
+	// The airports are stored after the migrations ran.
+	if err := migrations.SetMinConnectionTimes(ctx, db); err != nil {
+		return err
+	}
+	if err := migrations.SetAirportPlaceNames(ctx, db); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/mattn/go-sqlite3"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const (
+	// sqliteDriver is the go-sqlite3 driver with the spatial functions
+	// registered on every connection.
+	sqliteDriver = "sqlite3_spatial"
+	// GeometryContains is the name of the SQLite function reporting whether
+	// a GeoJSON geometry contains a point: geometry_contains(geojson, lng, lat).
+	GeometryContains = "geometry_contains"
+)
+
+func init() {
+	sql.Register(sqliteDriver, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc(GeometryContains, geometryContains, true)
+		},
+	})
+}
+
+// geometries keeps the geometry last parsed by geometryContains, since a query
+// passes the same one for every row.
+var geometries struct {
+	sync.Mutex
+	geojson  string
+	geometry *models.Geometry
+}
+
+// geometryContains implements GeometryContains. Invalid geometries contain
+// no point.
+func geometryContains(geojson string, lng, lat float64) bool {
+	geometries.Lock()
+	if geometries.geojson != geojson {
+		geometries.geojson, geometries.geometry = geojson, nil
+		if g, err := models.NewGeometry(strings.NewReader(geojson)); err == nil {
+			geometries.geometry = g
+		}
+	}
+	g := geometries.geometry
+	geometries.Unlock()
+
+	return g != nil && g.Contains(models.PointCoords{Lat: lat, Lng: lng})
+}
+
+// enableSpatial installs PostGIS, which the spatial filters rely on. SQLite
+// uses the functions registered with its driver instead.
+func enableSpatial(ctx context.Context, db *bun.DB) error {
+	if db.Dialect().Name() != dialect.PG {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, "CREATE EXTENSION IF NOT EXISTS postgis"); err != nil {
+		return fmt.Errorf("failed to install PostGIS: %w", err)
+	}
+	return nil
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/models"
 	"github.com/leanderkunstmann/terraroute/backend/services"
 )
 
@@ -13,32 +15,270 @@ var _ Handler = (*AircraftHandler)(nil)
 
 type AircraftHandler struct {
 	service *services.AircraftService
+	auth    Middleware
 }
 
-func NewAircraftHandler(svc *services.AircraftService) *AircraftHandler {
-	return &AircraftHandler{service: svc}
+// NewAircraftHandler creates the aircraft handler. auth protects the write
+// endpoints, a nil auth disables them.
+func NewAircraftHandler(svc *services.AircraftService, auth Middleware) *AircraftHandler {
+	return &AircraftHandler{service: svc, auth: auth}
 }
 
 func (ah *AircraftHandler) Register(r *mux.Router) {
 	r.HandleFunc(fmt.Sprintf("%s/aircraft", basePathV1), ah.GetAircraft).
 		Methods(http.MethodGet, http.MethodOptions).
 		Name("GetAircraft")
+	r.HandleFunc(fmt.Sprintf("%s/aircraft/{id:[0-9]+}", basePathV1), ah.GetAircraftById).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetAircraftById")
+	r.HandleFunc(fmt.Sprintf("%s/aircraft/{id:[0-9]+}/payload-range", basePathV1), ah.GetPayloadRange).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetPayloadRange")
+
+	r.Handle(fmt.Sprintf("%s/aircraft", basePathV1), protect(ah.auth, ah.CreateAircraft)).
+		Methods(http.MethodPost).
+		Name("CreateAircraft")
+	r.Handle(fmt.Sprintf("%s/aircraft/{id:[0-9]+}", basePathV1), protect(ah.auth, ah.ReplaceAircraft)).
+		Methods(http.MethodPut).
+		Name("ReplaceAircraft")
+	r.Handle(fmt.Sprintf("%s/aircraft/{id:[0-9]+}", basePathV1), protect(ah.auth, ah.PatchAircraft)).
+		Methods(http.MethodPatch).
+		Name("PatchAircraft")
+	r.Handle(fmt.Sprintf("%s/aircraft/{id:[0-9]+}", basePathV1), protect(ah.auth, ah.DeleteAircraft)).
+		Methods(http.MethodDelete).
+		Name("DeleteAircraft")
+	r.Handle(fmt.Sprintf("%s/aircraft/{id:[0-9]+}/payload-range", basePathV1), protect(ah.auth, ah.ReplacePayloadRange)).
+		Methods(http.MethodPut).
+		Name("ReplacePayloadRange")
 }
 
 func (ah *AircraftHandler) GetAircraft(w http.ResponseWriter, r *http.Request) {
-	manufacturer := r.URL.Query().Get("manufacturer")
-	aircraftType := r.URL.Query().Get("aircraftType")
+	filter, err := aircraftFilterFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	opts, err := listOptionsFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := ah.service.ListAircraft(r.Context(), filter, opts)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newPageResponse(w, r, res, opts.Fields)
+}
+
+func (ah *AircraftHandler) GetAircraftById(w http.ResponseWriter, r *http.Request) {
+	id, err := aircraftIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
 
-	res, err := ah.service.ListAircraft(r.Context(), manufacturer, aircraftType)
+	res, err := ah.service.FindAircraft(r.Context(), id)
 	if err != nil {
 		newErrorResponse(w, err, http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err = json.NewEncoder(w).Encode(res); err != nil {
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+func (ah *AircraftHandler) CreateAircraft(w http.ResponseWriter, r *http.Request) {
+	aircraft, err := models.NewAircraft(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.CreateAircraft(r.Context(), aircraft); err != nil {
 		newErrorResponse(w, err, http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
+
+	w.Header().Set("Location", fmt.Sprintf("%s/aircraft/%d", basePathV1, aircraft.Id))
+	newJSONResponse(w, aircraft, http.StatusCreated)
+}
+
+// ReplaceAircraft replaces all fields of an aircraft with the request body.
+func (ah *AircraftHandler) ReplaceAircraft(w http.ResponseWriter, r *http.Request) {
+	id, err := aircraftIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	aircraft, err := models.NewAircraft(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.UpdateAircraft(r.Context(), id, aircraft); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, aircraft, http.StatusOK)
+}
+
+// PatchAircraft applies the fields present in the request body to an aircraft
+// and leaves all other fields untouched (JSON merge patch).
+func (ah *AircraftHandler) PatchAircraft(w http.ResponseWriter, r *http.Request) {
+	id, err := aircraftIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	aircraft, err := ah.service.FindAircraft(r.Context(), id)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&aircraft); err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.UpdateAircraft(r.Context(), id, &aircraft); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, aircraft, http.StatusOK)
+}
+
+func (ah *AircraftHandler) DeleteAircraft(w http.ResponseWriter, r *http.Request) {
+	id, err := aircraftIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.DeleteAircraft(r.Context(), id); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetPayloadRange returns the payload-range diagram of an aircraft. With the
+// distance query parameter (nautical miles) it includes the maximum payload
+// over that distance, with payload (kg) the maximum range carrying it. Zero
+// means the distance or payload is beyond the envelope.
+func (ah *AircraftHandler) GetPayloadRange(w http.ResponseWriter, r *http.Request) {
+	id, err := aircraftIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	pr, err := ah.service.PayloadRange(r.Context(), id)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	res := models.PayloadRangeEnvelope{AircraftId: id, Points: pr}
+	for _, p := range []struct {
+		name  string
+		value **float64
+	}{
+		{"distance", &res.DistanceNm},
+		{"payload", &res.PayloadKg},
+	} {
+		v := r.URL.Query().Get(p.name)
+		if v == "" {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 {
+			newErrorResponse(w, fmt.Errorf("invalid %s %q: must be a non-negative number", p.name, v), http.StatusBadRequest)
+			return
+		}
+		*p.value = &f
+	}
+	if len(pr) > 0 && res.DistanceNm != nil {
+		payload, _ := pr.MaxPayloadKg(*res.DistanceNm)
+		res.MaxPayloadKg = &payload
+	}
+	if len(pr) > 0 && res.PayloadKg != nil {
+		rng, _ := pr.MaxRangeNm(*res.PayloadKg)
+		res.MaxRangeNm = &rng
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+// ReplacePayloadRange replaces the payload-range diagram of an aircraft.
+func (ah *AircraftHandler) ReplacePayloadRange(w http.ResponseWriter, r *http.Request) {
+	id, err := aircraftIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	pr, err := models.NewPayloadRange(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.SetPayloadRange(r.Context(), id, pr); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, models.PayloadRangeEnvelope{AircraftId: id, Points: pr}, http.StatusOK)
+}
+
+// aircraftFilterFromRequest reads the manufacturer, aircraftType, minRange
+// (nautical miles), minSeats, engines and iataCode query parameters.
+func aircraftFilterFromRequest(r *http.Request) (services.AircraftFilter, error) {
+	q := r.URL.Query()
+	filter := services.AircraftFilter{
+		Manufacturer: q.Get("manufacturer"),
+		Type:         q.Get("aircraftType"),
+		IATACode:     q.Get("iataCode"),
+	}
+
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{
+		{"minRange", &filter.MinRangeNm},
+		{"minSeats", &filter.MinSeats},
+		{"engines", &filter.Engines},
+	} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return filter, fmt.Errorf("invalid %s %q: must be a positive integer", p.name, v)
+		}
+		*p.value = n
+	}
+
+	return filter, nil
+}
+
+func aircraftIdFromRequest(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("invalid aircraft id %q", mux.Vars(r)["id"])
+	}
+	return id, nil
 }
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/gorilla/mux"
 	"github.com/leanderkunstmann/terraroute/backend/database"
 	"github.com/leanderkunstmann/terraroute/backend/models"
 	"github.com/leanderkunstmann/terraroute/backend/services"
@@ -25,7 +27,7 @@ func TestAircraft(t *testing.T) {
 	}()
 
 	service := services.NewAircraftService(db)
-	handler := NewAircraftHandler(service)
+	handler := NewAircraftHandler(service, nil)
 
 	const path = "/aircrafts"
 
@@ -35,9 +37,9 @@ func TestAircraft(t *testing.T) {
 	handler.GetAircraft(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
-	var aircrafts []models.Aircraft
+	var aircrafts models.Page[models.Aircraft]
 	json.Unmarshal(rr.Body.Bytes(), &aircrafts)
-	assert.Equal(t, 4, len(aircrafts)) // Check if all aircrafts are returned
+	assert.Equal(t, 4, len(aircrafts.Data)) // Check if all aircrafts are returned
 
 	// Test case 2: Filter by manufacturer
 	req, _ = http.NewRequest("GET", fmt.Sprintf("%s?manufacturer=Boeing", path), nil)
@@ -46,8 +48,8 @@ func TestAircraft(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 	json.Unmarshal(rr.Body.Bytes(), &aircrafts)
-	assert.Equal(t, 1, len(aircrafts)) // Check if correct number of aircrafts are returned
-	assert.Equal(t, models.Manufacturer("Boeing"), aircrafts[0].Manufacturer)
+	assert.Equal(t, 1, len(aircrafts.Data)) // Check if correct number of aircrafts are returned
+	assert.Equal(t, models.Manufacturer("Boeing"), aircrafts.Data[0].Manufacturer)
 
 	// Test case 3: Filter by aircraftType
 	req, _ = http.NewRequest("GET", fmt.Sprintf("%s?aircraftType=commercial", path), nil)
@@ -56,9 +58,9 @@ func TestAircraft(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 	json.Unmarshal(rr.Body.Bytes(), &aircrafts)
-	assert.Equal(t, 2, len(aircrafts))
-	assert.Equal(t, models.AircraftType("commercial"), aircrafts[0].Type)
-	assert.Equal(t, models.AircraftType("commercial"), aircrafts[1].Type)
+	assert.Equal(t, 2, len(aircrafts.Data))
+	assert.Equal(t, models.AircraftType("commercial"), aircrafts.Data[0].Type)
+	assert.Equal(t, models.AircraftType("commercial"), aircrafts.Data[1].Type)
 
 	// Test case 4: Filter by aircraftType and manufacturer
 	req, _ = http.NewRequest("GET", fmt.Sprintf("%s?manufacturer=Airbus&aircraftType=commercial", path), nil)
@@ -67,7 +69,215 @@ func TestAircraft(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 	json.Unmarshal(rr.Body.Bytes(), &aircrafts)
-	assert.Equal(t, 1, len(aircrafts))
-	assert.Equal(t, models.Manufacturer("Airbus"), aircrafts[0].Manufacturer)
+	assert.Equal(t, 1, len(aircrafts.Data))
+	assert.Equal(t, models.Manufacturer("Airbus"), aircrafts.Data[0].Manufacturer)
 
+	// Test case 5: Paginate sorted by range
+	req, _ = http.NewRequest("GET", fmt.Sprintf("%s?limit=3&sort=-range", path), nil)
+	rr = httptest.NewRecorder()
+	handler.GetAircraft(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	aircrafts = models.Page[models.Aircraft]{}
+	json.Unmarshal(rr.Body.Bytes(), &aircrafts)
+	assert.Equal(t, 4, aircrafts.Total)
+	assert.Equal(t, 3, len(aircrafts.Data))
+	assert.Equal(t, "Antonov An-225", aircrafts.Data[0].Name)
+	assert.NotEmpty(t, aircrafts.NextCursor)
+
+	req, _ = http.NewRequest("GET", aircrafts.Links.Next, nil)
+	rr = httptest.NewRecorder()
+	handler.GetAircraft(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	aircrafts = models.Page[models.Aircraft]{}
+	json.Unmarshal(rr.Body.Bytes(), &aircrafts)
+	assert.Equal(t, 1, len(aircrafts.Data))
+	assert.Equal(t, "Airbus A320", aircrafts.Data[0].Name)
+	assert.Empty(t, aircrafts.NextCursor)
+
+	// Test case 6: Filter by performance and capacity
+	for query, expected := range map[string][]string{
+		"minRange=7000":            {"Gulfstream G650", "Antonov An-225"},
+		"minSeats=100":             {"Boeing 737", "Airbus A320"},
+		"engines=6":                {"Antonov An-225"},
+		"minRange=3500&minSeats=1": {"Boeing 737", "Gulfstream G650"},
+	} {
+		req, _ = http.NewRequest("GET", fmt.Sprintf("%s?%s", path, query), nil)
+		rr = httptest.NewRecorder()
+		handler.GetAircraft(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code, query)
+		aircrafts = models.Page[models.Aircraft]{}
+		json.Unmarshal(rr.Body.Bytes(), &aircrafts)
+		var names []string
+		for _, a := range aircrafts.Data {
+			names = append(names, a.Name)
+		}
+		assert.ElementsMatch(t, expected, names, query)
+	}
+
+	// Test case 7: Invalid filters
+	for _, query := range []string{"minRange=far", "minSeats=0", "engines=-2"} {
+		req, _ = http.NewRequest("GET", fmt.Sprintf("%s?%s", path, query), nil)
+		rr = httptest.NewRecorder()
+		handler.GetAircraft(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+
+	// Test case 8: Paginate through unknown figures, which are listed last
+	for sort, expected := range map[string][]string{
+		"etops_minutes":         {"Boeing 737", "Airbus A320", "Gulfstream G650", "Antonov An-225"},
+		"-etops_minutes":        {"Boeing 737", "Airbus A320", "Gulfstream G650", "Antonov An-225"},
+		"-cargo_capacity_kg,id": {"Antonov An-225", "Boeing 737", "Airbus A320", "Gulfstream G650"},
+		"seats,-id":             {"Gulfstream G650", "Airbus A320", "Boeing 737", "Antonov An-225"},
+	} {
+		var names []string
+		next := fmt.Sprintf("%s?limit=1&sort=%s", path, sort)
+		for range 5 {
+			req, _ = http.NewRequest("GET", next, nil)
+			rr = httptest.NewRecorder()
+			handler.GetAircraft(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code, sort)
+			aircrafts = models.Page[models.Aircraft]{}
+			json.Unmarshal(rr.Body.Bytes(), &aircrafts)
+			for _, a := range aircrafts.Data {
+				names = append(names, a.Name)
+			}
+			if next = aircrafts.Links.Next; next == "" {
+				break
+			}
+		}
+		assert.Equal(t, expected, names, sort)
+	}
+
+	// Test case 9: The range keeps its name
+	req, _ = http.NewRequest("GET", fmt.Sprintf("%s?fields=name,range&sort=name", path), nil)
+	rr = httptest.NewRecorder()
+	handler.GetAircraft(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"range":3200`)
+}
+
+func TestWriteAircraft(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewAircraftHandler(services.NewAircraftService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Missing token", method: http.MethodPost, path: "/api/v1/aircraft", body: `{"type":"commercial","name":"Airbus A350","manufacturer":"Airbus","range":8700}`, expectedStatus: http.StatusUnauthorized},
+		{name: "Create", method: http.MethodPost, path: "/api/v1/aircraft", token: "secret", body: `{"type":"commercial","name":"Airbus A350","manufacturer":"Airbus","range":8700}`, expectedStatus: http.StatusCreated},
+		{name: "Create duplicate name", method: http.MethodPost, path: "/api/v1/aircraft", token: "secret", body: `{"type":"commercial","name":"Airbus A350","manufacturer":"Airbus","range":8700}`, expectedStatus: http.StatusConflict},
+		{name: "Create unknown type and manufacturer", method: http.MethodPost, path: "/api/v1/aircraft", token: "secret", body: `{"type":"spaceship","name":"Falcon","manufacturer":"SpaceX","range":1}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Create with inconsistent weights", method: http.MethodPost, path: "/api/v1/aircraft", token: "secret", body: `{"type":"commercial","name":"Heavy A350","manufacturer":"Airbus","range":8700,"mtow_kg":100000,"mlw_kg":120000,"seats":-1}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Get", method: http.MethodGet, path: "/api/v1/aircraft/5", expectedStatus: http.StatusOK},
+		{name: "Patch", method: http.MethodPatch, path: "/api/v1/aircraft/5", token: "secret", body: `{"range":8100}`, expectedStatus: http.StatusOK},
+		{name: "Patch to used name", method: http.MethodPatch, path: "/api/v1/aircraft/5", token: "secret", body: `{"name":"Airbus A320"}`, expectedStatus: http.StatusConflict},
+		{name: "Replace", method: http.MethodPut, path: "/api/v1/aircraft/5", token: "secret", body: `{"type":"commercial","name":"Airbus A350-900","manufacturer":"Airbus","range":8100}`, expectedStatus: http.StatusOK},
+		{name: "Replace with other id", method: http.MethodPut, path: "/api/v1/aircraft/5", token: "secret", body: `{"id":4,"type":"commercial","name":"Airbus A350-900","manufacturer":"Airbus","range":8100}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Delete aircraft with flights", method: http.MethodDelete, path: "/api/v1/aircraft/1", token: "secret", expectedStatus: http.StatusConflict},
+		{name: "Delete", method: http.MethodDelete, path: "/api/v1/aircraft/5", token: "secret", expectedStatus: http.StatusNoContent},
+		{name: "Get deleted", method: http.MethodGet, path: "/api/v1/aircraft/5", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+		})
+	}
+}
+
+func TestPayloadRange(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewAircraftHandler(services.NewAircraftService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	const a350 = `{"points":[{"range_nm":5000,"payload_kg":53300,"label":"mtow"},{"range_nm":8000,"payload_kg":30000,"label":"fuel"},{"range_nm":9500,"payload_kg":0,"label":"ferry"}]}`
+
+	ptr := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+		expectedPoints int
+		maxPayloadKg   *float64
+		maxRangeNm     *float64
+	}{
+		{name: "Seeded", method: http.MethodGet, path: "/api/v1/aircraft/3/payload-range", expectedStatus: http.StatusOK, expectedPoints: 3},
+		{name: "Payload at MTOW limited distance", method: http.MethodGet, path: "/api/v1/aircraft/3/payload-range?distance=1000", expectedStatus: http.StatusOK, expectedPoints: 3, maxPayloadKg: ptr(250000)},
+		{name: "Payload between corners", method: http.MethodGet, path: "/api/v1/aircraft/3/payload-range?distance=5950", expectedStatus: http.StatusOK, expectedPoints: 3, maxPayloadKg: ptr(100000)},
+		{name: "Distance beyond ferry range", method: http.MethodGet, path: "/api/v1/aircraft/3/payload-range?distance=10000", expectedStatus: http.StatusOK, expectedPoints: 3, maxPayloadKg: ptr(0)},
+		{name: "Range for payload", method: http.MethodGet, path: "/api/v1/aircraft/3/payload-range?payload=225000", expectedStatus: http.StatusOK, expectedPoints: 3, maxRangeNm: ptr(1775)},
+		{name: "Payload above maximum", method: http.MethodGet, path: "/api/v1/aircraft/3/payload-range?payload=300000", expectedStatus: http.StatusOK, expectedPoints: 3, maxRangeNm: ptr(0)},
+		{name: "Invalid distance", method: http.MethodGet, path: "/api/v1/aircraft/3/payload-range?distance=far", expectedStatus: http.StatusBadRequest},
+		{name: "Unknown aircraft", method: http.MethodGet, path: "/api/v1/aircraft/99/payload-range", expectedStatus: http.StatusNotFound},
+		{name: "Replace without token", method: http.MethodPut, path: "/api/v1/aircraft/4/payload-range", body: a350, expectedStatus: http.StatusUnauthorized},
+		{name: "Replace", method: http.MethodPut, path: "/api/v1/aircraft/4/payload-range", token: "secret", body: a350, expectedStatus: http.StatusOK, expectedPoints: 3},
+		{name: "Replaced", method: http.MethodGet, path: "/api/v1/aircraft/4/payload-range?payload=30000", expectedStatus: http.StatusOK, expectedPoints: 3, maxRangeNm: ptr(8000)},
+		{name: "Replace with increasing payload", method: http.MethodPut, path: "/api/v1/aircraft/4/payload-range", token: "secret", body: `{"points":[{"range_nm":1000,"payload_kg":10},{"range_nm":2000,"payload_kg":20}]}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Replace for unknown aircraft", method: http.MethodPut, path: "/api/v1/aircraft/99/payload-range", token: "secret", body: a350, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var res models.PayloadRangeEnvelope
+			json.Unmarshal(rr.Body.Bytes(), &res)
+			assert.Equal(t, tt.expectedPoints, len(res.Points))
+			if tt.maxPayloadKg != nil && assert.NotNil(t, res.MaxPayloadKg) {
+				assert.InDelta(t, *tt.maxPayloadKg, *res.MaxPayloadKg, 0.001)
+			}
+			if tt.maxRangeNm != nil && assert.NotNil(t, res.MaxRangeNm) {
+				assert.InDelta(t, *tt.maxRangeNm, *res.MaxRangeNm, 0.001)
+			}
+		})
+	}
 }
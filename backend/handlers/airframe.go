@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+var _ Handler = (*AirframeHandler)(nil)
+
+type AirframeHandler struct {
+	service *services.AirframeService
+	auth    Middleware
+}
+
+// NewAirframeHandler creates the airframe handler. auth protects the write
+// endpoints, a nil auth disables them.
+func NewAirframeHandler(svc *services.AirframeService, auth Middleware) *AirframeHandler {
+	return &AirframeHandler{service: svc, auth: auth}
+}
+
+func (ah *AirframeHandler) Register(r *mux.Router) {
+	r.HandleFunc(fmt.Sprintf("%s/airframes", basePathV1), ah.GetAirframes).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetAirframes")
+	r.HandleFunc(fmt.Sprintf("%s/airframes/{id:[0-9]+}", basePathV1), ah.GetAirframe).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetAirframe")
+
+	r.Handle(fmt.Sprintf("%s/airframes", basePathV1), protect(ah.auth, ah.CreateAirframe)).
+		Methods(http.MethodPost).
+		Name("CreateAirframe")
+	r.Handle(fmt.Sprintf("%s/airframes/{id:[0-9]+}", basePathV1), protect(ah.auth, ah.ReplaceAirframe)).
+		Methods(http.MethodPut).
+		Name("ReplaceAirframe")
+	r.Handle(fmt.Sprintf("%s/airframes/{id:[0-9]+}", basePathV1), protect(ah.auth, ah.PatchAirframe)).
+		Methods(http.MethodPatch).
+		Name("PatchAirframe")
+	r.Handle(fmt.Sprintf("%s/airframes/{id:[0-9]+}", basePathV1), protect(ah.auth, ah.DeleteAirframe)).
+		Methods(http.MethodDelete).
+		Name("DeleteAirframe")
+}
+
+// GetAirframes lists airframes, optionally filtered by the aircraftId,
+// registration, operator, homeBase and status query parameters.
+func (ah *AirframeHandler) GetAirframes(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := services.AirframeFilter{
+		Registration: q.Get("registration"),
+		Operator:     q.Get("operator"),
+		HomeBase:     q.Get("homeBase"),
+		Status:       q.Get("status"),
+	}
+	if v := q.Get("aircraftId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			newErrorResponse(w, fmt.Errorf("invalid aircraftId %q: must be a positive integer", v), http.StatusBadRequest)
+			return
+		}
+		filter.AircraftId = id
+	}
+
+	opts, err := listOptionsFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := ah.service.ListAirframes(r.Context(), filter, opts)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newPageResponse(w, r, res, opts.Fields)
+}
+
+func (ah *AirframeHandler) GetAirframe(w http.ResponseWriter, r *http.Request) {
+	id, err := airframeIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := ah.service.FindAirframe(r.Context(), id)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+func (ah *AirframeHandler) CreateAirframe(w http.ResponseWriter, r *http.Request) {
+	airframe, err := models.NewAirframe(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.CreateAirframe(r.Context(), airframe); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/airframes/%d", basePathV1, airframe.Id))
+	newJSONResponse(w, airframe, http.StatusCreated)
+}
+
+// ReplaceAirframe replaces all fields of an airframe with the request body.
+func (ah *AirframeHandler) ReplaceAirframe(w http.ResponseWriter, r *http.Request) {
+	id, err := airframeIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	airframe, err := models.NewAirframe(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.UpdateAirframe(r.Context(), id, airframe); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, airframe, http.StatusOK)
+}
+
+// PatchAirframe applies the fields present in the request body to an airframe
+// and leaves all other fields untouched (JSON merge patch).
+func (ah *AirframeHandler) PatchAirframe(w http.ResponseWriter, r *http.Request) {
+	id, err := airframeIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	airframe, err := ah.service.FindAirframe(r.Context(), id)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&airframe); err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.UpdateAirframe(r.Context(), id, &airframe); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, airframe, http.StatusOK)
+}
+
+func (ah *AirframeHandler) DeleteAirframe(w http.ResponseWriter, r *http.Request) {
+	id, err := airframeIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.DeleteAirframe(r.Context(), id); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func airframeIdFromRequest(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("invalid airframe id %q", mux.Vars(r)["id"])
+	}
+	return id, nil
+}
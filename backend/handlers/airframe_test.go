@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAirframes(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewAirframeHandler(services.NewAirframeService(db), BearerAuth([]string{"secret"})).Register(r)
+	NewAircraftHandler(services.NewAircraftService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	// Test case 1: Filter the registry
+	for query, expected := range map[string][]string{
+		"":                              {"N921AN", "N650GD", "UR-82060", "D-AIZA"},
+		"status=active":                 {"N921AN", "N650GD", "D-AIZA"},
+		"aircraftId=4":                  {"D-AIZA"},
+		"operator=lufthansa":            {"D-AIZA"},
+		"homeBase=jfk":                  {"N921AN"},
+		"registration=ur-82060":         {"UR-82060"},
+		"status=active&sort=-home_base": {"N650GD", "N921AN", "D-AIZA"},
+	} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/airframes?"+query, http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code, query)
+		var airframes models.Page[models.Airframe]
+		json.Unmarshal(rr.Body.Bytes(), &airframes)
+		var registrations []string
+		for _, a := range airframes.Data {
+			registrations = append(registrations, a.Registration)
+		}
+		if strings.Contains(query, "sort") {
+			assert.Equal(t, expected, registrations, query)
+		} else {
+			assert.ElementsMatch(t, expected, registrations, query)
+		}
+	}
+
+	// Test case 2: Paginate by home base, airframes without one come last
+	for sort, expected := range map[string][]string{
+		"home_base":     {"D-AIZA", "N921AN", "N650GD", "UR-82060"},
+		"-home_base":    {"N650GD", "N921AN", "D-AIZA", "UR-82060"},
+		"home_base,-id": {"D-AIZA", "N921AN", "N650GD", "UR-82060"},
+	} {
+		var registrations []string
+		next := "/api/v1/airframes?limit=1&sort=" + sort
+		for range 5 {
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, next, http.NoBody)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code, sort)
+			var airframes models.Page[models.Airframe]
+			json.Unmarshal(rr.Body.Bytes(), &airframes)
+			for _, a := range airframes.Data {
+				registrations = append(registrations, a.Registration)
+			}
+			if next = airframes.Links.Next; next == "" {
+				break
+			}
+		}
+		assert.Equal(t, expected, registrations, sort)
+	}
+
+	// Test case 3: Write the registry
+	const hbjna = `{"registration":"HB-JNA","aircraft_id":4,"operator":"Swiss","serial_number":"1234","home_base":"ZRH"}`
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Missing token", method: http.MethodPost, path: "/api/v1/airframes", body: hbjna, expectedStatus: http.StatusUnauthorized},
+		{name: "Create", method: http.MethodPost, path: "/api/v1/airframes", token: "secret", body: hbjna, expectedStatus: http.StatusCreated},
+		{name: "Create duplicate registration", method: http.MethodPost, path: "/api/v1/airframes", token: "secret", body: hbjna, expectedStatus: http.StatusConflict},
+		{name: "Create with unknown references", method: http.MethodPost, path: "/api/v1/airframes", token: "secret", body: `{"registration":"HB-JNB","aircraft_id":99,"home_base":"XXX"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Create invalid", method: http.MethodPost, path: "/api/v1/airframes", token: "secret", body: `{"registration":"hb jnb","aircraft_id":0,"status":"flying"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Get", method: http.MethodGet, path: "/api/v1/airframes/5", expectedStatus: http.StatusOK},
+		{name: "Patch status", method: http.MethodPatch, path: "/api/v1/airframes/5", token: "secret", body: `{"status":"maintenance"}`, expectedStatus: http.StatusOK},
+		{name: "Patch to used registration", method: http.MethodPatch, path: "/api/v1/airframes/5", token: "secret", body: `{"registration":"D-AIZA"}`, expectedStatus: http.StatusConflict},
+		{name: "Replace", method: http.MethodPut, path: "/api/v1/airframes/5", token: "secret", body: hbjna, expectedStatus: http.StatusOK},
+		{name: "Delete aircraft type with airframes", method: http.MethodDelete, path: "/api/v1/aircraft/4", token: "secret", expectedStatus: http.StatusConflict},
+		{name: "Delete airframe with flights", method: http.MethodDelete, path: "/api/v1/airframes/1", token: "secret", expectedStatus: http.StatusConflict},
+		{name: "Delete", method: http.MethodDelete, path: "/api/v1/airframes/5", token: "secret", expectedStatus: http.StatusNoContent},
+		{name: "Get deleted", method: http.MethodGet, path: "/api/v1/airframes/5", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+		})
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/models"
 	"github.com/leanderkunstmann/terraroute/backend/services"
 )
 
@@ -13,24 +16,214 @@ var _ Handler = (*AirportHandler)(nil)
 
 type AirportHandler struct {
 	service *services.AirportService
+	auth    Middleware
 }
 
-func NewAirportHandler(svc *services.AirportService) *AirportHandler {
-	return &AirportHandler{service: svc}
+// NewAirportHandler creates the airport handler. auth protects the write
+// endpoints, a nil auth disables them.
+func NewAirportHandler(svc *services.AirportService, auth Middleware) *AirportHandler {
+	return &AirportHandler{service: svc, auth: auth}
 }
 
 func (ah *AirportHandler) Register(r *mux.Router) {
 	r.HandleFunc(fmt.Sprintf("%s/airports", basePathV1), ah.getAirports).
 		Methods(http.MethodGet, http.MethodOptions).
 		Name("GetAirports")
+	r.HandleFunc(fmt.Sprintf("%s/airports/within", basePathV1), ah.getAirportsWithin).
+		Methods(http.MethodPost, http.MethodOptions).
+		Name("GetAirportsWithin")
+	r.HandleFunc(fmt.Sprintf("%s/airports/search", basePathV1), ah.searchAirports).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("SearchAirports")
+	r.HandleFunc(fmt.Sprintf("%s/airports/{code:[A-Za-z0-9]{3,4}}", basePathV1), ah.getAirport).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetAirport")
+
+	r.Handle(fmt.Sprintf("%s/airports", basePathV1), protect(ah.auth, ah.createAirport)).
+		Methods(http.MethodPost).
+		Name("CreateAirport")
+	r.Handle(fmt.Sprintf("%s/airports/{code:[A-Za-z]{3}}", basePathV1), protect(ah.auth, ah.replaceAirport)).
+		Methods(http.MethodPut).
+		Name("ReplaceAirport")
+	r.Handle(fmt.Sprintf("%s/airports/{code:[A-Za-z]{3}}", basePathV1), protect(ah.auth, ah.patchAirport)).
+		Methods(http.MethodPatch).
+		Name("PatchAirport")
+	r.Handle(fmt.Sprintf("%s/airports/{code:[A-Za-z]{3}}", basePathV1), protect(ah.auth, ah.deleteAirport)).
+		Methods(http.MethodDelete).
+		Name("DeleteAirport")
 }
 
 func (ah *AirportHandler) getAirports(w http.ResponseWriter, r *http.Request) {
-	country := r.URL.Query().Get("country")
-	iata := r.URL.Query().Get("iata")
-	continent := r.URL.Query().Get("continent")
+	filter, err := airportFilterFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	ah.listAirports(w, r, filter)
+}
+
+// getAirportsWithin lists the airports inside the GeoJSON polygon posted in
+// the request body. The query parameters of getAirports apply as well.
+func (ah *AirportHandler) getAirportsWithin(w http.ResponseWriter, r *http.Request) {
+	filter, err := airportFilterFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	filter.Geometry, err = models.NewGeometry(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	ah.listAirports(w, r, filter)
+}
+
+func (ah *AirportHandler) listAirports(w http.ResponseWriter, r *http.Request, filter services.AirportFilter) {
+	opts, err := listOptionsFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := ah.service.ListAirports(r.Context(), filter, opts)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newPageResponse(w, r, res, opts.Fields)
+}
+
+// getAirport returns a single airport by IATA or ICAO code with its related data.
+func (ah *AirportHandler) getAirport(w http.ResponseWriter, r *http.Request) {
+	res, err := ah.service.GetAirport(r.Context(), mux.Vars(r)["code"])
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+func (ah *AirportHandler) createAirport(w http.ResponseWriter, r *http.Request) {
+	airport, err := models.NewAirport(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.CreateAirport(r.Context(), airport); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/airports/%s", basePathV1, airport.IATA))
+	newJSONResponse(w, airport, http.StatusCreated)
+}
+
+// replaceAirport replaces all fields of an airport with the request body.
+func (ah *AirportHandler) replaceAirport(w http.ResponseWriter, r *http.Request) {
+	airport, err := models.NewAirport(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := ah.service.UpdateAirport(r.Context(), mux.Vars(r)["code"], airport); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, airport, http.StatusOK)
+}
+
+// patchAirport applies the fields present in the request body to an airport
+// and leaves all other fields untouched (JSON merge patch). A country or
+// continent changed by name only replaces the code as well.
+func (ah *AirportHandler) patchAirport(w http.ResponseWriter, r *http.Request) {
+	airport, err := ah.service.FindAirport(r.Context(), mux.Vars(r)["code"])
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+	iata := airport.IATA
+	stored := airport
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&airport); err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+	// Names are only resolved for airports without a code, and the stored
+	// ones are set again from the codes.
+	if airport.Country == stored.Country {
+		airport.Country = ""
+	} else if airport.CountryCode == stored.CountryCode {
+		airport.CountryCode = ""
+	}
+	if airport.Continent == stored.Continent {
+		airport.Continent = ""
+	} else if airport.ContinentCode == stored.ContinentCode {
+		airport.ContinentCode = ""
+	}
+
+	if err := ah.service.UpdateAirport(r.Context(), iata, &airport); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, airport, http.StatusOK)
+}
+
+func (ah *AirportHandler) deleteAirport(w http.ResponseWriter, r *http.Request) {
+	if err := ah.service.DeleteAirport(r.Context(), mux.Vars(r)["code"]); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func airportFilterFromRequest(r *http.Request) (services.AirportFilter, error) {
+	q := r.URL.Query()
+	filter := services.AirportFilter{
+		IATA:      q.Get("iata"),
+		Continent: q.Get("continent"),
+		Country:   q.Get("country"),
+		Metro:     strings.ToUpper(q.Get("metro")),
+	}
+
+	if bbox := q.Get("bbox"); bbox != "" {
+		var err error
+		if filter.BBox, err = models.ParseBBox(bbox); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+func (ah *AirportHandler) searchAirports(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query().Get("q")
+
+	var limit int
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			newErrorResponse(w, fmt.Errorf("invalid limit %q: %w", l, err), http.StatusBadRequest)
+			return
+		}
+	}
 
-	res, err := ah.service.ListAirports(r.Context(), iata, continent, country)
+	res, err := ah.service.SearchAirports(r.Context(), q, limit)
 	if err != nil {
 		newErrorResponse(w, err, http.StatusInternalServerError)
 		return
@@ -41,5 +234,4 @@ func (ah *AirportHandler) getAirports(w http.ResponseWriter, r *http.Request) {
 		newErrorResponse(w, err, http.StatusInternalServerError)
 		return
 	}
-	w.WriteHeader(http.StatusOK)
 }
@@ -5,12 +5,16 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/gorilla/mux"
 	"github.com/leanderkunstmann/terraroute/backend/database"
 	"github.com/leanderkunstmann/terraroute/backend/models"
 	"github.com/leanderkunstmann/terraroute/backend/services"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetAirports(t *testing.T) {
@@ -24,7 +28,7 @@ func TestGetAirports(t *testing.T) {
 	}()
 
 	service := services.NewAirportService(db)
-	handler := NewAirportHandler(service)
+	handler := NewAirportHandler(service, nil)
 
 	const path = "/airports"
 
@@ -34,9 +38,9 @@ func TestGetAirports(t *testing.T) {
 	handler.getAirports(rr, req)
 
 	assert.Equal(t, http.StatusOK, rr.Code)
-	var airports []models.Airport
+	var airports models.Page[models.Airport]
 	json.Unmarshal(rr.Body.Bytes(), &airports)
-	assert.Equal(t, 4, len(airports)) // Check if all airports are returned
+	assert.Equal(t, 9, len(airports.Data)) // Check if all airports are returned
 
 	// Test case 2: Filter by country
 	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?country=USA", path), http.NoBody)
@@ -45,7 +49,7 @@ func TestGetAirports(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 	json.Unmarshal(rr.Body.Bytes(), &airports)
-	assert.Equal(t, 2, len(airports)) // Check if correct number of airports are returned
+	assert.Equal(t, 2, len(airports.Data)) // Check if correct number of airports are returned
 
 	// Test case 3: Filter by iata
 	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?iata=JFK", path), http.NoBody)
@@ -54,8 +58,8 @@ func TestGetAirports(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 	json.Unmarshal(rr.Body.Bytes(), &airports)
-	assert.Equal(t, 1, len(airports))
-	assert.Equal(t, "JFK", airports[0].IATA)
+	assert.Equal(t, 1, len(airports.Data))
+	assert.Equal(t, "JFK", airports.Data[0].IATA)
 
 	// Test case 4: Filter by continent
 	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?iata=JFK", path), http.NoBody)
@@ -64,7 +68,375 @@ func TestGetAirports(t *testing.T) {
 
 	assert.Equal(t, http.StatusOK, rr.Code)
 	json.Unmarshal(rr.Body.Bytes(), &airports)
-	assert.Equal(t, 1, len(airports))
-	assert.Equal(t, "JFK", airports[0].IATA)
+	assert.Equal(t, 1, len(airports.Data))
+	assert.Equal(t, "JFK", airports.Data[0].IATA)
+	assert.Equal(t, 1, airports.Total)
 
+	// Test case 5: Paginate with a custom sort order, airports without a
+	// minimum connection time come last
+	for sort, expected := range map[string][]string{
+		"-latitude":               {"FRA", "CDG", "ZRH", "JFK", "NGO", "LAX", "PVG", "ADD", "AKL"},
+		"min_connection_minutes":  {"FRA", "CDG", "JFK", "PVG", "LAX", "ADD", "AKL", "NGO", "ZRH"},
+		"-min_connection_minutes": {"LAX", "PVG", "JFK", "CDG", "FRA", "ADD", "AKL", "NGO", "ZRH"},
+	} {
+		var iatas []string
+		next := fmt.Sprintf("%s?limit=4&sort=%s", path, sort)
+		for next != "" {
+			req, _ = http.NewRequestWithContext(ctx, http.MethodGet, next, http.NoBody)
+			rr = httptest.NewRecorder()
+			handler.getAirports(rr, req)
+
+			assert.Equal(t, http.StatusOK, rr.Code)
+			airports = models.Page[models.Airport]{}
+			json.Unmarshal(rr.Body.Bytes(), &airports)
+			assert.Equal(t, 9, airports.Total)
+			assert.LessOrEqual(t, len(airports.Data), 4)
+			for _, a := range airports.Data {
+				iatas = append(iatas, a.IATA)
+			}
+			next = airports.Links.Next
+		}
+		assert.Equal(t, expected, iatas, sort)
+	}
+
+	// Test case 6: Sparse fieldset
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?iata=JFK&fields=iata,latitude,longitude", path), http.NoBody)
+	rr = httptest.NewRecorder()
+	handler.getAirports(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var sparse models.Page[map[string]any]
+	json.Unmarshal(rr.Body.Bytes(), &sparse)
+	assert.Equal(t, []map[string]any{{"iata": "JFK", "latitude": 40.6413, "longitude": -73.7781}}, sparse.Data)
+
+	// Test case 7: Invalid parameters
+	for _, query := range []string{"sort=altitude", "fields=altitude", "limit=0", "cursor=garbage"} {
+		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s?%s", path, query), http.NoBody)
+		rr = httptest.NewRecorder()
+		handler.getAirports(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+	}
+}
+
+func TestSearchAirports(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	service := services.NewAirportService(db)
+	handler := NewAirportHandler(service, nil)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedFirst  string
+	}{
+		{name: "IATA prefix", query: "jf", expectedStatus: http.StatusOK, expectedFirst: "JFK"},
+		{name: "ICAO code", query: "EDDF", expectedStatus: http.StatusOK, expectedFirst: "FRA"},
+		{name: "City without accents", query: "zurich", expectedStatus: http.StatusOK, expectedFirst: "ZRH"},
+		{name: "Name prefix", query: "Charles de", expectedStatus: http.StatusOK, expectedFirst: "CDG"},
+		{name: "Typo", query: "frankfrut", expectedStatus: http.StatusOK, expectedFirst: "FRA"},
+		{name: "Typo in partial word", query: "aucl", expectedStatus: http.StatusOK, expectedFirst: "AKL"},
+		{name: "No match", query: "xyzzy", expectedStatus: http.StatusOK},
+		{name: "Empty query", query: "", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "/airports/search?q="+url.QueryEscape(tt.query), http.NoBody)
+			rr := httptest.NewRecorder()
+			handler.searchAirports(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var matches []models.AirportMatch
+			json.Unmarshal(rr.Body.Bytes(), &matches)
+			if tt.expectedFirst == "" {
+				assert.Empty(t, matches)
+				return
+			}
+			if assert.NotEmpty(t, matches) {
+				assert.Equal(t, tt.expectedFirst, matches[0].IATA)
+			}
+		})
+	}
+}
+
+func TestGetAirportsSpatial(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	service := services.NewAirportService(db)
+	handler := NewAirportHandler(service, nil)
+
+	// europe is a rectangle around central Europe with a hole around Zürich.
+	const europe = `{"type":"Polygon","coordinates":[
+		[[-5,40],[20,40],[20,55],[-5,55],[-5,40]],
+		[[8,47],[9,47],[9,48],[8,48],[8,47]]
+	]}`
+
+	tests := []struct {
+		name           string
+		method         string
+		query          string
+		body           string
+		expectedStatus int
+		expectedIATAs  []string
+	}{
+		{name: "Bounding box", method: http.MethodGet, query: "bbox=-10,35,30,60", expectedStatus: http.StatusOK, expectedIATAs: []string{"CDG", "FRA", "ZRH"}},
+		{name: "Bounding box crossing the antimeridian", method: http.MethodGet, query: "bbox=170,-50,-170,-30", expectedStatus: http.StatusOK, expectedIATAs: []string{"AKL"}},
+		{name: "Wide bounding box crossing the antimeridian", method: http.MethodGet, query: "bbox=100,-50,-60,60", expectedStatus: http.StatusOK, expectedIATAs: []string{"AKL", "JFK", "LAX", "NGO", "PVG"}},
+		{name: "Bounding box and country", method: http.MethodGet, query: "bbox=-10,35,30,60&country=Germany", expectedStatus: http.StatusOK, expectedIATAs: []string{"FRA"}},
+		{name: "Invalid bounding box", method: http.MethodGet, query: "bbox=0,60,10,50", expectedStatus: http.StatusBadRequest},
+		{name: "Polygon with hole", method: http.MethodPost, body: europe, expectedStatus: http.StatusOK, expectedIATAs: []string{"CDG", "FRA"}},
+		{name: "Polygon feature paginated", method: http.MethodPost, query: "limit=1", body: `{"type":"Feature","geometry":` + europe + `}`, expectedStatus: http.StatusOK, expectedIATAs: []string{"CDG", "FRA"}},
+		{name: "Unsupported geometry", method: http.MethodPost, body: `{"type":"Point","coordinates":[0,0]}`, expectedStatus: http.StatusBadRequest},
+		{name: "Open ring", method: http.MethodPost, body: `{"type":"Polygon","coordinates":[[[0,0],[1,0],[1,1],[0,1]]]}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var iatas []string
+			next := "/airports?" + tt.query
+			for next != "" {
+				req, _ := http.NewRequestWithContext(t.Context(), tt.method, next, strings.NewReader(tt.body))
+				rr := httptest.NewRecorder()
+				if tt.method == http.MethodPost {
+					handler.getAirportsWithin(rr, req)
+				} else {
+					handler.getAirports(rr, req)
+				}
+
+				assert.Equal(t, tt.expectedStatus, rr.Code)
+				if rr.Code != http.StatusOK {
+					return
+				}
+
+				var airports models.Page[models.Airport]
+				json.Unmarshal(rr.Body.Bytes(), &airports)
+				assert.Equal(t, len(tt.expectedIATAs), airports.Total)
+				for _, a := range airports.Data {
+					iatas = append(iatas, a.IATA)
+				}
+				next = airports.Links.Next
+			}
+			assert.Equal(t, tt.expectedIATAs, iatas)
+		})
+	}
+}
+
+func TestGetAirport(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	service := services.NewAirportService(db)
+	handler := NewAirportHandler(service, nil)
+
+	tests := []struct {
+		name           string
+		code           string
+		expectedStatus int
+	}{
+		{name: "IATA code", code: "JFK", expectedStatus: http.StatusOK},
+		{name: "ICAO code", code: "kjfk", expectedStatus: http.StatusOK},
+		{name: "Unknown code", code: "XXX", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "/airports/"+tt.code, http.NoBody)
+			req = mux.SetURLVars(req, map[string]string{"code": tt.code})
+			rr := httptest.NewRecorder()
+			handler.getAirport(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var airport models.AirportDetail
+			json.Unmarshal(rr.Body.Bytes(), &airport)
+			assert.Equal(t, "JFK", airport.IATA)
+			assert.Equal(t, "United States", airport.Country)
+			assert.Equal(t, "US", airport.CountryCode)
+			assert.Equal(t, "America/New_York", airport.TimeZone)
+			assert.Contains(t, []string{"-04:00", "-05:00"}, airport.UTCOffset)
+			assert.Len(t, airport.Runways, 4)
+			if assert.Len(t, airport.Departures, 1) {
+				assert.Equal(t, "AA100", airport.Departures[0].FlightNumber)
+			}
+			if assert.Len(t, airport.Arrivals, 1) {
+				assert.Equal(t, "AF200", airport.Arrivals[0].FlightNumber)
+			}
+			if assert.Len(t, airport.Nearest, 5) {
+				assert.Equal(t, "LAX", airport.Nearest[0].IATA)
+				assert.InDelta(t, 3974, airport.Nearest[0].DistanceKm, 1)
+			}
+		})
+	}
+}
+
+func TestNearestAirports(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	// Taveuni lies just east of the antimeridian, the other Fijian airports
+	// west of it.
+	fiji := []models.Airport{
+		{IATA: "TVU", ICAO: "NFNM", Name: "Matei Airport", City: "Taveuni", Latitude: -16.6906, Longitude: -179.877, TimeZone: "Pacific/Fiji"},
+		{IATA: "SVU", ICAO: "NFNS", Name: "Savusavu Airport", City: "Savusavu", Latitude: -16.8028, Longitude: 179.341, TimeZone: "Pacific/Fiji"},
+		{IATA: "NAN", ICAO: "NFFN", Name: "Nadi International Airport", City: "Nadi", Latitude: -17.7554, Longitude: 177.443, TimeZone: "Pacific/Fiji"},
+	}
+	_, err = db.NewInsert().Model(&fiji).Exec(ctx)
+	require.NoError(t, err)
+
+	handler := NewAirportHandler(services.NewAirportService(db), nil)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/airports/TVU", http.NoBody)
+	req = mux.SetURLVars(req, map[string]string{"code": "TVU"})
+	rr := httptest.NewRecorder()
+	handler.getAirport(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+	var airport models.AirportDetail
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &airport))
+	// The seeded airports are half a world away, the search has to widen
+	// until it covers them.
+	if assert.Len(t, airport.Nearest, 5) {
+		assert.Equal(t, "SVU", airport.Nearest[0].IATA)
+		assert.Equal(t, "NAN", airport.Nearest[1].IATA)
+		for i := 1; i < len(airport.Nearest); i++ {
+			assert.LessOrEqual(t, airport.Nearest[i-1].DistanceKm, airport.Nearest[i].DistanceKm)
+		}
+	}
+}
+
+func TestWriteAirports(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewAirportHandler(services.NewAirportService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	const bru = `{"iata":"BRU","icao":"EBBR","name":"Brussels Airport","city":"Brussels","country_code":"BE","continent_code":"EU","latitude":50.9014,"longitude":4.4844,"time_zone":"Europe/Brussels"}`
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Missing token", method: http.MethodPost, path: "/api/v1/airports", body: bru, expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong token", method: http.MethodPost, path: "/api/v1/airports", token: "guess", body: bru, expectedStatus: http.StatusUnauthorized},
+		{name: "Create", method: http.MethodPost, path: "/api/v1/airports", token: "secret", body: bru, expectedStatus: http.StatusCreated},
+		{name: "Create duplicate", method: http.MethodPost, path: "/api/v1/airports", token: "secret", body: bru, expectedStatus: http.StatusConflict},
+		{name: "Create with used ICAO code", method: http.MethodPost, path: "/api/v1/airports", token: "secret", body: `{"iata":"XFK","icao":"KJFK","name":"Duplicate","latitude":0,"longitude":0}`, expectedStatus: http.StatusConflict},
+		{name: "Create invalid", method: http.MethodPost, path: "/api/v1/airports", token: "secret", body: `{"iata":"brux","name":"","latitude":91,"longitude":-181,"time_zone":"Mars/Olympus"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Create with unknown country", method: http.MethodPost, path: "/api/v1/airports", token: "secret", body: `{"iata":"XYZ","name":"Nowhere","country_code":"XX","latitude":0,"longitude":0}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Create with unknown country name", method: http.MethodPost, path: "/api/v1/airports", token: "secret", body: `{"iata":"XYZ","name":"Nowhere","country":"Atlantis","latitude":0,"longitude":0}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Create unknown field", method: http.MethodPost, path: "/api/v1/airports", token: "secret", body: `{"iata":"XYZ","altitude":10}`, expectedStatus: http.StatusBadRequest},
+		{name: "Patch", method: http.MethodPatch, path: "/api/v1/airports/BRU", token: "secret", body: `{"name":"Brussels Zaventem Airport"}`, expectedStatus: http.StatusOK},
+		{name: "Patch IATA code", method: http.MethodPatch, path: "/api/v1/airports/BRU", token: "secret", body: `{"iata":"BRX"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Replace", method: http.MethodPut, path: "/api/v1/airports/BRU", token: "secret", body: bru, expectedStatus: http.StatusOK},
+		{name: "Replace unknown", method: http.MethodPut, path: "/api/v1/airports/XXX", token: "secret", body: `{"iata":"XXX","name":"Nowhere","latitude":0,"longitude":0}`, expectedStatus: http.StatusNotFound},
+		{name: "Delete airport with flights", method: http.MethodDelete, path: "/api/v1/airports/JFK", token: "secret", expectedStatus: http.StatusConflict},
+		{name: "Delete", method: http.MethodDelete, path: "/api/v1/airports/BRU", token: "secret", expectedStatus: http.StatusNoContent},
+		{name: "Delete again", method: http.MethodDelete, path: "/api/v1/airports/BRU", token: "secret", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+		})
+	}
+
+	// The validation errors are listed per field.
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/airports", strings.NewReader(`{"iata":"XYZ","name":"Test","latitude":100,"longitude":0}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var res struct {
+		Details []models.ValidationError `json:"details"`
+	}
+	json.Unmarshal(rr.Body.Bytes(), &res)
+	assert.Equal(t, []models.ValidationError{{Field: "latitude", Message: "must be between -90 and 90"}}, res.Details)
+
+	// Countries and continents are returned by name and code, and can be
+	// given by either.
+	place := func(t *testing.T, method, path, body string) models.Airport {
+		req, _ := http.NewRequestWithContext(t.Context(), method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Less(t, rr.Code, 300, rr.Body.String())
+
+		var airport models.Airport
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &airport))
+		return airport
+	}
+	crl := place(t, http.MethodPost, "/api/v1/airports", `{"iata":"CRL","name":"Brussels South Charleroi Airport","country":"belgium","latitude":50.4592,"longitude":4.45382}`)
+	assert.Equal(t, []string{"Belgium", "BE", "Europe", "EU"}, []string{crl.Country, crl.CountryCode, crl.Continent, crl.ContinentCode})
+	crl = place(t, http.MethodPatch, "/api/v1/airports/CRL", `{"country":"France"}`)
+	assert.Equal(t, []string{"France", "FR", "Europe", "EU"}, []string{crl.Country, crl.CountryCode, crl.Continent, crl.ContinentCode})
+	crl = place(t, http.MethodPatch, "/api/v1/airports/CRL", `{"country_code":"US","continent_code":""}`)
+	assert.Equal(t, []string{"United States", "US", "North America", "NA"}, []string{crl.Country, crl.CountryCode, crl.Continent, crl.ContinentCode})
+	crl = place(t, http.MethodGet, "/api/v1/airports/CRL", "")
+	assert.Equal(t, "United States", crl.Country)
+	req, _ = http.NewRequestWithContext(ctx, http.MethodDelete, "/api/v1/airports/CRL", http.NoBody)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusNoContent, rr.Code)
+
+	// Writes are visible to the search index.
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/airports/search?q=bruss", http.NoBody)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var matches []models.AirportMatch
+	json.Unmarshal(rr.Body.Bytes(), &matches)
+	assert.Empty(t, matches)
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps a handler, e.g. to authenticate requests before they reach it.
+type Middleware func(http.Handler) http.Handler
+
+type unauthorizedError string
+
+func (e unauthorizedError) Error() string {
+	return string(e)
+}
+
+func (e unauthorizedError) Code() int {
+	return http.StatusUnauthorized
+}
+
+// BearerAuth only lets requests through that carry one of the given tokens
+// in an "Authorization: Bearer <token>" header. Without any tokens every
+// request is rejected, so write endpoints stay closed unless explicitly
+// configured.
+func BearerAuth(tokens []string) Middleware {
+	var valid [][]byte
+	for _, t := range tokens {
+		if t = strings.TrimSpace(t); t != "" {
+			valid = append(valid, []byte(t))
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := checkBearerToken(r, valid); err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="terraroute"`)
+				newErrorResponse(w, err, http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func checkBearerToken(r *http.Request, valid [][]byte) error {
+	if len(valid) == 0 {
+		return unauthorizedError("write access is disabled: no API tokens configured")
+	}
+
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return unauthorizedError("missing bearer token")
+	}
+
+	for _, v := range valid {
+		if subtle.ConstantTimeCompare([]byte(token), v) == 1 {
+			return nil
+		}
+	}
+	return unauthorizedError("invalid bearer token")
+}
+
+// protect wraps h with auth. A nil auth denies every request.
+func protect(auth Middleware, h http.HandlerFunc) http.Handler {
+	if auth == nil {
+		auth = BearerAuth(nil)
+	}
+	return auth(h)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+// boardRefreshInterval is how often a streamed board is rebuilt, so that
+// flights move in and out of a sliding window.
+const boardRefreshInterval = 30 * time.Second
+
+var _ Handler = (*BoardHandler)(nil)
+
+type BoardHandler struct {
+	service *services.FlightService
+}
+
+func NewBoardHandler(svc *services.FlightService) *BoardHandler {
+	return &BoardHandler{service: svc}
+}
+
+func (bh *BoardHandler) Register(r *mux.Router) {
+	for _, board := range []struct {
+		typ, name string
+	}{
+		{models.BoardDepartures, "Departures"},
+		{models.BoardArrivals, "Arrivals"},
+	} {
+		path := fmt.Sprintf("%s/airports/{code:[A-Za-z]{3}}/%s", basePathV1, board.typ)
+		r.HandleFunc(path, bh.getBoard(board.typ)).
+			Methods(http.MethodGet, http.MethodOptions).
+			Name("Get" + board.name)
+		r.HandleFunc(path+"/stream", bh.streamBoard(board.typ)).
+			Methods(http.MethodGet, http.MethodOptions).
+			Name("Stream" + board.name)
+	}
+}
+
+// getBoard returns the departures or arrivals board of an airport. The
+// window is the local day at the airport given by the date query parameter,
+// or starts at from and ends at to (RFC 3339). It defaults to the next 12
+// hours, starting one hour ago.
+func (bh *BoardHandler) getBoard(boardType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := boardQueryFromRequest(r, boardType)
+		if err != nil {
+			newErrorResponse(w, err, http.StatusBadRequest)
+			return
+		}
+
+		res, err := bh.service.Board(r.Context(), query)
+		if err != nil {
+			newErrorResponse(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		newJSONResponse(w, res, http.StatusOK)
+	}
+}
+
+// streamBoard streams a board as server-sent "board" events. The current
+// board is sent right away and again whenever it changes, because a flight
+// of the airport changed its status or because a default window moved on.
+func (bh *BoardHandler) streamBoard(boardType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query, err := boardQueryFromRequest(r, boardType)
+		if err != nil {
+			newErrorResponse(w, err, http.StatusBadRequest)
+			return
+		}
+
+		// Subscribe before building the first board so that no change is
+		// missed in between.
+		sub, err := bh.service.Subscribe(services.StreamFilter{Types: []string{models.StreamStatus}, Airport: query.Airport}, 0)
+		if err != nil {
+			newErrorResponse(w, err, http.StatusServiceUnavailable)
+			return
+		}
+		defer bh.service.Unsubscribe(sub)
+
+		board, err := bh.service.Board(r.Context(), query)
+		if err != nil {
+			newErrorResponse(w, err, http.StatusInternalServerError)
+			return
+		}
+
+		stream, ok := newEventStream(w)
+		if !ok {
+			return
+		}
+
+		// Boards are only sent when they differ from the last one, status
+		// changes of flights on the other board of the airport are skipped.
+		var last []byte
+		send := func(board models.Board) bool {
+			data, err := json.Marshal(board.Flights)
+			if err != nil {
+				return false
+			}
+			if bytes.Equal(data, last) {
+				return true
+			}
+			last = data
+			return stream.send("board", board)
+		}
+		if !send(board) {
+			return
+		}
+
+		heartbeat := time.NewTicker(streamHeartbeatInterval)
+		defer heartbeat.Stop()
+		refresh := time.NewTicker(boardRefreshInterval)
+		defer refresh.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if !stream.heartbeat() {
+					return
+				}
+				continue
+			case _, ok := <-sub.C:
+				if !ok {
+					return
+				}
+			case <-refresh.C:
+			}
+
+			board, err := bh.service.Board(r.Context(), query)
+			if err != nil {
+				return
+			}
+			if !send(board) {
+				return
+			}
+		}
+	}
+}
+
+func boardQueryFromRequest(r *http.Request, boardType string) (services.BoardQuery, error) {
+	q := r.URL.Query()
+	query := services.BoardQuery{Airport: mux.Vars(r)["code"], Type: boardType}
+
+	if date := q.Get("date"); date != "" {
+		if q.Get("from") != "" || q.Get("to") != "" {
+			return query, fmt.Errorf("date cannot be combined with from or to")
+		}
+		d, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return query, fmt.Errorf("invalid date %q: must be a date (YYYY-MM-DD)", date)
+		}
+		query.Date = d
+	}
+
+	for _, p := range []struct {
+		name  string
+		value *time.Time
+	}{
+		{"from", &query.From},
+		{"to", &query.To},
+	} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return query, fmt.Errorf("invalid %s %q: must be an RFC 3339 time", p.name, v)
+		}
+		*p.value = t
+	}
+
+	return query, nil
+}
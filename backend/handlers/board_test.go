@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetBoards(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	svc := services.NewFlightService(db)
+	r := mux.NewRouter()
+	NewBoardHandler(svc).Register(r)
+
+	// AF200 from CDG to JFK, scheduled 09:00Z to 12:00Z, is delayed by 90 minutes.
+	estimate := time.Date(2023, 10, 2, 10, 30, 0, 0, time.UTC)
+	require.NoError(t, svc.RecordFlightEvent(ctx, 2, &models.FlightEvent{Status: models.FlightDelayed, EstimatedDepartureTime: &estimate}))
+
+	get := func(path string) (int, models.Board) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, path, http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		var board models.Board
+		if rr.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &board))
+		}
+		return rr.Code, board
+	}
+	local := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	t.Run("Departures", func(t *testing.T) {
+		code, board := get("/api/v1/airports/jfk/departures?date=2023-10-01")
+		require.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "JFK", board.Airport)
+		assert.Equal(t, models.BoardDepartures, board.Type)
+		assert.Equal(t, "America/New_York", board.TimeZone)
+		assert.Equal(t, "2023-10-01T00:00:00-04:00", board.From.Format(time.RFC3339))
+		require.Len(t, board.Flights, 1)
+		f := board.Flights[0]
+		assert.Equal(t, "AA100", f.FlightNumber)
+		assert.Equal(t, "LAX", f.Airport)
+		assert.Equal(t, "Los Angeles", f.City)
+		assert.Equal(t, "2023-10-01T04:00:00-04:00", f.ScheduledTime.Format(time.RFC3339))
+		assert.Nil(t, f.EstimatedTime)
+		assert.Equal(t, models.FlightScheduled, f.Status)
+		assert.Equal(t, "Boeing 737", f.Aircraft)
+		assert.Equal(t, "N921AN", f.Registration)
+	})
+
+	t.Run("Delayed departure", func(t *testing.T) {
+		code, board := get("/api/v1/airports/CDG/departures?date=2023-10-02")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, board.Flights, 1)
+		assert.Equal(t, "JFK", board.Flights[0].Airport)
+		assert.Equal(t, "2023-10-02T11:00:00+02:00", board.Flights[0].ScheduledTime.Format(time.RFC3339))
+		assert.Equal(t, "2023-10-02T12:30:00+02:00", local(board.Flights[0].EstimatedTime))
+		assert.Equal(t, models.FlightDelayed, board.Flights[0].Status)
+	})
+
+	t.Run("Arrivals by estimated time", func(t *testing.T) {
+		// AF200 is scheduled to arrive before the window but expected in it.
+		code, board := get("/api/v1/airports/JFK/arrivals?from=2023-10-02T13:00:00Z&to=2023-10-02T18:00:00Z")
+		require.Equal(t, http.StatusOK, code)
+		require.Len(t, board.Flights, 1)
+		f := board.Flights[0]
+		assert.Equal(t, "AF200", f.FlightNumber)
+		assert.Equal(t, "CDG", f.Airport)
+		assert.Equal(t, "2023-10-02T08:00:00-04:00", f.ScheduledTime.Format(time.RFC3339))
+		assert.Equal(t, "2023-10-02T09:30:00-04:00", local(f.EstimatedTime))
+		assert.Equal(t, "2023-10-02T09:00:00-04:00", board.From.Format(time.RFC3339))
+
+		code, board = get("/api/v1/airports/JFK/arrivals?from=2023-10-02T14:00:00Z&to=2023-10-02T18:00:00Z")
+		require.Equal(t, http.StatusOK, code)
+		assert.Empty(t, board.Flights)
+	})
+
+	t.Run("Invalid requests", func(t *testing.T) {
+		for path, expected := range map[string]int{
+			"/api/v1/airports/XXX/departures":                                                 http.StatusNotFound,
+			"/api/v1/airports/JFK/departures?date=2023-10-01&from=2023-10-01T00:00:00Z":       http.StatusBadRequest,
+			"/api/v1/airports/JFK/departures?date=yesterday":                                  http.StatusBadRequest,
+			"/api/v1/airports/JFK/departures?from=2023-10-01":                                 http.StatusBadRequest,
+			"/api/v1/airports/JFK/arrivals?from=2023-10-02T00:00:00Z&to=2023-10-01T00:00:00Z": http.StatusBadRequest,
+			"/api/v1/airports/JFK/arrivals?from=2023-10-01T00:00:00Z&to=2023-10-09T00:00:00Z": http.StatusBadRequest,
+		} {
+			code, _ := get(path)
+			assert.Equal(t, expected, code, path)
+		}
+	})
+}
+
+func TestStreamBoard(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	svc := services.NewFlightService(db)
+	r := mux.NewRouter()
+	NewBoardHandler(svc).Register(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL + "/api/v1/airports/XXX/arrivals/stream")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, err = http.Get(srv.URL + "/api/v1/airports/JFK/arrivals/stream?date=2023-10-02")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	br := bufio.NewReader(res.Body)
+	next := func() (string, models.Board) {
+		var event string
+		var board models.Board
+		for {
+			line, err := br.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &board))
+			case line == "" && event != "":
+				return event, board
+			}
+		}
+	}
+
+	event, board := next()
+	assert.Equal(t, "board", event)
+	require.Len(t, board.Flights, 1)
+	assert.Equal(t, models.FlightScheduled, board.Flights[0].Status)
+
+	// AA100 only departs from JFK and does not change the arrivals board,
+	// the boarding of AF200 at CDG does.
+	require.NoError(t, svc.RecordFlightEvent(ctx, 1, &models.FlightEvent{Status: models.FlightBoarding}))
+	require.NoError(t, svc.RecordFlightEvent(ctx, 2, &models.FlightEvent{Status: models.FlightBoarding}))
+
+	event, board = next()
+	assert.Equal(t, "board", event)
+	require.Len(t, board.Flights, 1)
+	assert.Equal(t, "AF200", board.Flights[0].FlightNumber)
+	assert.Equal(t, models.FlightBoarding, board.Flights[0].Status)
+
+	svc.CloseStreams()
+}
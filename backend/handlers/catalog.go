@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+var _ Handler = (*CatalogHandler)(nil)
+
+type CatalogHandler struct {
+	service *services.CatalogService
+	auth    Middleware
+}
+
+// NewCatalogHandler creates the handler for the manufacturer and aircraft
+// type catalogs. auth protects the write endpoints, a nil auth disables them.
+func NewCatalogHandler(svc *services.CatalogService, auth Middleware) *CatalogHandler {
+	return &CatalogHandler{service: svc, auth: auth}
+}
+
+func (ch *CatalogHandler) Register(r *mux.Router) {
+	r.HandleFunc(fmt.Sprintf("%s/manufacturers", basePathV1), ch.getManufacturers).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetManufacturers")
+	r.HandleFunc(fmt.Sprintf("%s/aircraft-types", basePathV1), ch.getAircraftTypes).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetAircraftTypes")
+
+	r.Handle(fmt.Sprintf("%s/manufacturers", basePathV1), protect(ch.auth, ch.createManufacturer)).
+		Methods(http.MethodPost).
+		Name("CreateManufacturer")
+	r.Handle(fmt.Sprintf("%s/manufacturers/{name}", basePathV1), protect(ch.auth, ch.deleteManufacturer)).
+		Methods(http.MethodDelete).
+		Name("DeleteManufacturer")
+}
+
+func (ch *CatalogHandler) getManufacturers(w http.ResponseWriter, r *http.Request) {
+	res, err := ch.service.ListManufacturers(r.Context())
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+func (ch *CatalogHandler) getAircraftTypes(w http.ResponseWriter, r *http.Request) {
+	res, err := ch.service.ListAircraftTypes(r.Context())
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+func (ch *CatalogHandler) createManufacturer(w http.ResponseWriter, r *http.Request) {
+	m, err := models.NewManufacturerEntry(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := ch.service.CreateManufacturer(r.Context(), m); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, m, http.StatusCreated)
+}
+
+func (ch *CatalogHandler) deleteManufacturer(w http.ResponseWriter, r *http.Request) {
+	if err := ch.service.DeleteManufacturer(r.Context(), mux.Vars(r)["name"]); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
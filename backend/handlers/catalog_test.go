@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewCatalogHandler(services.NewCatalogService(db), BearerAuth([]string{"secret"})).Register(r)
+	NewAircraftHandler(services.NewAircraftService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	// Test case 1: Catalogs
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/manufacturers", http.NoBody)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var manufacturers []models.ManufacturerEntry
+	json.Unmarshal(rr.Body.Bytes(), &manufacturers)
+	counts := map[models.Manufacturer]int{}
+	for _, m := range manufacturers {
+		counts[m.Name] = m.AircraftCount
+	}
+	assert.Equal(t, map[models.Manufacturer]int{"Airbus": 1, "Antonov": 1, "Boeing": 1, "Bombardier": 0, "Gulfstream": 1}, counts)
+
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/aircraft-types", http.NoBody)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var types []models.AircraftTypeEntry
+	json.Unmarshal(rr.Body.Bytes(), &types)
+	assert.Equal(t, len(models.AircraftTypes), len(types))
+	for _, at := range types {
+		if at.Type == models.Commercial {
+			assert.Equal(t, 2, at.AircraftCount)
+		}
+	}
+
+	// Test case 2: Unknown filter values are rejected with the valid options
+	for query, param := range map[string]string{
+		"aircraftType=foo":    "aircraftType",
+		"manufacturer=Cessna": "manufacturer",
+	} {
+		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/aircraft?"+query, http.NoBody)
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		var res struct {
+			Details struct {
+				Param string   `json:"param"`
+				Valid []string `json:"valid"`
+			} `json:"details"`
+		}
+		json.Unmarshal(rr.Body.Bytes(), &res)
+		assert.Equal(t, param, res.Details.Param)
+		assert.NotEmpty(t, res.Details.Valid)
+	}
+
+	// Test case 3: Manufacturer names match case insensitively
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/aircraft?manufacturer=boeing", http.NoBody)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var aircraft models.Page[models.Aircraft]
+	json.Unmarshal(rr.Body.Bytes(), &aircraft)
+	assert.Equal(t, 1, aircraft.Total)
+
+	// Test case 4: Extend the catalog without code changes
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Aircraft of unknown manufacturer", method: http.MethodPost, path: "/api/v1/aircraft", body: `{"type":"light","name":"Cessna 172","manufacturer":"Cessna","range":640}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Create manufacturer", method: http.MethodPost, path: "/api/v1/manufacturers", body: `{"name":"Cessna","country":"US"}`, expectedStatus: http.StatusCreated},
+		{name: "Create duplicate manufacturer", method: http.MethodPost, path: "/api/v1/manufacturers", body: `{"name":"cessna"}`, expectedStatus: http.StatusConflict},
+		{name: "Create manufacturer of unknown country", method: http.MethodPost, path: "/api/v1/manufacturers", body: `{"name":"Piper","country":"XX"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Aircraft of new manufacturer", method: http.MethodPost, path: "/api/v1/aircraft", body: `{"type":"light","name":"Cessna 172","manufacturer":"cessna","range":640}`, expectedStatus: http.StatusCreated},
+		{name: "Delete manufacturer in use", method: http.MethodDelete, path: "/api/v1/manufacturers/Cessna", expectedStatus: http.StatusConflict},
+		{name: "Delete unused manufacturer", method: http.MethodDelete, path: "/api/v1/manufacturers/Bombardier", expectedStatus: http.StatusNoContent},
+		{name: "Delete unknown manufacturer", method: http.MethodDelete, path: "/api/v1/manufacturers/Bombardier", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+		})
+	}
+}
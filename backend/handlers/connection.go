@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+var _ Handler = (*ConnectionHandler)(nil)
+
+type ConnectionHandler struct {
+	service *services.ConnectionService
+}
+
+func NewConnectionHandler(svc *services.ConnectionService) *ConnectionHandler {
+	return &ConnectionHandler{service: svc}
+}
+
+func (ch *ConnectionHandler) Register(r *mux.Router) {
+	r.HandleFunc(fmt.Sprintf("%s/connections", basePathV1), ch.GetConnections).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetConnections")
+}
+
+// GetConnections searches itineraries between the airports or metro areas in
+// the from and to query parameters departing on date (YYYY-MM-DD, local to
+// the origin). maxStops limits the number of connections, maxLayover the
+// minutes between two legs, sort ranks the results by duration, distance or
+// stops and limit caps their number. Itineraries beaten on departure,
+// arrival, stops and distance alike by another one are left out.
+//
+// The itineraries can be downloaded as an iCalendar file with one event per
+// leg or as CSV with one row per leg, selected by the format query parameter
+// or the Accept header. itinerary then picks a single result, numbered from 1.
+func (ch *ConnectionHandler) GetConnections(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormatFromRequest(w, r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	query := services.ConnectionQuery{
+		From:       q.Get("from"),
+		To:         q.Get("to"),
+		MaxStops:   services.DefaultMaxStops,
+		MaxLayover: services.DefaultMaxLayover,
+		Sort:       q.Get("sort"),
+	}
+	for _, param := range []string{"from", "to", "date"} {
+		if q.Get(param) == "" {
+			newErrorResponse(w, fmt.Errorf("missing %s", param), http.StatusBadRequest)
+			return
+		}
+	}
+
+	date, err := time.Parse(time.DateOnly, q.Get("date"))
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("invalid date %q: must be a date (YYYY-MM-DD)", q.Get("date")), http.StatusBadRequest)
+		return
+	}
+	query.Date = date
+
+	var maxLayover, itinerary int
+	for _, p := range []struct {
+		name  string
+		value *int
+	}{
+		{"maxStops", &query.MaxStops},
+		{"maxLayover", &maxLayover},
+		{"limit", &query.Limit},
+		{"itinerary", &itinerary},
+	} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		if *p.value, err = strconv.Atoi(v); err != nil {
+			newErrorResponse(w, fmt.Errorf("invalid %s %q: must be an integer", p.name, v), http.StatusBadRequest)
+			return
+		}
+	}
+	if q.Get("maxLayover") != "" {
+		query.MaxLayover = time.Duration(maxLayover) * time.Minute
+	}
+
+	res, err := ch.service.SearchConnections(r.Context(), query)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if format == formatJSON {
+		newJSONResponse(w, res, http.StatusOK)
+		return
+	}
+
+	itineraries := res.Itineraries
+	if itinerary != 0 {
+		if itinerary < 1 || itinerary > len(itineraries) {
+			newErrorResponse(w, services.NotFoundError(fmt.Sprintf("itinerary not found: %d", itinerary)), http.StatusNotFound)
+			return
+		}
+		itineraries = itineraries[itinerary-1 : itinerary]
+	}
+
+	airports, err := ch.service.ItineraryAirports(r.Context(), itineraries)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if format == formatCalendar {
+		err = models.WriteCalendar(&buf, models.ItineraryFlights(itineraries, airports), time.Now())
+	} else {
+		err = models.WriteItinerariesCSV(&buf, itineraries, airports)
+	}
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to export itineraries: %w", err), http.StatusInternalServerError)
+		return
+	}
+	newExportResponse(w, &buf, format, fmt.Sprintf("%s-%s-%s", res.From, res.To, res.Date))
+}
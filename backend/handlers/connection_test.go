@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetConnections(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	// AF200 arrives at JFK (75 minutes minimum connection time) at 12:00Z.
+	at := func(s string) time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	flights := []models.Flight{
+		{FlightNumber: "AA102", AircraftId: 1, Origin: "JFK", Destination: "LAX", DepartureTime: at("2023-10-02T13:30:00Z"), ArrivalTime: at("2023-10-02T19:30:00Z")},
+		{FlightNumber: "AA104", AircraftId: 1, Origin: "JFK", Destination: "LAX", DepartureTime: at("2023-10-02T12:30:00Z"), ArrivalTime: at("2023-10-02T18:30:00Z")},
+		{FlightNumber: "AA106", AircraftId: 1, Origin: "JFK", Destination: "LAX", DepartureTime: at("2023-10-02T20:00:00Z"), ArrivalTime: at("2023-10-03T02:00:00Z")},
+		{FlightNumber: "AF066", AircraftId: 2, Origin: "CDG", Destination: "LAX", DepartureTime: at("2023-10-02T10:00:00Z"), ArrivalTime: at("2023-10-02T21:30:00Z")},
+	}
+	_, err = db.NewInsert().Model(&flights).Exec(ctx)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	NewConnectionHandler(services.NewConnectionService(db)).Register(r)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expected       []string
+	}{
+		{name: "By duration", query: "from=CDG&to=LAX&date=2023-10-02", expectedStatus: http.StatusOK, expected: []string{"AF200+AA102", "AF066"}},
+		{name: "By stops", query: "from=CDG&to=LAX&date=2023-10-02&sort=stops", expectedStatus: http.StatusOK, expected: []string{"AF066", "AF200+AA102"}},
+		{name: "By distance", query: "from=CDG&to=LAX&date=2023-10-02&sort=distance", expectedStatus: http.StatusOK, expected: []string{"AF066", "AF200+AA102"}},
+		{name: "Nonstop only", query: "from=CDG&to=LAX&date=2023-10-02&maxStops=0", expectedStatus: http.StatusOK, expected: []string{"AF066"}},
+		{name: "Short layover", query: "from=CDG&to=LAX&date=2023-10-02&maxLayover=80", expectedStatus: http.StatusOK, expected: []string{"AF066"}},
+		{name: "Later connection beaten", query: "from=CDG&to=LAX&date=2023-10-02&maxLayover=600", expectedStatus: http.StatusOK, expected: []string{"AF200+AA102", "AF066"}},
+		{name: "Limit", query: "from=CDG&to=LAX&date=2023-10-02&limit=1", expectedStatus: http.StatusOK, expected: []string{"AF200+AA102"}},
+		{name: "Metro areas", query: "from=PAR&to=LAX&date=2023-10-02&maxStops=0", expectedStatus: http.StatusOK, expected: []string{"AF066"}},
+		{name: "Local date", query: "from=LAX&to=PAR&date=2023-10-03", expectedStatus: http.StatusOK, expected: []string{"DL300"}},
+		{name: "No flights", query: "from=CDG&to=LAX&date=2023-10-05", expectedStatus: http.StatusOK, expected: nil},
+		{name: "Missing date", query: "from=CDG&to=LAX", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid date", query: "from=CDG&to=LAX&date=tomorrow", expectedStatus: http.StatusBadRequest},
+		{name: "Too many stops", query: "from=CDG&to=LAX&date=2023-10-02&maxStops=5", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid layover", query: "from=CDG&to=LAX&date=2023-10-02&maxLayover=0", expectedStatus: http.StatusBadRequest},
+		{name: "Unknown sort", query: "from=CDG&to=LAX&date=2023-10-02&sort=price", expectedStatus: http.StatusBadRequest},
+		{name: "Same airport", query: "from=NYC&to=JFK&date=2023-10-02", expectedStatus: http.StatusBadRequest},
+		{name: "Unknown airport", query: "from=CDG&to=XXX&date=2023-10-02", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "/api/v1/connections?"+tt.query, http.NoBody)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var res models.Connections
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			var itineraries []string
+			for _, it := range res.Itineraries {
+				var numbers []string
+				for _, leg := range it.Legs {
+					numbers = append(numbers, leg.FlightNumber)
+				}
+				itineraries = append(itineraries, strings.Join(numbers, "+"))
+				assert.Equal(t, len(it.Legs)-1, it.Stops)
+				assert.Len(t, it.Layovers, it.Stops)
+			}
+			assert.Equal(t, tt.expected, itineraries)
+		})
+	}
+
+	// The layover at JFK is reported with the itinerary.
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/connections?from=CDG&to=LAX&date=2023-10-02&maxStops=1&limit=1", http.NoBody)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	var res models.Connections
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	require.Len(t, res.Itineraries, 1)
+	assert.Equal(t, 2, res.Total)
+	assert.Equal(t, 630, res.Itineraries[0].DurationMinutes)
+	assert.Equal(t, []models.Layover{{Airport: "JFK", Minutes: 90}}, res.Itineraries[0].Layovers)
+
+	// In a dense network every departure only keeps its first connection.
+	var shuttles []models.Flight
+	for d := at("2023-10-10T06:00:00Z"); d.Before(at("2023-10-10T18:00:00Z")); d = d.Add(10 * time.Minute) {
+		shuttles = append(shuttles, models.Flight{FlightNumber: "AF001", AircraftId: 2, Origin: "CDG", Destination: "JFK", DepartureTime: d, ArrivalTime: d.Add(8 * time.Hour)})
+		shuttles = append(shuttles, models.Flight{FlightNumber: "AA001", AircraftId: 1, Origin: "JFK", Destination: "LAX", DepartureTime: d.Add(6 * time.Hour), ArrivalTime: d.Add(12 * time.Hour)})
+	}
+	_, err = db.NewInsert().Model(&shuttles).Exec(ctx)
+	require.NoError(t, err)
+
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/connections?from=CDG&to=LAX&date=2023-10-10&maxLayover=1440&limit=100", http.NoBody)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	res = models.Connections{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	// Only AF001 departing up to 14:30Z make the last AA001 at 23:50Z.
+	assert.Equal(t, 52, res.Total)
+	for _, it := range res.Itineraries {
+		assert.Equal(t, []models.Layover{{Airport: "JFK", Minutes: 80}}, it.Layovers)
+	}
+}
+
+func TestExportConnections(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	at := func(s string) time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	flights := []models.Flight{
+		{FlightNumber: "AA102", AircraftId: 1, Origin: "JFK", Destination: "LAX", DepartureTime: at("2023-10-02T13:30:00Z"), ArrivalTime: at("2023-10-02T19:30:00Z")},
+		{FlightNumber: "AF066", AircraftId: 2, Origin: "CDG", Destination: "LAX", DepartureTime: at("2023-10-02T10:00:00Z"), ArrivalTime: at("2023-10-02T21:30:00Z")},
+	}
+	_, err = db.NewInsert().Model(&flights).Exec(ctx)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	NewConnectionHandler(services.NewConnectionService(db)).Register(r)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/connections?from=CDG&to=LAX&date=2023-10-02&"+query, http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Calendar of one itinerary", func(t *testing.T) {
+		rr := get("format=ics&itinerary=1")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, "text/calendar; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Equal(t, "attachment; filename=CDG-LAX-2023-10-02.ics", rr.Header().Get("Content-Disposition"))
+
+		body := strings.ReplaceAll(rr.Body.String(), "\r\n ", "")
+		assert.Equal(t, 2, strings.Count(body, "BEGIN:VEVENT"))
+		assert.Contains(t, body, "SUMMARY:AF200 CDG-JFK")
+		assert.Contains(t, body, "DTSTART;TZID=Europe/Paris:20231002T110000")
+		assert.Contains(t, body, "SUMMARY:AA102 JFK-LAX")
+		assert.Contains(t, body, "DTEND;TZID=America/Los_Angeles:20231002T123000")
+		assert.NotContains(t, body, "AF066")
+	})
+
+	t.Run("CSV of all itineraries", func(t *testing.T) {
+		rr := get("format=csv")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 4)
+		// itinerary, leg, flight number, local departure and layover.
+		legs := [][]string{}
+		for _, rec := range records[1:] {
+			legs = append(legs, []string{rec[0], rec[1], rec[3], rec[8], rec[11]})
+		}
+		assert.Equal(t, [][]string{
+			{"1", "1", "AF200", "2023-10-02T11:00:00+02:00", ""},
+			{"1", "2", "AA102", "2023-10-02T09:30:00-04:00", "90"},
+			{"2", "1", "AF066", "2023-10-02T12:00:00+02:00", ""},
+		}, legs)
+	})
+
+	t.Run("Unknown itinerary", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("format=ics&itinerary=3").Code)
+		assert.Equal(t, http.StatusBadRequest, get("format=ics&itinerary=first").Code)
+	})
+}
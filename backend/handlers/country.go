@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+var _ Handler = (*CountryHandler)(nil)
+
+type CountryHandler struct {
+	service *services.CountryService
+}
+
+func NewCountryHandler(svc *services.CountryService) *CountryHandler {
+	return &CountryHandler{service: svc}
+}
+
+func (ch *CountryHandler) Register(r *mux.Router) {
+	r.HandleFunc(fmt.Sprintf("%s/countries", basePathV1), ch.getCountries).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetCountries")
+	r.HandleFunc(fmt.Sprintf("%s/continents", basePathV1), ch.getContinents).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetContinents")
+}
+
+func (ch *CountryHandler) getCountries(w http.ResponseWriter, r *http.Request) {
+	continent := r.URL.Query().Get("continent")
+	region := r.URL.Query().Get("region")
+
+	res, err := ch.service.ListCountries(r.Context(), continent, region)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+func (ch *CountryHandler) getContinents(w http.ResponseWriter, r *http.Request) {
+	res, err := ch.service.ListContinents(r.Context())
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
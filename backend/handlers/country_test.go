@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountries(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewCountryHandler(services.NewCountryService(db)).Register(r)
+
+	// Test case 1: All countries
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/countries", http.NoBody)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var countries []models.Country
+	json.Unmarshal(rr.Body.Bytes(), &countries)
+	assert.Equal(t, 249, len(countries))
+
+	// Test case 2: Filter by continent name and region
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/countries?continent=europe&region=europe", http.NoBody)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	countries = nil
+	json.Unmarshal(rr.Body.Bytes(), &countries)
+	counts := map[string]int{}
+	for _, c := range countries {
+		assert.Equal(t, "EU", c.ContinentCode)
+		counts[c.Alpha2] = c.AirportCount
+	}
+	assert.Equal(t, 1, counts["FR"])
+	assert.Equal(t, 1, counts["DE"])
+	assert.Equal(t, 0, counts["BE"])
+
+	// Test case 3: Continents with airport counts
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/continents", http.NoBody)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var continents []models.Continent
+	json.Unmarshal(rr.Body.Bytes(), &continents)
+	assert.Equal(t, 7, len(continents))
+	for _, c := range continents {
+		if c.Code == "EU" {
+			assert.Equal(t, 3, c.AirportCount)
+		}
+	}
+}
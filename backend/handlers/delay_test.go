@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimulateDelay(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	// N921AN flies AA100 (JFK-LAX, 08:00Z-11:00Z), AA101 back to JFK and
+	// AA103 to LAX again with an hour to turn around each time. UA500 leaves
+	// LAX exactly the 90 minutes minimum connection time after AA100 arrived.
+	at := func(s string) time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	flights := []models.Flight{
+		{FlightNumber: "AA101", AircraftId: 1, AirframeId: 1, Origin: "LAX", Destination: "JFK", DepartureTime: at("2023-10-01T12:00:00Z"), ArrivalTime: at("2023-10-01T17:00:00Z")},
+		{FlightNumber: "AA103", AircraftId: 1, AirframeId: 1, Origin: "JFK", Destination: "LAX", DepartureTime: at("2023-10-01T18:00:00Z"), ArrivalTime: at("2023-10-01T21:00:00Z")},
+		{FlightNumber: "UA500", AircraftId: 2, AirframeId: 2, Origin: "LAX", Destination: "CDG", DepartureTime: at("2023-10-01T12:30:00Z"), ArrivalTime: at("2023-10-01T23:30:00Z")},
+		{FlightNumber: "UA502", AircraftId: 1, Origin: "LAX", Destination: "NGO", DepartureTime: at("2023-10-01T19:00:00Z"), ArrivalTime: at("2023-10-02T06:00:00Z")},
+	}
+	_, err = db.NewInsert().Model(&flights).Exec(ctx)
+	require.NoError(t, err)
+
+	svc := services.NewFlightService(db)
+	require.NoError(t, svc.RecordFlightEvent(ctx, 3, &models.FlightEvent{Status: models.FlightCancelled}))
+
+	r := mux.NewRouter()
+	NewFleetHandler(services.NewFleetService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	simulate := func(token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/fleet/delay-simulations", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	type delay struct {
+		flight        string
+		cause         models.DelayCause
+		delay, added  int
+		causedBy, dep int64
+	}
+	run := func(t *testing.T, body string) ([]delay, models.DelaySimulation) {
+		rr := simulate("secret", body)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var res models.DelaySimulation
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+		delays := []delay{}
+		for _, f := range res.Flights {
+			delays = append(delays, delay{f.FlightNumber, f.Cause, f.DelayMinutes, f.AddedDelayMinutes, f.CausedBy, int64(f.Depth)})
+		}
+		return delays, res
+	}
+
+	t.Run("Rotation and missed connection", func(t *testing.T) {
+		// Without max_hold_minutes UA500 is not held for AA100.
+		delays, res := run(t, `{"flight_id":1,"delay_minutes":90}`)
+		assert.Equal(t, []delay{
+			{"AA100", models.DelayInjected, 90, 90, 0, 0},
+			{"AA101", models.DelayRotation, 60, 60, 1, 1},
+			{"AA103", models.DelayRotation, 30, 30, 4, 2},
+		}, delays)
+		assert.Equal(t, 180, res.TotalAddedDelayMinutes)
+		assert.Equal(t, "2023-10-01T09:30:00Z", res.From.Format(time.RFC3339))
+		assert.Equal(t, "2023-10-02T09:30:00Z", res.To.Format(time.RFC3339))
+		assert.Equal(t, "2023-10-01T13:00:00Z", res.Flights[1].ProjectedDeparture.Format(time.RFC3339))
+		assert.Equal(t, "2023-10-01T18:00:00Z", res.Flights[1].ProjectedArrival.Format(time.RFC3339))
+
+		require.Len(t, res.MissedConnections, 1)
+		m := res.MissedConnections[0]
+		assert.Equal(t, "LAX", m.Airport)
+		assert.Equal(t, "AA100", m.ArrivingFlightNumber)
+		assert.Equal(t, "UA500", m.DepartingFlightNumber)
+		assert.Equal(t, 90, m.ShortMinutes)
+	})
+
+	t.Run("Held connection", func(t *testing.T) {
+		delays, res := run(t, `{"flight_id":1,"delay_minutes":90,"max_hold_minutes":120}`)
+		assert.Equal(t, []delay{
+			{"AA100", models.DelayInjected, 90, 90, 0, 0},
+			{"AA101", models.DelayRotation, 60, 60, 1, 1},
+			{"UA500", models.DelayConnection, 90, 90, 1, 1},
+			{"AA103", models.DelayRotation, 30, 30, 4, 2},
+		}, delays)
+		assert.Equal(t, 270, res.TotalAddedDelayMinutes)
+		assert.Empty(t, res.MissedConnections)
+	})
+
+	t.Run("Only the added delay counts", func(t *testing.T) {
+		// With 90 minutes to turn around AA101 and AA103 are late anyway.
+		delays, _ := run(t, `{"flight_id":1,"delay_minutes":90,"min_turnaround_minutes":90}`)
+		assert.Equal(t, []delay{
+			{"AA100", models.DelayInjected, 90, 90, 0, 0},
+			{"AA101", models.DelayRotation, 120, 90, 1, 1},
+			{"AA103", models.DelayRotation, 150, 90, 4, 2},
+		}, delays)
+	})
+
+	t.Run("Absorbed delay", func(t *testing.T) {
+		// 30 minutes fit into the turnaround, but UA500 has no slack.
+		delays, res := run(t, `{"flight_id":1,"delay_minutes":30,"horizon_hours":6}`)
+		assert.Equal(t, []delay{{"AA100", models.DelayInjected, 30, 30, 0, 0}}, delays)
+		require.Len(t, res.MissedConnections, 1)
+		assert.Equal(t, 30, res.MissedConnections[0].ShortMinutes)
+	})
+
+	t.Run("Flights are not changed", func(t *testing.T) {
+		var flight models.Flight
+		require.NoError(t, db.NewSelect().Model(&flight).Where("flight_number = 'AA101'").Scan(ctx))
+		assert.Equal(t, "2023-10-01T12:00:00Z", flight.DepartureTime.UTC().Format(time.RFC3339))
+		assert.Nil(t, flight.EstimatedDepartureTime)
+	})
+
+	t.Run("Invalid scenarios", func(t *testing.T) {
+		for body, expected := range map[string]int{
+			`{"flight_id":999,"delay_minutes":30}`:                     http.StatusNotFound,
+			`{"flight_id":3,"delay_minutes":30}`:                       http.StatusConflict,
+			`{"flight_id":1,"delay_minutes":0}`:                        http.StatusUnprocessableEntity,
+			`{"flight_id":1,"delay_minutes":30,"horizon_hours":100}`:   http.StatusUnprocessableEntity,
+			`{"flight_id":1,"delay_minutes":30,"max_hold_minutes":-1}`: http.StatusUnprocessableEntity,
+			`{"flight_id":1,"minutes":30}`:                             http.StatusBadRequest,
+		} {
+			rr := simulate("secret", body)
+			assert.Equal(t, expected, rr.Code, body+": "+rr.Body.String())
+		}
+		assert.Equal(t, http.StatusUnauthorized, simulate("", `{"flight_id":1,"delay_minutes":30}`).Code)
+	})
+}
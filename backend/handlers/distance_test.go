@@ -73,6 +73,11 @@ func TestCalculateDistance(t *testing.T) {
 			requestBody:    models.DistanceRequest{Departure: "JFK", Destination: "XXX"},
 			expectedStatus: http.StatusNotFound,
 		},
+		{
+			name:           "Invalid pairs option",
+			requestBody:    models.DistanceRequest{Departure: "JFK", Destination: "LAX", Pairs: "some"},
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -94,3 +99,86 @@ func TestCalculateDistance(t *testing.T) {
 		})
 	}
 }
+
+func TestCalculateMetroAreaDistance(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	ewr := &models.Airport{IATA: "EWR", Name: "Newark Liberty International Airport", CountryCode: "US", MetroCode: "NYC", Latitude: 40.6895, Longitude: -74.1745}
+	if err := services.NewAirportService(db).CreateAirport(ctx, ewr); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewDistanceHandler(services.NewDistanceCalculator(db))
+
+	tests := []struct {
+		name                string
+		requestBody         models.DistanceRequest
+		expectedStatus      int
+		expectedDeparture   string
+		expectedDestination string
+		expectedPairs       [][2]string
+	}{
+		{
+			name:                "Closest pair between metro areas",
+			requestBody:         models.DistanceRequest{Departure: "NYC", Destination: "PAR"},
+			expectedStatus:      http.StatusOK,
+			expectedDeparture:   "JFK",
+			expectedDestination: "CDG",
+		},
+		{
+			name:                "All pairs from a metro area to an airport",
+			requestBody:         models.DistanceRequest{Departure: "LAX", Destination: "nyc", Pairs: models.PairsAll},
+			expectedStatus:      http.StatusOK,
+			expectedDeparture:   "LAX",
+			expectedDestination: "EWR",
+			expectedPairs:       [][2]string{{"LAX", "EWR"}, {"LAX", "JFK"}},
+		},
+		{
+			name:           "Same metro area",
+			requestBody:    models.DistanceRequest{Departure: "NYC", Destination: "NYC", Pairs: models.PairsAll},
+			expectedStatus: http.StatusOK,
+			expectedPairs:  [][2]string{{"EWR", "JFK"}, {"JFK", "EWR"}},
+		},
+		{
+			name:           "Metro area without airports",
+			requestBody:    models.DistanceRequest{Departure: "LON", Destination: "PAR"},
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(tt.requestBody)
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodPost, "/routes", bytes.NewBuffer(body))
+			rr := httptest.NewRecorder()
+			handler.CalculateDistance(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response models.DistanceData
+			json.NewDecoder(rr.Body).Decode(&response)
+			if tt.expectedDeparture != "" {
+				assert.Equal(t, tt.expectedDeparture, response.Departure)
+				assert.Equal(t, tt.expectedDestination, response.Destination)
+			}
+			var pairs [][2]string
+			for _, p := range response.Pairs {
+				pairs = append(pairs, [2]string{p.Departure, p.Destination})
+			}
+			assert.ElementsMatch(t, tt.expectedPairs, pairs)
+			for i := 1; i < len(response.Pairs); i++ {
+				assert.LessOrEqual(t, response.Pairs[i-1].Distances["km"], response.Pairs[i].Distances["km"])
+			}
+		})
+	}
+}
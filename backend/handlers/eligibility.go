@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+var _ Handler = (*EligibilityHandler)(nil)
+
+type EligibilityHandler struct {
+	service *services.EligibilityService
+}
+
+func NewEligibilityHandler(svc *services.EligibilityService) *EligibilityHandler {
+	return &EligibilityHandler{service: svc}
+}
+
+func (eh *EligibilityHandler) Register(r *mux.Router) {
+	r.HandleFunc(fmt.Sprintf("%s/routes/{from:[A-Za-z]{3}}-{to:[A-Za-z]{3}}/aircraft", basePathV1), eh.getEligibleAircraft).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetEligibleAircraft")
+}
+
+// getEligibleAircraft lists the aircraft able to fly between two airports or
+// metropolitan areas. The optional reserve query parameter adds a fraction of
+// the distance to the required range, payload (kg) checks the range against
+// the payload-range diagrams. maxStops plans up to that many technical stops
+// for aircraft that cannot fly the route nonstop. The aircraft list filters
+// apply as well.
+func (eh *EligibilityHandler) getEligibleAircraft(w http.ResponseWriter, r *http.Request) {
+	filter, err := aircraftFilterFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var opts services.EligibilityOptions
+	for _, p := range []struct {
+		name  string
+		value *float64
+	}{
+		{"reserve", &opts.Reserve},
+		{"payload", &opts.PayloadKg},
+	} {
+		v := r.URL.Query().Get(p.name)
+		if v == "" {
+			continue
+		}
+		if *p.value, err = strconv.ParseFloat(v, 64); err != nil {
+			newErrorResponse(w, fmt.Errorf("invalid %s %q: must be a number", p.name, v), http.StatusBadRequest)
+			return
+		}
+	}
+	if v := r.URL.Query().Get("maxStops"); v != "" {
+		if opts.MaxStops, err = strconv.Atoi(v); err != nil {
+			newErrorResponse(w, fmt.Errorf("invalid maxStops %q: must be an integer", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	vars := mux.Vars(r)
+	res, err := eh.service.EligibleAircraft(r.Context(), vars["from"], vars["to"], opts, filter)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
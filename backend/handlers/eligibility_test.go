@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEligibleAircraft(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	// Anchorage is a technical stop on the way from New York to Asia.
+	anc := models.Airport{IATA: "ANC", ICAO: "PANC", Name: "Ted Stevens Anchorage International Airport", City: "Anchorage", Latitude: 61.1743, Longitude: -149.996, TimeZone: "America/Anchorage"}
+	_, err = db.NewInsert().Model(&anc).Exec(ctx)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	NewEligibilityHandler(services.NewEligibilityService(db)).Register(r)
+
+	tests := []struct {
+		name             string
+		path             string
+		expectedStatus   int
+		expectedAircraft []string
+		expectedStops    map[string][]string
+	}{
+		{name: "Domestic route", path: "/api/v1/routes/JFK-LAX/aircraft", expectedStatus: http.StatusOK, expectedAircraft: []string{"Airbus A320", "Boeing 737", "Gulfstream G650", "Antonov An-225"}},
+		{name: "With reserve", path: "/api/v1/routes/jfk-lax/aircraft?reserve=0.5", expectedStatus: http.StatusOK, expectedAircraft: []string{"Boeing 737", "Gulfstream G650", "Antonov An-225"}},
+		{name: "Long haul", path: "/api/v1/routes/JFK-PVG/aircraft", expectedStatus: http.StatusOK, expectedAircraft: []string{"Gulfstream G650", "Antonov An-225"}},
+		{name: "With aircraft filter", path: "/api/v1/routes/JFK-LAX/aircraft?minSeats=100&manufacturer=Boeing", expectedStatus: http.StatusOK, expectedAircraft: []string{"Boeing 737"}},
+		{name: "Metro areas", path: "/api/v1/routes/NYC-PAR/aircraft", expectedStatus: http.StatusOK, expectedAircraft: []string{"Airbus A320", "Boeing 737", "Gulfstream G650", "Antonov An-225"}},
+		{name: "Heavy payload", path: "/api/v1/routes/JFK-PVG/aircraft?payload=50000", expectedStatus: http.StatusOK, expectedAircraft: []string{"Antonov An-225"}},
+		{name: "Light payload", path: "/api/v1/routes/JFK-PVG/aircraft?payload=1000", expectedStatus: http.StatusOK, expectedAircraft: []string{"Gulfstream G650", "Antonov An-225"}},
+		{name: "Payload beyond every envelope", path: "/api/v1/routes/JFK-LAX/aircraft?payload=300000", expectedStatus: http.StatusOK, expectedAircraft: nil},
+		{name: "One stop is not enough", path: "/api/v1/routes/JFK-PVG/aircraft?maxStops=1", expectedStatus: http.StatusOK, expectedAircraft: []string{"Gulfstream G650", "Antonov An-225"}},
+		{name: "Technical stops", path: "/api/v1/routes/JFK-PVG/aircraft?maxStops=2", expectedStatus: http.StatusOK,
+			expectedAircraft: []string{"Gulfstream G650", "Antonov An-225", "Airbus A320", "Boeing 737"},
+			expectedStops:    map[string][]string{"Boeing 737": {"ANC", "NGO"}, "Airbus A320": {"ANC", "NGO"}}},
+		{name: "Technical stops with payload", path: "/api/v1/routes/JFK-PVG/aircraft?maxStops=3&payload=3000", expectedStatus: http.StatusOK,
+			expectedAircraft: []string{"Antonov An-225", "Boeing 737"},
+			expectedStops:    map[string][]string{"Boeing 737": {"ANC", "NGO"}}},
+		{name: "Too many stops", path: "/api/v1/routes/JFK-PVG/aircraft?maxStops=4", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid stops", path: "/api/v1/routes/JFK-PVG/aircraft?maxStops=one", expectedStatus: http.StatusBadRequest},
+		{name: "Negative payload", path: "/api/v1/routes/JFK-LAX/aircraft?payload=-1", expectedStatus: http.StatusBadRequest},
+		{name: "Reserve out of range", path: "/api/v1/routes/JFK-LAX/aircraft?reserve=2", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid reserve", path: "/api/v1/routes/JFK-LAX/aircraft?reserve=lots", expectedStatus: http.StatusBadRequest},
+		{name: "Unknown airport", path: "/api/v1/routes/JFK-XXX/aircraft", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, tt.path, http.NoBody)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var res models.RouteAircraft
+			json.Unmarshal(rr.Body.Bytes(), &res)
+			var names []string
+			for _, a := range res.Aircraft {
+				names = append(names, a.Name)
+				assert.GreaterOrEqual(t, a.MarginNm, 0.0)
+				usable := float64(a.RangeNm)
+				if res.PayloadKg > 0 {
+					usable = a.RangeAtPayloadNm
+					assert.GreaterOrEqual(t, a.MaxPayloadKg, res.PayloadKg)
+				}
+				required := res.RequiredRangeNm
+				if len(a.Stops) > 0 {
+					required = a.LongestLegNm * (1 + res.ReserveFactor)
+					assert.Less(t, required, res.RequiredRangeNm)
+				}
+				assert.InDelta(t, usable-required, a.MarginNm, 0.001)
+				assert.Equal(t, tt.expectedStops[a.Name], a.Stops, a.Name)
+			}
+			assert.Equal(t, tt.expectedAircraft, names)
+			assert.InDelta(t, res.DistanceNm*(1+res.ReserveFactor), res.RequiredRangeNm, 0.001)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	streamHeartbeatInterval = 15 * time.Second
+	streamWriteTimeout      = 10 * time.Second
+)
+
+// eventStream writes server-sent events to a client.
+type eventStream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// newEventStream starts a server-sent event response. It returns false if
+// the response cannot be flushed, e.g. because the client went away.
+func newEventStream(w http.ResponseWriter) (*eventStream, bool) {
+	s := &eventStream{w: w, rc: http.NewResponseController(w)}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	return s, s.rc.Flush() == nil
+}
+
+// send writes v as JSON in an event of the given type. It returns false if
+// the event could not be delivered.
+func (s *eventStream) send(event string, v any) bool {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false
+	}
+	return s.write("event: %s\ndata: %s\n\n", event, data)
+}
+
+// heartbeat writes a comment that keeps idle connections open.
+func (s *eventStream) heartbeat() bool {
+	return s.write(": heartbeat\n\n")
+}
+
+// write sends a frame. A client that stops reading must not hold the handler
+// forever, so every write gets a deadline. Connections that do not support
+// deadlines are written to without one.
+func (s *eventStream) write(format string, args ...any) bool {
+	_ = s.rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	if _, err := fmt.Fprintf(s.w, format, args...); err != nil {
+		return false
+	}
+	return s.rc.Flush() == nil
+}
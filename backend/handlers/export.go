@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+// Formats a list can be downloaded in besides the default JSON.
+const (
+	formatJSON     = "json"
+	formatCalendar = "ics"
+	formatCSV      = "csv"
+)
+
+// formatMediaTypes maps the formats to their media type.
+var formatMediaTypes = map[string]string{
+	formatJSON:     "application/json",
+	formatCalendar: "text/calendar",
+	formatCSV:      "text/csv",
+}
+
+// exportFormats lists the formats in the order they are preferred in when the
+// Accept header rates several of them the same.
+var exportFormats = []string{formatJSON, formatCalendar, formatCSV}
+
+// exportFormatFromRequest returns the format of the response, either from the
+// format query parameter or negotiated from the Accept header. It defaults to
+// JSON.
+func exportFormatFromRequest(w http.ResponseWriter, r *http.Request) (string, error) {
+	w.Header().Add("Vary", "Accept")
+
+	if f := r.URL.Query().Get("format"); f != "" {
+		format := strings.ToLower(f)
+		if _, ok := formatMediaTypes[format]; !ok {
+			return "", fmt.Errorf("invalid format %q: must be one of json, ics or csv", f)
+		}
+		return format, nil
+	}
+
+	ranges := acceptFromRequest(r)
+	if len(ranges) == 0 {
+		return formatJSON, nil
+	}
+	format, ok := negotiateFormat(ranges)
+	if !ok {
+		return "", services.NotAcceptableError("none of the accepted media types is supported: must be one of application/json, text/calendar or text/csv")
+	}
+	return format, nil
+}
+
+// mediaRange is a media range of an Accept header with its quality.
+type mediaRange struct {
+	mediaType string // type/subtype, type/* or */*
+	q         float64
+}
+
+// specificity ranks how closely the range matches mediaType: 2 for the
+// media type itself, 1 for its type/*, 0 for */* and -1 if it does not match.
+func (m mediaRange) specificity(mediaType string) int {
+	switch {
+	case m.mediaType == mediaType:
+		return 2
+	case m.mediaType == "*/*":
+		return 0
+	}
+	if typ, ok := strings.CutSuffix(m.mediaType, "/*"); ok && strings.HasPrefix(mediaType, typ+"/") {
+		return 1
+	}
+	return -1
+}
+
+// acceptFromRequest parses the media ranges of the Accept header, ordered by
+// their quality and then by their position. Malformed ranges are skipped.
+func acceptFromRequest(r *http.Request) []mediaRange {
+	var ranges []mediaRange
+	for _, accept := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			m := mediaRange{mediaType: mediaType, q: 1}
+			if q, ok := params["q"]; ok {
+				if m.q, err = strconv.ParseFloat(q, 64); err != nil || m.q < 0 || m.q > 1 {
+					continue
+				}
+			}
+			ranges = append(ranges, m)
+		}
+	}
+	slices.SortStableFunc(ranges, func(a, b mediaRange) int {
+		return cmp.Compare(b.q, a.q)
+	})
+	return ranges
+}
+
+// negotiateFormat returns the format the ranges accept best. A format takes
+// the quality of the most specific range matching its media type, so that
+// text/csv;q=0 excludes CSV even if text/* is accepted. Formats with a zero
+// quality are never chosen.
+func negotiateFormat(ranges []mediaRange) (string, bool) {
+	best, bestRank := "", len(ranges)
+	for _, format := range exportFormats {
+		rank, specificity := -1, -1
+		for i, m := range ranges {
+			if s := m.specificity(formatMediaTypes[format]); s > specificity {
+				rank, specificity = i, s
+			}
+		}
+		if rank < 0 || ranges[rank].q == 0 {
+			continue
+		}
+		// The ranges are sorted by quality, so a lower rank is preferred.
+		if rank < bestRank {
+			best, bestRank = format, rank
+		}
+	}
+	return best, best != ""
+}
+
+// newFileResponse writes buf as a downloadable file.
+func newFileResponse(w http.ResponseWriter, buf *bytes.Buffer, contentType, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.WriteHeader(http.StatusOK)
+	_, _ = buf.WriteTo(w)
+}
+
+// newExportResponse writes buf as a downloadable file of the given format,
+// named after name.
+func newExportResponse(w http.ResponseWriter, buf *bytes.Buffer, format, name string) {
+	newFileResponse(w, buf, formatMediaTypes[format]+"; charset=utf-8", name+"."+format)
+}
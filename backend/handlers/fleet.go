@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+// rotationJobRetryAfter is when clients are asked to retry starting a
+// rotation job while too many are running.
+const rotationJobRetryAfter = 10 * time.Second
+
+var _ Handler = (*FleetHandler)(nil)
+
+type FleetHandler struct {
+	service *services.FleetService
+	auth    Middleware
+}
+
+// NewFleetHandler creates the fleet handler. auth protects starting rotation
+// jobs and delay simulations, a nil auth disables them.
+func NewFleetHandler(svc *services.FleetService, auth Middleware) *FleetHandler {
+	return &FleetHandler{service: svc, auth: auth}
+}
+
+func (fh *FleetHandler) Register(r *mux.Router) {
+	r.HandleFunc(fmt.Sprintf("%s/fleet/conflicts", basePathV1), fh.GetConflicts).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetFleetConflicts")
+	r.HandleFunc(fmt.Sprintf("%s/fleet/rotations/{id:[0-9a-f]+}", basePathV1), fh.GetRotationJob).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetRotationJob")
+
+	r.Handle(fmt.Sprintf("%s/fleet/rotations", basePathV1), protect(fh.auth, fh.CreateRotationJob)).
+		Methods(http.MethodPost).
+		Name("CreateRotationJob")
+	r.Handle(fmt.Sprintf("%s/fleet/delay-simulations", basePathV1), protect(fh.auth, fh.SimulateDelay)).
+		Methods(http.MethodPost).
+		Name("SimulateDelay")
+}
+
+// GetConflicts reports flights their airframes cannot operate because they
+// overlap with, depart away from or turn around too quickly after the
+// previous flight of the airframe. airframeId limits the report to one
+// airframe, from and to (a date or an RFC 3339 time, a date in to includes
+// that day) to flights departing within a window.
+func (fh *FleetHandler) GetConflicts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var filter services.FleetConflictFilter
+
+	if v := q.Get("airframeId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			newErrorResponse(w, fmt.Errorf("invalid airframeId %q: must be a positive integer", v), http.StatusBadRequest)
+			return
+		}
+		filter.AirframeId = id
+	}
+	if v := q.Get("from"); v != "" {
+		t, _, err := parseTimeParam(v)
+		if err != nil {
+			newErrorResponse(w, fmt.Errorf("invalid from %q: %w", v, err), http.StatusBadRequest)
+			return
+		}
+		filter.From = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, dateOnly, err := parseTimeParam(v)
+		if err != nil {
+			newErrorResponse(w, fmt.Errorf("invalid to %q: %w", v, err), http.StatusBadRequest)
+			return
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = t
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		newErrorResponse(w, fmt.Errorf("invalid time range: from must be before to"), http.StatusBadRequest)
+		return
+	}
+
+	res, err := fh.service.Conflicts(r.Context(), filter)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+// CreateRotationJob starts computing a tail assignment for the flights of a
+// day. It responds with 202 Accepted and the pending job, which can be polled
+// at the Location header until it succeeded or failed. While too many jobs
+// are running it responds with 503 Service Unavailable and a Retry-After
+// header.
+//
+// The assignment is a greedy heuristic, not an optimum: flights are assigned
+// in order of departure to the airframe needing the shortest ferry leg, which
+// can take more ferry legs than necessary over the whole day.
+func (fh *FleetHandler) CreateRotationJob(w http.ResponseWriter, r *http.Request) {
+	req, err := models.NewRotationRequest(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	job, err := fh.service.StartRotationJob(r.Context(), req)
+	if err != nil {
+		var unavailable services.UnavailableError
+		if errors.As(err, &unavailable) {
+			w.Header().Set("Retry-After", strconv.Itoa(int(rotationJobRetryAfter.Seconds())))
+		}
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/fleet/rotations/%s", basePathV1, job.Id))
+	newJSONResponse(w, job, http.StatusAccepted)
+}
+
+// GetRotationJob returns a rotation job with its plan once it succeeded.
+// Finished jobs are kept for an hour.
+func (fh *FleetHandler) GetRotationJob(w http.ResponseWriter, r *http.Request) {
+	job, err := fh.service.RotationJob(mux.Vars(r)["id"])
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, job, http.StatusOK)
+}
+
+// SimulateDelay runs a what-if scenario: it injects a departure delay on a
+// flight and responds with the flights it would delay and the connections it
+// would break through rotations and connections. No flight is changed.
+// max_hold_minutes defaults to 0: flights are never held for late connecting
+// flights, so the delay only propagates through rotations and every late
+// connection is reported as missed.
+func (fh *FleetHandler) SimulateDelay(w http.ResponseWriter, r *http.Request) {
+	scenario, err := models.NewDelayScenario(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	res, err := fh.service.SimulateDelay(r.Context(), *scenario)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
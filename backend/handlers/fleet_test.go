@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFleetConflicts(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewFlightHandler(services.NewFlightService(db), BearerAuth([]string{"secret"})).Register(r)
+	NewFleetHandler(services.NewFleetService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	// N921AN (airframe 1) operates AA100 from JFK to LAX, arriving at 11:00Z.
+	writes := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+		expectedKind   models.FleetConflictKind
+	}{
+		{name: "Overlap", path: "/api/v1/flights", body: `{"flight_number":"AA101","aircraft_id":1,"airframe_id":1,"origin":"LAX","destination":"JFK","departure_time":"2023-10-01T10:00:00Z","arrival_time":"2023-10-01T15:00:00Z"}`, expectedStatus: http.StatusConflict, expectedKind: models.ConflictOverlap},
+		{name: "Out of position", path: "/api/v1/flights", body: `{"flight_number":"AA101","aircraft_id":1,"airframe_id":1,"origin":"JFK","destination":"LAX","departure_time":"2023-10-01T12:00:00Z","arrival_time":"2023-10-01T18:00:00Z"}`, expectedStatus: http.StatusConflict, expectedKind: models.ConflictPosition},
+		{name: "Short turnaround", path: "/api/v1/flights", body: `{"flight_number":"AA101","aircraft_id":1,"airframe_id":1,"origin":"LAX","destination":"JFK","departure_time":"2023-10-01T11:15:00Z","arrival_time":"2023-10-01T16:15:00Z"}`, expectedStatus: http.StatusConflict, expectedKind: models.ConflictTurnaround},
+		{name: "Before the next flight", path: "/api/v1/flights", body: `{"flight_number":"AA099","aircraft_id":1,"airframe_id":1,"origin":"LAX","destination":"JFK","departure_time":"2023-10-01T02:00:00Z","arrival_time":"2023-10-01T07:45:00Z"}`, expectedStatus: http.StatusConflict, expectedKind: models.ConflictTurnaround},
+		{name: "Feasible", path: "/api/v1/flights", body: `{"flight_number":"AA101","aircraft_id":1,"airframe_id":1,"origin":"LAX","destination":"JFK","departure_time":"2023-10-01T12:00:00Z","arrival_time":"2023-10-01T17:00:00Z"}`, expectedStatus: http.StatusCreated},
+		{name: "Forced overlap", path: "/api/v1/flights?force=true", body: `{"flight_number":"AA103","aircraft_id":1,"airframe_id":1,"origin":"LAX","destination":"JFK","departure_time":"2023-10-01T10:00:00Z","arrival_time":"2023-10-01T15:00:00Z"}`, expectedStatus: http.StatusCreated},
+	}
+
+	for _, tt := range writes {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodPost, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedKind != "" {
+				var res struct {
+					Details []models.FleetConflict `json:"details"`
+				}
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+				require.Len(t, res.Details, 1)
+				assert.Equal(t, tt.expectedKind, res.Details[0].Kind)
+				assert.Equal(t, "N921AN", res.Details[0].Registration)
+			}
+		})
+	}
+
+	// AA103 overlaps AA100 and AA101 departs before AA103 arrived.
+	tests := []struct {
+		name            string
+		query           string
+		expectedStatus  int
+		expectedFlights []string
+	}{
+		{name: "All", expectedStatus: http.StatusOK, expectedFlights: []string{"AA103", "AA101"}},
+		{name: "Other airframe", query: "?airframeId=2", expectedStatus: http.StatusOK, expectedFlights: []string{}},
+		{name: "From", query: "?from=2023-10-01T11:00:00Z", expectedStatus: http.StatusOK, expectedFlights: []string{"AA101"}},
+		{name: "To", query: "?to=2023-09-30", expectedStatus: http.StatusOK, expectedFlights: []string{}},
+		{name: "Invalid airframe", query: "?airframeId=abc", expectedStatus: http.StatusBadRequest},
+		{name: "Reversed window", query: "?from=2023-10-02&to=2023-10-01T00:00:00Z", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "/api/v1/fleet/conflicts"+tt.query, http.NoBody)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var res models.FleetConflicts
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			flights := []string{}
+			for _, c := range res.Conflicts {
+				assert.Equal(t, models.ConflictOverlap, c.Kind)
+				flights = append(flights, c.FlightNumber)
+			}
+			assert.Equal(t, tt.expectedFlights, flights)
+			assert.Equal(t, len(tt.expectedFlights), res.Total)
+		})
+	}
+}
@@ -1,28 +1,505 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
 )
 
 var _ Handler = (*FlightHandler)(nil)
 
-type FlightHandler struct{}
+type FlightHandler struct {
+	service *services.FlightService
+	auth    Middleware
+}
 
-func NewFlightHandler() *FlightHandler {
-	return &FlightHandler{}
+// NewFlightHandler creates the flight handler. auth protects the write
+// endpoints, a nil auth disables them.
+func NewFlightHandler(svc *services.FlightService, auth Middleware) *FlightHandler {
+	return &FlightHandler{service: svc, auth: auth}
 }
 
 func (fh *FlightHandler) Register(r *mux.Router) {
 	r.HandleFunc(fmt.Sprintf("%s/flights", basePathV1), fh.GetFlights).
 		Methods(http.MethodGet, http.MethodOptions).
 		Name("GetFlights")
+	r.HandleFunc(fmt.Sprintf("%s/flights/positions", basePathV1), fh.GetFlightPositions).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetFlightPositions")
+	r.HandleFunc(fmt.Sprintf("%s/flights/stream", basePathV1), fh.StreamFlights).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("StreamFlights")
+	r.HandleFunc(fmt.Sprintf("%s/flights/{id:[0-9]+}", basePathV1), fh.GetFlight).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetFlight")
+	r.HandleFunc(fmt.Sprintf("%s/flights/{id:[0-9]+}/events", basePathV1), fh.GetFlightEvents).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetFlightEvents")
+
+	r.Handle(fmt.Sprintf("%s/flights", basePathV1), protect(fh.auth, fh.CreateFlight)).
+		Methods(http.MethodPost).
+		Name("CreateFlight")
+	r.Handle(fmt.Sprintf("%s/flights/{id:[0-9]+}", basePathV1), protect(fh.auth, fh.ReplaceFlight)).
+		Methods(http.MethodPut).
+		Name("ReplaceFlight")
+	r.Handle(fmt.Sprintf("%s/flights/{id:[0-9]+}", basePathV1), protect(fh.auth, fh.PatchFlight)).
+		Methods(http.MethodPatch).
+		Name("PatchFlight")
+	r.Handle(fmt.Sprintf("%s/flights/{id:[0-9]+}", basePathV1), protect(fh.auth, fh.DeleteFlight)).
+		Methods(http.MethodDelete).
+		Name("DeleteFlight")
+	r.Handle(fmt.Sprintf("%s/flights/{id:[0-9]+}/events", basePathV1), protect(fh.auth, fh.CreateFlightEvent)).
+		Methods(http.MethodPost).
+		Name("CreateFlightEvent")
+	// Registered after the id route, which takes precedence for numeric paths.
+	r.Handle(fmt.Sprintf("%s/flights/{number:[A-Za-z0-9]{2,3}[0-9]{1,4}[A-Za-z]?}/events", basePathV1), protect(fh.auth, fh.CreateFlightEventByNumber)).
+		Methods(http.MethodPost).
+		Name("CreateFlightEventByNumber")
 }
 
+// GetFlights lists flights, optionally filtered by the origin, destination,
+// aircraftId, airframeId, flightNumber, from and to query parameters. from
+// and to take a date or an RFC 3339 time, a date in to includes that day.
+// The page can be downloaded as an iCalendar or CSV file with the format
+// query parameter or the Accept header. Files hold up to the maximum page
+// size unless a limit is given; the number of matching flights is sent in
+// the X-Total-Count header and the next page, if any, is linked in the Link
+// header.
 func (fh *FlightHandler) GetFlights(w http.ResponseWriter, r *http.Request) {
-	// Placeholder for handling flight requests
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Flight handler placeholder"))
+	format, err := exportFormatFromRequest(w, r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	filter, err := flightFilterFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	opts, err := listOptionsFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+	if format != formatJSON && len(opts.Fields) > 0 {
+		newErrorResponse(w, fmt.Errorf("fields is only supported for JSON"), http.StatusBadRequest)
+		return
+	}
+	if format != formatJSON && opts.Limit == 0 {
+		opts.Limit = services.MaxPageLimit
+	}
+
+	res, err := fh.service.ListFlights(r.Context(), filter, opts)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if format == formatJSON {
+		newPageResponse(w, r, res, opts.Fields)
+		return
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(res.Total))
+	if next := nextPageURI(r, res.NextCursor); next != "" {
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next))
+	}
+	newFlightsExport(w, res.Data, format, "flights")
+}
+
+// GetFlight returns a flight, or downloads it as an iCalendar or CSV file
+// like GetFlights.
+func (fh *FlightHandler) GetFlight(w http.ResponseWriter, r *http.Request) {
+	format, err := exportFormatFromRequest(w, r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	id, err := flightIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := fh.service.FindFlight(r.Context(), id)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if format == formatJSON {
+		newJSONResponse(w, res, http.StatusOK)
+		return
+	}
+	newFlightsExport(w, []models.FlightDetail{res}, format, fmt.Sprintf("flight-%d", res.Id))
+}
+
+// newFlightsExport writes flights as an iCalendar or CSV file.
+func newFlightsExport(w http.ResponseWriter, flights []models.FlightDetail, format, name string) {
+	var buf bytes.Buffer
+	var err error
+	if format == formatCalendar {
+		err = models.WriteCalendar(&buf, flights, time.Now())
+	} else {
+		err = models.WriteFlightsCSV(&buf, flights)
+	}
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to export flights: %w", err), http.StatusInternalServerError)
+		return
+	}
+	newExportResponse(w, &buf, format, name)
+}
+
+// CreateFlight stores a new flight. Flights its airframe cannot operate
+// between its other flights are rejected unless the force query parameter is
+// true.
+func (fh *FlightHandler) CreateFlight(w http.ResponseWriter, r *http.Request) {
+	force, err := forceFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	flight, err := models.NewFlight(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := fh.service.CreateFlight(r.Context(), flight, force); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/flights/%d", basePathV1, flight.Id))
+	newJSONResponse(w, flight, http.StatusCreated)
+}
+
+// ReplaceFlight replaces all fields of a flight with the request body. The
+// force query parameter overrides rotation conflicts like in CreateFlight.
+func (fh *FlightHandler) ReplaceFlight(w http.ResponseWriter, r *http.Request) {
+	id, err := flightIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+	force, err := forceFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	flight, err := models.NewFlight(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := fh.service.UpdateFlight(r.Context(), id, flight, force); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, flight, http.StatusOK)
+}
+
+// PatchFlight applies the fields present in the request body to a flight and
+// leaves all other fields untouched (JSON merge patch). The force query
+// parameter overrides rotation conflicts like in CreateFlight.
+func (fh *FlightHandler) PatchFlight(w http.ResponseWriter, r *http.Request) {
+	id, err := flightIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+	force, err := forceFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	detail, err := fh.service.FindFlight(r.Context(), id)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+	flight := detail.Flight
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&flight); err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := fh.service.UpdateFlight(r.Context(), id, &flight, force); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, flight, http.StatusOK)
+}
+
+func (fh *FlightHandler) DeleteFlight(w http.ResponseWriter, r *http.Request) {
+	id, err := flightIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := fh.service.DeleteFlight(r.Context(), id); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetFlightPositions returns the simulated positions of all flights airborne
+// at the RFC 3339 time in the at query parameter, or now.
+func (fh *FlightHandler) GetFlightPositions(w http.ResponseWriter, r *http.Request) {
+	at := time.Now()
+	if v := r.URL.Query().Get("at"); v != "" {
+		var err error
+		if at, err = time.Parse(time.RFC3339, v); err != nil {
+			newErrorResponse(w, fmt.Errorf("invalid at %q: must be an RFC 3339 time", v), http.StatusBadRequest)
+			return
+		}
+	}
+
+	res, err := fh.service.Positions(r.Context(), at)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+// StreamFlights pushes position updates and status changes of flights as
+// server-sent events until the client disconnects or the server shuts down.
+// The types query parameter selects the comma-separated message types, bbox
+// (minLng,minLat,maxLng,maxLat) limits positions to an area, airport and
+// flightNumber limit messages to flights from or to an airport or with a
+// flight number.
+func (fh *FlightHandler) StreamFlights(w http.ResponseWriter, r *http.Request) {
+	filter, err := streamFilterFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	sub, err := fh.service.Subscribe(filter, 0)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusServiceUnavailable)
+		return
+	}
+	defer fh.service.Unsubscribe(sub)
+
+	stream, ok := newEventStream(w)
+	if !ok {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !stream.heartbeat() {
+				return
+			}
+		case msg, ok := <-sub.C:
+			if !ok || !stream.send(msg.Type, msg) {
+				return
+			}
+		}
+	}
+}
+
+func streamFilterFromRequest(r *http.Request) (services.StreamFilter, error) {
+	q := r.URL.Query()
+	filter := services.StreamFilter{
+		Airport:      strings.ToUpper(q.Get("airport")),
+		FlightNumber: strings.ToUpper(q.Get("flightNumber")),
+	}
+
+	if types := q.Get("types"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			if !slices.Contains(models.StreamTypes, t) {
+				return filter, services.UnknownValueError{Param: "types", Value: t, Valid: models.StreamTypes}
+			}
+			filter.Types = append(filter.Types, t)
+		}
+	}
+	if bbox := q.Get("bbox"); bbox != "" {
+		var err error
+		if filter.BBox, err = models.ParseBBox(bbox); err != nil {
+			return filter, err
+		}
+	}
+
+	return filter, nil
+}
+
+// GetFlightEvents lists the status history of a flight, oldest first.
+func (fh *FlightHandler) GetFlightEvents(w http.ResponseWriter, r *http.Request) {
+	id, err := flightIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := fh.service.FlightEvents(r.Context(), id)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+// CreateFlightEvent changes the status of a flight. Transitions the status
+// machine does not allow are rejected with 409 Conflict.
+func (fh *FlightHandler) CreateFlightEvent(w http.ResponseWriter, r *http.Request) {
+	id, err := flightIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	fh.recordFlightEvent(w, r, id)
+}
+
+// CreateFlightEventByNumber changes the status of a flight like
+// CreateFlightEvent for clients that only know the flight number. The date
+// query parameter is required, it is the local date of the departure at the
+// origin, as numbers repeat every day.
+func (fh *FlightHandler) CreateFlightEventByNumber(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		newErrorResponse(w, fmt.Errorf("date is required"), http.StatusBadRequest)
+		return
+	}
+	d, err := time.Parse(time.DateOnly, date)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("invalid date %q: must be a date (YYYY-MM-DD)", date), http.StatusBadRequest)
+		return
+	}
+
+	id, err := fh.service.FlightIdByNumber(r.Context(), mux.Vars(r)["number"], d)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	fh.recordFlightEvent(w, r, id)
+}
+
+func (fh *FlightHandler) recordFlightEvent(w http.ResponseWriter, r *http.Request, id int64) {
+	event, err := models.NewFlightEvent(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := fh.service.RecordFlightEvent(r.Context(), id, event); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, event, http.StatusCreated)
+}
+
+func flightFilterFromRequest(r *http.Request) (services.FlightFilter, error) {
+	q := r.URL.Query()
+	filter := services.FlightFilter{
+		Origin:       q.Get("origin"),
+		Destination:  q.Get("destination"),
+		FlightNumber: q.Get("flightNumber"),
+	}
+
+	ids := map[string]*int{"aircraftId": &filter.AircraftId, "airframeId": &filter.AirframeId}
+	for param, dst := range ids {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			return filter, fmt.Errorf("invalid %s %q: must be a positive integer", param, v)
+		}
+		*dst = id
+	}
+
+	if v := q.Get("from"); v != "" {
+		t, _, err := parseTimeParam(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid from %q: %w", v, err)
+		}
+		filter.From = t
+	}
+	if v := q.Get("to"); v != "" {
+		t, dateOnly, err := parseTimeParam(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid to %q: %w", v, err)
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.To = t
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, fmt.Errorf("invalid time range: from must be before to")
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam parses a query parameter holding either a date (UTC) or an
+// RFC 3339 time and reports whether it was a date.
+func parseTimeParam(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, v); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("must be a date (YYYY-MM-DD) or an RFC 3339 time")
+	}
+	return t, false, nil
+}
+
+// forceFromRequest reports whether the force query parameter asks to store a
+// flight despite conflicts with the rotation of its airframe.
+func forceFromRequest(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("force")
+	if v == "" {
+		return false, nil
+	}
+	force, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid force %q: must be true or false", v)
+	}
+	return force, nil
+}
+
+func flightIdFromRequest(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid flight id %q", mux.Vars(r)["id"])
+	}
+	return id, nil
 }
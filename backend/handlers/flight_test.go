@@ -1 +1,692 @@
 package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFlights(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewFlightHandler(services.NewFlightService(db), nil).Register(r)
+
+	tests := []struct {
+		name            string
+		path            string
+		expectedStatus  int
+		expectedFlights []string
+	}{
+		{name: "All flights", path: "/api/v1/flights", expectedStatus: http.StatusOK, expectedFlights: []string{"AA100", "AF200", "DL300"}},
+		{name: "By origin", path: "/api/v1/flights?origin=jfk", expectedStatus: http.StatusOK, expectedFlights: []string{"AA100"}},
+		{name: "By metro area", path: "/api/v1/flights?destination=NYC", expectedStatus: http.StatusOK, expectedFlights: []string{"AF200"}},
+		{name: "By aircraft", path: "/api/v1/flights?aircraftId=3", expectedStatus: http.StatusOK, expectedFlights: []string{"DL300"}},
+		{name: "By airframe", path: "/api/v1/flights?airframeId=2", expectedStatus: http.StatusOK, expectedFlights: []string{"AF200"}},
+		{name: "By flight number", path: "/api/v1/flights?flightNumber=aa100", expectedStatus: http.StatusOK, expectedFlights: []string{"AA100"}},
+		{name: "By date", path: "/api/v1/flights?from=2023-10-02&to=2023-10-02", expectedStatus: http.StatusOK, expectedFlights: []string{"AF200"}},
+		{name: "By time", path: "/api/v1/flights?from=2023-10-01T09:00:00Z&to=2023-10-03T10:00:00Z", expectedStatus: http.StatusOK, expectedFlights: []string{"AF200"}},
+		{name: "Sorted", path: "/api/v1/flights?sort=-departure_time", expectedStatus: http.StatusOK, expectedFlights: []string{"DL300", "AF200", "AA100"}},
+		{name: "No match", path: "/api/v1/flights?origin=LAX&destination=JFK", expectedStatus: http.StatusOK, expectedFlights: nil},
+		{name: "Invalid date", path: "/api/v1/flights?from=yesterday", expectedStatus: http.StatusBadRequest},
+		{name: "Empty range", path: "/api/v1/flights?from=2023-10-03&to=2023-10-01", expectedStatus: http.StatusBadRequest},
+		{name: "Invalid aircraft", path: "/api/v1/flights?aircraftId=0", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, tt.path, http.NoBody)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var page models.Page[models.FlightDetail]
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+			var numbers []string
+			for _, f := range page.Data {
+				numbers = append(numbers, f.FlightNumber)
+				assert.NotNil(t, f.OriginAirport)
+				assert.NotNil(t, f.DestinationAirport)
+				assert.NotNil(t, f.Aircraft)
+			}
+			assert.Equal(t, tt.expectedFlights, numbers)
+			assert.Equal(t, len(tt.expectedFlights), page.Total)
+		})
+	}
+}
+
+func TestPageFlights(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	// A second flight on the day of DL300.
+	_, err = db.NewInsert().Model(&models.Flight{
+		FlightNumber:  "UA400",
+		AircraftId:    4,
+		Origin:        "LAX",
+		Destination:   "JFK",
+		DepartureTime: time.Date(2023, 10, 3, 14, 0, 0, 0, time.UTC),
+		ArrivalTime:   time.Date(2023, 10, 3, 19, 30, 0, 0, time.UTC),
+	}).Exec(ctx)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	NewFlightHandler(services.NewFlightService(db), nil).Register(r)
+
+	// walk follows the next links from path and returns the flight numbers of
+	// all pages.
+	walk := func(t *testing.T, path string) []string {
+		var numbers []string
+		for range 10 {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, path, http.NoBody)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+			var page models.Page[models.FlightDetail]
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+			require.LessOrEqual(t, len(page.Data), 1)
+			for _, f := range page.Data {
+				numbers = append(numbers, f.FlightNumber)
+			}
+			if page.Links.Next == "" {
+				return numbers
+			}
+			path = page.Links.Next
+		}
+		t.Fatalf("no last page after %v", numbers)
+		return nil
+	}
+
+	for sort, expected := range map[string][]string{
+		"departure_time":        {"AA100", "AF200", "DL300", "UA400"},
+		"-departure_time":       {"UA400", "DL300", "AF200", "AA100"},
+		"arrival_time":          {"AA100", "AF200", "DL300", "UA400"},
+		"-arrival_time,-id":     {"UA400", "DL300", "AF200", "AA100"},
+		"origin,departure_time": {"AF200", "AA100", "DL300", "UA400"},
+		"airframe_id":           {"AA100", "AF200", "DL300", "UA400"},
+		"-airframe_id":          {"AF200", "AA100", "DL300", "UA400"},
+		"-airframe_id,-id":      {"AF200", "AA100", "UA400", "DL300"},
+	} {
+		t.Run(sort, func(t *testing.T) {
+			assert.Equal(t, expected, walk(t, "/api/v1/flights?limit=1&sort="+sort))
+		})
+	}
+
+	t.Run("Invalid cursor", func(t *testing.T) {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "/api/v1/flights?sort=departure_time&cursor=eyJzIjoiZGVwYXJ0dXJlX3RpbWUsaWQiLCJ2IjpbMSwxXX0", http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+}
+
+func TestGetFlight(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewFlightHandler(services.NewFlightService(db), nil).Register(r)
+
+	t.Run("Existing flight", func(t *testing.T) {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "/api/v1/flights/1", http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var flight models.FlightDetail
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &flight))
+		assert.Equal(t, "AA100", flight.FlightNumber)
+		assert.Equal(t, "JFK", flight.OriginAirport.IATA)
+		assert.Equal(t, "LAX", flight.DestinationAirport.IATA)
+		assert.Equal(t, "Boeing 737", flight.Aircraft.Name)
+		assert.Equal(t, "N921AN", flight.Airframe.Registration)
+	})
+
+	t.Run("Without airframe", func(t *testing.T) {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "/api/v1/flights/3", http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.NotContains(t, rr.Body.String(), `"airframe"`)
+	})
+
+	t.Run("Unknown flight", func(t *testing.T) {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "/api/v1/flights/99", http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
+
+func TestWriteFlights(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewFlightHandler(services.NewFlightService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	const lh400 = `{"flight_number":"LH400","aircraft_id":2,"origin":"FRA","destination":"JFK","departure_time":"2023-10-04T10:00:00+02:00","arrival_time":"2023-10-04T12:45:00-04:00"}`
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+		expectedFields []string
+	}{
+		{name: "Missing token", method: http.MethodPost, path: "/api/v1/flights", body: lh400, expectedStatus: http.StatusUnauthorized},
+		{name: "Create", method: http.MethodPost, path: "/api/v1/flights", token: "secret", body: lh400, expectedStatus: http.StatusCreated},
+		{name: "Create with invalid time", method: http.MethodPost, path: "/api/v1/flights", token: "secret", body: `{"flight_number":"LH400","aircraft_id":2,"origin":"FRA","destination":"JFK","departure_time":"tomorrow","arrival_time":"2023-10-04T12:45:00Z"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Create with invalid schedule", method: http.MethodPost, path: "/api/v1/flights", token: "secret", body: `{"flight_number":"lh 400","aircraft_id":2,"origin":"FRA","destination":"FRA","departure_time":"2023-10-04T10:00:00Z","arrival_time":"2023-10-04T09:00:00Z"}`, expectedStatus: http.StatusUnprocessableEntity, expectedFields: []string{"flight_number", "destination", "arrival_time"}},
+		{name: "Create with unknown references", method: http.MethodPost, path: "/api/v1/flights", token: "secret", body: `{"flight_number":"LH400","aircraft_id":9,"airframe_id":9,"origin":"FRA","destination":"XXX","departure_time":"2023-10-04T10:00:00Z","arrival_time":"2023-10-04T11:00:00Z"}`, expectedStatus: http.StatusUnprocessableEntity, expectedFields: []string{"destination", "aircraft_id", "airframe_id"}},
+		{name: "Create beyond aircraft range", method: http.MethodPost, path: "/api/v1/flights", token: "secret", body: `{"flight_number":"LH728","aircraft_id":4,"origin":"FRA","destination":"PVG","departure_time":"2023-10-04T10:00:00Z","arrival_time":"2023-10-05T00:00:00Z"}`, expectedStatus: http.StatusUnprocessableEntity, expectedFields: []string{"aircraft_id"}},
+		{name: "Create with airframe of other type", method: http.MethodPost, path: "/api/v1/flights", token: "secret", body: `{"flight_number":"LH400","aircraft_id":2,"airframe_id":4,"origin":"FRA","destination":"JFK","departure_time":"2023-10-04T10:00:00Z","arrival_time":"2023-10-04T18:00:00Z"}`, expectedStatus: http.StatusUnprocessableEntity, expectedFields: []string{"airframe_id"}},
+		{name: "Patch with airframe out of position", method: http.MethodPatch, path: "/api/v1/flights/4", token: "secret", body: `{"airframe_id":2}`, expectedStatus: http.StatusConflict},
+		{name: "Patch with invalid force", method: http.MethodPatch, path: "/api/v1/flights/4?force=maybe", token: "secret", body: `{"airframe_id":2}`, expectedStatus: http.StatusBadRequest},
+		{name: "Patch", method: http.MethodPatch, path: "/api/v1/flights/4?force=true", token: "secret", body: `{"airframe_id":2}`, expectedStatus: http.StatusOK},
+		{name: "Patch arrival before departure", method: http.MethodPatch, path: "/api/v1/flights/4", token: "secret", body: `{"arrival_time":"2023-10-04T07:00:00Z"}`, expectedStatus: http.StatusUnprocessableEntity, expectedFields: []string{"arrival_time"}},
+		{name: "Replace", method: http.MethodPut, path: "/api/v1/flights/4", token: "secret", body: lh400, expectedStatus: http.StatusOK},
+		{name: "Replace with other id", method: http.MethodPut, path: "/api/v1/flights/4", token: "secret", body: `{"id":1,"flight_number":"LH400","aircraft_id":2,"origin":"FRA","destination":"JFK","departure_time":"2023-10-04T10:00:00Z","arrival_time":"2023-10-04T18:00:00Z"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Replace unknown flight", method: http.MethodPut, path: "/api/v1/flights/99", token: "secret", body: lh400, expectedStatus: http.StatusNotFound},
+		{name: "Delete", method: http.MethodDelete, path: "/api/v1/flights/4", token: "secret", expectedStatus: http.StatusNoContent},
+		{name: "Delete again", method: http.MethodDelete, path: "/api/v1/flights/4", token: "secret", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedFields == nil {
+				return
+			}
+
+			var res struct {
+				Details []models.ValidationError `json:"details"`
+			}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			var fields []string
+			for _, d := range res.Details {
+				fields = append(fields, d.Field)
+			}
+			assert.Equal(t, tt.expectedFields, fields)
+		})
+	}
+
+	// Times are stored in UTC whatever offset they were written with.
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/flights", strings.NewReader(lh400))
+	req.Header.Set("Authorization", "Bearer secret")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	var flight models.Flight
+	require.NoError(t, db.NewSelect().Model(&flight).Where("flight_number = ?", "LH400").Scan(ctx))
+	assert.Equal(t, "2023-10-04T08:00:00Z", flight.DepartureTime.Format(time.RFC3339))
+	assert.Equal(t, "2023-10-04T16:45:00Z", flight.ArrivalTime.Format(time.RFC3339))
+}
+
+func TestFlightEvents(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewFlightHandler(services.NewFlightService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	// AF200 from CDG to JFK is scheduled to depart at 09:00Z.
+	tests := []struct {
+		name           string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Missing token", path: "/api/v1/flights/2/events", body: `{"status":"boarding"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "Unknown flight", path: "/api/v1/flights/99/events", token: "secret", body: `{"status":"boarding"}`, expectedStatus: http.StatusNotFound},
+		{name: "Unknown status", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"teleported"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Delay without estimate", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"delayed"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Delay", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"delayed","time":"2023-10-02T08:00:00Z","estimated_departure_time":"2023-10-02T10:30:00Z","remark":"late inbound aircraft"}`, expectedStatus: http.StatusCreated},
+		{name: "Land before departing", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"landed","time":"2023-10-02T09:00:00Z"}`, expectedStatus: http.StatusConflict},
+		{name: "Board", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"boarding","time":"2023-10-02T10:00:00Z"}`, expectedStatus: http.StatusCreated},
+		{name: "Depart before boarding", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"departed","time":"2023-10-02T09:55:00Z"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Depart", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"departed","time":"2023-10-02T10:35:00Z"}`, expectedStatus: http.StatusCreated},
+		{name: "Delay after departing", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"delayed","time":"2023-10-02T10:40:00Z","estimated_departure_time":"2023-10-02T11:00:00Z"}`, expectedStatus: http.StatusConflict},
+		{name: "Take off without date", path: "/api/v1/flights/AF200/events", token: "secret", body: `{"status":"airborne","time":"2023-10-02T10:50:00Z"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Take off on another day", path: "/api/v1/flights/AF200/events?date=2023-10-03", token: "secret", body: `{"status":"airborne","time":"2023-10-02T10:50:00Z"}`, expectedStatus: http.StatusNotFound},
+		{name: "Take off", path: "/api/v1/flights/af200/events?date=2023-10-02", token: "secret", body: `{"status":"airborne","time":"2023-10-02T10:50:00Z"}`, expectedStatus: http.StatusCreated},
+		{name: "Divert to unknown airport", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"diverted","time":"2023-10-02T11:30:00Z","diverted_to":"XXX"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Divert to destination", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"diverted","time":"2023-10-02T11:30:00Z","diverted_to":"JFK"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Divert", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"diverted","time":"2023-10-02T11:30:00Z","diverted_to":"FRA"}`, expectedStatus: http.StatusCreated},
+		{name: "Land", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"landed","time":"2023-10-02T12:20:00Z"}`, expectedStatus: http.StatusCreated},
+		{name: "Arrive", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"arrived","time":"2023-10-02T12:30:00Z"}`, expectedStatus: http.StatusCreated},
+		{name: "Cancel after arrival", path: "/api/v1/flights/2/events", token: "secret", body: `{"status":"cancelled"}`, expectedStatus: http.StatusConflict},
+		{name: "Cancel other flight", path: "/api/v1/flights/3/events", token: "secret", body: `{"status":"cancelled"}`, expectedStatus: http.StatusCreated},
+		// AA100 departs JFK at 04:00 local time on October 1.
+		{name: "Board on the UTC date", path: "/api/v1/flights/AA100/events?date=2023-09-30", token: "secret", body: `{"status":"boarding"}`, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+		})
+	}
+
+	get := func(path string, v any) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, path, http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), v))
+	}
+
+	var events []models.FlightEvent
+	get("/api/v1/flights/2/events", &events)
+	var history []string
+	for _, e := range events {
+		history = append(history, string(e.PreviousStatus)+">"+string(e.Status))
+	}
+	assert.Equal(t, []string{
+		"scheduled>delayed", "delayed>boarding", "boarding>departed", "departed>airborne",
+		"airborne>diverted", "diverted>landed", "landed>arrived",
+	}, history)
+	assert.Equal(t, "late inbound aircraft", events[0].Remark)
+
+	var flight models.FlightDetail
+	get("/api/v1/flights/2", &flight)
+	assert.Equal(t, models.FlightArrived, flight.Status)
+	assert.Equal(t, "2023-10-02T10:30:00Z", flight.EstimatedDepartureTime.Format(time.RFC3339))
+	assert.Equal(t, "2023-10-02T10:35:00Z", flight.ActualDepartureTime.Format(time.RFC3339))
+	assert.Equal(t, "2023-10-02T10:50:00Z", flight.ActualTakeoffTime.Format(time.RFC3339))
+	assert.Equal(t, "2023-10-02T12:20:00Z", flight.ActualLandingTime.Format(time.RFC3339))
+	assert.Equal(t, "2023-10-02T12:30:00Z", flight.ActualArrivalTime.Format(time.RFC3339))
+	assert.Equal(t, "FRA", flight.DivertedTo)
+
+	get("/api/v1/flights/1", &flight)
+	assert.Equal(t, models.FlightScheduled, flight.Status)
+
+	// Replacing the timetable keeps the operational state.
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPut, "/api/v1/flights/3", strings.NewReader(`{"flight_number":"DL300","aircraft_id":3,"origin":"LAX","destination":"CDG","departure_time":"2023-10-03T11:00:00Z","arrival_time":"2023-10-03T19:00:00Z","status":"scheduled"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	get("/api/v1/flights/3", &flight)
+	assert.Equal(t, models.FlightCancelled, flight.Status)
+	assert.Equal(t, "2023-10-03T11:00:00Z", flight.DepartureTime.Format(time.RFC3339))
+}
+
+func TestGetFlightPositions(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	// DL300 leaves LAX two hours late.
+	_, err = db.NewUpdate().Model((*models.Flight)(nil)).
+		Set("status = ?", models.FlightDeparted).
+		Set("actual_departure_time = ?", time.Date(2023, 10, 3, 12, 0, 0, 0, time.UTC)).
+		Where("flight_number = ?", "DL300").
+		Exec(ctx)
+	require.NoError(t, err)
+
+	r := mux.NewRouter()
+	NewFlightHandler(services.NewFlightService(db), nil).Register(r)
+
+	// AA100 flies from JFK to LAX between 08:00Z and 11:00Z at 35000 ft.
+	tests := []struct {
+		name             string
+		at               string
+		expectedStatus   int
+		expectedFlights  []string
+		expectedPhase    models.FlightPhase
+		expectedAltitude int
+		expectedProgress float64
+	}{
+		{name: "Before departure", at: "2023-10-01T07:59:59Z", expectedStatus: http.StatusOK},
+		{name: "Climb", at: "2023-10-01T08:10:00Z", expectedStatus: http.StatusOK, expectedFlights: []string{"AA100"}, expectedPhase: models.PhaseClimb, expectedAltitude: 14000, expectedProgress: 1.0 / 18},
+		{name: "Cruise", at: "2023-10-01T09:30:00Z", expectedStatus: http.StatusOK, expectedFlights: []string{"AA100"}, expectedPhase: models.PhaseCruise, expectedAltitude: 35000, expectedProgress: 0.5},
+		{name: "Descent", at: "2023-10-01T10:50:00Z", expectedStatus: http.StatusOK, expectedFlights: []string{"AA100"}, expectedPhase: models.PhaseDescent, expectedAltitude: 11667, expectedProgress: 17.0 / 18},
+		{name: "At arrival", at: "2023-10-01T11:00:00Z", expectedStatus: http.StatusOK},
+		{name: "Scheduled time of delayed flight", at: "2023-10-03T11:00:00Z", expectedStatus: http.StatusOK},
+		{name: "Delayed flight", at: "2023-10-03T16:00:00Z", expectedStatus: http.StatusOK, expectedFlights: []string{"DL300"}, expectedPhase: models.PhaseCruise, expectedAltitude: 33000, expectedProgress: 0.5},
+		{name: "Invalid time", at: "yesterday", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "/api/v1/flights/positions?at="+tt.at, http.NoBody)
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var res models.FlightPositions
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+			var numbers []string
+			for _, f := range res.Flights {
+				numbers = append(numbers, f.FlightNumber)
+			}
+			require.Equal(t, tt.expectedFlights, numbers)
+			if len(res.Flights) == 0 {
+				return
+			}
+
+			pos := res.Flights[0]
+			assert.Equal(t, tt.expectedPhase, pos.Phase)
+			assert.Equal(t, tt.expectedAltitude, pos.AltitudeFt)
+			assert.InDelta(t, tt.expectedProgress, pos.Progress, 1e-9)
+			assert.InDelta(t, pos.DistanceFlownKm+pos.DistanceRemainingKm, pos.DistanceFlownKm/pos.Progress, 1e-6)
+		})
+	}
+
+	// Halfway between JFK and LAX the great circle heads west over the Midwest.
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/flights/positions?at=2023-10-01T09:30:00Z", http.NoBody)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	var res models.FlightPositions
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+	require.Len(t, res.Flights, 1)
+	pos := res.Flights[0]
+	assert.InDelta(t, 39.4, pos.Position.Lat, 0.5)
+	assert.InDelta(t, -97.4, pos.Position.Lng, 0.5)
+	assert.InDelta(t, 260, pos.Heading, 10)
+	assert.InDelta(t, 713, pos.GroundSpeedKts, 5)
+}
+
+func TestStreamFlights(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	svc := services.NewFlightService(db)
+	r := mux.NewRouter()
+	NewFlightHandler(svc, BearerAuth([]string{"secret"})).Register(r)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	for _, query := range []string{"types=weather", "bbox=1,2,3"} {
+		res, err := http.Get(srv.URL + "/api/v1/flights/stream?" + query)
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode, query)
+	}
+
+	stream := func(query string) *bufio.Reader {
+		res, err := http.Get(srv.URL + "/api/v1/flights/stream?" + query)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+		return bufio.NewReader(res.Body)
+	}
+	next := func(br *bufio.Reader) (string, models.StreamMessage) {
+		var event string
+		var msg models.StreamMessage
+		for {
+			line, err := br.ReadString('\n')
+			require.NoError(t, err)
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &msg))
+			case line == "" && event != "":
+				return event, msg
+			}
+		}
+	}
+	record := func(path, body string) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	jfk := stream("airport=jfk&types=status")
+	dl300 := stream("flightNumber=dl300")
+
+	// AA100 departs from JFK, DL300 flies from LAX to CDG.
+	record("/api/v1/flights/1/events", `{"status":"boarding","time":"2023-10-01T07:30:00Z"}`)
+	record("/api/v1/flights/3/events", `{"status":"cancelled","remark":"crew shortage"}`)
+
+	event, msg := next(jfk)
+	assert.Equal(t, models.StreamStatus, event)
+	require.NotNil(t, msg.Status)
+	assert.Equal(t, "AA100", msg.Status.FlightNumber)
+	assert.Equal(t, models.FlightBoarding, msg.Status.Status)
+	assert.Equal(t, models.FlightScheduled, msg.Status.PreviousStatus)
+
+	event, msg = next(dl300)
+	assert.Equal(t, models.StreamStatus, event)
+	require.NotNil(t, msg.Status)
+	assert.Equal(t, "DL300", msg.Status.FlightNumber)
+	assert.Equal(t, models.FlightCancelled, msg.Status.Status)
+	assert.Equal(t, "crew shortage", msg.Status.Remark)
+
+	// Closing the streams ends the open ones and rejects new subscribers.
+	svc.CloseStreams()
+	_, err = io.ReadAll(jfk)
+	assert.NoError(t, err)
+	res, err := http.Get(srv.URL + "/api/v1/flights/stream")
+	require.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+}
+
+func TestExportFlights(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewFlightHandler(services.NewFlightService(db), nil).Register(r)
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, path, http.NoBody)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Calendar", func(t *testing.T) {
+		rr := get("/api/v1/flights?flightNumber=AA100&format=ics", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, "text/calendar; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename=flights.ics`, rr.Header().Get("Content-Disposition"))
+
+		body := rr.Body.String()
+		require.True(t, strings.HasSuffix(body, "END:VCALENDAR\r\n"))
+		for _, line := range strings.Split(strings.TrimSuffix(body, "\r\n"), "\r\n") {
+			assert.LessOrEqual(t, len(line), 75, "line %q is not folded", line)
+		}
+
+		// Unfold the content lines before looking at the properties.
+		body = strings.ReplaceAll(body, "\r\n ", "")
+		assert.Equal(t, 1, strings.Count(body, "BEGIN:VEVENT"))
+		for _, property := range []string{
+			"UID:flight-1@terraroute",
+			"DTSTART;TZID=America/New_York:20231001T040000",
+			"DTEND;TZID=America/Los_Angeles:20231001T040000",
+			"SUMMARY:AA100 JFK-LAX",
+			`LOCATION:John F. Kennedy International Airport (JFK)`,
+			"GEO:40.641300;-73.778100",
+			"TZID:America/New_York",
+			"TZID:America/Los_Angeles",
+			// The 2023 DST switch of New York is described.
+			"DTSTART:20230312T020000\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0400\r\nTZNAME:EDT",
+		} {
+			assert.Contains(t, body, property)
+		}
+	})
+
+	t.Run("Negotiated CSV", func(t *testing.T) {
+		rr := get("/api/v1/flights?sort=id&limit=2", "application/xml, text/csv;q=0.9")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+		assert.Equal(t, `</api/v1/flights?cursor=`, rr.Header().Get("Link")[:len(`</api/v1/flights?cursor=`)])
+		assert.Contains(t, rr.Header().Get("Link"), `rel="next"`)
+		total, err := strconv.Atoi(rr.Header().Get("X-Total-Count"))
+		require.NoError(t, err)
+		assert.Greater(t, total, 2)
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, []string{
+			"id", "flight_number", "status", "origin", "destination",
+			"departure_time", "arrival_time", "departure_local", "arrival_local",
+			"block_minutes", "aircraft", "registration",
+		}, records[0])
+		assert.Equal(t, []string{
+			"1", "AA100", "scheduled", "JFK", "LAX",
+			"2023-10-01T08:00:00Z", "2023-10-01T11:00:00Z", "2023-10-01T04:00:00-04:00", "2023-10-01T04:00:00-07:00",
+			"180", "Boeing 737", "N921AN",
+		}, records[1])
+		assert.Equal(t, "AF200", records[2][1])
+	})
+
+	t.Run("Export without a limit", func(t *testing.T) {
+		rr := get("/api/v1/flights?format=csv", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Empty(t, rr.Header().Get("Link"))
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, rr.Header().Get("X-Total-Count"), strconv.Itoa(len(records)-1))
+	})
+
+	t.Run("Negotiation", func(t *testing.T) {
+		for accept, expected := range map[string]string{
+			"text/csv;q=0.5, text/calendar":        "text/calendar; charset=utf-8",
+			"text/calendar;q=0.2, */*;q=0.1":       "text/calendar; charset=utf-8",
+			"text/*, text/calendar;q=0":            "text/csv; charset=utf-8",
+			"text/html, */*;q=0.8":                 "application/json",
+			"text/csv;q=0, application/json;q=0.1": "application/json",
+		} {
+			rr := get("/api/v1/flights/2", accept)
+			require.Equal(t, http.StatusOK, rr.Code, accept)
+			assert.Equal(t, expected, rr.Header().Get("Content-Type"), accept)
+		}
+
+		for _, accept := range []string{"image/png", "text/csv;q=0", "*/*;q=0"} {
+			assert.Equal(t, http.StatusNotAcceptable, get("/api/v1/flights/2", accept).Code, accept)
+		}
+	})
+
+	t.Run("Single flight", func(t *testing.T) {
+		rr := get("/api/v1/flights/2", "text/calendar")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, `attachment; filename=flight-2.ics`, rr.Header().Get("Content-Disposition"))
+		assert.Contains(t, rr.Body.String(), "DTSTART;TZID=Europe/Paris:20231002T110000")
+
+		rr = get("/api/v1/flights/2", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+		assert.Equal(t, http.StatusNotFound, get("/api/v1/flights/999?format=ics", "").Code)
+	})
+
+	t.Run("Formulas are escaped", func(t *testing.T) {
+		_, err := db.NewUpdate().Model((*models.Aircraft)(nil)).Set("name = ?", "=HYPERLINK(\"http://example.com\")").Where("id = 1").Exec(ctx)
+		require.NoError(t, err)
+
+		rr := get("/api/v1/flights/1?format=csv", "")
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "'=HYPERLINK(\"http://example.com\")", records[1][10])
+		assert.Equal(t, "N921AN", records[1][11])
+	})
+
+	t.Run("Invalid requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("/api/v1/flights?format=xml", "").Code)
+		assert.Equal(t, http.StatusBadRequest, get("/api/v1/flights?format=csv&fields=id", "").Code)
+	})
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+var _ Handler = (*MetroAreaHandler)(nil)
+
+type MetroAreaHandler struct {
+	service *services.MetroAreaService
+	auth    Middleware
+}
+
+// NewMetroAreaHandler creates the metropolitan area handler. auth protects
+// the write endpoints, a nil auth disables them.
+func NewMetroAreaHandler(svc *services.MetroAreaService, auth Middleware) *MetroAreaHandler {
+	return &MetroAreaHandler{service: svc, auth: auth}
+}
+
+func (mh *MetroAreaHandler) Register(r *mux.Router) {
+	r.HandleFunc(fmt.Sprintf("%s/metro-areas", basePathV1), mh.getMetroAreas).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetMetroAreas")
+	r.HandleFunc(fmt.Sprintf("%s/metro-areas/{code:[A-Za-z]{3}}", basePathV1), mh.getMetroArea).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetMetroArea")
+
+	r.Handle(fmt.Sprintf("%s/metro-areas", basePathV1), protect(mh.auth, mh.createMetroArea)).
+		Methods(http.MethodPost).
+		Name("CreateMetroArea")
+	r.Handle(fmt.Sprintf("%s/metro-areas/{code:[A-Za-z]{3}}", basePathV1), protect(mh.auth, mh.replaceMetroArea)).
+		Methods(http.MethodPut).
+		Name("ReplaceMetroArea")
+	r.Handle(fmt.Sprintf("%s/metro-areas/{code:[A-Za-z]{3}}", basePathV1), protect(mh.auth, mh.patchMetroArea)).
+		Methods(http.MethodPatch).
+		Name("PatchMetroArea")
+	r.Handle(fmt.Sprintf("%s/metro-areas/{code:[A-Za-z]{3}}", basePathV1), protect(mh.auth, mh.deleteMetroArea)).
+		Methods(http.MethodDelete).
+		Name("DeleteMetroArea")
+}
+
+func (mh *MetroAreaHandler) getMetroAreas(w http.ResponseWriter, r *http.Request) {
+	res, err := mh.service.ListMetroAreas(r.Context(), r.URL.Query().Get("country"))
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+func (mh *MetroAreaHandler) getMetroArea(w http.ResponseWriter, r *http.Request) {
+	res, err := mh.service.FindMetroArea(r.Context(), mux.Vars(r)["code"])
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+func (mh *MetroAreaHandler) createMetroArea(w http.ResponseWriter, r *http.Request) {
+	metro, err := models.NewMetroArea(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := mh.service.CreateMetroArea(r.Context(), metro); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/metro-areas/%s", basePathV1, metro.Code))
+	newJSONResponse(w, metro, http.StatusCreated)
+}
+
+// replaceMetroArea replaces a metropolitan area and its list of airports with
+// the request body.
+func (mh *MetroAreaHandler) replaceMetroArea(w http.ResponseWriter, r *http.Request) {
+	metro, err := models.NewMetroArea(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := mh.service.UpdateMetroArea(r.Context(), mux.Vars(r)["code"], metro); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, metro, http.StatusOK)
+}
+
+// patchMetroArea applies the fields present in the request body to a
+// metropolitan area (JSON merge patch). A given airports list replaces the
+// current one.
+func (mh *MetroAreaHandler) patchMetroArea(w http.ResponseWriter, r *http.Request) {
+	metro, err := mh.service.FindMetroArea(r.Context(), mux.Vars(r)["code"])
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+	code := metro.Code
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&metro); err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := mh.service.UpdateMetroArea(r.Context(), code, &metro); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, metro, http.StatusOK)
+}
+
+func (mh *MetroAreaHandler) deleteMetroArea(w http.ResponseWriter, r *http.Request) {
+	if err := mh.service.DeleteMetroArea(r.Context(), mux.Vars(r)["code"]); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetroAreas(t *testing.T) {
+	ctx := context.Background()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewMetroAreaHandler(services.NewMetroAreaService(db), BearerAuth([]string{"secret"})).Register(r)
+	NewAirportHandler(services.NewAirportService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	tests := []struct {
+		name             string
+		method           string
+		path             string
+		body             string
+		expectedStatus   int
+		expectedAirports []string
+	}{
+		{name: "Get seeded", method: http.MethodGet, path: "/api/v1/metro-areas/nyc", expectedStatus: http.StatusOK, expectedAirports: []string{"JFK"}},
+		{name: "Get unknown", method: http.MethodGet, path: "/api/v1/metro-areas/XXX", expectedStatus: http.StatusNotFound},
+		{name: "Create", method: http.MethodPost, path: "/api/v1/metro-areas", body: `{"code":"ZZZ","name":"Test City","country":"CH","airports":["ZRH"]}`, expectedStatus: http.StatusCreated, expectedAirports: []string{"ZRH"}},
+		{name: "Create duplicate", method: http.MethodPost, path: "/api/v1/metro-areas", body: `{"code":"ZZZ","name":"Test City"}`, expectedStatus: http.StatusConflict},
+		{name: "Create with airport of another metro area", method: http.MethodPost, path: "/api/v1/metro-areas", body: `{"code":"YYY","name":"Other","airports":["JFK"]}`, expectedStatus: http.StatusConflict},
+		{name: "Create with unknown airport", method: http.MethodPost, path: "/api/v1/metro-areas", body: `{"code":"YYY","name":"Other","airports":["XXX"]}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Create invalid", method: http.MethodPost, path: "/api/v1/metro-areas", body: `{"code":"yy","name":"","airports":["ZRH","ZRH"]}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Patch airports", method: http.MethodPatch, path: "/api/v1/metro-areas/ZZZ", body: `{"airports":["ZRH","FRA"]}`, expectedStatus: http.StatusOK, expectedAirports: []string{"ZRH", "FRA"}},
+		{name: "Replace", method: http.MethodPut, path: "/api/v1/metro-areas/ZZZ", body: `{"code":"ZZZ","name":"Test City","airports":["FRA"]}`, expectedStatus: http.StatusOK, expectedAirports: []string{"FRA"}},
+		{name: "Get replaced", method: http.MethodGet, path: "/api/v1/metro-areas/ZZZ", expectedStatus: http.StatusOK, expectedAirports: []string{"FRA"}},
+		{name: "Replace code", method: http.MethodPut, path: "/api/v1/metro-areas/ZZZ", body: `{"code":"ZZY","name":"Test City"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Delete", method: http.MethodDelete, path: "/api/v1/metro-areas/ZZZ", expectedStatus: http.StatusNoContent},
+		{name: "Delete again", method: http.MethodDelete, path: "/api/v1/metro-areas/ZZZ", expectedStatus: http.StatusNotFound},
+		{name: "Airport with unknown metro area", method: http.MethodPatch, path: "/api/v1/airports/FRA", body: `{"metro":"ZZZ"}`, expectedStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+			if tt.expectedAirports != nil {
+				var metro models.MetroArea
+				json.Unmarshal(rr.Body.Bytes(), &metro)
+				assert.ElementsMatch(t, tt.expectedAirports, metro.Airports)
+			}
+		})
+	}
+
+	// Deleting a metro area keeps its airports.
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/airports/FRA", http.NoBody)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var airport models.AirportDetail
+	json.Unmarshal(rr.Body.Bytes(), &airport)
+	assert.Equal(t, "", airport.MetroCode)
+
+	// List all metro areas of a country.
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/metro-areas?country=FR", http.NoBody)
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var metros []models.MetroArea
+	json.Unmarshal(rr.Body.Bytes(), &metros)
+	if assert.Equal(t, 1, len(metros)) {
+		assert.Equal(t, "PAR", metros[0].Code)
+		assert.Equal(t, []string{"CDG"}, metros[0].Airports)
+	}
+}
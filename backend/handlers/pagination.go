@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+// listOptionsFromRequest reads the limit, cursor, sort and fields query
+// parameters shared by all paginated list endpoints.
+func listOptionsFromRequest(r *http.Request) (services.ListOptions, error) {
+	q := r.URL.Query()
+	opts := services.ListOptions{
+		Cursor: q.Get("cursor"),
+		Sort:   q.Get("sort"),
+	}
+
+	if l := q.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return opts, fmt.Errorf("invalid limit %q: must be a positive integer", l)
+		}
+		opts.Limit = limit
+	}
+
+	if f := q.Get("fields"); f != "" {
+		for _, field := range strings.Split(f, ",") {
+			if field = strings.TrimSpace(field); field != "" {
+				opts.Fields = append(opts.Fields, field)
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// newPageResponse writes a page as JSON. It fills in the self and next links
+// from the request URL and reduces every item to the requested sparse fieldset.
+func newPageResponse[T any](w http.ResponseWriter, r *http.Request, page models.Page[T], fields []string) {
+	page.Links.Self = r.URL.RequestURI()
+	page.Links.Next = nextPageURI(r, page.NextCursor)
+
+	var res any = page
+	if len(fields) > 0 {
+		data, err := sparseFields(page.Data, fields)
+		if err != nil {
+			newErrorResponse(w, err, http.StatusInternalServerError)
+			return
+		}
+		res = models.Page[map[string]any]{
+			Data:       data,
+			Total:      page.Total,
+			NextCursor: page.NextCursor,
+			Links:      page.Links,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+}
+
+// nextPageURI returns the request URI with its cursor replaced by cursor, or
+// an empty string if there is no next page.
+func nextPageURI(r *http.Request, cursor string) string {
+	if cursor == "" {
+		return ""
+	}
+	next := *r.URL
+	q := next.Query()
+	q.Set("cursor", cursor)
+	next.RawQuery = q.Encode()
+	return next.RequestURI()
+}
+
+// sparseFields converts items to JSON objects holding only the given fields.
+func sparseFields[T any](items []T, fields []string) ([]map[string]any, error) {
+	out := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return nil, fmt.Errorf("failed to select fields: %w", err)
+		}
+		var all map[string]any
+		if err := json.Unmarshal(b, &all); err != nil {
+			return nil, fmt.Errorf("failed to select fields: %w", err)
+		}
+
+		selected := make(map[string]any, len(fields))
+		for _, f := range fields {
+			selected[f] = all[f]
+		}
+		out = append(out, selected)
+	}
+	return out, nil
+}
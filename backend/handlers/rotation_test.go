@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotationJobs(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	// N921AN (airframe 1, based at JFK) last arrived at LAX, N737LA is based
+	// at LAX. Both are Boeing 737s, the An-225 is retired.
+	at := func(s string) time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return t
+	}
+	airframe := models.Airframe{Id: 5, Registration: "N737LA", AircraftId: 1, Operator: "American Airlines", SerialNumber: "30001", HomeBase: "LAX", Status: models.AirframeActive}
+	_, err = db.NewInsert().Model(&airframe).Exec(ctx)
+	require.NoError(t, err)
+	flights := []models.Flight{
+		{FlightNumber: "AA2", AircraftId: 1, Origin: "LAX", Destination: "JFK", DepartureTime: at("2023-10-05T08:00:00Z"), ArrivalTime: at("2023-10-05T13:00:00Z")},
+		{FlightNumber: "AA4", AircraftId: 1, Origin: "JFK", Destination: "LAX", DepartureTime: at("2023-10-05T09:00:00Z"), ArrivalTime: at("2023-10-05T15:00:00Z")},
+		{FlightNumber: "AA6", AircraftId: 1, Origin: "JFK", Destination: "LAX", DepartureTime: at("2023-10-05T14:00:00Z"), ArrivalTime: at("2023-10-05T20:00:00Z")},
+		{FlightNumber: "ADB1", AircraftId: 3, Origin: "LAX", Destination: "CDG", DepartureTime: at("2023-10-05T10:00:00Z"), ArrivalTime: at("2023-10-05T20:00:00Z")},
+	}
+	_, err = db.NewInsert().Model(&flights).Exec(ctx)
+	require.NoError(t, err)
+
+	fleet := services.NewFleetService(db)
+	r := mux.NewRouter()
+	NewFleetHandler(fleet, BearerAuth([]string{"secret"})).Register(r)
+
+	start := func(token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/fleet/rotations", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, path, http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+	plan := func(body string) models.RotationPlan {
+		rr := start("secret", body)
+		require.Equal(t, http.StatusAccepted, rr.Code, rr.Body.String())
+		location := rr.Header().Get("Location")
+		require.NotEmpty(t, location)
+
+		var job models.RotationJob
+		require.Eventually(t, func() bool {
+			rr := get(location)
+			require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &job))
+			return job.Status != models.RotationJobPending && job.Status != models.RotationJobRunning
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Equal(t, models.RotationJobSucceeded, job.Status, job.Error)
+		require.NotNil(t, job.Plan)
+		return *job.Plan
+	}
+	legs := func(rotation models.Rotation) []string {
+		res := []string{}
+		for _, leg := range rotation.Legs {
+			if leg.Ferry {
+				res = append(res, "ferry "+leg.Origin+"-"+leg.Destination)
+			} else {
+				res = append(res, leg.FlightNumber)
+			}
+		}
+		return res
+	}
+	uncovered := func(p models.RotationPlan) []string {
+		res := []string{}
+		for _, f := range p.Uncovered {
+			res = append(res, f.FlightNumber)
+		}
+		return res
+	}
+
+	t.Run("Invalid requests", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, start("", `{"date":"2023-10-05"}`).Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, start("secret", `{"date":"tomorrow"}`).Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, start("secret", `{"date":"2023-10-05","airframe_ids":[3]}`).Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, start("secret", `{"date":"2023-10-05","positions":{"9":"JFK"}}`).Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, start("secret", `{"date":"2023-10-05","airframe_ids":[1],"positions":{"1":"XXX"}}`).Code)
+		assert.Equal(t, http.StatusNotFound, get("/api/v1/fleet/rotations/0123abcd").Code)
+	})
+
+	t.Run("Active fleet", func(t *testing.T) {
+		p := plan(`{"date":"2023-10-05"}`)
+		assert.Equal(t, 4, p.Flights)
+		assert.Equal(t, 3, p.Covered)
+		assert.Equal(t, 2, p.FerryLegs)
+		assert.Equal(t, []string{"ADB1"}, uncovered(p))
+		assert.Equal(t, "no airframe of aircraft type 3", p.Uncovered[0].Reason)
+
+		rotations := map[int]models.Rotation{}
+		for _, rotation := range p.Rotations {
+			rotations[rotation.AirframeId] = rotation
+		}
+		require.Len(t, rotations, 4)
+		assert.Equal(t, []string{"AA2", "AA6", "ferry LAX-JFK"}, legs(rotations[1]))
+		assert.Equal(t, "JFK", rotations[1].End)
+		assert.Equal(t, []string{"ferry LAX-JFK", "AA4"}, legs(rotations[5]))
+		assert.Equal(t, "LAX", rotations[5].End)
+		assert.Empty(t, rotations[4].Legs)
+
+		ferry := rotations[5].Legs[0]
+		assert.Equal(t, at("2023-10-05T00:00:00Z"), ferry.DepartureTime)
+		assert.False(t, ferry.ArrivalTime.Add(services.MinTurnaround).After(at("2023-10-05T09:00:00Z")))
+	})
+
+	t.Run("Single airframe with position", func(t *testing.T) {
+		p := plan(`{"date":"2023-10-05","airframe_ids":[1],"positions":{"1":"JFK"},"min_turnaround_minutes":45}`)
+		assert.Equal(t, 2, p.Covered)
+		assert.Equal(t, []string{"AA4", "ADB1"}, uncovered(p))
+		require.Len(t, p.Rotations, 1)
+		assert.Equal(t, "JFK", p.Rotations[0].Start)
+		assert.Equal(t, []string{"ferry JFK-LAX", "AA2", "AA6", "ferry LAX-JFK"}, legs(p.Rotations[0]))
+	})
+	t.Run("Too many jobs", func(t *testing.T) {
+		fleet.SetMaxActiveRotationJobs(0)
+		defer fleet.SetMaxActiveRotationJobs(services.DefaultMaxActiveRotationJobs)
+
+		rr := start("secret", `{"date":"2023-10-05"}`)
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code, rr.Body.String())
+		assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+	})
+}
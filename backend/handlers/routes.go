@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
@@ -18,12 +19,20 @@ type Handler interface {
 // It sets the Content-Type header to application/json and writes the error message
 // and status code to the response writer.
 // If the error implements the Code() method, it uses that to determine the status code.
+// If the error wraps one implementing the Details() method, the details are added
+// to the response, e.g. the individual field errors of a failed validation.
 func newErrorResponse(w http.ResponseWriter, err error, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 
+	res := map[string]any{"error": err.Error()}
+	var detailed interface{ Details() any }
+	if errors.As(err, &detailed) {
+		res["details"] = detailed.Details()
+	}
+
 	code := codeFromError(err, statusCode)
 	w.WriteHeader(code)
-	if err := json.NewEncoder(w).Encode(map[string]string{"error": err.Error()}); err != nil {
+	if err := json.NewEncoder(w).Encode(res); err != nil {
 		http.Error(w, fmt.Sprintf("failed to encode error response: %v", err), http.StatusInternalServerError)
 	}
 }
@@ -56,3 +65,16 @@ func codeFromError(err error, defaultCode int) int {
 
 	return defaultCode
 }
+
+// newJSONResponse is a helper function to write res as JSON with the given status code.
+func newJSONResponse(w http.ResponseWriter, res any, statusCode int) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to encode response: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(append(b, '\n'))
+}
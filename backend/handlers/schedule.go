@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+)
+
+// maxSSIMFileSize is the largest SSIM file ImportSSIM accepts. It holds
+// about 50,000 flight leg records.
+const maxSSIMFileSize = 10 << 20
+
+var _ Handler = (*ScheduleHandler)(nil)
+
+type ScheduleHandler struct {
+	service *services.ScheduleService
+	auth    Middleware
+}
+
+// NewScheduleHandler creates the schedule handler. auth protects the write
+// endpoints, a nil auth disables them.
+func NewScheduleHandler(svc *services.ScheduleService, auth Middleware) *ScheduleHandler {
+	return &ScheduleHandler{service: svc, auth: auth}
+}
+
+func (sh *ScheduleHandler) Register(r *mux.Router) {
+	r.HandleFunc(fmt.Sprintf("%s/schedules", basePathV1), sh.GetSchedules).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetSchedules")
+	r.HandleFunc(fmt.Sprintf("%s/schedules/ssim", basePathV1), sh.ExportSSIM).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("ExportSchedulesSSIM")
+	r.HandleFunc(fmt.Sprintf("%s/schedules/{id:[0-9]+}", basePathV1), sh.GetSchedule).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("GetSchedule")
+	r.HandleFunc(fmt.Sprintf("%s/schedules/{id:[0-9]+}/flights", basePathV1), sh.PreviewFlights).
+		Methods(http.MethodGet, http.MethodOptions).
+		Name("PreviewScheduleFlights")
+
+	r.Handle(fmt.Sprintf("%s/schedules", basePathV1), protect(sh.auth, sh.CreateSchedule)).
+		Methods(http.MethodPost).
+		Name("CreateSchedule")
+	r.Handle(fmt.Sprintf("%s/schedules/ssim", basePathV1), protect(sh.auth, sh.ImportSSIM)).
+		Methods(http.MethodPost).
+		Name("ImportSchedulesSSIM")
+	r.Handle(fmt.Sprintf("%s/schedules/{id:[0-9]+}", basePathV1), protect(sh.auth, sh.ReplaceSchedule)).
+		Methods(http.MethodPut).
+		Name("ReplaceSchedule")
+	r.Handle(fmt.Sprintf("%s/schedules/{id:[0-9]+}", basePathV1), protect(sh.auth, sh.PatchSchedule)).
+		Methods(http.MethodPatch).
+		Name("PatchSchedule")
+	r.Handle(fmt.Sprintf("%s/schedules/{id:[0-9]+}", basePathV1), protect(sh.auth, sh.DeleteSchedule)).
+		Methods(http.MethodDelete).
+		Name("DeleteSchedule")
+	r.Handle(fmt.Sprintf("%s/schedules/{id:[0-9]+}/flights", basePathV1), protect(sh.auth, sh.MaterializeFlights)).
+		Methods(http.MethodPost).
+		Name("MaterializeScheduleFlights")
+}
+
+// GetSchedules lists schedules, optionally filtered by the flightNumber,
+// origin, destination and aircraftId query parameters.
+func (sh *ScheduleHandler) GetSchedules(w http.ResponseWriter, r *http.Request) {
+	filter, err := scheduleFilterFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	opts, err := listOptionsFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := sh.service.ListSchedules(r.Context(), filter, opts)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newPageResponse(w, r, res, opts.Fields)
+}
+
+func (sh *ScheduleHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := scheduleIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := sh.service.FindSchedule(r.Context(), id)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+func (sh *ScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := models.NewSchedule(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := sh.service.CreateSchedule(r.Context(), schedule); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/schedules/%d", basePathV1, schedule.Id))
+	newJSONResponse(w, schedule, http.StatusCreated)
+}
+
+// ReplaceSchedule replaces all fields of a schedule with the request body.
+func (sh *ScheduleHandler) ReplaceSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := scheduleIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := models.NewSchedule(r.Body)
+	if err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := sh.service.UpdateSchedule(r.Context(), id, schedule); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, schedule, http.StatusOK)
+}
+
+// PatchSchedule applies the fields present in the request body to a schedule
+// and leaves all other fields untouched (JSON merge patch).
+func (sh *ScheduleHandler) PatchSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := scheduleIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	schedule, err := sh.service.FindSchedule(r.Context(), id)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&schedule); err != nil {
+		newErrorResponse(w, fmt.Errorf("failed to parse request: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := sh.service.UpdateSchedule(r.Context(), id, &schedule); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, schedule, http.StatusOK)
+}
+
+func (sh *ScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := scheduleIdFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := sh.service.DeleteSchedule(r.Context(), id); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PreviewFlights lists the flights a schedule operates between the from and
+// to dates (inclusive, local to the origin) without creating them.
+func (sh *ScheduleHandler) PreviewFlights(w http.ResponseWriter, r *http.Request) {
+	id, from, to, err := scheduleWindowFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := sh.service.PreviewFlights(r.Context(), id, from, to)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+// MaterializeFlights creates the flights a schedule operates between the from
+// and to dates. Flights that already exist are skipped.
+func (sh *ScheduleHandler) MaterializeFlights(w http.ResponseWriter, r *http.Request) {
+	id, from, to, err := scheduleWindowFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	res, err := sh.service.MaterializeFlights(r.Context(), id, from, to)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+func scheduleIdFromRequest(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, fmt.Errorf("invalid schedule id %q", mux.Vars(r)["id"])
+	}
+	return id, nil
+}
+
+// scheduleWindowFromRequest reads the schedule id and the required from and
+// to date query parameters.
+func scheduleWindowFromRequest(r *http.Request) (int, time.Time, time.Time, error) {
+	id, err := scheduleIdFromRequest(r)
+	if err != nil {
+		return 0, time.Time{}, time.Time{}, err
+	}
+
+	var dates [2]time.Time
+	for i, param := range []string{"from", "to"} {
+		v := r.URL.Query().Get(param)
+		if v == "" {
+			return 0, time.Time{}, time.Time{}, fmt.Errorf("missing %s date", param)
+		}
+		if dates[i], err = time.Parse(time.DateOnly, v); err != nil {
+			return 0, time.Time{}, time.Time{}, fmt.Errorf("invalid %s %q: must be a date (YYYY-MM-DD)", param, v)
+		}
+	}
+
+	return id, dates[0], dates[1], nil
+}
+
+// ExportSSIM downloads the schedules as an IATA SSIM Chapter 7 file,
+// optionally filtered like GetSchedules.
+func (sh *ScheduleHandler) ExportSSIM(w http.ResponseWriter, r *http.Request) {
+	filter, err := scheduleFilterFromRequest(r)
+	if err != nil {
+		newErrorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := sh.service.ExportSSIM(r.Context(), &buf, filter); err != nil {
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newFileResponse(w, &buf, "text/plain; charset=utf-8", "schedules.ssim")
+}
+
+// ImportSSIM stores the schedules and flights of the IATA SSIM Chapter 7 file
+// in the request body. Invalid files are rejected as a whole with the
+// problems listed by line, files larger than maxSSIMFileSize with 413 Content
+// Too Large.
+func (sh *ScheduleHandler) ImportSSIM(w http.ResponseWriter, r *http.Request) {
+	res, err := sh.service.ImportSSIM(r.Context(), http.MaxBytesReader(w, r.Body, maxSSIMFileSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			newErrorResponse(w, fmt.Errorf("SSIM file must not be larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		newErrorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	newJSONResponse(w, res, http.StatusOK)
+}
+
+func scheduleFilterFromRequest(r *http.Request) (services.ScheduleFilter, error) {
+	q := r.URL.Query()
+	filter := services.ScheduleFilter{
+		FlightNumber: q.Get("flightNumber"),
+		Origin:       q.Get("origin"),
+		Destination:  q.Get("destination"),
+	}
+	if v := q.Get("aircraftId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			return filter, fmt.Errorf("invalid aircraftId %q: must be a positive integer", v)
+		}
+		filter.AircraftId = id
+	}
+	return filter, nil
+}
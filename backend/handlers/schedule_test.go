@@ -0,0 +1,324 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedules(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewScheduleHandler(services.NewScheduleService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	// Mondays, Wednesdays and Fridays in March 2024, across the start of
+	// daylight saving time in New York on Sunday, March 10.
+	const aa100 = `{"flight_number":"AA100","aircraft_id":1,"origin":"JFK","destination":"LAX","days_of_week":"1.3.5..","departure_local":"08:00","block_minutes":390,"effective_from":"2024-03-01","effective_to":"2024-03-31"}`
+
+	// Test case 1: Write schedules
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Missing token", method: http.MethodPost, path: "/api/v1/schedules", body: aa100, expectedStatus: http.StatusUnauthorized},
+		{name: "Create", method: http.MethodPost, path: "/api/v1/schedules", token: "secret", body: aa100, expectedStatus: http.StatusCreated},
+		{name: "Create with invalid pattern", method: http.MethodPost, path: "/api/v1/schedules", token: "secret", body: `{"flight_number":"AA100","aircraft_id":1,"origin":"JFK","destination":"LAX","days_of_week":"135","departure_local":"25:00","block_minutes":0,"effective_from":"2024-03-31","effective_to":"2024-03-01"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Create beyond aircraft range", method: http.MethodPost, path: "/api/v1/schedules", token: "secret", body: `{"flight_number":"AA200","aircraft_id":1,"origin":"JFK","destination":"PVG","days_of_week":"1234567","departure_local":"08:00","block_minutes":900,"effective_from":"2024-03-01","effective_to":"2024-03-31"}`, expectedStatus: http.StatusUnprocessableEntity},
+		{name: "Get", method: http.MethodGet, path: "/api/v1/schedules/1", expectedStatus: http.StatusOK},
+		{name: "List", method: http.MethodGet, path: "/api/v1/schedules?origin=jfk", expectedStatus: http.StatusOK},
+		{name: "Patch", method: http.MethodPatch, path: "/api/v1/schedules/1", token: "secret", body: `{"block_minutes":380}`, expectedStatus: http.StatusOK},
+		{name: "Replace", method: http.MethodPut, path: "/api/v1/schedules/1", token: "secret", body: aa100, expectedStatus: http.StatusOK},
+		{name: "Replace unknown schedule", method: http.MethodPut, path: "/api/v1/schedules/9", token: "secret", body: aa100, expectedStatus: http.StatusNotFound},
+		{name: "Preview without window", method: http.MethodGet, path: "/api/v1/schedules/1/flights?from=2024-03-01", expectedStatus: http.StatusBadRequest},
+		{name: "Preview reversed window", method: http.MethodGet, path: "/api/v1/schedules/1/flights?from=2024-03-31&to=2024-03-01", expectedStatus: http.StatusBadRequest},
+		{name: "Preview too long window", method: http.MethodGet, path: "/api/v1/schedules/1/flights?from=2024-01-01&to=2025-12-31", expectedStatus: http.StatusBadRequest},
+		{name: "Preview unknown schedule", method: http.MethodGet, path: "/api/v1/schedules/9/flights?from=2024-03-01&to=2024-03-31", expectedStatus: http.StatusNotFound},
+		{name: "Materialize without token", method: http.MethodPost, path: "/api/v1/schedules/1/flights?from=2024-03-01&to=2024-03-31", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code, rr.Body.String())
+		})
+	}
+
+	expand := func(method string) models.ScheduleFlights {
+		req, _ := http.NewRequestWithContext(ctx, method, "/api/v1/schedules/1/flights?from=2024-03-08&to=2024-03-13", http.NoBody)
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var res models.ScheduleFlights
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+		return res
+	}
+	departures := func(flights []models.Flight) []string {
+		var times []string
+		for _, f := range flights {
+			times = append(times, f.DepartureTime.Format(time.RFC3339))
+		}
+		return times
+	}
+
+	// Test case 2: Preview follows the local departure time across DST
+	preview := expand(http.MethodGet)
+	assert.Equal(t, []string{"2024-03-08T13:00:00Z", "2024-03-11T12:00:00Z", "2024-03-13T12:00:00Z"}, departures(preview.Flights))
+	assert.Equal(t, "2024-03-08T19:30:00Z", preview.Flights[0].ArrivalTime.Format(time.RFC3339))
+	n, err := db.NewSelect().Model((*models.Flight)(nil)).Where("schedule_id = 1").Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	// Test case 3: Materializing is idempotent
+	created := expand(http.MethodPost)
+	assert.Equal(t, departures(preview.Flights), departures(created.Flights))
+	assert.Equal(t, 0, created.Skipped)
+	for _, f := range created.Flights {
+		assert.NotZero(t, f.Id)
+		assert.Equal(t, 1, f.ScheduleId)
+	}
+	again := expand(http.MethodPost)
+	assert.Empty(t, again.Flights)
+	assert.Equal(t, 3, again.Skipped)
+
+	// Test case 4: Deleting the schedule keeps its flights
+	req, _ := http.NewRequestWithContext(ctx, http.MethodDelete, "/api/v1/schedules/1", http.NoBody)
+	req.Header.Set("Authorization", "Bearer secret")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNoContent, rr.Code, rr.Body.String())
+
+	n, err = db.NewSelect().Model((*models.Flight)(nil)).Where("flight_number = ?", "AA100").Where("schedule_id IS NULL").Count(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	// Test case 5: Departures in the skipped and repeated hours in Frankfurt,
+	// where time.Date returns the second occurrence of a repeated time.
+	req, _ = http.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/schedules", strings.NewReader(`{"flight_number":"LH1000","aircraft_id":4,"origin":"FRA","destination":"CDG","days_of_week":"1234567","departure_local":"02:30","block_minutes":80,"effective_from":"2024-03-01","effective_to":"2024-10-31"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	require.Equal(t, http.StatusCreated, rr.Code, rr.Body.String())
+	var lh1000 models.Schedule
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &lh1000))
+
+	for window, expected := range map[string][]string{
+		"from=2024-03-30&to=2024-04-01": {"2024-03-30T01:30:00Z", "2024-03-31T01:30:00Z", "2024-04-01T00:30:00Z"},
+		"from=2024-10-26&to=2024-10-28": {"2024-10-26T00:30:00Z", "2024-10-27T00:30:00Z", "2024-10-28T01:30:00Z"},
+	} {
+		req, _ = http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("/api/v1/schedules/%d/flights?%s", lh1000.Id, window), http.NoBody)
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var res models.ScheduleFlights
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+		assert.Equal(t, expected, departures(res.Flights), window)
+	}
+}
+
+func TestSchedulesSSIM(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	r := mux.NewRouter()
+	NewScheduleHandler(services.NewScheduleService(db), BearerAuth([]string{"secret"})).Register(r)
+
+	// record builds an SSIM record from its fields by 1-based position.
+	record := func(fields map[int]string) string {
+		rec := []byte(strings.Repeat(" ", models.SSIMRecordLength))
+		for pos, v := range fields {
+			copy(rec[pos-1:], v)
+		}
+		return string(rec)
+	}
+	leg := func(airline, number, period, days, origin, std, depVar, destination, sta, arrVar, aircraft string) string {
+		return record(map[int]string{1: "3", 3: airline, 6: number, 10: "01", 12: "01", 14: "J", 15: period, 29: days,
+			37: origin, 40: std, 44: std, 48: depVar, 55: destination, 58: sta, 62: sta, 66: arrVar, 73: aircraft, 193: "00"})
+	}
+	file := func(legs ...string) string {
+		lines := []string{record(map[int]string{1: "1", 2: "AIRLINE STANDARD SCHEDULE DATA SET"}), record(map[int]string{1: "2", 2: "L", 3: "LH"})}
+		lines = append(lines, legs...)
+		return strings.Join(append(lines, record(map[int]string{1: "5", 194: "E"})), "\r\n") + "\r\n"
+	}
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodPost, "/api/v1/schedules/ssim", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// LH1000 is operated daily by an A320, AA100 on Monday, Wednesday and
+	// Friday by a Boeing 737 across the US daylight saving time switch on
+	// 2024-03-10.
+	valid := file(
+		leg("LH ", "1000", "01MAR2407APR24", "1234567", "FRA", "0700", "+0100", "CDG", "0820", "+0100", "320"),
+		record(map[int]string{1: "4", 3: "LH ", 6: "1000", 10: "01", 12: "01", 14: "J", 29: "A", 30: "B", 31: "127", 34: "FRA", 37: "CDG", 40: "LUFTHANSA CITYLINE"}),
+		leg("AA ", "0100", "01MAR2431MAR24", "1 3 5  ", "JFK", "0800", "-0500", "LAX", "1130", "-0800", "737"),
+	)
+
+	t.Run("Missing token", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, post("", valid).Code)
+	})
+
+	t.Run("File too large", func(t *testing.T) {
+		rr := post("secret", strings.Repeat("\n", maxSSIMFileSize+1))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, rr.Body.String())
+	})
+
+	t.Run("Invalid file", func(t *testing.T) {
+		rr := post("secret", file(
+			leg("LH ", "1000", "01MAR2407APR24", "1234567", "FRA", "0700", "+0100", "CDG", "0820", "+0100", "744"),
+			leg("LH ", "1002", "01MAR2407APR24", "1234567", "FRA", "0700", "+0100", "XXX", "0820", "+0100", "320"),
+			leg("LH ", "1004", "31FEB2407APR24", "1234567", "FRA", "0700", "+0100", "CDG", "0820", "+0100", "320"),
+		))
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+
+		var res struct {
+			Details []models.ValidationError `json:"details"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+		lines := []string{}
+		for _, d := range res.Details {
+			lines = append(lines, d.Field)
+		}
+		assert.Equal(t, []string{"line 5"}, lines, "only the parse error is reported before the database is checked")
+
+		rr = post("secret", file(
+			leg("LH ", "1000", "01MAR2407APR24", "1234567", "FRA", "0700", "+0100", "CDG", "0820", "+0100", "744"),
+			leg("LH ", "1002", "01MAR2407APR24", "1234567", "FRA", "0700", "+0100", "XXX", "0820", "+0100", "320"),
+		))
+		require.Equal(t, http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+		assert.Contains(t, rr.Body.String(), `unknown aircraft type \"744\"`)
+		assert.Contains(t, rr.Body.String(), "destination unknown airport XXX")
+	})
+
+	var imported models.SSIMImport
+	t.Run("Import", func(t *testing.T) {
+		rr := post("secret", valid)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &imported))
+		assert.Equal(t, models.SSIMImport{Legs: 2, Segments: 1, SchedulesCreated: 2, FlightsCreated: 38 + 13}, imported)
+
+		var schedules models.Page[models.Schedule]
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/schedules?flightNumber=AA100", http.NoBody)
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &schedules))
+		require.Len(t, schedules.Data, 1)
+		s := schedules.Data[0]
+		assert.Equal(t, "1.3.5..", s.DaysOfWeek)
+		assert.Equal(t, "08:00", s.DepartureLocal)
+		assert.Equal(t, 390, s.BlockMinutes)
+		assert.Equal(t, "2024-03-01", s.EffectiveFrom)
+		assert.Equal(t, "2024-03-31", s.EffectiveTo)
+
+		var departure time.Time
+		require.NoError(t, db.NewSelect().Model((*models.Flight)(nil)).Column("departure_time").
+			Where("flight_number = 'AA100' AND departure_time >= ?", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)).Order("departure_time").Offset(4).Limit(1).Scan(ctx, &departure))
+		assert.Equal(t, "2024-03-11T12:00:00Z", departure.UTC().Format(time.RFC3339))
+	})
+
+	t.Run("Import again", func(t *testing.T) {
+		rr := post("secret", valid)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+		var res models.SSIMImport
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &res))
+		assert.Equal(t, models.SSIMImport{Legs: 2, Segments: 1, SchedulesExisting: 2, FlightsExisting: imported.FlightsCreated}, res)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/schedules/ssim", http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		lines := strings.Split(strings.TrimSuffix(rr.Body.String(), "\n"), "\n")
+		assert.Zero(t, len(lines)%5, "records are padded to blocks of five")
+		for _, line := range lines {
+			assert.Len(t, line, models.SSIMRecordLength)
+		}
+
+		legs, err := models.ParseSSIM(strings.NewReader(rr.Body.String()))
+		require.NoError(t, err)
+		type period struct{ flight, from, to, days, depVar, arrVar string }
+		periods := []period{}
+		for _, l := range legs {
+			periods = append(periods, period{l.FlightDesignator(), l.From.Format(time.DateOnly), l.To.Format(time.DateOnly), l.DaysOfWeek,
+				fmt.Sprint(l.DepartureOffset), fmt.Sprint(l.ArrivalOffset)})
+		}
+		assert.Equal(t, []period{
+			{"AA100", "2024-03-01", "2024-03-08", "1.3.5..", "-300", "-480"},
+			{"AA100", "2024-03-11", "2024-03-29", "1.3.5..", "-240", "-420"},
+			{"LH1000", "2024-03-01", "2024-03-30", "1234567", "60", "60"},
+			{"LH1000", "2024-03-31", "2024-04-07", "1234567", "120", "120"},
+		}, periods)
+		for _, l := range legs {
+			s := l.Schedule(1)
+			assert.Contains(t, []string{"07:00", "08:00"}, s.DepartureLocal)
+			assert.Contains(t, []int{80, 390}, s.BlockMinutes)
+		}
+	})
+
+	t.Run("Export aircraft without code", func(t *testing.T) {
+		schedule := models.Schedule{FlightNumber: "GA1", AircraftId: 2, Origin: "LAX", Destination: "JFK", DaysOfWeek: "1......", DepartureLocal: "09:00", BlockMinutes: 330, EffectiveFrom: "2024-03-04", EffectiveTo: "2024-03-04"}
+		_, err := db.NewInsert().Model(&schedule).Exec(ctx)
+		require.NoError(t, err)
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/schedules/ssim", http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	})
+	t.Run("Export flight number without SSIM designator", func(t *testing.T) {
+		schedule := models.Schedule{FlightNumber: "LH12345", AircraftId: 4, Origin: "FRA", Destination: "CDG", DaysOfWeek: "1......", DepartureLocal: "09:00", BlockMinutes: 80, EffectiveFrom: "2024-03-04", EffectiveTo: "2024-03-04"}
+		_, err := db.NewInsert().Model(&schedule).Exec(ctx)
+		require.NoError(t, err)
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/v1/schedules/ssim?flightNumber=LH12345", http.NoBody)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusConflict, rr.Code, rr.Body.String())
+		assert.Contains(t, rr.Body.String(), `flight number \"LH12345\"`)
+	})
+}
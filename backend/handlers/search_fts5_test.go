@@ -0,0 +1,98 @@
+//go:build sqlite_fts5
+
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/leanderkunstmann/terraroute/backend/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSearchAirportsFTS5 covers the SQLite full-text index, which only
+// exists when go-sqlite3 is built with the sqlite_fts5 tag:
+//
+//	go test -tags sqlite_fts5 ./...
+func TestSearchAirportsFTS5(t *testing.T) {
+	ctx := t.Context()
+	db, err := database.New(ctx, &database.Config{LocalDB: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		db.Close()
+	}()
+
+	handler := NewAirportHandler(services.NewAirportService(db), nil)
+
+	// indexed returns the airports the index matches for an FTS5 query.
+	indexed := func(t *testing.T, match string) []string {
+		var iatas []string
+		require.NoError(t, db.NewSelect().
+			Table(database.AirportSearchTable).
+			Column("iata").
+			Where(database.AirportSearchTable+" MATCH ?", match).
+			Scan(t.Context(), &iatas))
+		return iatas
+	}
+	search := func(t *testing.T, q string) []string {
+		req, _ := http.NewRequestWithContext(t.Context(), http.MethodGet, "/airports/search?q="+url.QueryEscape(q), http.NoBody)
+		rr := httptest.NewRecorder()
+		handler.searchAirports(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		var matches []models.AirportMatch
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &matches))
+		var iatas []string
+		for _, m := range matches {
+			iatas = append(iatas, m.IATA)
+		}
+		return iatas
+	}
+
+	t.Run("Seeded airports are indexed", func(t *testing.T) {
+		assert.Equal(t, []string{"ZRH"}, indexed(t, `"zurich"*`))
+		assert.Equal(t, []string{"CDG"}, indexed(t, `"charles"* "de"*`))
+	})
+
+	t.Run("Search", func(t *testing.T) {
+		for q, expectedFirst := range map[string]string{
+			"zurich":     "ZRH",
+			"Charles de": "CDG",
+			"eddf":       "FRA",
+			"frankfrut":  "FRA", // typos fall back to the leading characters
+			"zuirch":     "ZRH",
+		} {
+			if iatas := search(t, q); assert.NotEmpty(t, iatas, q) {
+				assert.Equal(t, expectedFirst, iatas[0], q)
+			}
+		}
+		assert.Empty(t, search(t, "xyzzy"))
+	})
+
+	t.Run("Index follows writes", func(t *testing.T) {
+		airport := models.Airport{IATA: "BSL", ICAO: "LFSB", Name: "EuroAirport Basel-Mulhouse", City: "Basel", Latitude: 47.59, Longitude: 7.5291, TimeZone: "Europe/Zurich"}
+		_, err := db.NewInsert().Model(&airport).Exec(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"BSL"}, indexed(t, `city : "basel"*`))
+		assert.Equal(t, []string{"BSL"}, search(t, "euroairport"))
+
+		airport.City = "Saint-Louis"
+		_, err = db.NewUpdate().Model(&airport).WherePK().Exec(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, indexed(t, `city : "basel"*`))
+		assert.Equal(t, []string{"BSL"}, indexed(t, `city : "saint"* "louis"*`))
+
+		_, err = db.NewDelete().Model(&airport).WherePK().Exec(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, indexed(t, `"euroairport"*`))
+		assert.Empty(t, search(t, "euroairport"))
+	})
+}
@@ -6,7 +6,9 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gorilla/mux"
@@ -21,22 +23,35 @@ type Config struct {
 	Database    database.Config `mapstructure:"database"`
 	AllowedCors []string        `mapstructure:"allowed_cors"`
 	ListenAddr  string          `mapstructure:"listen_addr"`
+	APITokens   []string        `mapstructure:"api_tokens"`
 }
 
 type svcs struct {
 	Aircraft *services.AircraftService
+	Airframe *services.AirframeService
 	Airport  *services.AirportService
+	Catalog  *services.CatalogService
+	Connect  *services.ConnectionService
+	Country  *services.CountryService
+	Fleet    *services.FleetService
+	Flight   *services.FlightService
+	Metro    *services.MetroAreaService
+	Schedule *services.ScheduleService
 	Distance *services.DistanceCalculator
+	Eligible *services.EligibilityService
 }
 
 const (
 	listenAddr        = ":8080"
 	readHeaderTimeout = 5 * time.Second
+	shutdownTimeout   = 10 * time.Second
 	defaultCORSOrigin = "http://localhost:5173"
 )
 
 func main() {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	err := godotenv.Load()
 	if err != nil {
 		log.Fatal("Error loading .env file")
@@ -52,11 +67,19 @@ func main() {
 		log.Printf("Allowed CORS origins set to default: %v", allowedOrigins)
 	}
 
+	var apiTokens []string
+	if tokens := os.Getenv("API_TOKENS"); tokens != "" {
+		apiTokens = strings.Split(tokens, ",")
+	} else {
+		log.Printf("No API tokens configured, write endpoints are disabled")
+	}
+
 	// TODO: read config via viper from both .env and config file
 	// optionally use viper with cobra to also read from CLI args
 	cfg := Config{
 		ListenAddr:  listenAddr,
 		AllowedCors: allowedOrigins,
+		APITokens:   apiTokens,
 		Database: database.Config{
 			LocalDB:  strings.EqualFold(os.Getenv("LOCAL_DB"), "true"),
 			Username: os.Getenv("DB_USER"),
@@ -74,13 +97,40 @@ func main() {
 
 	s := svcs{
 		Aircraft: services.NewAircraftService(db),
+		Airframe: services.NewAirframeService(db),
 		Airport:  services.NewAirportService(db),
+		Catalog:  services.NewCatalogService(db),
+		Connect:  services.NewConnectionService(db),
+		Country:  services.NewCountryService(db),
+		Fleet:    services.NewFleetService(db),
+		Flight:   services.NewFlightService(db),
+		Metro:    services.NewMetroAreaService(db),
+		Schedule: services.NewScheduleService(db),
 		Distance: services.NewDistanceCalculator(db),
+		Eligible: services.NewEligibilityService(db),
+	}
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, s, os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
+
+	auth := handlers.BearerAuth(cfg.APITokens)
 	handlers := []handlers.Handler{
-		handlers.NewAircraftHandler(s.Aircraft),
-		handlers.NewAirportHandler(s.Airport),
+		handlers.NewAircraftHandler(s.Aircraft, auth),
+		handlers.NewAirframeHandler(s.Airframe, auth),
+		handlers.NewAirportHandler(s.Airport, auth),
+		handlers.NewBoardHandler(s.Flight),
+		handlers.NewCatalogHandler(s.Catalog, auth),
+		handlers.NewConnectionHandler(s.Connect),
+		handlers.NewCountryHandler(s.Country),
+		handlers.NewFleetHandler(s.Fleet, auth),
+		handlers.NewFlightHandler(s.Flight, auth),
+		handlers.NewMetroAreaHandler(s.Metro, auth),
+		handlers.NewScheduleHandler(s.Schedule, auth),
 		handlers.NewDistanceHandler(s.Distance),
+		handlers.NewEligibilityHandler(s.Eligible),
 	}
 
 	r := mux.NewRouter()
@@ -91,8 +141,9 @@ func main() {
 
 	corsOptions := cors.New(cors.Options{
 		AllowedOrigins: cfg.AllowedCors,
-		AllowedMethods: []string{"GET", "POST", "OPTIONS"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		ExposedHeaders: []string{"Link", "X-Total-Count"},
 		// AllowCredentials: true,
 	})
 
@@ -105,6 +156,11 @@ func main() {
 		Handler:           handler,
 		ReadHeaderTimeout: readHeaderTimeout,
 	}
+	// Streams never end on their own, close them so Shutdown does not wait
+	// for their clients to disconnect.
+	srv.RegisterOnShutdown(s.Flight.CloseStreams)
+	go s.Flight.StreamPositions(ctx, services.DefaultPositionInterval)
+	go s.Fleet.PruneRotationJobs(ctx, services.RotationJobPruneInterval)
 
 	fmt.Println("Server listening on :8080")
 	fmt.Println("Available routes:")
@@ -115,7 +171,25 @@ func main() {
 		}
 		return nil
 	})
-	if err := srv.ListenAndServe(); err != nil {
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
 		log.Fatal(err)
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Failed to shut down gracefully: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		log.Printf("Failed to close database: %v", err)
 	}
 }
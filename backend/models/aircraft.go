@@ -1,5 +1,16 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/uptrace/bun"
+)
+
 type AircraftType string
 
 const (
@@ -11,8 +22,13 @@ const (
 	Military   AircraftType = "military"
 )
 
+// AircraftTypes lists all known aircraft types.
+var AircraftTypes = []AircraftType{Ultralight, Light, Heavy, Commercial, Cargo, Military}
+
 type Manufacturer string
 
+// Manufacturers present in the initial catalog. The catalog itself is the
+// manufacturers table, see ManufacturerEntry.
 const (
 	Airbus     Manufacturer = "Airbus"
 	Antonov    Manufacturer = "Antonov"
@@ -21,10 +37,137 @@ const (
 	Gulfstream Manufacturer = "Gulfstream"
 )
 
+// ManufacturerEntry is a manufacturer of the catalog aircraft are checked against.
+type ManufacturerEntry struct {
+	bun.BaseModel `bun:"table:manufacturers,alias:manufacturer"`
+
+	Name          Manufacturer `json:"name" bun:",pk"`          // unique identifier
+	CountryCode   string       `json:"country" bun:",nullzero"` // ISO 3166-1 alpha-2 code, foreign key
+	AircraftCount int          `json:"aircraft_count" bun:",scanonly"`
+}
+
+// NewManufacturerEntry decodes and validates a manufacturer from b.
+func NewManufacturerEntry(b io.Reader) (*ManufacturerEntry, error) {
+	var m ManufacturerEntry
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to decode manufacturer: %w", err)
+	}
+
+	var errs ValidationErrors
+	m.Name = Manufacturer(strings.TrimSpace(string(m.Name)))
+	if m.Name == "" {
+		errs.add("name", "is required")
+	}
+	if m.CountryCode != "" && !alpha2Pattern.MatchString(m.CountryCode) {
+		errs.add("country", "must be an ISO 3166-1 alpha-2 code")
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// aircraftCodePattern matches IATA aircraft type codes such as "320" or "77W".
+var aircraftCodePattern = regexp.MustCompile(`^[A-Z0-9]{3}$`)
+
+// AircraftTypeEntry is an aircraft type together with the number of aircraft
+// of that type.
+type AircraftTypeEntry struct {
+	Type          AircraftType `json:"type"`
+	AircraftCount int          `json:"aircraft_count"`
+}
+
+// Aircraft describes an aircraft model. Field names carry their unit; a zero
+// performance or capacity value means it is unknown.
 type Aircraft struct {
-	Id           int          `json:"id"` // unique identifier
-	Type         AircraftType `json:"type"`
-	Name         string       `json:"name"`
-	Manufacturer Manufacturer `json:"manufacturer"`
-	Range        int          `json:"range"`
+	Id               int          `json:"id" bun:",pk,autoincrement"` // unique identifier
+	Type             AircraftType `json:"type"`
+	Name             string       `json:"name"`
+	Manufacturer     Manufacturer `json:"manufacturer"`
+	IATACode         string       `json:"iata_code,omitempty" bun:"iata_code,nullzero"`     // IATA aircraft type code used in schedules, e.g. "320"
+	RangeNm          int          `json:"range"`                                            // range in nautical miles
+	CruiseSpeedKts   int          `json:"cruise_speed_kts" bun:",nullzero"`                 // cruise speed in knots true airspeed
+	CruiseAltitudeFt int          `json:"cruise_altitude_ft" bun:",nullzero"`               // typical cruise altitude in feet
+	MTOWKg           int          `json:"mtow_kg" bun:"mtow_kg,nullzero"`                   // maximum takeoff weight
+	MLWKg            int          `json:"mlw_kg" bun:"mlw_kg,nullzero"`                     // maximum landing weight
+	OEWKg            int          `json:"oew_kg" bun:"oew_kg,nullzero"`                     // operating empty weight
+	FuelCapacityKg   int          `json:"fuel_capacity_kg" bun:",nullzero"`                 // usable fuel
+	Seats            int          `json:"seats" bun:",nullzero"`                            // passenger seats in a typical configuration
+	CargoCapacityKg  int          `json:"cargo_capacity_kg" bun:",nullzero"`                // maximum cargo payload
+	Engines          int          `json:"engines" bun:",nullzero"`                          // number of engines
+	ETOPSMinutes     int          `json:"etops_minutes" bun:"etops_minutes,nullzero"`       // ETOPS rating, zero if not certified
+	TakeoffRunwayM   int          `json:"takeoff_runway_m" bun:"takeoff_runway_m,nullzero"` // takeoff field length at MTOW
+	LandingRunwayM   int          `json:"landing_runway_m" bun:"landing_runway_m,nullzero"` // landing field length at MLW
+}
+
+// NewAircraft decodes and validates an aircraft from b.
+func NewAircraft(b io.Reader) (*Aircraft, error) {
+	var aircraft Aircraft
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&aircraft); err != nil {
+		return nil, fmt.Errorf("failed to decode aircraft: %w", err)
+	}
+
+	if err := aircraft.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &aircraft, nil
+}
+
+// Validate checks that the type is known, a manufacturer is set, the range is
+// positive, the other figures are not negative and the weights are consistent.
+// The manufacturer is checked against the catalog when the aircraft is stored.
+func (a *Aircraft) Validate() error {
+	var errs ValidationErrors
+	if !slices.Contains(AircraftTypes, a.Type) {
+		errs.add("type", fmt.Sprintf("must be one of %v", AircraftTypes))
+	}
+	if a.Name == "" {
+		errs.add("name", "is required")
+	}
+	if a.Manufacturer == "" {
+		errs.add("manufacturer", "is required")
+	}
+	if a.IATACode != "" && !aircraftCodePattern.MatchString(a.IATACode) {
+		errs.add("iata_code", "must be three uppercase letters or digits")
+	}
+	if a.RangeNm <= 0 {
+		errs.add("range", "must be positive")
+	}
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"cruise_speed_kts", a.CruiseSpeedKts},
+		{"cruise_altitude_ft", a.CruiseAltitudeFt},
+		{"mtow_kg", a.MTOWKg},
+		{"mlw_kg", a.MLWKg},
+		{"oew_kg", a.OEWKg},
+		{"fuel_capacity_kg", a.FuelCapacityKg},
+		{"seats", a.Seats},
+		{"cargo_capacity_kg", a.CargoCapacityKg},
+		{"engines", a.Engines},
+		{"etops_minutes", a.ETOPSMinutes},
+		{"takeoff_runway_m", a.TakeoffRunwayM},
+		{"landing_runway_m", a.LandingRunwayM},
+	} {
+		if f.value < 0 {
+			errs.add(f.name, "must not be negative")
+		}
+	}
+	if a.MTOWKg > 0 && a.MLWKg > a.MTOWKg {
+		errs.add("mlw_kg", "must not exceed mtow_kg")
+	}
+	if a.OEWKg > 0 && a.MLWKg > 0 && a.OEWKg >= a.MLWKg {
+		errs.add("oew_kg", "must be less than mlw_kg")
+	}
+	if a.OEWKg > 0 && a.MTOWKg > 0 && a.OEWKg >= a.MTOWKg {
+		errs.add("oew_kg", "must be less than mtow_kg")
+	}
+	return errs.err()
 }
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+)
+
+type AirframeStatus string
+
+const (
+	AirframeActive      AirframeStatus = "active"
+	AirframeMaintenance AirframeStatus = "maintenance"
+	AirframeStored      AirframeStatus = "stored"
+	AirframeRetired     AirframeStatus = "retired"
+)
+
+// AirframeStatuses lists all known airframe statuses.
+var AirframeStatuses = []AirframeStatus{AirframeActive, AirframeMaintenance, AirframeStored, AirframeRetired}
+
+// registrationPattern matches aircraft registrations such as "N12345",
+// "D-AIMA" or "HB-JNA": a nationality prefix with an optional dash followed
+// by the registration mark.
+var registrationPattern = regexp.MustCompile(`^[A-Z0-9]{1,3}-?[A-Z0-9]{1,5}$`)
+
+// Airframe is an individual aircraft identified by its registration (tail
+// number). Aircraft describes its type.
+type Airframe struct {
+	Id           int            `json:"id" bun:",pk,autoincrement"` // unique identifier
+	Registration string         `json:"registration"`               // unique tail number, e.g. "D-AIMA"
+	AircraftId   int            `json:"aircraft_id"`                // aircraft type, foreign key
+	Operator     string         `json:"operator"`
+	SerialNumber string         `json:"serial_number"`             // manufacturer serial number (MSN)
+	HomeBase     string         `json:"home_base" bun:",nullzero"` // IATA code of the home base airport, foreign key
+	Status       AirframeStatus `json:"status"`
+}
+
+// NewAirframe decodes and validates an airframe from b. A missing status
+// defaults to active.
+func NewAirframe(b io.Reader) (*Airframe, error) {
+	var airframe Airframe
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&airframe); err != nil {
+		return nil, fmt.Errorf("failed to decode airframe: %w", err)
+	}
+	if airframe.Status == "" {
+		airframe.Status = AirframeActive
+	}
+
+	if err := airframe.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &airframe, nil
+}
+
+// Validate checks the registration, home base and status formats and that an
+// aircraft type is set.
+func (a *Airframe) Validate() error {
+	var errs ValidationErrors
+	if !registrationPattern.MatchString(a.Registration) {
+		errs.add("registration", "must be an uppercase aircraft registration, e.g. D-AIMA")
+	}
+	if a.AircraftId <= 0 {
+		errs.add("aircraft_id", "is required")
+	}
+	if a.HomeBase != "" && !iataCodePattern.MatchString(a.HomeBase) {
+		errs.add("home_base", "must be three uppercase letters")
+	}
+	if !slices.Contains(AirframeStatuses, a.Status) {
+		errs.add("status", fmt.Sprintf("must be one of %v", AirframeStatuses))
+	}
+	return errs.err()
+}
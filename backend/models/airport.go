@@ -1,11 +1,131 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+)
+
+var (
+	iataCodePattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	icaoCodePattern = regexp.MustCompile(`^[A-Z]{4}$`)
+	alpha2Pattern   = regexp.MustCompile(`^[A-Z]{2}$`)
+)
+
 type Airport struct {
-	IATA      string  `json:"iata"` // unique identifier
-	Name      string  `json:"name"`
-	City      string  `json:"city"`
-	Country   string  `json:"country"`
-	Continent string  `json:"continent"`
-	Latitude  float64 `json:"latitude"`
-	Longitude float64 `json:"longitude"`
+	IATA                 string  `json:"iata" bun:",pk"` // unique identifier
+	ICAO                 string  `json:"icao"`
+	Name                 string  `json:"name"`
+	City                 string  `json:"city"`
+	Country              string  `json:"country" bun:",nullzero"`        // country name, copied from the countries table
+	CountryCode          string  `json:"country_code" bun:",nullzero"`   // ISO 3166-1 alpha-2 code, foreign key
+	Continent            string  `json:"continent" bun:",nullzero"`      // continent name, copied from the continents table
+	ContinentCode        string  `json:"continent_code" bun:",nullzero"` // continent code, foreign key
+	MetroCode            string  `json:"metro" bun:",nullzero"`          // IATA metropolitan area code, foreign key
+	Latitude             float64 `json:"latitude"`
+	Longitude            float64 `json:"longitude"`
+	TimeZone             string  `json:"time_zone"`                                        // IANA time zone name, e.g. "Europe/Zurich"
+	MinConnectionMinutes int     `json:"min_connection_minutes,omitempty" bun:",nullzero"` // minimum time from arrival to a connecting departure, zero uses DefaultMinConnectionMinutes
+}
+
+// DefaultMinConnectionMinutes is the minimum connection time of airports that
+// do not publish one.
+const DefaultMinConnectionMinutes = 60
+
+// MinConnectionTime returns the minimum connection time at the airport.
+func (a *Airport) MinConnectionTime() time.Duration {
+	if a.MinConnectionMinutes > 0 {
+		return time.Duration(a.MinConnectionMinutes) * time.Minute
+	}
+	return DefaultMinConnectionMinutes * time.Minute
+}
+
+// NewAirport decodes and validates an airport from b.
+func NewAirport(b io.Reader) (*Airport, error) {
+	var airport Airport
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&airport); err != nil {
+		return nil, fmt.Errorf("failed to decode airport: %w", err)
+	}
+
+	if err := airport.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &airport, nil
+}
+
+// Validate checks the format of the codes, the coordinate ranges and the time zone.
+func (a *Airport) Validate() error {
+	var errs ValidationErrors
+	if !iataCodePattern.MatchString(a.IATA) {
+		errs.add("iata", "must be three uppercase letters")
+	}
+	if a.ICAO != "" && !icaoCodePattern.MatchString(a.ICAO) {
+		errs.add("icao", "must be four uppercase letters")
+	}
+	if a.Name == "" {
+		errs.add("name", "is required")
+	}
+	if a.CountryCode != "" && !alpha2Pattern.MatchString(a.CountryCode) {
+		errs.add("country_code", "must be an ISO 3166-1 alpha-2 code")
+	}
+	if a.ContinentCode != "" && !alpha2Pattern.MatchString(a.ContinentCode) {
+		errs.add("continent_code", "must be a two letter continent code")
+	}
+	if a.MetroCode != "" && !iataCodePattern.MatchString(a.MetroCode) {
+		errs.add("metro", "must be three uppercase letters")
+	}
+	if a.Latitude < -90 || a.Latitude > 90 {
+		errs.add("latitude", "must be between -90 and 90")
+	}
+	if a.Longitude < -180 || a.Longitude > 180 {
+		errs.add("longitude", "must be between -180 and 180")
+	}
+	if a.MinConnectionMinutes < 0 {
+		errs.add("min_connection_minutes", "must not be negative")
+	}
+	if a.TimeZone != "" {
+		if _, err := time.LoadLocation(a.TimeZone); err != nil {
+			errs.add("time_zone", "must be an IANA time zone name")
+		}
+	}
+	return errs.err()
+}
+
+// AirportMatch is a single ranked result of an airport text search.
+type AirportMatch struct {
+	Airport
+	Score     float64 `json:"score"`      // relevance between 0 and 1
+	MatchedOn string  `json:"matched_on"` // field that produced the best match
+}
+
+type Runway struct {
+	Id          int64  `json:"id" bun:",pk,autoincrement"`
+	AirportIATA string `json:"airport_iata"` // foreign key
+	Designator  string `json:"designator"`   // e.g. "04L/22R"
+	LengthM     int    `json:"length_m"`
+	WidthM      int    `json:"width_m"`
+	Surface     string `json:"surface"`
+}
+
+// NearbyAirport is an airport together with its great-circle distance to a
+// reference airport.
+type NearbyAirport struct {
+	Airport
+	DistanceKm float64 `json:"distance_km"`
+}
+
+// AirportDetail is an airport together with its related data.
+type AirportDetail struct {
+	Airport
+	UTCOffset  string          `json:"utc_offset"` // current offset of the time zone, e.g. "+02:00"
+	LocalTime  time.Time       `json:"local_time"`
+	Runways    []Runway        `json:"runways"`
+	Departures []Flight        `json:"departures"`
+	Arrivals   []Flight        `json:"arrivals"`
+	Nearest    []NearbyAirport `json:"nearest"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Types of airport boards.
+const (
+	BoardDepartures = "departures"
+	BoardArrivals   = "arrivals"
+)
+
+// Board lists the flights departing from or arriving at an airport in a time
+// window, ordered by scheduled time. All times are local to the airport.
+type Board struct {
+	Airport  string        `json:"airport"`   // IATA code
+	Type     string        `json:"type"`      // departures or arrivals
+	TimeZone string        `json:"time_zone"` // IANA time zone of the times, UTC if the airport has none
+	From     time.Time     `json:"from"`      // start of the window, inclusive
+	To       time.Time     `json:"to"`        // end of the window, exclusive
+	Flights  []BoardFlight `json:"flights"`
+}
+
+// BoardFlight is a row of an airport board. For departures the times are
+// those of the departure and Airport is the destination, for arrivals the
+// times are those of the arrival and Airport is the origin.
+type BoardFlight struct {
+	FlightId      int64        `json:"flight_id"`
+	FlightNumber  string       `json:"flight_number"`
+	Airport       string       `json:"airport"` // IATA code of the other end of the flight
+	City          string       `json:"city,omitempty"`
+	ScheduledTime time.Time    `json:"scheduled_time"`
+	EstimatedTime *time.Time   `json:"estimated_time,omitempty"` // expected time of a flight that has not yet departed or arrived
+	ActualTime    *time.Time   `json:"actual_time,omitempty"`
+	Status        FlightStatus `json:"status"`
+	DivertedTo    string       `json:"diverted_to,omitempty"`
+	AircraftId    int          `json:"aircraft_id"`
+	Aircraft      string       `json:"aircraft,omitempty"`     // aircraft type name
+	Registration  string       `json:"registration,omitempty"` // tail number of the operating airframe
+}
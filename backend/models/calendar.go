@@ -0,0 +1,255 @@
+package models
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// calendarProductId identifies the exporter in iCalendar files.
+	calendarProductId = "-//terraroute//flights//EN"
+	// calendarLineLength is the maximum length in octets of an iCalendar
+	// content line, longer lines are folded.
+	calendarLineLength = 75
+
+	calendarDateTimeLayout = "20060102T150405"
+)
+
+var calendarTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// WriteCalendar writes flights as an iCalendar (RFC 5545) file with one
+// VEVENT per flight. Departure and arrival are given in the time zone of their
+// airport, which is described by a VTIMEZONE covering the flights, and the
+// origin airport is the location of the event. Flights whose airports have no
+// time zone are written in UTC. Flights are identified by their id, so
+// re-importing an updated export replaces the events. stamp is the creation
+// time of the file.
+func WriteCalendar(w io.Writer, flights []FlightDetail, stamp time.Time) error {
+	locations := map[string]*time.Location{}
+	spans := map[string][2]time.Time{}
+	cover := func(a *Airport, t time.Time) {
+		loc := airportTimeZone(a, locations)
+		if loc == time.UTC {
+			return
+		}
+		span, ok := spans[loc.String()]
+		if !ok || t.Before(span[0]) {
+			span[0] = t
+		}
+		if !ok || t.After(span[1]) {
+			span[1] = t
+		}
+		spans[loc.String()] = span
+	}
+	for _, f := range flights {
+		cover(f.OriginAirport, f.DepartureTime)
+		cover(f.DestinationAirport, f.ArrivalTime)
+	}
+
+	var buf bytes.Buffer
+	line := func(name, value string) {
+		writeCalendarLine(&buf, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", calendarProductId)
+	line("CALSCALE", "GREGORIAN")
+	line("METHOD", "PUBLISH")
+
+	for _, name := range slices.Sorted(maps.Keys(spans)) {
+		span := spans[name]
+		writeCalendarTimeZone(&buf, locations[name], span[0], span[1])
+	}
+
+	for _, f := range flights {
+		line("BEGIN", "VEVENT")
+		line("UID", fmt.Sprintf("flight-%d@terraroute", f.Id))
+		line("DTSTAMP", stamp.UTC().Format(calendarDateTimeLayout)+"Z")
+		writeCalendarLine(&buf, calendarTime("DTSTART", f.DepartureTime, airportTimeZone(f.OriginAirport, locations)))
+		writeCalendarLine(&buf, calendarTime("DTEND", f.ArrivalTime, airportTimeZone(f.DestinationAirport, locations)))
+		line("SUMMARY", calendarText(fmt.Sprintf("%s %s-%s", f.FlightNumber, f.Origin, f.Destination)))
+		if a := f.OriginAirport; a != nil {
+			line("LOCATION", calendarText(airportLabel(a)))
+			line("GEO", fmt.Sprintf("%.6f;%.6f", a.Latitude, a.Longitude))
+		}
+		line("DESCRIPTION", calendarText(calendarDescription(f)))
+		if f.Status == FlightCancelled {
+			line("STATUS", "CANCELLED")
+		} else {
+			line("STATUS", "CONFIRMED")
+		}
+		line("TRANSP", "OPAQUE")
+		line("END", "VEVENT")
+	}
+
+	line("END", "VCALENDAR")
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// calendarDescription summarizes the route, aircraft and status of a flight.
+func calendarDescription(f FlightDetail) string {
+	from, to := f.Origin, f.Destination
+	if f.OriginAirport != nil {
+		from = airportLabel(f.OriginAirport)
+	}
+	if f.DestinationAirport != nil {
+		to = airportLabel(f.DestinationAirport)
+	}
+	lines := []string{
+		fmt.Sprintf("Flight %s from %s to %s", f.FlightNumber, from, to),
+		fmt.Sprintf("Block time: %s", f.ArrivalTime.Sub(f.DepartureTime).Round(time.Minute)),
+	}
+	if f.Aircraft != nil {
+		aircraft := f.Aircraft.Name
+		if f.Airframe != nil {
+			aircraft += " (" + f.Airframe.Registration + ")"
+		}
+		lines = append(lines, "Aircraft: "+aircraft)
+	}
+	if f.Status != "" {
+		lines = append(lines, "Status: "+string(f.Status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// airportLabel names an airport with its code, e.g. "Zurich Airport (ZRH)".
+func airportLabel(a *Airport) string {
+	if a.Name == "" {
+		return a.IATA
+	}
+	return fmt.Sprintf("%s (%s)", a.Name, a.IATA)
+}
+
+// airportTimeZone returns the time zone of an airport, loaded once per name
+// into locations. Missing airports, time zones and unknown zone names fall
+// back to UTC.
+func airportTimeZone(a *Airport, locations map[string]*time.Location) *time.Location {
+	if a == nil || a.TimeZone == "" {
+		return time.UTC
+	}
+	if loc, ok := locations[a.TimeZone]; ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(a.TimeZone)
+	if err != nil || loc.String() == time.UTC.String() {
+		loc = time.UTC
+	}
+	locations[a.TimeZone] = loc
+	return loc
+}
+
+// calendarTime formats a date-time property in loc, or in UTC if loc is UTC.
+func calendarTime(name string, t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return name + ":" + t.UTC().Format(calendarDateTimeLayout) + "Z"
+	}
+	return fmt.Sprintf("%s;TZID=%s:%s", name, loc, t.In(loc).Format(calendarDateTimeLayout))
+}
+
+// calendarText escapes a TEXT property value.
+func calendarText(s string) string {
+	return calendarTextEscaper.Replace(s)
+}
+
+// writeCalendarTimeZone writes the VTIMEZONE of loc with one observance for
+// the offset in effect at from and one for every transition until to.
+func writeCalendarTimeZone(buf *bytes.Buffer, loc *time.Location, from, to time.Time) {
+	observance := func(start time.Time, offsetFrom int) {
+		name, offset := start.In(loc).Zone()
+		kind := "STANDARD"
+		if start.In(loc).IsDST() {
+			kind = "DAYLIGHT"
+		}
+		writeCalendarLine(buf, "BEGIN:"+kind)
+		// DTSTART is the local time of the transition before it happens.
+		writeCalendarLine(buf, "DTSTART:"+start.In(time.FixedZone("", offsetFrom)).Format(calendarDateTimeLayout))
+		writeCalendarLine(buf, "TZOFFSETFROM:"+calendarOffset(offsetFrom))
+		writeCalendarLine(buf, "TZOFFSETTO:"+calendarOffset(offset))
+		if name != "" && name[0] != '+' && name[0] != '-' {
+			writeCalendarLine(buf, "TZNAME:"+calendarText(name))
+		}
+		writeCalendarLine(buf, "END:"+kind)
+	}
+
+	writeCalendarLine(buf, "BEGIN:VTIMEZONE")
+	writeCalendarLine(buf, "TZID:"+loc.String())
+
+	start := time.Date(from.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	_, offset := start.In(loc).Zone()
+	observance(start, offset)
+	for _, t := range zoneTransitions(loc, start, to) {
+		observance(t, offset)
+		_, offset = t.In(loc).Zone()
+	}
+
+	writeCalendarLine(buf, "END:VTIMEZONE")
+}
+
+// zoneTransitions returns the times in (from, to] at which the offset of loc
+// changes.
+func zoneTransitions(loc *time.Location, from, to time.Time) []time.Time {
+	const step = 12 * time.Hour
+
+	var transitions []time.Time
+	zone := func(t time.Time) int {
+		_, offset := t.In(loc).Zone()
+		return offset
+	}
+	for t := from; t.Before(to); t = t.Add(step) {
+		next := t.Add(step)
+		if zone(t) == zone(next) {
+			continue
+		}
+		// Narrow the transition down to the second.
+		lo, hi := t, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if zone(mid) == zone(lo) {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+		if !hi.After(to) {
+			transitions = append(transitions, hi)
+		}
+	}
+	return transitions
+}
+
+// calendarOffset formats a UTC offset in seconds as "+HHMM".
+func calendarOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds/60%60)
+}
+
+// writeCalendarLine writes a content line, folded after calendarLineLength
+// octets without splitting UTF-8 sequences.
+func writeCalendarLine(buf *bytes.Buffer, line string) {
+	limit := calendarLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space that counts towards the limit.
+		limit = calendarLineLength - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
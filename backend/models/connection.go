@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Itinerary is a journey of one or more flights where each flight departs
+// from the airport the previous one arrived at.
+type Itinerary struct {
+	Departure       string    `json:"departure"`   // IATA code of the first origin
+	Destination     string    `json:"destination"` // IATA code of the final destination
+	DepartureTime   time.Time `json:"departure_time"`
+	ArrivalTime     time.Time `json:"arrival_time"`
+	DurationMinutes int       `json:"duration_minutes"` // total elapsed time including layovers
+	DistanceKm      float64   `json:"distance_km"`      // sum of the great-circle distances of the legs
+	Stops           int       `json:"stops"`
+	Legs            []Flight  `json:"legs"`
+	Layovers        []Layover `json:"layovers"`
+}
+
+// Layover is the time spent at a connecting airport between two legs.
+type Layover struct {
+	Airport string `json:"airport"`
+	Minutes int    `json:"minutes"`
+}
+
+// Connections is the result of a connection search, best itinerary first.
+type Connections struct {
+	From        string      `json:"from"`
+	To          string      `json:"to"`
+	Date        string      `json:"date"` // departure date, local to the origin
+	Sort        string      `json:"sort"`
+	Total       int         `json:"total"` // number of itineraries found before the limit was applied
+	Itineraries []Itinerary `json:"itineraries"`
+}
+
+// ItineraryFlights returns the legs of itineraries with their airports, for
+// example to export them as a calendar. Flights shared by several itineraries
+// are returned once.
+func ItineraryFlights(itineraries []Itinerary, airports map[string]Airport) []FlightDetail {
+	var flights []FlightDetail
+	seen := map[int64]bool{}
+	airport := func(code string) *Airport {
+		if a, ok := airports[code]; ok {
+			return &a
+		}
+		return nil
+	}
+	for _, it := range itineraries {
+		for _, leg := range it.Legs {
+			if seen[leg.Id] {
+				continue
+			}
+			seen[leg.Id] = true
+			flights = append(flights, FlightDetail{
+				Flight:             leg,
+				OriginAirport:      airport(leg.Origin),
+				DestinationAirport: airport(leg.Destination),
+			})
+		}
+	}
+	return flights
+}
@@ -0,0 +1,19 @@
+package models
+
+type Continent struct {
+	Code         string `json:"code" bun:",pk"` // unique identifier, e.g. "EU"
+	Name         string `json:"name"`
+	AirportCount int    `json:"airport_count" bun:",scanonly"`
+}
+
+type Country struct {
+	Alpha2        string `json:"alpha2" bun:",pk"` // unique identifier, ISO 3166-1 alpha-2 code
+	Alpha3        string `json:"alpha3"`           // ISO 3166-1 alpha-3 code
+	Numeric       string `json:"numeric"`          // ISO 3166-1 numeric code
+	Name          string `json:"name"`
+	OfficialName  string `json:"official_name,omitempty"`
+	Region        string `json:"region"`    // UN M49 region, e.g. "Europe"
+	Subregion     string `json:"subregion"` // UN M49 subregion, e.g. "Western Europe"
+	ContinentCode string `json:"continent"` // foreign key
+	AirportCount  int    `json:"airport_count" bun:",scanonly"`
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	flightCSVHeader = []string{
+		"id", "flight_number", "status", "origin", "destination",
+		"departure_time", "arrival_time", "departure_local", "arrival_local",
+		"block_minutes", "aircraft", "registration",
+	}
+	itineraryCSVHeader = []string{
+		"itinerary", "leg", "flight_id", "flight_number", "origin", "destination",
+		"departure_time", "arrival_time", "departure_local", "arrival_local",
+		"block_minutes", "layover_minutes",
+	}
+)
+
+// csvText escapes a text cell that spreadsheets would run as a formula by
+// prefixing it with a quote.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// WriteFlightsCSV writes flights as CSV with a header row. Times are given in
+// UTC and local to their airport, both as RFC 3339. Text cells are escaped
+// with csvText.
+func WriteFlightsCSV(w io.Writer, flights []FlightDetail) error {
+	locations := map[string]*time.Location{}
+	cw := csv.NewWriter(w)
+	cw.Write(flightCSVHeader)
+	for _, f := range flights {
+		var aircraft, registration string
+		if f.Aircraft != nil {
+			aircraft = f.Aircraft.Name
+		}
+		if f.Airframe != nil {
+			registration = f.Airframe.Registration
+		}
+		cw.Write([]string{
+			strconv.FormatInt(f.Id, 10),
+			csvText(f.FlightNumber),
+			string(f.Status),
+			csvText(f.Origin),
+			csvText(f.Destination),
+			f.DepartureTime.UTC().Format(time.RFC3339),
+			f.ArrivalTime.UTC().Format(time.RFC3339),
+			f.DepartureTime.In(airportTimeZone(f.OriginAirport, locations)).Format(time.RFC3339),
+			f.ArrivalTime.In(airportTimeZone(f.DestinationAirport, locations)).Format(time.RFC3339),
+			strconv.Itoa(int(f.ArrivalTime.Sub(f.DepartureTime).Minutes())),
+			csvText(aircraft),
+			csvText(registration),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteItinerariesCSV writes one row per leg of every itinerary, numbered
+// from 1 in the order given. layover_minutes is the time spent at the origin
+// of a leg since the previous one arrived, empty for the first leg. airports
+// provides the time zones of the local times. Text cells are escaped with
+// csvText.
+func WriteItinerariesCSV(w io.Writer, itineraries []Itinerary, airports map[string]Airport) error {
+	locations := map[string]*time.Location{}
+	zone := func(code string) *time.Location {
+		a, ok := airports[code]
+		if !ok {
+			return time.UTC
+		}
+		return airportTimeZone(&a, locations)
+	}
+
+	cw := csv.NewWriter(w)
+	cw.Write(itineraryCSVHeader)
+	for i, it := range itineraries {
+		for j, leg := range it.Legs {
+			var layover string
+			if j > 0 {
+				layover = strconv.Itoa(int(leg.DepartureTime.Sub(it.Legs[j-1].ArrivalTime).Minutes()))
+			}
+			cw.Write([]string{
+				strconv.Itoa(i + 1),
+				strconv.Itoa(j + 1),
+				strconv.FormatInt(leg.Id, 10),
+				csvText(leg.FlightNumber),
+				csvText(leg.Origin),
+				csvText(leg.Destination),
+				leg.DepartureTime.UTC().Format(time.RFC3339),
+				leg.ArrivalTime.UTC().Format(time.RFC3339),
+				leg.DepartureTime.In(zone(leg.Origin)).Format(time.RFC3339),
+				leg.ArrivalTime.In(zone(leg.Destination)).Format(time.RFC3339),
+				strconv.Itoa(int(leg.ArrivalTime.Sub(leg.DepartureTime).Minutes())),
+				layover,
+			})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
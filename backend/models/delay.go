@@ -0,0 +1,119 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+const (
+	// MaxInjectedDelayMinutes is the longest delay a scenario can inject.
+	MaxInjectedDelayMinutes = 24 * 60
+	// MaxHoldMinutes is the longest a scenario can hold a flight for its
+	// connections.
+	MaxHoldMinutes = 12 * 60
+	// MaxHorizonHours is the longest time after the delayed flight a
+	// scenario can follow the delay for.
+	MaxHorizonHours = 72
+)
+
+// DelayScenario injects a departure delay on a flight to see how it
+// propagates through the rotations of the airframes and the connections
+// between flights.
+type DelayScenario struct {
+	FlightId             int64 `json:"flight_id"`
+	DelayMinutes         int   `json:"delay_minutes"`                    // departure delay against the schedule
+	MinTurnaroundMinutes int   `json:"min_turnaround_minutes,omitempty"` // ground time between two legs of an airframe, the fleet default if zero
+	MaxHoldMinutes       int   `json:"max_hold_minutes,omitempty"`       // how long a flight waits for late connecting passengers and crew, zero never holds
+	HorizonHours         int   `json:"horizon_hours,omitempty"`          // how long after the delayed flight departs the delay is followed, the default if zero
+}
+
+// NewDelayScenario decodes and validates a delay scenario from b.
+func NewDelayScenario(b io.Reader) (*DelayScenario, error) {
+	var scenario DelayScenario
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&scenario); err != nil {
+		return nil, fmt.Errorf("failed to decode delay scenario: %w", err)
+	}
+
+	if err := scenario.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &scenario, nil
+}
+
+// Validate checks the flight and the ranges of the delay and the options.
+func (s *DelayScenario) Validate() error {
+	var errs ValidationErrors
+	if s.FlightId <= 0 {
+		errs.add("flight_id", "is required")
+	}
+	if s.DelayMinutes < 1 || s.DelayMinutes > MaxInjectedDelayMinutes {
+		errs.add("delay_minutes", fmt.Sprintf("must be between 1 and %d", MaxInjectedDelayMinutes))
+	}
+	if s.MinTurnaroundMinutes < 0 || s.MinTurnaroundMinutes > MaxTurnaroundMinutes {
+		errs.add("min_turnaround_minutes", fmt.Sprintf("must be between 0 and %d", MaxTurnaroundMinutes))
+	}
+	if s.MaxHoldMinutes < 0 || s.MaxHoldMinutes > MaxHoldMinutes {
+		errs.add("max_hold_minutes", fmt.Sprintf("must be between 0 and %d", MaxHoldMinutes))
+	}
+	if s.HorizonHours < 0 || s.HorizonHours > MaxHorizonHours {
+		errs.add("horizon_hours", fmt.Sprintf("must be between 0 and %d", MaxHorizonHours))
+	}
+	return errs.err()
+}
+
+// DelayCause tells why a flight of a delay simulation departs late.
+type DelayCause string
+
+const (
+	DelayInjected   DelayCause = "injected"   // the delay of the scenario
+	DelayRotation   DelayCause = "rotation"   // the airframe arrives late from its previous flight
+	DelayConnection DelayCause = "connection" // the flight is held for a late connecting flight
+)
+
+// DelayedFlight is a flight a delay simulation projects to depart later than
+// currently expected.
+type DelayedFlight struct {
+	FlightId           int64      `json:"flight_id"`
+	FlightNumber       string     `json:"flight_number"`
+	Origin             string     `json:"origin"`
+	Destination        string     `json:"destination"`
+	AirframeId         int        `json:"airframe_id,omitempty"`
+	ScheduledDeparture time.Time  `json:"scheduled_departure"`
+	ProjectedDeparture time.Time  `json:"projected_departure"`
+	ProjectedArrival   time.Time  `json:"projected_arrival"`
+	DelayMinutes       int        `json:"delay_minutes"`       // projected departure delay against the schedule
+	AddedDelayMinutes  int        `json:"added_delay_minutes"` // part of the delay caused by the scenario
+	Cause              DelayCause `json:"cause"`
+	CausedBy           int64      `json:"caused_by,omitempty"` // flight the delay propagated from
+	Depth              int        `json:"depth"`               // propagation steps from the delayed flight, which is 0
+}
+
+// MissedConnection is a connection a delay simulation projects to break
+// because the arriving flight is too late for the departing one.
+type MissedConnection struct {
+	Airport               string    `json:"airport"`
+	ArrivingFlightId      int64     `json:"arriving_flight_id"`
+	ArrivingFlightNumber  string    `json:"arriving_flight_number"`
+	DepartingFlightId     int64     `json:"departing_flight_id"`
+	DepartingFlightNumber string    `json:"departing_flight_number"`
+	ProjectedArrival      time.Time `json:"projected_arrival"`
+	ProjectedDeparture    time.Time `json:"projected_departure"`
+	ShortMinutes          int       `json:"short_minutes"` // how much the minimum connection time is missed by
+}
+
+// DelaySimulation is the outcome of a delay scenario. Only the changes
+// caused by the scenario are listed, delays and missed connections that are
+// expected anyway are left out.
+type DelaySimulation struct {
+	Scenario               DelayScenario      `json:"scenario"`
+	From                   time.Time          `json:"from"` // projected departure of the delayed flight
+	To                     time.Time          `json:"to"`   // end of the simulated horizon
+	Flights                []DelayedFlight    `json:"flights"`
+	MissedConnections      []MissedConnection `json:"missed_connections"`
+	TotalAddedDelayMinutes int                `json:"total_added_delay_minutes"`
+}
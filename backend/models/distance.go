@@ -8,10 +8,17 @@ import (
 	"io"
 )
 
+// Pair modes of a DistanceRequest between metropolitan areas.
+const (
+	PairsShortest = "shortest" // only the closest airport pair, the default
+	PairsAll      = "all"      // every airport pair, closest first
+)
+
 type DistanceRequest struct {
-	Departure   string   `json:"departure"`
-	Destination string   `json:"destination"`
+	Departure   string   `json:"departure"`   // airport IATA code or metropolitan area code
+	Destination string   `json:"destination"` // airport IATA code or metropolitan area code
 	Borders     []string `json:"borders"`
+	Pairs       string   `json:"pairs,omitempty"` // PairsShortest or PairsAll
 }
 
 func NewDistanceRequest(b io.Reader) (*DistanceRequest, error) {
@@ -35,6 +42,9 @@ func (req *DistanceRequest) validate() error {
 	if req.Destination == "" {
 		err = errors.Join(err, errors.New("destination IATA code is required"))
 	}
+	if req.Pairs != "" && req.Pairs != PairsShortest && req.Pairs != PairsAll {
+		err = errors.Join(err, fmt.Errorf("pairs must be %q or %q", PairsShortest, PairsAll))
+	}
 	return err
 }
 
@@ -43,9 +53,23 @@ type PointCoords struct {
 	Lng float64 `json:"lng"`
 }
 
+// DistanceData describes the route between the closest pair of departure and
+// destination airports. Pairs lists every airport pair when requested.
 type DistanceData struct {
-	Route     *DistanceRequest   `json:"route"`
-	Distances map[string]float64 `json:"distances"`
-	Path      []PointCoords      `json:"path"`
-	Midpoint  PointCoords        `json:"midpoint"`
+	Route       *DistanceRequest   `json:"route"`
+	Departure   string             `json:"departure"`   // IATA code of the departure airport
+	Destination string             `json:"destination"` // IATA code of the destination airport
+	Distances   map[string]float64 `json:"distances"`
+	Path        []PointCoords      `json:"path"`
+	Midpoint    PointCoords        `json:"midpoint"`
+	Pairs       []AirportPair      `json:"pairs,omitempty"`
+}
+
+// AirportPair is the route between one departure and one destination airport.
+type AirportPair struct {
+	Departure   string             `json:"departure"`
+	Destination string             `json:"destination"`
+	Distances   map[string]float64 `json:"distances"`
+	Path        []PointCoords      `json:"path"`
+	Midpoint    PointCoords        `json:"midpoint"`
 }
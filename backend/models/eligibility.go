@@ -0,0 +1,28 @@
+package models
+
+// EligibleAircraft is an aircraft whose range covers a route together with
+// the range left over after flying it. With a payload the range is taken from
+// the aircraft's payload-range diagram. Aircraft flying the route with
+// technical stops are measured against their longest leg.
+type EligibleAircraft struct {
+	Aircraft
+	RangeAtPayloadNm float64  `json:"range_at_payload_nm,omitempty"` // range with the requested payload
+	MaxPayloadKg     float64  `json:"max_payload_kg,omitempty"`      // payload possible over the required range
+	Stops            []string `json:"stops,omitempty"`               // IATA codes of the technical stops in flight order
+	LongestLegNm     float64  `json:"longest_leg_nm,omitempty"`      // longest leg between the stops
+	MarginNm         float64  `json:"margin_nm"`                     // range left after the route or the longest leg including reserves
+	MarginPercent    float64  `json:"margin_percent"`                // MarginNm relative to the usable range
+}
+
+// RouteAircraft lists the aircraft able to fly a route, fewest stops and
+// smallest margin first.
+type RouteAircraft struct {
+	Departure       string             `json:"departure"`   // IATA code of the departure airport
+	Destination     string             `json:"destination"` // IATA code of the destination airport
+	DistanceNm      float64            `json:"distance_nm"` // great-circle distance
+	ReserveFactor   float64            `json:"reserve_factor"`
+	PayloadKg       float64            `json:"payload_kg,omitempty"`
+	MaxStops        int                `json:"max_stops,omitempty"` // technical stops allowed
+	RequiredRangeNm float64            `json:"required_range_nm"`   // distance including reserves
+	Aircraft        []EligibleAircraft `json:"aircraft"`
+}
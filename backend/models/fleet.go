@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// FleetConflictKind is the rule a rotation of an airframe violates.
+type FleetConflictKind string
+
+const (
+	// ConflictOverlap is a flight departing before the previous flight of
+	// its airframe arrived.
+	ConflictOverlap FleetConflictKind = "overlap"
+	// ConflictPosition is a flight departing from another airport than the
+	// previous flight of its airframe arrived at.
+	ConflictPosition FleetConflictKind = "position"
+	// ConflictTurnaround is a flight departing less than the minimum
+	// turnaround time after the previous flight of its airframe arrived.
+	ConflictTurnaround FleetConflictKind = "turnaround"
+)
+
+// FleetConflict is a flight that its airframe cannot operate after the
+// previous flight of its rotation.
+type FleetConflict struct {
+	Kind                 FleetConflictKind `json:"kind"`
+	AirframeId           int               `json:"airframe_id"`
+	Registration         string            `json:"registration"`
+	FlightId             int64             `json:"flight_id"`
+	FlightNumber         string            `json:"flight_number"`
+	DepartureTime        time.Time         `json:"departure_time"` // departure of the flight, estimated or actual if known
+	PreviousFlightId     int64             `json:"previous_flight_id"`
+	PreviousFlightNumber string            `json:"previous_flight_number"`
+	PreviousArrivalTime  time.Time         `json:"previous_arrival_time"` // arrival of the previous flight, actual if known
+	Message              string            `json:"message"`
+}
+
+// FleetConflicts is the rotation conflicts report of the fleet, ordered by
+// airframe and departure.
+type FleetConflicts struct {
+	Total     int             `json:"total"`
+	Conflicts []FleetConflict `json:"conflicts"`
+}
@@ -1,10 +1,115 @@
 package models
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/uptrace/bun"
+)
+
+// flightNumberPattern matches flight numbers such as "LH400" or "U21234A": a
+// two or three character airline designator, up to four digits and an
+// optional operational suffix.
+var flightNumberPattern = regexp.MustCompile(`^([A-Z0-9]{2}|[A-Z]{3})[0-9]{1,4}[A-Z]?$`)
+
 type Flight struct {
-	FlightNumber  string `json:"flight_number"` // unique identifier
-	AircraftId    int    `json:"aircraft_id"`   // foreign key
-	Origin        string `json:"origin"`        // foreign key
-	Destination   string `json:"destination"`   // foreign key
-	DepartureTime string `json:"departure_time"`
-	ArrivalTime   string `json:"arrival_time"`
+	Id            int64     `json:"id" bun:",pk,autoincrement"`            // unique identifier
+	FlightNumber  string    `json:"flight_number"`                         // e.g. "LH400", repeats on every day it is operated
+	AircraftId    int       `json:"aircraft_id"`                           // foreign key
+	AirframeId    int       `json:"airframe_id,omitempty" bun:",nullzero"` // operating airframe, foreign key
+	ScheduleId    int       `json:"schedule_id,omitempty" bun:",nullzero"` // schedule the flight was materialized from, foreign key
+	Origin        string    `json:"origin"`                                // foreign key
+	Destination   string    `json:"destination"`                           // foreign key
+	DepartureTime time.Time `json:"departure_time"`                        // scheduled departure, stored in UTC
+	ArrivalTime   time.Time `json:"arrival_time"`                          // scheduled arrival, stored in UTC
+
+	// Operational state, changed by flight events only.
+	Status                 FlightStatus `json:"status"`
+	EstimatedDepartureTime *time.Time   `json:"estimated_departure_time,omitempty"` // latest estimate of a delayed flight
+	ActualDepartureTime    *time.Time   `json:"actual_departure_time,omitempty"`    // off-block time
+	ActualTakeoffTime      *time.Time   `json:"actual_takeoff_time,omitempty"`      // wheels-up time
+	ActualLandingTime      *time.Time   `json:"actual_landing_time,omitempty"`      // touchdown time
+	ActualArrivalTime      *time.Time   `json:"actual_arrival_time,omitempty"`      // on-block time
+	DivertedTo             string       `json:"diverted_to,omitempty" bun:",nullzero"`
+}
+
+var _ bun.BeforeAppendModelHook = (*Flight)(nil)
+
+// BeforeAppendModel defaults the status of new flights to scheduled.
+func (f *Flight) BeforeAppendModel(ctx context.Context, query bun.Query) error {
+	if _, ok := query.(*bun.InsertQuery); ok && f.Status == "" {
+		f.Status = FlightScheduled
+	}
+	return nil
+}
+
+// NewFlight decodes and validates a flight from b.
+func NewFlight(b io.Reader) (*Flight, error) {
+	var flight Flight
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&flight); err != nil {
+		return nil, fmt.Errorf("failed to decode flight: %w", err)
+	}
+
+	if err := flight.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &flight, nil
+}
+
+// Validate checks the flight number and airport formats, that origin and
+// destination differ and that the flight arrives after it departs. Times are
+// normalized to UTC.
+func (f *Flight) Validate() error {
+	var errs ValidationErrors
+	if !flightNumberPattern.MatchString(f.FlightNumber) {
+		errs.add("flight_number", "must be an uppercase flight number, e.g. LH400")
+	}
+	if f.AircraftId <= 0 {
+		errs.add("aircraft_id", "is required")
+	}
+	if f.AirframeId < 0 {
+		errs.add("airframe_id", "must be positive")
+	}
+	if f.ScheduleId < 0 {
+		errs.add("schedule_id", "must be positive")
+	}
+	if !iataCodePattern.MatchString(f.Origin) {
+		errs.add("origin", "must be three uppercase letters")
+	}
+	if !iataCodePattern.MatchString(f.Destination) {
+		errs.add("destination", "must be three uppercase letters")
+	} else if f.Destination == f.Origin {
+		errs.add("destination", "must differ from origin")
+	}
+	if f.Status != "" && !slices.Contains(FlightStatuses, f.Status) {
+		errs.add("status", fmt.Sprintf("must be one of %v", FlightStatuses))
+	}
+	if f.DepartureTime.IsZero() {
+		errs.add("departure_time", "is required")
+	}
+	if f.ArrivalTime.IsZero() {
+		errs.add("arrival_time", "is required")
+	} else if !f.DepartureTime.IsZero() && !f.ArrivalTime.After(f.DepartureTime) {
+		errs.add("arrival_time", "must be after departure_time")
+	}
+	f.DepartureTime = f.DepartureTime.UTC()
+	f.ArrivalTime = f.ArrivalTime.UTC()
+	return errs.err()
+}
+
+// FlightDetail is a flight together with its airports and aircraft.
+type FlightDetail struct {
+	Flight
+	OriginAirport      *Airport  `json:"origin_airport"`
+	DestinationAirport *Airport  `json:"destination_airport"`
+	Aircraft           *Aircraft `json:"aircraft"`
+	Airframe           *Airframe `json:"airframe,omitempty"`
 }
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+)
+
+type FlightStatus string
+
+const (
+	FlightScheduled FlightStatus = "scheduled"
+	FlightBoarding  FlightStatus = "boarding"
+	FlightDeparted  FlightStatus = "departed" // pushed back from the gate
+	FlightAirborne  FlightStatus = "airborne"
+	FlightLanded    FlightStatus = "landed"
+	FlightArrived   FlightStatus = "arrived" // at the gate
+	FlightDelayed   FlightStatus = "delayed"
+	FlightDiverted  FlightStatus = "diverted"
+	FlightCancelled FlightStatus = "cancelled"
+)
+
+// FlightStatuses lists all known flight statuses.
+var FlightStatuses = []FlightStatus{
+	FlightScheduled, FlightBoarding, FlightDeparted, FlightAirborne, FlightLanded,
+	FlightArrived, FlightDelayed, FlightDiverted, FlightCancelled,
+}
+
+// flightTransitions lists the statuses a flight can move to from each status.
+// A delayed flight can be delayed again to update its estimate. Arrived and
+// cancelled flights are final.
+var flightTransitions = map[FlightStatus][]FlightStatus{
+	FlightScheduled: {FlightBoarding, FlightDelayed, FlightCancelled},
+	FlightDelayed:   {FlightBoarding, FlightDelayed, FlightCancelled},
+	FlightBoarding:  {FlightDeparted, FlightDelayed, FlightCancelled},
+	FlightDeparted:  {FlightAirborne},
+	FlightAirborne:  {FlightLanded, FlightDiverted},
+	FlightDiverted:  {FlightLanded},
+	FlightLanded:    {FlightArrived},
+}
+
+// CanTransition reports whether a flight in status s can move to status to.
+func (s FlightStatus) CanTransition(to FlightStatus) bool {
+	return slices.Contains(flightTransitions[s], to)
+}
+
+// FlightEvent is a status change of a flight. The events of a flight form its
+// audit history.
+type FlightEvent struct {
+	Id                     int64        `json:"id" bun:",pk,autoincrement"` // unique identifier
+	FlightId               int64        `json:"flight_id"`                  // foreign key
+	Status                 FlightStatus `json:"status"`                     // status the flight moved to
+	PreviousStatus         FlightStatus `json:"previous_status"`
+	Time                   time.Time    `json:"time"`                                  // when the change happened, defaults to when it was recorded
+	EstimatedDepartureTime *time.Time   `json:"estimated_departure_time,omitempty"`    // new estimate of a delayed flight
+	DivertedTo             string       `json:"diverted_to,omitempty" bun:",nullzero"` // IATA code of the alternate airport of a diverted flight
+	Remark                 string       `json:"remark,omitempty" bun:",nullzero"`      // free text, e.g. the reason of a delay
+	RecordedAt             time.Time    `json:"recorded_at"`                           // when the event was stored
+}
+
+// NewFlightEvent decodes and validates a flight event from b.
+func NewFlightEvent(b io.Reader) (*FlightEvent, error) {
+	var event FlightEvent
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&event); err != nil {
+		return nil, fmt.Errorf("failed to decode flight event: %w", err)
+	}
+
+	if err := event.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+// Validate checks the status and that delays carry a new estimate and
+// diversions an alternate airport.
+func (e *FlightEvent) Validate() error {
+	var errs ValidationErrors
+	if !slices.Contains(FlightStatuses, e.Status) || e.Status == FlightScheduled {
+		errs.add("status", fmt.Sprintf("must be one of %v except %s", FlightStatuses, FlightScheduled))
+	}
+	if e.Status == FlightDelayed && e.EstimatedDepartureTime == nil {
+		errs.add("estimated_departure_time", "is required for delays")
+	} else if e.Status != FlightDelayed && e.EstimatedDepartureTime != nil {
+		errs.add("estimated_departure_time", "is only allowed for delays")
+	}
+	if e.Status == FlightDiverted && !iataCodePattern.MatchString(e.DivertedTo) {
+		errs.add("diverted_to", "must be the three letter code of the alternate airport")
+	} else if e.Status != FlightDiverted && e.DivertedTo != "" {
+		errs.add("diverted_to", "is only allowed for diversions")
+	}
+	return errs.err()
+}
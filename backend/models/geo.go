@@ -0,0 +1,211 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BBox is a geographic bounding box in degrees. A box whose MinLng is greater
+// than its MaxLng crosses the antimeridian, e.g. 170,-50,-170,-30 covers the
+// area between 170°E and 170°W.
+type BBox struct {
+	MinLng float64 `json:"min_lng"`
+	MinLat float64 `json:"min_lat"`
+	MaxLng float64 `json:"max_lng"`
+	MaxLat float64 `json:"max_lat"`
+}
+
+// ParseBBox parses a bounding box given as "minLng,minLat,maxLng,maxLat".
+func ParseBBox(s string) (*BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("bbox must have the form minLng,minLat,maxLng,maxLat")
+	}
+
+	var values [4]float64
+	for i, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bbox value %q: %w", p, err)
+		}
+		values[i] = v
+	}
+
+	b := &BBox{MinLng: values[0], MinLat: values[1], MaxLng: values[2], MaxLat: values[3]}
+	if err := b.validate(); err != nil {
+		return nil, fmt.Errorf("invalid bbox: %w", err)
+	}
+
+	return b, nil
+}
+
+func (b *BBox) validate() error {
+	var err error
+	if b.MinLng < -180 || b.MinLng > 180 || b.MaxLng < -180 || b.MaxLng > 180 {
+		err = errors.New("longitudes must be between -180 and 180")
+	}
+	if b.MinLat < -90 || b.MinLat > 90 || b.MaxLat < -90 || b.MaxLat > 90 {
+		err = errors.Join(err, errors.New("latitudes must be between -90 and 90"))
+	}
+	if b.MinLat > b.MaxLat {
+		err = errors.Join(err, errors.New("minLat must not be greater than maxLat"))
+	}
+	return err
+}
+
+// CrossesAntimeridian reports whether the box wraps around 180° longitude.
+func (b *BBox) CrossesAntimeridian() bool {
+	return b.MinLng > b.MaxLng
+}
+
+// Contains reports whether the point lies within the box, edges included.
+func (b *BBox) Contains(p PointCoords) bool {
+	if p.Lat < b.MinLat || p.Lat > b.MaxLat {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return p.Lng >= b.MinLng || p.Lng <= b.MaxLng
+	}
+	return p.Lng >= b.MinLng && p.Lng <= b.MaxLng
+}
+
+// Geometry is a GeoJSON Polygon or MultiPolygon geometry.
+// Positions are [longitude, latitude] pairs as mandated by RFC 7946.
+type Geometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+
+	polygons [][][][2]float64
+}
+
+// NewGeometry decodes a GeoJSON Polygon or MultiPolygon from b. A Feature
+// wrapping such a geometry is accepted as well.
+func NewGeometry(b io.Reader) (*Geometry, error) {
+	var doc struct {
+		Geometry
+		Feature *Geometry `json:"geometry"`
+	}
+	if err := json.NewDecoder(b).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode geometry: %w", err)
+	}
+
+	g := &doc.Geometry
+	if doc.Type == "Feature" {
+		if doc.Feature == nil {
+			return nil, errors.New("feature has no geometry")
+		}
+		g = doc.Feature
+	}
+
+	if err := g.parse(); err != nil {
+		return nil, fmt.Errorf("invalid geometry: %w", err)
+	}
+
+	return g, nil
+}
+
+func (g *Geometry) parse() error {
+	switch g.Type {
+	case "Polygon":
+		var polygon [][][2]float64
+		if err := json.Unmarshal(g.Coordinates, &polygon); err != nil {
+			return fmt.Errorf("invalid polygon coordinates: %w", err)
+		}
+		g.polygons = [][][][2]float64{polygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(g.Coordinates, &g.polygons); err != nil {
+			return fmt.Errorf("invalid multipolygon coordinates: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported geometry type %q, expected Polygon or MultiPolygon", g.Type)
+	}
+
+	if len(g.polygons) == 0 {
+		return errors.New("geometry has no polygons")
+	}
+	for _, polygon := range g.polygons {
+		if len(polygon) == 0 {
+			return errors.New("polygon has no rings")
+		}
+		for _, ring := range polygon {
+			if len(ring) < 4 {
+				return errors.New("polygon rings need at least four positions")
+			}
+			if ring[0] != ring[len(ring)-1] {
+				return errors.New("polygon rings must be closed")
+			}
+			for _, pos := range ring {
+				if pos[0] < -180 || pos[0] > 180 || pos[1] < -90 || pos[1] > 90 {
+					return fmt.Errorf("position %v is out of range", pos)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// String returns the GeoJSON encoding of the geometry.
+func (g *Geometry) String() string {
+	b, err := json.Marshal(struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	}{g.Type, g.Coordinates})
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// BBox returns the bounding box of all polygon exteriors.
+func (g *Geometry) BBox() BBox {
+	b := BBox{MinLng: math.Inf(1), MinLat: math.Inf(1), MaxLng: math.Inf(-1), MaxLat: math.Inf(-1)}
+	for _, polygon := range g.polygons {
+		for _, pos := range polygon[0] {
+			b.MinLng = min(b.MinLng, pos[0])
+			b.MaxLng = max(b.MaxLng, pos[0])
+			b.MinLat = min(b.MinLat, pos[1])
+			b.MaxLat = max(b.MaxLat, pos[1])
+		}
+	}
+	return b
+}
+
+// Contains reports whether the point lies inside any of the polygons and
+// outside of their holes. Edges are treated as planar lines in degrees.
+func (g *Geometry) Contains(p PointCoords) bool {
+	for _, polygon := range g.polygons {
+		if !ringContains(polygon[0], p) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if ringContains(hole, p) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// ringContains implements the even-odd ray casting test.
+func ringContains(ring [][2]float64, p PointCoords) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > p.Lat) != (yj > p.Lat) && p.Lng < (xj-xi)*(p.Lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// MetroArea groups the airports serving the same city under an IATA
+// metropolitan area code, e.g. NYC for JFK, LGA and EWR.
+type MetroArea struct {
+	Code        string   `json:"code" bun:",pk"` // unique identifier, IATA city code
+	Name        string   `json:"name"`
+	CountryCode string   `json:"country" bun:",nullzero"` // ISO 3166-1 alpha-2 code, foreign key
+	Airports    []string `json:"airports" bun:"-"`        // IATA codes of the member airports
+}
+
+// NewMetroArea decodes and validates a metropolitan area from b.
+func NewMetroArea(b io.Reader) (*MetroArea, error) {
+	var metro MetroArea
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&metro); err != nil {
+		return nil, fmt.Errorf("failed to decode metro area: %w", err)
+	}
+
+	if err := metro.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &metro, nil
+}
+
+// Validate checks the format of the metro area and airport codes.
+func (m *MetroArea) Validate() error {
+	var errs ValidationErrors
+	if !iataCodePattern.MatchString(m.Code) {
+		errs.add("code", "must be three uppercase letters")
+	}
+	if m.Name == "" {
+		errs.add("name", "is required")
+	}
+	if m.CountryCode != "" && !alpha2Pattern.MatchString(m.CountryCode) {
+		errs.add("country", "must be an ISO 3166-1 alpha-2 code")
+	}
+	seen := map[string]bool{}
+	for i, a := range m.Airports {
+		field := fmt.Sprintf("airports[%d]", i)
+		switch {
+		case !iataCodePattern.MatchString(a):
+			errs.add(field, "must be three uppercase letters")
+		case seen[a]:
+			errs.add(field, fmt.Sprintf("duplicate airport %s", a))
+		}
+		seen[a] = true
+	}
+	return errs.err()
+}
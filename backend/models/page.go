@@ -0,0 +1,14 @@
+package models
+
+// Page is the response envelope of paginated list endpoints.
+type Page[T any] struct {
+	Data       []T       `json:"data"`
+	Total      int       `json:"total"`                 // number of rows matching the filters
+	NextCursor string    `json:"next_cursor,omitempty"` // empty on the last page
+	Links      PageLinks `json:"links"`
+}
+
+type PageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// PayloadRangePoint is a corner of an aircraft's payload-range diagram.
+type PayloadRangePoint struct {
+	Id         int64   `json:"-" bun:",pk,autoincrement"`
+	AircraftId int     `json:"-"` // foreign key
+	RangeNm    float64 `json:"range_nm"`
+	PayloadKg  float64 `json:"payload_kg"`
+	Label      string  `json:"label,omitempty"` // e.g. "mtow", "fuel" or "ferry"
+}
+
+// PayloadRange is the payload-range envelope of an aircraft as a piecewise
+// linear curve through its corner points, ordered by increasing range. The
+// first point is usually the range at maximum payload (MTOW-limited), then the
+// range with full tanks (fuel-limited) and last the ferry range without payload.
+// Up to the first point the maximum payload is available, beyond the last no
+// flight is possible.
+type PayloadRange []PayloadRangePoint
+
+// NewPayloadRange decodes and validates a payload-range envelope from b.
+func NewPayloadRange(b io.Reader) (PayloadRange, error) {
+	var doc struct {
+		Points PayloadRange `json:"points"`
+	}
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to decode payload-range: %w", err)
+	}
+
+	if err := doc.Points.Validate(); err != nil {
+		return nil, err
+	}
+
+	return doc.Points, nil
+}
+
+// Validate checks that ranges increase and payloads do not increase along
+// the envelope.
+func (pr PayloadRange) Validate() error {
+	var errs ValidationErrors
+	if len(pr) < 2 {
+		errs.add("points", "needs at least two points")
+	}
+	for i, p := range pr {
+		field := fmt.Sprintf("points[%d]", i)
+		if p.RangeNm < 0 || p.PayloadKg < 0 {
+			errs.add(field, "range and payload must not be negative")
+			continue
+		}
+		if i == 0 {
+			continue
+		}
+		if p.RangeNm <= pr[i-1].RangeNm {
+			errs.add(field, "range must be greater than the range of the previous point")
+		}
+		if p.PayloadKg > pr[i-1].PayloadKg {
+			errs.add(field, "payload must not exceed the payload of the previous point")
+		}
+	}
+	return errs.err()
+}
+
+// MaxPayloadKg returns the largest payload that can be carried over
+// distanceNm. ok is false if the distance exceeds the envelope.
+func (pr PayloadRange) MaxPayloadKg(distanceNm float64) (payloadKg float64, ok bool) {
+	if len(pr) == 0 || distanceNm > pr[len(pr)-1].RangeNm {
+		return 0, false
+	}
+	if distanceNm <= pr[0].RangeNm {
+		return pr[0].PayloadKg, true
+	}
+	for i := 1; i < len(pr); i++ {
+		a, b := pr[i-1], pr[i]
+		if distanceNm <= b.RangeNm {
+			t := (distanceNm - a.RangeNm) / (b.RangeNm - a.RangeNm)
+			return a.PayloadKg + t*(b.PayloadKg-a.PayloadKg), true
+		}
+	}
+	return 0, false
+}
+
+// MaxRangeNm returns the longest distance payloadKg can be carried. ok is
+// false if the payload exceeds the maximum payload.
+func (pr PayloadRange) MaxRangeNm(payloadKg float64) (rangeNm float64, ok bool) {
+	if len(pr) == 0 || payloadKg > pr[0].PayloadKg {
+		return 0, false
+	}
+	for i := len(pr) - 1; i > 0; i-- {
+		a, b := pr[i-1], pr[i]
+		if payloadKg <= b.PayloadKg {
+			return b.RangeNm, true
+		}
+		if payloadKg <= a.PayloadKg {
+			t := (a.PayloadKg - payloadKg) / (a.PayloadKg - b.PayloadKg)
+			return a.RangeNm + t*(b.RangeNm-a.RangeNm), true
+		}
+	}
+	return pr[0].RangeNm, true
+}
+
+// PayloadRangeEnvelope is the payload-range diagram of an aircraft, optionally
+// evaluated for a route distance or a payload.
+type PayloadRangeEnvelope struct {
+	AircraftId   int          `json:"aircraft_id"`
+	Points       PayloadRange `json:"points"`
+	DistanceNm   *float64     `json:"distance_nm,omitempty"`
+	MaxPayloadKg *float64     `json:"max_payload_kg,omitempty"` // payload possible over DistanceNm
+	PayloadKg    *float64     `json:"payload_kg,omitempty"`
+	MaxRangeNm   *float64     `json:"max_range_nm,omitempty"` // range possible with PayloadKg
+}
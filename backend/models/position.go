@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+type FlightPhase string
+
+const (
+	PhaseClimb   FlightPhase = "climb"
+	PhaseCruise  FlightPhase = "cruise"
+	PhaseDescent FlightPhase = "descent"
+)
+
+// FlightPosition is the simulated position of an airborne flight, assuming it
+// flies the great-circle track from its origin to its destination at a
+// constant ground speed.
+type FlightPosition struct {
+	FlightId            int64        `json:"flight_id"`
+	FlightNumber        string       `json:"flight_number"`
+	Origin              string       `json:"origin"`
+	Destination         string       `json:"destination"` // the alternate airport of diverted flights
+	Status              FlightStatus `json:"status"`
+	Position            PointCoords  `json:"position"`
+	Heading             float64      `json:"heading"`  // true track in degrees, clockwise from north
+	Progress            float64      `json:"progress"` // fraction of the distance flown, between 0 and 1
+	Phase               FlightPhase  `json:"phase"`
+	AltitudeFt          int          `json:"altitude_ft"` // estimated from the phase and the cruise altitude of the aircraft
+	GroundSpeedKts      float64      `json:"ground_speed_kts"`
+	DistanceFlownKm     float64      `json:"distance_flown_km"`
+	DistanceRemainingKm float64      `json:"distance_remaining_km"`
+	DepartureTime       time.Time    `json:"departure_time"` // actual or expected departure
+	ArrivalTime         time.Time    `json:"arrival_time"`   // expected arrival
+}
+
+// FlightPositions lists the flights airborne at a point in time.
+type FlightPositions struct {
+	At      time.Time        `json:"at"`
+	Flights []FlightPosition `json:"flights"`
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// MaxTurnaroundMinutes is the longest minimum turnaround time a rotation
+// request can ask for.
+const MaxTurnaroundMinutes = 24 * 60
+
+// RotationRequest asks for a tail assignment of the flights departing on a
+// day: which airframe operates which flight.
+type RotationRequest struct {
+	Date                 string         `json:"date"`                             // UTC departure date of the flights to assign (YYYY-MM-DD)
+	AirframeIds          []int          `json:"airframe_ids,omitempty"`           // airframes to assign, all active airframes if empty
+	Positions            map[int]string `json:"positions,omitempty"`              // IATA code of the starting airport by airframe id
+	MinTurnaroundMinutes int            `json:"min_turnaround_minutes,omitempty"` // ground time between two legs, the fleet default if zero
+}
+
+// NewRotationRequest decodes and validates a rotation request from b.
+func NewRotationRequest(b io.Reader) (*RotationRequest, error) {
+	var req RotationRequest
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		return nil, fmt.Errorf("failed to decode rotation request: %w", err)
+	}
+
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &req, nil
+}
+
+// Validate checks the date, the airframe ids and the starting positions.
+func (r *RotationRequest) Validate() error {
+	var errs ValidationErrors
+	if _, err := time.Parse(time.DateOnly, r.Date); err != nil {
+		errs.add("date", "must be a date (YYYY-MM-DD)")
+	}
+	if slices.ContainsFunc(r.AirframeIds, func(id int) bool { return id <= 0 }) {
+		errs.add("airframe_ids", "must be positive")
+	}
+	for id, iata := range r.Positions {
+		if id <= 0 {
+			errs.add("positions", fmt.Sprintf("invalid airframe id %d", id))
+		} else if !iataCodePattern.MatchString(iata) {
+			errs.add("positions."+strconv.Itoa(id), "must be three uppercase letters")
+		}
+	}
+	if r.MinTurnaroundMinutes < 0 || r.MinTurnaroundMinutes > MaxTurnaroundMinutes {
+		errs.add("min_turnaround_minutes", fmt.Sprintf("must be between 0 and %d", MaxTurnaroundMinutes))
+	}
+	return errs.err()
+}
+
+// RotationLeg is a flight or a ferry leg of a rotation.
+type RotationLeg struct {
+	FlightId      int64     `json:"flight_id,omitempty"` // zero for ferry legs
+	FlightNumber  string    `json:"flight_number,omitempty"`
+	Ferry         bool      `json:"ferry"` // positioning leg without passengers
+	Origin        string    `json:"origin"`
+	Destination   string    `json:"destination"`
+	DepartureTime time.Time `json:"departure_time"`
+	ArrivalTime   time.Time `json:"arrival_time"`
+	DistanceKm    float64   `json:"distance_km"`
+}
+
+// Rotation is the sequence of legs an airframe operates during the day.
+type Rotation struct {
+	AirframeId   int           `json:"airframe_id"`
+	Registration string        `json:"registration"`
+	AircraftId   int           `json:"aircraft_id"`
+	HomeBase     string        `json:"home_base,omitempty"` // maintenance base the airframe returns to
+	Start        string        `json:"start,omitempty"`     // position at the start of the day, empty if unknown
+	End          string        `json:"end,omitempty"`       // position at the end of the day
+	Flights      int           `json:"flights"`
+	FerryLegs    int           `json:"ferry_legs"`
+	Legs         []RotationLeg `json:"legs"`
+}
+
+// UncoveredFlight is a flight no airframe could be assigned to.
+type UncoveredFlight struct {
+	FlightId      int64     `json:"flight_id"`
+	FlightNumber  string    `json:"flight_number"`
+	AircraftId    int       `json:"aircraft_id"`
+	Origin        string    `json:"origin"`
+	Destination   string    `json:"destination"`
+	DepartureTime time.Time `json:"departure_time"`
+	Reason        string    `json:"reason"`
+}
+
+// RotationPlan is a proposed tail assignment. It is not applied to the
+// flights.
+type RotationPlan struct {
+	Date      string            `json:"date"`
+	Flights   int               `json:"flights"`    // flights of the day
+	Covered   int               `json:"covered"`    // flights assigned to an airframe
+	FerryLegs int               `json:"ferry_legs"` // ferry legs of all rotations
+	Rotations []Rotation        `json:"rotations"`
+	Uncovered []UncoveredFlight `json:"uncovered"`
+}
+
+type RotationJobStatus string
+
+const (
+	RotationJobPending   RotationJobStatus = "pending"
+	RotationJobRunning   RotationJobStatus = "running"
+	RotationJobSucceeded RotationJobStatus = "succeeded"
+	RotationJobFailed    RotationJobStatus = "failed"
+)
+
+// RotationJob computes a rotation plan in the background. Plan is set once
+// the job succeeded, Error once it failed.
+type RotationJob struct {
+	Id         string            `json:"id"`
+	Status     RotationJobStatus `json:"status"`
+	Request    RotationRequest   `json:"request"`
+	CreatedAt  time.Time         `json:"created_at"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+	Plan       *RotationPlan     `json:"plan,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
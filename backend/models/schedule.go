@@ -0,0 +1,163 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+)
+
+// daysOfWeekPattern matches an operating days pattern such as "1234567" or
+// "1.3.5..": one position per ISO weekday from Monday (1) to Sunday (7),
+// holding the day number if the schedule operates on it and "." otherwise.
+var daysOfWeekPattern = regexp.MustCompile(`^[1.][2.][3.][4.][5.][6.][7.]$`)
+
+// localTimeLayout is the layout of local clock times such as "08:30".
+const localTimeLayout = "15:04"
+
+// MaxBlockMinutes is the longest block time a schedule can have.
+const MaxBlockMinutes = 24 * 60
+
+// Schedule is a recurring flight as published by airlines: a flight number
+// operated on some days of the week at the same local departure time between
+// two dates. It is expanded into dated flights by ScheduleService.
+type Schedule struct {
+	Id             int    `json:"id" bun:",pk,autoincrement"` // unique identifier
+	FlightNumber   string `json:"flight_number"`              // e.g. "LH400"
+	AircraftId     int    `json:"aircraft_id"`                // aircraft type, foreign key
+	Origin         string `json:"origin"`                     // foreign key
+	Destination    string `json:"destination"`                // foreign key
+	DaysOfWeek     string `json:"days_of_week"`               // e.g. "1.3.5..", see daysOfWeekPattern
+	DepartureLocal string `json:"departure_local"`            // departure time in the origin's time zone, e.g. "08:30"
+	BlockMinutes   int    `json:"block_minutes"`              // gate to gate time
+	EffectiveFrom  string `json:"effective_from"`             // first operating date (YYYY-MM-DD) in the origin's time zone
+	EffectiveTo    string `json:"effective_to"`               // last operating date (YYYY-MM-DD), inclusive
+}
+
+// NewSchedule decodes and validates a schedule from b.
+func NewSchedule(b io.Reader) (*Schedule, error) {
+	var schedule Schedule
+	dec := json.NewDecoder(b)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&schedule); err != nil {
+		return nil, fmt.Errorf("failed to decode schedule: %w", err)
+	}
+
+	if err := schedule.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &schedule, nil
+}
+
+// Validate checks the formats of all fields and that the effective period is
+// not reversed.
+func (s *Schedule) Validate() error {
+	var errs ValidationErrors
+	if !flightNumberPattern.MatchString(s.FlightNumber) {
+		errs.add("flight_number", "must be an uppercase flight number, e.g. LH400")
+	}
+	if s.AircraftId <= 0 {
+		errs.add("aircraft_id", "is required")
+	}
+	if !iataCodePattern.MatchString(s.Origin) {
+		errs.add("origin", "must be three uppercase letters")
+	}
+	if !iataCodePattern.MatchString(s.Destination) {
+		errs.add("destination", "must be three uppercase letters")
+	} else if s.Destination == s.Origin {
+		errs.add("destination", "must differ from origin")
+	}
+	if !daysOfWeekPattern.MatchString(s.DaysOfWeek) || s.DaysOfWeek == "......." {
+		errs.add("days_of_week", "must list the operating weekdays from Monday (1) to Sunday (7), e.g. 1.3.5..")
+	}
+	if _, err := time.Parse(localTimeLayout, s.DepartureLocal); err != nil {
+		errs.add("departure_local", "must be a time of day, e.g. 08:30")
+	}
+	if s.BlockMinutes <= 0 || s.BlockMinutes > MaxBlockMinutes {
+		errs.add("block_minutes", fmt.Sprintf("must be between 1 and %d", MaxBlockMinutes))
+	}
+	from, fromErr := time.Parse(time.DateOnly, s.EffectiveFrom)
+	if fromErr != nil {
+		errs.add("effective_from", "must be a date (YYYY-MM-DD)")
+	}
+	to, toErr := time.Parse(time.DateOnly, s.EffectiveTo)
+	if toErr != nil {
+		errs.add("effective_to", "must be a date (YYYY-MM-DD)")
+	} else if fromErr == nil && to.Before(from) {
+		errs.add("effective_to", "must not be before effective_from")
+	}
+	return errs.err()
+}
+
+// OperatesOn reports whether the schedule operates on weekday.
+func (s *Schedule) OperatesOn(weekday time.Weekday) bool {
+	// ISO weekdays start on Monday, time.Weekday on Sunday.
+	i := (int(weekday) + 6) % 7
+	return i < len(s.DaysOfWeek) && s.DaysOfWeek[i] != '.'
+}
+
+// Flights expands the schedule into its dated flights departing on the local
+// dates from to to (inclusive) at origin, whose time zone is loc. Only dates
+// within the effective period are included. Departure times are local to
+// loc, so the UTC departure shifts with daylight saving time. A departure
+// falling into a skipped hour is moved forward by the length of the gap, an
+// ambiguous one during a repeated hour takes its first occurrence, which is
+// still on the earlier offset.
+func (s *Schedule) Flights(loc *time.Location, from, to time.Time) []Flight {
+	dep, err := time.Parse(localTimeLayout, s.DepartureLocal)
+	if err != nil {
+		return nil
+	}
+	if effective, err := time.Parse(time.DateOnly, s.EffectiveFrom); err == nil && effective.After(from) {
+		from = effective
+	}
+	if effective, err := time.Parse(time.DateOnly, s.EffectiveTo); err == nil && effective.Before(to) {
+		to = effective
+	}
+
+	flights := []Flight{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if !s.OperatesOn(day.Weekday()) {
+			continue
+		}
+		departure := time.Date(day.Year(), day.Month(), day.Day(), dep.Hour(), dep.Minute(), 0, 0, loc)
+		if departure.Hour() != dep.Hour() || departure.Minute() != dep.Minute() {
+			// time.Date moves times in a gap back by its length.
+			_, before := departure.Zone()
+			_, after := departure.Add(3 * time.Hour).Zone()
+			departure = departure.Add(time.Duration(after-before) * time.Second)
+		} else {
+			// time.Date returns either occurrence of a time in a repeated
+			// hour depending on the zone, the earlier offset gives the first.
+			_, before := departure.Add(-3 * time.Hour).Zone()
+			_, offset := departure.Zone()
+			if first := departure.Add(time.Duration(offset-before) * time.Second); before > offset &&
+				first.Hour() == dep.Hour() && first.Minute() == dep.Minute() {
+				departure = first
+			}
+		}
+		flights = append(flights, Flight{
+			FlightNumber:  s.FlightNumber,
+			AircraftId:    s.AircraftId,
+			ScheduleId:    s.Id,
+			Origin:        s.Origin,
+			Destination:   s.Destination,
+			DepartureTime: departure.UTC(),
+			ArrivalTime:   departure.Add(time.Duration(s.BlockMinutes) * time.Minute).UTC(),
+		})
+	}
+	return flights
+}
+
+// ScheduleFlights lists the dated flights of a schedule within a date window.
+// When materializing, Flights holds the newly created flights and Skipped
+// counts the ones that already existed.
+type ScheduleFlights struct {
+	ScheduleId int      `json:"schedule_id"`
+	From       string   `json:"from"`
+	To         string   `json:"to"`
+	Flights    []Flight `json:"flights"`
+	Skipped    int      `json:"skipped"`
+}
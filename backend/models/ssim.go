@@ -0,0 +1,569 @@
+package models
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSIMRecordLength is the length of every record of an SSIM file.
+const SSIMRecordLength = 200
+
+// ssimDateLayout is the layout of SSIM dates such as "01JAN24".
+const ssimDateLayout = "02Jan06"
+
+// flightNumberParts splits a flight number into its airline designator,
+// number and operational suffix, see flightNumberPattern.
+var flightNumberParts = regexp.MustCompile(`^([A-Z0-9]{2}|[A-Z]{3})([0-9]{1,4})([A-Z]?)$`)
+
+// SSIMLeg is a flight leg record (type 3) of an IATA SSIM Chapter 7 schedule
+// file together with its segment data records (type 4). Times are local to
+// the stations unless UTC is set, offsets are the UTC variations in minutes.
+type SSIMLeg struct {
+	Line              int // line of the record in the file
+	UTC               bool
+	Airline           string // airline designator, e.g. "LH"
+	FlightNumber      int
+	Suffix            string // operational suffix
+	Variation         int    // itinerary variation identifier
+	LegSequence       int
+	ServiceType       string
+	From              time.Time // first date of the period of operation
+	To                time.Time // last date of the period of operation
+	DaysOfWeek        string    // operating days, see Schedule.DaysOfWeek
+	Origin            string
+	Departure         int // aircraft departure time in minutes after midnight
+	DepartureOffset   int
+	Destination       string
+	Arrival           int // aircraft arrival time in minutes after midnight
+	ArrivalOffset     int
+	AircraftType      string // IATA aircraft type code
+	DepartureDayShift int    // days the leg departs after the date of operation
+	ArrivalDayShift   int    // days the leg arrives after the date of operation
+	Segments          []SSIMSegment
+}
+
+// SSIMSegment is a segment data record (type 4) of a flight leg.
+type SSIMSegment struct {
+	Line        int
+	BoardPoint  string
+	OffPoint    string
+	DataElement int // data element identifier, e.g. 127 for the operating airline disclosure
+	Data        string
+}
+
+// FlightDesignator returns the flight number of the leg, e.g. "LH400".
+func (l *SSIMLeg) FlightDesignator() string {
+	return fmt.Sprintf("%s%d%s", l.Airline, l.FlightNumber, l.Suffix)
+}
+
+// Schedule converts the leg into a schedule operated by aircraftId. Times in
+// UTC are converted to the local time of the origin, moving the operating
+// days and period when the local date differs.
+func (l *SSIMLeg) Schedule(aircraftId int) Schedule {
+	departure := l.Departure
+	shift := l.DepartureDayShift
+	if l.UTC {
+		departure += l.DepartureOffset
+		shift += floorDiv(departure, 24*60)
+		departure -= floorDiv(departure, 24*60) * 24 * 60
+	}
+
+	block := l.Arrival - l.Departure + (l.ArrivalDayShift-l.DepartureDayShift)*24*60
+	if !l.UTC {
+		block += l.DepartureOffset - l.ArrivalOffset
+	}
+
+	return Schedule{
+		FlightNumber:   l.FlightDesignator(),
+		AircraftId:     aircraftId,
+		Origin:         l.Origin,
+		Destination:    l.Destination,
+		DaysOfWeek:     shiftDaysOfWeek(l.DaysOfWeek, shift),
+		DepartureLocal: fmt.Sprintf("%02d:%02d", departure/60, departure%60),
+		BlockMinutes:   block,
+		EffectiveFrom:  l.From.AddDate(0, 0, shift).Format(time.DateOnly),
+		EffectiveTo:    l.To.AddDate(0, 0, shift).Format(time.DateOnly),
+	}
+}
+
+// SSIMLegs converts the schedule into flight leg records in local time. The
+// period is split wherever the UTC variation at either end or the arrival day
+// changes, e.g. at daylight saving time switches, as each record has a
+// single one. origin and destination are the time zones of the airports.
+// Schedules whose flight number or period SSIM cannot express are rejected.
+func (s *Schedule) SSIMLegs(origin, destination *time.Location, aircraftType string) ([]SSIMLeg, error) {
+	parts := flightNumberParts.FindStringSubmatch(s.FlightNumber)
+	if parts == nil {
+		return nil, fmt.Errorf("flight number %q of schedule %d is not an airline designator with up to four digits", s.FlightNumber, s.Id)
+	}
+	number, _ := strconv.Atoi(parts[2])
+	from, errFrom := time.Parse(time.DateOnly, s.EffectiveFrom)
+	to, errTo := time.Parse(time.DateOnly, s.EffectiveTo)
+	if errFrom != nil || errTo != nil {
+		return nil, fmt.Errorf("invalid period %s to %s of %s", s.EffectiveFrom, s.EffectiveTo, s.FlightNumber)
+	}
+
+	var legs []SSIMLeg
+	var days [7]bool
+	for _, f := range s.Flights(origin, from, to) {
+		dep := f.DepartureTime.In(origin)
+		arr := f.ArrivalTime.In(destination)
+		_, depOffset := dep.Zone()
+		_, arrOffset := arr.Zone()
+		date := time.Date(dep.Year(), dep.Month(), dep.Day(), 0, 0, 0, 0, time.UTC)
+		leg := SSIMLeg{
+			Airline:         parts[1],
+			FlightNumber:    number,
+			Suffix:          parts[3],
+			ServiceType:     "J",
+			From:            date,
+			To:              date,
+			Origin:          s.Origin,
+			Departure:       dep.Hour()*60 + dep.Minute(),
+			DepartureOffset: depOffset / 60,
+			Destination:     s.Destination,
+			Arrival:         arr.Hour()*60 + arr.Minute(),
+			ArrivalOffset:   arrOffset / 60,
+			AircraftType:    aircraftType,
+			ArrivalDayShift: int(time.Date(arr.Year(), arr.Month(), arr.Day(), 0, 0, 0, 0, time.UTC).Sub(date).Hours() / 24),
+		}
+
+		if n := len(legs); n > 0 {
+			last := &legs[n-1]
+			if last.Departure == leg.Departure && last.DepartureOffset == leg.DepartureOffset &&
+				last.Arrival == leg.Arrival && last.ArrivalOffset == leg.ArrivalOffset &&
+				last.ArrivalDayShift == leg.ArrivalDayShift {
+				last.To = date
+				days[(int(date.Weekday())+6)%7] = true
+				last.DaysOfWeek = formatDaysOfWeek(days)
+				continue
+			}
+		}
+		days = [7]bool{}
+		days[(int(date.Weekday())+6)%7] = true
+		leg.DaysOfWeek = formatDaysOfWeek(days)
+		legs = append(legs, leg)
+	}
+
+	for i := range legs {
+		legs[i].Variation = i%99 + 1
+		legs[i].LegSequence = 1
+	}
+	return legs, nil
+}
+
+// ParseSSIM reads the flight legs of an SSIM Chapter 7 file. Header (type 1),
+// carrier (type 2), trailer (type 5) and filler records are checked for
+// their type only, the time mode of a carrier applies to its legs. All
+// malformed records are reported as ValidationErrors by line.
+func ParseSSIM(r io.Reader) ([]SSIMLeg, error) {
+	var legs []SSIMLeg
+	var errs ValidationErrors
+	utc := false
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		rec := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(rec) == "" {
+			continue
+		}
+		line := fmt.Sprintf("line %d", n)
+		if len(rec) > SSIMRecordLength {
+			errs.add(line, fmt.Sprintf("record longer than %d characters", SSIMRecordLength))
+			continue
+		}
+		rec += strings.Repeat(" ", SSIMRecordLength-len(rec))
+
+		switch rec[0] {
+		case '0', '1', '5':
+		case '2':
+			switch rec[1] {
+			case 'U':
+				utc = true
+			case 'L':
+				utc = false
+			default:
+				errs.add(line, "time mode must be U or L")
+			}
+		case '3':
+			leg, err := parseSSIMLeg(rec)
+			if err != nil {
+				errs.add(line, err.Error())
+				continue
+			}
+			leg.Line, leg.UTC = n, utc
+			legs = append(legs, leg)
+		case '4':
+			seg, err := parseSSIMSegment(rec)
+			if err != nil {
+				errs.add(line, err.Error())
+				continue
+			}
+			seg.Line = n
+			if len(legs) == 0 || !sameSSIMLeg(rec, legs[len(legs)-1]) {
+				errs.add(line, "segment data must follow the record of its flight leg")
+				continue
+			}
+			legs[len(legs)-1].Segments = append(legs[len(legs)-1].Segments, seg)
+		default:
+			errs.add(line, fmt.Sprintf("unknown record type %q", rec[0]))
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read SSIM file: %w", err)
+	}
+
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	return legs, nil
+}
+
+// WriteSSIM writes legs as an SSIM Chapter 7 file in local time mode, one
+// carrier per airline designator. Records are padded with filler records to
+// blocks of five.
+func WriteSSIM(w io.Writer, legs []SSIMLeg, created time.Time) error {
+	legs = slices.Clone(legs)
+	slices.SortStableFunc(legs, func(a, b SSIMLeg) int {
+		return cmp.Or(
+			cmp.Compare(a.Airline, b.Airline),
+			cmp.Compare(a.FlightNumber, b.FlightNumber),
+			cmp.Compare(a.Suffix, b.Suffix),
+			a.From.Compare(b.From),
+		)
+	})
+
+	var buf bytes.Buffer
+	serial := 0
+	write := func(rec ssimRecord) {
+		serial++
+		if rec[0] != '0' {
+			rec.put(195, fmt.Sprintf("%06d", serial%1000000))
+		}
+		buf.Write(rec[:])
+		buf.WriteString("\n")
+	}
+	pad := func() {
+		for serial%5 != 0 {
+			write(newSSIMRecord('0'))
+		}
+	}
+
+	header := newSSIMRecord('1')
+	header.put(2, "AIRLINE STANDARD SCHEDULE DATA SET")
+	header.put(192, "001")
+	write(header)
+	pad()
+
+	for start := 0; start < len(legs); {
+		airline := legs[start].Airline
+		end := start
+		for end < len(legs) && legs[end].Airline == airline {
+			end++
+		}
+		group := legs[start:end]
+		start = end
+
+		first, last := group[0].From, group[0].To
+		for _, l := range group {
+			first = minTime(first, l.From)
+			last = maxTime(last, l.To)
+		}
+		carrier := newSSIMRecord('2')
+		carrier.put(2, "L")
+		carrier.put(3, airline)
+		carrier.put(15, formatSSIMDate(first))
+		carrier.put(22, formatSSIMDate(last))
+		carrier.put(29, formatSSIMDate(created))
+		write(carrier)
+		pad()
+
+		for _, l := range group {
+			write(l.record())
+		}
+		trailer := newSSIMRecord('5')
+		trailer.put(3, airline)
+		trailer.put(188, fmt.Sprintf("%06d", serial%1000000))
+		trailer.put(194, "E")
+		write(trailer)
+		pad()
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ssimRecord is a fixed width SSIM record. Positions are 1-based like in the
+// SSIM manual.
+type ssimRecord [SSIMRecordLength]byte
+
+func newSSIMRecord(recordType byte) ssimRecord {
+	var rec ssimRecord
+	fill := byte(' ')
+	if recordType == '0' {
+		fill = '0'
+	}
+	for i := range rec {
+		rec[i] = fill
+	}
+	rec[0] = recordType
+	return rec
+}
+
+func (r *ssimRecord) put(pos int, s string) {
+	copy(r[pos-1:], s)
+}
+
+func (l *SSIMLeg) record() ssimRecord {
+	rec := newSSIMRecord('3')
+	rec.put(2, l.Suffix)
+	rec.put(3, l.Airline)
+	rec.put(6, fmt.Sprintf("%04d", l.FlightNumber))
+	rec.put(10, fmt.Sprintf("%02d", l.Variation))
+	rec.put(12, fmt.Sprintf("%02d", l.LegSequence))
+	rec.put(14, l.ServiceType)
+	rec.put(15, formatSSIMDate(l.From))
+	rec.put(22, formatSSIMDate(l.To))
+	days := []byte(l.DaysOfWeek)
+	for i, d := range days {
+		if d == '.' {
+			days[i] = ' '
+		}
+	}
+	rec.put(29, string(days))
+	rec.put(37, l.Origin)
+	rec.put(40, formatSSIMTime(l.Departure))
+	rec.put(44, formatSSIMTime(l.Departure))
+	rec.put(48, formatSSIMOffset(l.DepartureOffset))
+	rec.put(55, l.Destination)
+	rec.put(58, formatSSIMTime(l.Arrival))
+	rec.put(62, formatSSIMTime(l.Arrival))
+	rec.put(66, formatSSIMOffset(l.ArrivalOffset))
+	rec.put(73, l.AircraftType)
+	rec.put(193, formatSSIMDayShift(l.DepartureDayShift))
+	rec.put(194, formatSSIMDayShift(l.ArrivalDayShift))
+	return rec
+}
+
+// field returns the characters from position from to to (1-based, inclusive)
+// of rec without surrounding spaces.
+func field(rec string, from, to int) string {
+	return strings.TrimSpace(rec[from-1 : to])
+}
+
+func parseSSIMLeg(rec string) (SSIMLeg, error) {
+	var leg SSIMLeg
+	var err error
+	leg.Suffix = field(rec, 2, 2)
+	leg.Airline = field(rec, 3, 5)
+	if !flightNumberParts.MatchString(leg.Airline + "1") {
+		return leg, fmt.Errorf("invalid airline designator %q", leg.Airline)
+	}
+	if leg.FlightNumber, err = strconv.Atoi(field(rec, 6, 9)); err != nil || leg.FlightNumber < 1 {
+		return leg, fmt.Errorf("invalid flight number %q", field(rec, 6, 9))
+	}
+	if leg.Variation, err = strconv.Atoi(field(rec, 10, 11)); err != nil {
+		return leg, fmt.Errorf("invalid itinerary variation identifier %q", field(rec, 10, 11))
+	}
+	if leg.LegSequence, err = strconv.Atoi(field(rec, 12, 13)); err != nil {
+		return leg, fmt.Errorf("invalid leg sequence number %q", field(rec, 12, 13))
+	}
+	leg.ServiceType = field(rec, 14, 14)
+	if leg.From, err = parseSSIMDate(field(rec, 15, 21)); err != nil {
+		return leg, fmt.Errorf("invalid start of period of operation: %w", err)
+	}
+	if leg.To, err = parseSSIMDate(field(rec, 22, 28)); err != nil {
+		return leg, fmt.Errorf("invalid end of period of operation: %w", err)
+	}
+	days := []byte(rec[28:35])
+	for i, d := range days {
+		switch d {
+		case byte('1' + i):
+		case ' ':
+			days[i] = '.'
+		default:
+			return leg, fmt.Errorf("invalid days of operation %q", rec[28:35])
+		}
+	}
+	leg.DaysOfWeek = string(days)
+	if f := field(rec, 36, 36); f != "" && f != "1" {
+		return leg, fmt.Errorf("unsupported frequency rate %q: only weekly flights are supported", f)
+	}
+	leg.Origin = field(rec, 37, 39)
+	if leg.Departure, err = parseSSIMTime(field(rec, 44, 47)); err != nil {
+		return leg, fmt.Errorf("invalid aircraft departure time: %w", err)
+	}
+	if leg.DepartureOffset, err = parseSSIMOffset(field(rec, 48, 52)); err != nil {
+		return leg, fmt.Errorf("invalid departure UTC variation: %w", err)
+	}
+	leg.Destination = field(rec, 55, 57)
+	if leg.Arrival, err = parseSSIMTime(field(rec, 58, 61)); err != nil {
+		return leg, fmt.Errorf("invalid aircraft arrival time: %w", err)
+	}
+	if leg.ArrivalOffset, err = parseSSIMOffset(field(rec, 66, 70)); err != nil {
+		return leg, fmt.Errorf("invalid arrival UTC variation: %w", err)
+	}
+	leg.AircraftType = field(rec, 73, 75)
+	if leg.DepartureDayShift, err = parseSSIMDayShift(rec[192]); err != nil {
+		return leg, fmt.Errorf("invalid departure date variation: %w", err)
+	}
+	if leg.ArrivalDayShift, err = parseSSIMDayShift(rec[193]); err != nil {
+		return leg, fmt.Errorf("invalid arrival date variation: %w", err)
+	}
+	return leg, nil
+}
+
+func parseSSIMSegment(rec string) (SSIMSegment, error) {
+	seg := SSIMSegment{
+		BoardPoint: field(rec, 34, 36),
+		OffPoint:   field(rec, 37, 39),
+		Data:       field(rec, 40, 194),
+	}
+	var err error
+	if seg.DataElement, err = strconv.Atoi(field(rec, 31, 33)); err != nil {
+		return seg, fmt.Errorf("invalid data element identifier %q", field(rec, 31, 33))
+	}
+	return seg, nil
+}
+
+// sameSSIMLeg reports whether the segment data record rec belongs to leg.
+func sameSSIMLeg(rec string, leg SSIMLeg) bool {
+	number, _ := strconv.Atoi(field(rec, 6, 9))
+	variation, _ := strconv.Atoi(field(rec, 10, 11))
+	sequence, _ := strconv.Atoi(field(rec, 12, 13))
+	return field(rec, 2, 2) == leg.Suffix && field(rec, 3, 5) == leg.Airline &&
+		number == leg.FlightNumber && variation == leg.Variation && sequence == leg.LegSequence
+}
+
+func parseSSIMDate(s string) (time.Time, error) {
+	if s == "00XXX00" {
+		return time.Time{}, fmt.Errorf("open-ended periods are not supported")
+	}
+	t, err := time.Parse(ssimDateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date such as 01JAN24", s)
+	}
+	return t, nil
+}
+
+func formatSSIMDate(t time.Time) string {
+	return strings.ToUpper(t.Format(ssimDateLayout))
+}
+
+func parseSSIMTime(s string) (int, error) {
+	t, err := time.Parse("1504", s)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time such as 0830", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatSSIMTime(minutes int) string {
+	return fmt.Sprintf("%02d%02d", minutes/60, minutes%60)
+}
+
+func parseSSIMOffset(s string) (int, error) {
+	if len(s) != 5 || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("%q is not a variation such as +0100", s)
+	}
+	minutes, err := parseSSIMTime(s[1:])
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a variation such as +0100", s)
+	}
+	if s[0] == '-' {
+		minutes = -minutes
+	}
+	return minutes, nil
+}
+
+func formatSSIMOffset(minutes int) string {
+	sign := '+'
+	if minutes < 0 {
+		sign, minutes = '-', -minutes
+	}
+	return fmt.Sprintf("%c%s", sign, formatSSIMTime(minutes))
+}
+
+// parseSSIMDayShift parses a date variation: a number of days or A for the
+// day before.
+func parseSSIMDayShift(c byte) (int, error) {
+	switch {
+	case c == ' ':
+		return 0, nil
+	case c == 'A':
+		return -1, nil
+	case c >= '0' && c <= '9':
+		return int(c - '0'), nil
+	}
+	return 0, fmt.Errorf("%q is not a number of days or A", c)
+}
+
+func formatSSIMDayShift(days int) string {
+	if days < 0 {
+		return "A"
+	}
+	return strconv.Itoa(days)
+}
+
+// shiftDaysOfWeek moves the operating days of a days of week pattern by days.
+func shiftDaysOfWeek(pattern string, days int) string {
+	if len(pattern) != 7 {
+		return pattern
+	}
+	var operates [7]bool
+	for i := range 7 {
+		if pattern[i] != '.' {
+			operates[((i+days)%7+7)%7] = true
+		}
+	}
+	return formatDaysOfWeek(operates)
+}
+
+func formatDaysOfWeek(operates [7]bool) string {
+	b := []byte(".......")
+	for i, ok := range operates {
+		if ok {
+			b[i] = byte('1' + i)
+		}
+	}
+	return string(b)
+}
+
+func floorDiv(a, b int) int {
+	if a < 0 {
+		return -((-a + b - 1) / b)
+	}
+	return a / b
+}
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// SSIMImport summarizes the import of an SSIM file.
+type SSIMImport struct {
+	Legs              int `json:"legs"`     // flight leg records read
+	Segments          int `json:"segments"` // segment data records read, their data is not stored
+	SchedulesCreated  int `json:"schedules_created"`
+	SchedulesExisting int `json:"schedules_existing"` // legs matching a stored schedule
+	FlightsCreated    int `json:"flights_created"`
+	FlightsExisting   int `json:"flights_existing"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Types of flight stream messages.
+const (
+	StreamPosition = "position"
+	StreamStatus   = "status"
+)
+
+// StreamTypes lists all types of flight stream messages.
+var StreamTypes = []string{StreamPosition, StreamStatus}
+
+// StreamMessage is pushed to the subscribers of the flight stream. Exactly
+// one of Position and Status is set, depending on Type.
+type StreamMessage struct {
+	Type     string              `json:"type"`
+	Time     time.Time           `json:"time"`
+	Position *FlightPosition     `json:"position,omitempty"`
+	Status   *FlightStatusChange `json:"status,omitempty"`
+}
+
+// FlightStatusChange is a flight event together with the flight it changed.
+type FlightStatusChange struct {
+	FlightEvent
+	FlightNumber string `json:"flight_number"`
+	Origin       string `json:"origin"`
+	Destination  string `json:"destination"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ValidationError describes why the value of a single field was rejected.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors collects all field errors of a rejected write request.
+// It is reported as 422 Unprocessable Entity with the individual errors
+// listed in the response details.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, v := range e {
+		msgs[i] = v.Field + ": " + v.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e ValidationErrors) Code() int {
+	return http.StatusUnprocessableEntity
+}
+
+func (e ValidationErrors) Details() any {
+	return []ValidationError(e)
+}
+
+// add records a field error.
+func (e *ValidationErrors) add(field, message string) {
+	*e = append(*e, ValidationError{Field: field, Message: message})
+}
+
+// err returns e as an error, or nil if no field errors were recorded.
+func (e ValidationErrors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/leanderkunstmann/terraroute/backend/models"
 	"github.com/uptrace/bun"
@@ -18,20 +20,223 @@ func NewAircraftService(db *bun.DB) *AircraftService {
 	return &AircraftService{db: db}
 }
 
-func (as *AircraftService) ListAircraft(ctx context.Context, manufacturer, aircraftType string) ([]models.Aircraft, error) {
-	var aircrafts []models.Aircraft
-	query := as.db.NewSelect().Model(&aircrafts)
+// AircraftFilter narrows down the aircraft returned by ListAircraft.
+type AircraftFilter struct {
+	Manufacturer string
+	Type         string
+	MinRangeNm   int // only aircraft with at least this range
+	MinSeats     int // only aircraft with at least this many seats
+	Engines      int // only aircraft with exactly this many engines
+	IATACode     string
+}
+
+// aircraftListSpec lists the aircraft fields that can be sorted and selected.
+var aircraftListSpec = listSpec{
+	columns: map[string]string{
+		"id":                 "id",
+		"type":               "type",
+		"name":               "name",
+		"manufacturer":       "manufacturer",
+		"iata_code":          "iata_code",
+		"range":              "range_nm",
+		"cruise_speed_kts":   "cruise_speed_kts",
+		"cruise_altitude_ft": "cruise_altitude_ft",
+		"mtow_kg":            "mtow_kg",
+		"mlw_kg":             "mlw_kg",
+		"oew_kg":             "oew_kg",
+		"fuel_capacity_kg":   "fuel_capacity_kg",
+		"seats":              "seats",
+		"cargo_capacity_kg":  "cargo_capacity_kg",
+		"engines":            "engines",
+		"etops_minutes":      "etops_minutes",
+		"takeoff_runway_m":   "takeoff_runway_m",
+		"landing_runway_m":   "landing_runway_m",
+	},
+	pk: "id",
+}
 
-	if manufacturer != "" {
+func (as *AircraftService) ListAircraft(ctx context.Context, filter AircraftFilter, opts ListOptions) (models.Page[models.Aircraft], error) {
+	query := as.db.NewSelect().Model((*models.Aircraft)(nil))
+
+	if filter.Manufacturer != "" {
+		manufacturer, valid, ok, err := resolveManufacturer(ctx, as.db, filter.Manufacturer)
+		if err != nil {
+			return models.Page[models.Aircraft]{}, err
+		}
+		if !ok {
+			return models.Page[models.Aircraft]{}, UnknownValueError{Param: "manufacturer", Value: filter.Manufacturer, Valid: valid}
+		}
 		query.Where("manufacturer = ?", manufacturer)
 	}
-	if aircraftType != "" {
-		query.Where("type = ?", aircraftType)
+	if filter.Type != "" {
+		if !slices.Contains(models.AircraftTypes, models.AircraftType(filter.Type)) {
+			return models.Page[models.Aircraft]{}, UnknownValueError{Param: "aircraftType", Value: filter.Type, Valid: aircraftTypeNames()}
+		}
+		query.Where("type = ?", filter.Type)
+	}
+	if filter.MinRangeNm > 0 {
+		query.Where("range_nm >= ?", filter.MinRangeNm)
+	}
+	if filter.MinSeats > 0 {
+		query.Where("seats >= ?", filter.MinSeats)
+	}
+	if filter.Engines > 0 {
+		query.Where("engines = ?", filter.Engines)
+	}
+	if filter.IATACode != "" {
+		query.Where("iata_code = ?", strings.ToUpper(filter.IATACode))
 	}
 
-	if err := query.Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return nil, fmt.Errorf("failed to list aircrafts: %w", err)
+	page, err := paginate[models.Aircraft](ctx, query, opts, aircraftListSpec)
+	if err != nil {
+		return page, fmt.Errorf("failed to list aircrafts: %w", err)
 	}
 
-	return aircrafts, nil
+	return page, nil
+}
+
+// FindAircraft returns the aircraft with the given id.
+func (as *AircraftService) FindAircraft(ctx context.Context, id int) (models.Aircraft, error) {
+	var aircraft models.Aircraft
+	if err := as.db.NewSelect().Model(&aircraft).Where("id = ?", id).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Aircraft{}, NotFoundError(fmt.Sprintf("aircraft not found: %d", id))
+		}
+		return models.Aircraft{}, fmt.Errorf("failed to find aircraft: %w", err)
+	}
+
+	return aircraft, nil
+}
+
+// CreateAircraft validates and stores a new aircraft. The id is assigned by
+// the database and the name must not be used by another aircraft yet.
+func (as *AircraftService) CreateAircraft(ctx context.Context, aircraft *models.Aircraft) error {
+	if err := aircraft.Validate(); err != nil {
+		return err
+	}
+	aircraft.Id = 0
+
+	return as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkAircraftManufacturer(ctx, tx, aircraft); err != nil {
+			return err
+		}
+		if err := checkAircraftNameUnique(ctx, tx, aircraft.Name, 0); err != nil {
+			return err
+		}
+		if err := checkAircraftCodeUnique(ctx, tx, aircraft.IATACode, 0); err != nil {
+			return err
+		}
+		if _, err := tx.NewInsert().Model(aircraft).Returning("id").Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create aircraft: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateAircraft replaces the aircraft with the given id.
+func (as *AircraftService) UpdateAircraft(ctx context.Context, id int, aircraft *models.Aircraft) error {
+	if aircraft.Id != 0 && aircraft.Id != id {
+		return models.ValidationErrors{{Field: "id", Message: fmt.Sprintf("cannot be changed from %d", id)}}
+	}
+	aircraft.Id = id
+	if err := aircraft.Validate(); err != nil {
+		return err
+	}
+
+	return as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkAircraftManufacturer(ctx, tx, aircraft); err != nil {
+			return err
+		}
+		if err := checkAircraftNameUnique(ctx, tx, aircraft.Name, id); err != nil {
+			return err
+		}
+		if err := checkAircraftCodeUnique(ctx, tx, aircraft.IATACode, id); err != nil {
+			return err
+		}
+		res, err := tx.NewUpdate().Model(aircraft).WherePK().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update aircraft: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("aircraft not found: %d", id))
+		}
+		return nil
+	})
+}
+
+// DeleteAircraft removes an aircraft and its payload-range diagram. Aircraft
+// still used by flights or airframes cannot be deleted.
+func (as *AircraftService) DeleteAircraft(ctx context.Context, id int) error {
+	return as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		n, err := tx.NewSelect().Model((*models.Flight)(nil)).Where("aircraft_id = ?", id).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check flights of aircraft: %w", err)
+		}
+		if n > 0 {
+			return ConflictError(fmt.Sprintf("aircraft %d is used by %d flights", id, n))
+		}
+		n, err = tx.NewSelect().Model((*models.Airframe)(nil)).Where("aircraft_id = ?", id).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check airframes of aircraft: %w", err)
+		}
+		if n > 0 {
+			return ConflictError(fmt.Sprintf("aircraft %d is the type of %d airframes", id, n))
+		}
+
+		if _, err := tx.NewDelete().Model((*models.PayloadRangePoint)(nil)).Where("aircraft_id = ?", id).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to delete payload-range: %w", err)
+		}
+		res, err := tx.NewDelete().Model((*models.Aircraft)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete aircraft: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("aircraft not found: %d", id))
+		}
+		return nil
+	})
+}
+
+// checkAircraftManufacturer verifies that the manufacturer of aircraft is in
+// the catalog and normalizes its spelling.
+func checkAircraftManufacturer(ctx context.Context, tx bun.Tx, aircraft *models.Aircraft) error {
+	manufacturer, valid, ok, err := resolveManufacturer(ctx, tx, string(aircraft.Manufacturer))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return models.ValidationErrors{{Field: "manufacturer", Message: fmt.Sprintf("must be one of %s", strings.Join(valid, ", "))}}
+	}
+	aircraft.Manufacturer = manufacturer
+	return nil
+}
+
+// checkAircraftNameUnique returns a ConflictError if an aircraft other than
+// self already uses name.
+func checkAircraftNameUnique(ctx context.Context, tx bun.Tx, name string, self int) error {
+	n, err := tx.NewSelect().Model((*models.Aircraft)(nil)).Where("name = ?", name).Where("id != ?", self).Count(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check aircraft name: %w", err)
+	}
+	if n > 0 {
+		return ConflictError(fmt.Sprintf("aircraft name already exists: %s", name))
+	}
+	return nil
+}
+
+// checkAircraftCodeUnique returns a ConflictError if an aircraft other than
+// self already uses the IATA type code, which identifies aircraft in
+// schedule files.
+func checkAircraftCodeUnique(ctx context.Context, tx bun.Tx, code string, self int) error {
+	if code == "" {
+		return nil
+	}
+	n, err := tx.NewSelect().Model((*models.Aircraft)(nil)).Where("iata_code = ?", code).Where("id != ?", self).Count(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check aircraft IATA code: %w", err)
+	}
+	if n > 0 {
+		return ConflictError(fmt.Sprintf("aircraft IATA code already exists: %s", code))
+	}
+	return nil
 }
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+type AirframeService struct {
+	db *bun.DB
+}
+
+func NewAirframeService(db *bun.DB) *AirframeService {
+	return &AirframeService{db: db}
+}
+
+// AirframeFilter narrows down the airframes returned by ListAirframes.
+type AirframeFilter struct {
+	AircraftId   int
+	Registration string
+	Operator     string
+	HomeBase     string
+	Status       string
+}
+
+// airframeListSpec lists the airframe fields that can be sorted and selected.
+var airframeListSpec = listSpec{
+	columns: map[string]string{
+		"id":            "id",
+		"registration":  "registration",
+		"aircraft_id":   "aircraft_id",
+		"operator":      "operator",
+		"serial_number": "serial_number",
+		"home_base":     "home_base",
+		"status":        "status",
+	},
+	pk: "id",
+}
+
+func (as *AirframeService) ListAirframes(ctx context.Context, filter AirframeFilter, opts ListOptions) (models.Page[models.Airframe], error) {
+	query := as.db.NewSelect().Model((*models.Airframe)(nil))
+
+	if filter.AircraftId > 0 {
+		query.Where("aircraft_id = ?", filter.AircraftId)
+	}
+	if filter.Registration != "" {
+		query.Where("registration = ?", strings.ToUpper(filter.Registration))
+	}
+	if filter.Operator != "" {
+		query.Where("lower(operator) = lower(?)", filter.Operator)
+	}
+	if filter.HomeBase != "" {
+		query.Where("home_base = ?", strings.ToUpper(filter.HomeBase))
+	}
+	if filter.Status != "" {
+		query.Where("status = ?", filter.Status)
+	}
+
+	page, err := paginate[models.Airframe](ctx, query, opts, airframeListSpec)
+	if err != nil {
+		return page, fmt.Errorf("failed to list airframes: %w", err)
+	}
+
+	return page, nil
+}
+
+// FindAirframe returns the airframe with the given id.
+func (as *AirframeService) FindAirframe(ctx context.Context, id int) (models.Airframe, error) {
+	var airframe models.Airframe
+	if err := as.db.NewSelect().Model(&airframe).Where("id = ?", id).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Airframe{}, NotFoundError(fmt.Sprintf("airframe not found: %d", id))
+		}
+		return models.Airframe{}, fmt.Errorf("failed to find airframe: %w", err)
+	}
+
+	return airframe, nil
+}
+
+// CreateAirframe validates and stores a new airframe. The registration must
+// not be used by another airframe yet.
+func (as *AirframeService) CreateAirframe(ctx context.Context, airframe *models.Airframe) error {
+	if err := airframe.Validate(); err != nil {
+		return err
+	}
+	airframe.Id = 0
+
+	return as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkAirframeReferences(ctx, tx, airframe); err != nil {
+			return err
+		}
+		if err := checkRegistrationUnique(ctx, tx, airframe.Registration, 0); err != nil {
+			return err
+		}
+		if _, err := tx.NewInsert().Model(airframe).Returning("id").Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create airframe: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateAirframe replaces the airframe with the given id.
+func (as *AirframeService) UpdateAirframe(ctx context.Context, id int, airframe *models.Airframe) error {
+	if airframe.Id != 0 && airframe.Id != id {
+		return models.ValidationErrors{{Field: "id", Message: fmt.Sprintf("cannot be changed from %d", id)}}
+	}
+	airframe.Id = id
+	if err := airframe.Validate(); err != nil {
+		return err
+	}
+
+	return as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkAirframeReferences(ctx, tx, airframe); err != nil {
+			return err
+		}
+		if err := checkRegistrationUnique(ctx, tx, airframe.Registration, id); err != nil {
+			return err
+		}
+		res, err := tx.NewUpdate().Model(airframe).WherePK().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update airframe: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("airframe not found: %d", id))
+		}
+		return nil
+	})
+}
+
+// DeleteAirframe removes an airframe. Airframes still operating flights
+// cannot be deleted, retire them instead.
+func (as *AirframeService) DeleteAirframe(ctx context.Context, id int) error {
+	return as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		n, err := tx.NewSelect().Model((*models.Flight)(nil)).Where("airframe_id = ?", id).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check flights of airframe: %w", err)
+		}
+		if n > 0 {
+			return ConflictError(fmt.Sprintf("airframe %d is used by %d flights", id, n))
+		}
+
+		res, err := tx.NewDelete().Model((*models.Airframe)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete airframe: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("airframe not found: %d", id))
+		}
+		return nil
+	})
+}
+
+// checkAirframeReferences verifies that the aircraft type and home base of
+// airframe exist.
+func checkAirframeReferences(ctx context.Context, tx bun.Tx, airframe *models.Airframe) error {
+	var errs models.ValidationErrors
+	n, err := tx.NewSelect().Model((*models.Aircraft)(nil)).Where("id = ?", airframe.AircraftId).Count(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find aircraft: %w", err)
+	}
+	if n == 0 {
+		errs = append(errs, models.ValidationError{Field: "aircraft_id", Message: fmt.Sprintf("unknown aircraft %d", airframe.AircraftId)})
+	}
+	if airframe.HomeBase != "" {
+		n, err := tx.NewSelect().Model((*models.Airport)(nil)).Where("iata = ?", airframe.HomeBase).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to find airport: %w", err)
+		}
+		if n == 0 {
+			errs = append(errs, models.ValidationError{Field: "home_base", Message: fmt.Sprintf("unknown airport %s", airframe.HomeBase)})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkRegistrationUnique returns a ConflictError if an airframe other than
+// self already uses registration.
+func checkRegistrationUnique(ctx context.Context, tx bun.Tx, registration string, self int) error {
+	n, err := tx.NewSelect().Model((*models.Airframe)(nil)).Where("registration = ?", registration).Where("id != ?", self).Count(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check registration: %w", err)
+	}
+	if n > 0 {
+		return ConflictError(fmt.Sprintf("registration already exists: %s", registration))
+	}
+	return nil
+}
@@ -5,36 +5,439 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // airports carry IANA time zones, do not rely on the host's zoneinfo
 
+	"github.com/leanderkunstmann/terraroute/backend/database"
 	"github.com/leanderkunstmann/terraroute/backend/models"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 )
 
 type AirportService struct {
 	db *bun.DB
+
+	searchTable probe
+}
+
+// probe caches the answer of a check of the database setup. Only answers of
+// successful checks are kept, a failed check is run again on the next call.
+type probe struct {
+	mu    sync.Mutex
+	known bool
+	value bool
+}
+
+func (p *probe) get(check func() (bool, error)) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.known {
+		value, err := check()
+		if err != nil {
+			return false
+		}
+		p.known, p.value = true, value
+	}
+	return p.value
+}
+
+// AirportFilter narrows down the airports returned by ListAirports.
+type AirportFilter struct {
+	IATA      string
+	Continent string           // continent code or name
+	Country   string           // ISO 3166-1 alpha-2 or alpha-3 code or country name
+	Metro     string           // IATA metropolitan area code
+	BBox      *models.BBox     // only airports inside the box
+	Geometry  *models.Geometry // only airports inside the polygon
 }
 
 func NewAirportService(db *bun.DB) *AirportService {
 	return &AirportService{db: db}
 }
 
-func (as *AirportService) ListAirports(ctx context.Context, iata, continent, country string) ([]models.Airport, error) {
-	var airports []models.Airport
-	query := as.db.NewSelect().Model(&airports)
+// airportListSpec lists the airport fields that can be sorted and selected.
+var airportListSpec = listSpec{
+	columns: map[string]string{
+		"iata":           "iata",
+		"icao":           "icao",
+		"name":           "name",
+		"city":           "city",
+		"country":        "country",
+		"country_code":   "country_code",
+		"continent":      "continent",
+		"continent_code": "continent_code",
+		"metro":          "metro_code",
+		"latitude":       "latitude",
+		"longitude":      "longitude",
+		"time_zone":      "time_zone",
+
+		"min_connection_minutes": "min_connection_minutes",
+	},
+	pk: "iata",
+}
+
+func (as *AirportService) ListAirports(ctx context.Context, filter AirportFilter, opts ListOptions) (models.Page[models.Airport], error) {
+	query := as.db.NewSelect().Model((*models.Airport)(nil))
 
-	if iata != "" {
-		query.Where("iata = ?", iata)
+	if filter.IATA != "" {
+		query.Where("iata = ?", filter.IATA)
 	}
-	if continent != "" {
-		query.Where("continent = ?", continent)
+	if filter.Continent != "" {
+		query.Where("continent_code IN (?)", continentCodeQuery(as.db, filter.Continent))
 	}
-	if country != "" {
-		query.Where("country = ?", country)
+	if filter.Country != "" {
+		query.Where("country_code IN (?)", countryCodeQuery(as.db, filter.Country))
 	}
+	if filter.Metro != "" {
+		query.Where("metro_code = ?", filter.Metro)
+	}
+	if filter.BBox != nil {
+		whereInBBox(query, filter.BBox)
+	}
+
+	if filter.Geometry != nil {
+		// The bounding box lets the database discard most rows before the
+		// exact test, which SQLite runs in Go for every remaining row.
+		bbox := filter.Geometry.BBox()
+		whereInBBox(query, &bbox)
 
-	if err := query.Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
-		return nil, fmt.Errorf("failed to list airports: %w", err)
+		if as.db.Dialect().Name() == dialect.PG {
+			query.Where("ST_Intersects(ST_SetSRID(ST_GeomFromGeoJSON(?), 4326), ST_SetSRID(ST_MakePoint(longitude, latitude), 4326))",
+				filter.Geometry.String())
+		} else {
+			query.Where(database.GeometryContains+"(?, longitude, latitude)", filter.Geometry.String())
+		}
 	}
 
-	return airports, nil
+	page, err := paginate[models.Airport](ctx, query, opts, airportListSpec)
+	if err != nil {
+		return page, fmt.Errorf("failed to list airports: %w", err)
+	}
+
+	return page, nil
+}
+
+// whereInBBox restricts query to airports inside b. Boxes crossing the
+// antimeridian are split into the parts east and west of it.
+func whereInBBox(query *bun.SelectQuery, b *models.BBox) {
+	query.Where("latitude BETWEEN ? AND ?", b.MinLat, b.MaxLat)
+	if b.CrossesAntimeridian() {
+		query.Where("(longitude >= ? OR longitude <= ?)", b.MinLng, b.MaxLng)
+	} else {
+		query.Where("longitude BETWEEN ? AND ?", b.MinLng, b.MaxLng)
+	}
+}
+
+// nearestAirportsCount is the number of nearby airports included in an airport's details.
+const nearestAirportsCount = 5
+
+// GetAirport returns the airport with the given IATA or ICAO code together
+// with its runways, flights, nearest airports and current time zone offset.
+func (as *AirportService) GetAirport(ctx context.Context, code string) (models.AirportDetail, error) {
+	airport, err := as.FindAirport(ctx, code)
+	if err != nil {
+		return models.AirportDetail{}, err
+	}
+
+	detail := models.AirportDetail{
+		Airport:    airport,
+		Runways:    []models.Runway{},
+		Departures: []models.Flight{},
+		Arrivals:   []models.Flight{},
+	}
+
+	if airport.TimeZone != "" {
+		loc, err := time.LoadLocation(airport.TimeZone)
+		if err != nil {
+			return models.AirportDetail{}, fmt.Errorf("invalid time zone of airport %s: %w", airport.IATA, err)
+		}
+		detail.LocalTime = time.Now().In(loc).Truncate(time.Second)
+		detail.UTCOffset = detail.LocalTime.Format("-07:00")
+	}
+
+	if err := as.db.NewSelect().Model(&detail.Runways).Where("airport_iata = ?", airport.IATA).Order("designator").Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.AirportDetail{}, fmt.Errorf("failed to list runways: %w", err)
+	}
+	if err := as.db.NewSelect().Model(&detail.Departures).Where("origin = ?", airport.IATA).Order("departure_time").Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.AirportDetail{}, fmt.Errorf("failed to list departures: %w", err)
+	}
+	if err := as.db.NewSelect().Model(&detail.Arrivals).Where("destination = ?", airport.IATA).Order("arrival_time").Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.AirportDetail{}, fmt.Errorf("failed to list arrivals: %w", err)
+	}
+
+	nearest, err := as.nearestAirports(ctx, airport, nearestAirportsCount)
+	if err != nil {
+		return models.AirportDetail{}, err
+	}
+	detail.Nearest = nearest
+
+	return detail, nil
+}
+
+// nearestAirportsRadiusKm is the radius around an airport first searched
+// for its nearest airports. It is doubled until enough airports are found.
+const nearestAirportsRadiusKm = 250
+
+// nearestAirports returns up to n airports closest to airport, nearest first.
+// Only the airports in a bounding box around it are read, the box is widened
+// until it holds n airports within the searched radius.
+func (as *AirportService) nearestAirports(ctx context.Context, airport models.Airport, n int) ([]models.NearbyAirport, error) {
+	origin := models.PointCoords{Lat: airport.Latitude, Lng: airport.Longitude}
+
+	for radius := float64(nearestAirportsRadiusKm); ; radius *= 2 {
+		bbox, global := bboxAround(origin, radius)
+		query := as.db.NewSelect().Model((*models.Airport)(nil)).Where("iata != ?", airport.IATA)
+		if !global {
+			whereInBBox(query, &bbox)
+		}
+		var airports []models.Airport
+		if err := query.Scan(ctx, &airports); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to list airports: %w", err)
+		}
+
+		// Airports in the corners of the box may be farther away than
+		// airports just outside of it, only the ones within the radius are
+		// known to be the nearest.
+		nearby := make([]models.NearbyAirport, 0, len(airports))
+		for _, a := range airports {
+			d := distanceKm(origin, models.PointCoords{Lat: a.Latitude, Lng: a.Longitude})
+			if d <= radius || global {
+				nearby = append(nearby, models.NearbyAirport{Airport: a, DistanceKm: d})
+			}
+		}
+		if len(nearby) < n && !global {
+			continue
+		}
+
+		sort.Slice(nearby, func(i, j int) bool {
+			return nearby[i].DistanceKm < nearby[j].DistanceKm
+		})
+
+		return nearby[:min(n, len(nearby))], nil
+	}
+}
+
+// bboxAround returns the smallest bounding box holding every point within
+// radiusKm of p. global is set when that is the whole world.
+func bboxAround(p models.PointCoords, radiusKm float64) (bbox models.BBox, global bool) {
+	angle := radiusKm / earthRadiusKm
+	if angle >= math.Pi {
+		return models.BBox{MinLng: -180, MinLat: -90, MaxLng: 180, MaxLat: 90}, true
+	}
+
+	dLat := angle * 180 / math.Pi
+	bbox = models.BBox{MinLng: -180, MinLat: max(p.Lat-dLat, -90), MaxLng: 180, MaxLat: min(p.Lat+dLat, 90)}
+	// Boxes reaching a pole span every longitude.
+	if bbox.MinLat == -90 || bbox.MaxLat == 90 {
+		return bbox, false
+	}
+	ratio := math.Sin(angle) / math.Cos(p.Lat*math.Pi/180)
+	if ratio >= 1 {
+		return bbox, false
+	}
+
+	dLng := math.Asin(ratio) * 180 / math.Pi
+	bbox.MinLng, bbox.MaxLng = p.Lng-dLng, p.Lng+dLng
+	if bbox.MinLng < -180 {
+		bbox.MinLng += 360
+	}
+	if bbox.MaxLng > 180 {
+		bbox.MaxLng -= 360
+	}
+	return bbox, false
+}
+
+// FindAirport returns the airport with the given IATA or ICAO code.
+func (as *AirportService) FindAirport(ctx context.Context, code string) (models.Airport, error) {
+	code = strings.ToUpper(code)
+
+	var airport models.Airport
+	if err := as.db.NewSelect().Model(&airport).Where("iata = ? OR icao = ?", code, code).Limit(1).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Airport{}, NotFoundError(fmt.Sprintf("airport not found: %s", code))
+		}
+		return models.Airport{}, fmt.Errorf("failed to find airport: %w", err)
+	}
+
+	return airport, nil
+}
+
+// CreateAirport validates and stores a new airport. IATA and ICAO codes must
+// not be used by another airport yet.
+func (as *AirportService) CreateAirport(ctx context.Context, airport *models.Airport) error {
+	if err := airport.Validate(); err != nil {
+		return err
+	}
+
+	return as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkAirportReferences(ctx, tx, airport); err != nil {
+			return err
+		}
+		if err := checkAirportCodesUnique(ctx, tx, airport, ""); err != nil {
+			return err
+		}
+		if _, err := tx.NewInsert().Model(airport).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create airport: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateAirport replaces the airport stored under iata. The IATA code itself
+// is the identity of an airport and cannot be changed.
+func (as *AirportService) UpdateAirport(ctx context.Context, iata string, airport *models.Airport) error {
+	iata = strings.ToUpper(iata)
+	if airport.IATA != iata {
+		return models.ValidationErrors{{Field: "iata", Message: fmt.Sprintf("cannot be changed from %s", iata)}}
+	}
+	if err := airport.Validate(); err != nil {
+		return err
+	}
+
+	return as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkAirportReferences(ctx, tx, airport); err != nil {
+			return err
+		}
+		if err := checkAirportCodesUnique(ctx, tx, airport, iata); err != nil {
+			return err
+		}
+		res, err := tx.NewUpdate().Model(airport).WherePK().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update airport: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("airport not found: %s", iata))
+		}
+		return nil
+	})
+}
+
+// DeleteAirport removes an airport and its runways. Airports still used by
+// flights or as home base of airframes cannot be deleted.
+func (as *AirportService) DeleteAirport(ctx context.Context, iata string) error {
+	iata = strings.ToUpper(iata)
+
+	return as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		n, err := tx.NewSelect().Model((*models.Flight)(nil)).Where("origin = ? OR destination = ? OR diverted_to = ?", iata, iata, iata).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check flights of airport: %w", err)
+		}
+		if n > 0 {
+			return ConflictError(fmt.Sprintf("airport %s is used by %d flights", iata, n))
+		}
+		n, err = tx.NewSelect().Model((*models.Airframe)(nil)).Where("home_base = ?", iata).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check airframes of airport: %w", err)
+		}
+		if n > 0 {
+			return ConflictError(fmt.Sprintf("airport %s is the home base of %d airframes", iata, n))
+		}
+
+		if _, err := tx.NewDelete().Model((*models.Runway)(nil)).Where("airport_iata = ?", iata).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to delete runways: %w", err)
+		}
+		res, err := tx.NewDelete().Model((*models.Airport)(nil)).Where("iata = ?", iata).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete airport: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("airport not found: %s", iata))
+		}
+		return nil
+	})
+}
+
+// checkAirportCodesUnique returns a ConflictError if another airport than the
+// one stored under self already uses the IATA or ICAO code of airport.
+func checkAirportCodesUnique(ctx context.Context, tx bun.Tx, airport *models.Airport, self string) error {
+	query := tx.NewSelect().Model((*models.Airport)(nil)).Column("iata")
+	if airport.ICAO != "" {
+		query.WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.Where("iata = ?", airport.IATA).WhereOr("icao = ?", airport.ICAO)
+		})
+	} else {
+		query.Where("iata = ?", airport.IATA)
+	}
+	if self != "" {
+		query.Where("iata != ?", self)
+	}
+
+	var existing []string
+	if err := query.Scan(ctx, &existing); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check airport codes: %w", err)
+	}
+	if len(existing) > 0 {
+		return ConflictError(fmt.Sprintf("airport code already used by %s", existing[0]))
+	}
+	return nil
+}
+
+// checkAirportReferences verifies that country, continent and metro area of airport exist.
+// Countries and continents given by name only are resolved to their codes, a
+// missing continent is taken from the country. The names are then set from
+// the codes.
+func checkAirportReferences(ctx context.Context, tx bun.Tx, airport *models.Airport) error {
+	var errs models.ValidationErrors
+	if airport.CountryCode == "" && airport.Country != "" {
+		if err := countryCodeQuery(tx, airport.Country).Limit(1).Scan(ctx, &airport.CountryCode); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to find country: %w", err)
+		}
+		if airport.CountryCode == "" {
+			errs = append(errs, models.ValidationError{Field: "country", Message: fmt.Sprintf("unknown country %s", airport.Country)})
+		}
+	}
+	if airport.ContinentCode == "" && airport.Continent != "" {
+		if err := continentCodeQuery(tx, airport.Continent).Limit(1).Scan(ctx, &airport.ContinentCode); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to find continent: %w", err)
+		}
+		if airport.ContinentCode == "" {
+			errs = append(errs, models.ValidationError{Field: "continent", Message: fmt.Sprintf("unknown continent %s", airport.Continent)})
+		}
+	}
+
+	airport.Country, airport.Continent = "", ""
+	if airport.CountryCode != "" {
+		var country models.Country
+		err := tx.NewSelect().Model(&country).Where("alpha2 = ?", airport.CountryCode).Scan(ctx)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			errs = append(errs, models.ValidationError{Field: "country_code", Message: fmt.Sprintf("unknown country %s", airport.CountryCode)})
+		case err != nil:
+			return fmt.Errorf("failed to find country: %w", err)
+		default:
+			airport.Country = country.Name
+			if airport.ContinentCode == "" {
+				airport.ContinentCode = country.ContinentCode
+			}
+		}
+	}
+	if airport.ContinentCode != "" {
+		var continent models.Continent
+		err := tx.NewSelect().Model(&continent).Where("code = ?", airport.ContinentCode).Scan(ctx)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			errs = append(errs, models.ValidationError{Field: "continent_code", Message: fmt.Sprintf("unknown continent %s", airport.ContinentCode)})
+		case err != nil:
+			return fmt.Errorf("failed to find continent: %w", err)
+		default:
+			airport.Continent = continent.Name
+		}
+	}
+	if airport.MetroCode != "" {
+		n, err := tx.NewSelect().Model((*models.MetroArea)(nil)).Where("code = ?", airport.MetroCode).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to find metro area: %w", err)
+		}
+		if n == 0 {
+			errs = append(errs, models.ValidationError{Field: "metro", Message: fmt.Sprintf("unknown metro area %s", airport.MetroCode)})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
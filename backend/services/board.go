@@ -0,0 +1,193 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// BoardLookBack is how long before now the default board window starts,
+	// so that flights which just departed or arrived are still shown.
+	BoardLookBack = time.Hour
+	// DefaultBoardWindow is the length of a board window without an end.
+	DefaultBoardWindow = 12 * time.Hour
+	// MaxBoardWindow is the longest window a board can show.
+	MaxBoardWindow = 7 * 24 * time.Hour
+	// maxBoardDelay is how late a flight scheduled before the window can be
+	// and still show up on the board because of its estimated or actual time.
+	maxBoardDelay = 24 * time.Hour
+)
+
+// BoardTypes lists the types of airport boards.
+var BoardTypes = []string{models.BoardDepartures, models.BoardArrivals}
+
+// BoardQuery selects the flights of an airport board. Date selects the local
+// day at the airport. Otherwise From and To bound the window; From defaults
+// to BoardLookBack before now and To to DefaultBoardWindow after From.
+type BoardQuery struct {
+	Airport string
+	Type    string
+	Date    time.Time
+	From    time.Time
+	To      time.Time
+}
+
+// Board returns the departures or arrivals of an airport whose scheduled,
+// estimated or actual time falls into the query window. Arrivals include
+// flights diverted to the airport.
+func (fs *FlightService) Board(ctx context.Context, q BoardQuery) (models.Board, error) {
+	if !slices.Contains(BoardTypes, q.Type) {
+		return models.Board{}, UnknownValueError{Param: "type", Value: q.Type, Valid: BoardTypes}
+	}
+
+	code := strings.ToUpper(q.Airport)
+	var airport models.Airport
+	if err := fs.db.NewSelect().Model(&airport).Where("iata = ?", code).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Board{}, NotFoundError(fmt.Sprintf("airport not found: %s", code))
+		}
+		return models.Board{}, fmt.Errorf("failed to load airport: %w", err)
+	}
+	loc, err := airportLocation(airport)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	from, to := q.From, q.To
+	if !q.Date.IsZero() {
+		y, m, d := q.Date.Date()
+		from = time.Date(y, m, d, 0, 0, 0, 0, loc)
+		to = from.AddDate(0, 0, 1)
+	}
+	if from.IsZero() {
+		from = time.Now().Add(-BoardLookBack)
+	}
+	if to.IsZero() {
+		to = from.Add(DefaultBoardWindow)
+	}
+	if !to.After(from) {
+		return models.Board{}, BadRequestError("invalid board window: to must be after from")
+	}
+	if to.Sub(from) > MaxBoardWindow {
+		return models.Board{}, BadRequestError(fmt.Sprintf("invalid board window: must not be longer than %s", MaxBoardWindow))
+	}
+
+	column := "departure_time"
+	query := fs.db.NewSelect().Model((*models.Flight)(nil))
+	if q.Type == models.BoardDepartures {
+		query.Where("origin = ?", code)
+	} else {
+		column = "arrival_time"
+		query.Where("(destination = ? OR diverted_to = ?)", code, code)
+	}
+	var flights []models.Flight
+	if err := query.
+		Where("? >= ?", bun.Ident(column), from.Add(-maxBoardDelay).UTC()).
+		Where("? < ?", bun.Ident(column), to.UTC()).
+		Scan(ctx, &flights); err != nil {
+		return models.Board{}, fmt.Errorf("failed to list board flights: %w", err)
+	}
+
+	details := make([]models.FlightDetail, len(flights))
+	for i, f := range flights {
+		details[i].Flight = f
+	}
+	if err := fs.loadFlightDetails(ctx, details); err != nil {
+		return models.Board{}, err
+	}
+
+	board := models.Board{
+		Airport:  code,
+		Type:     q.Type,
+		TimeZone: loc.String(),
+		From:     from.In(loc),
+		To:       to.In(loc),
+		Flights:  []models.BoardFlight{},
+	}
+	inWindow := func(t time.Time) bool {
+		return !t.Before(from) && t.Before(to)
+	}
+	for _, f := range details {
+		row := newBoardFlight(f, q.Type, loc)
+		latest := row.ScheduledTime
+		if row.ActualTime != nil {
+			latest = *row.ActualTime
+		} else if row.EstimatedTime != nil {
+			latest = *row.EstimatedTime
+		}
+		if inWindow(row.ScheduledTime) || inWindow(latest) {
+			board.Flights = append(board.Flights, row)
+		}
+	}
+	slices.SortFunc(board.Flights, func(a, b models.BoardFlight) int {
+		return cmp.Or(a.ScheduledTime.Compare(b.ScheduledTime), cmp.Compare(a.FlightNumber, b.FlightNumber))
+	})
+
+	return board, nil
+}
+
+// newBoardFlight converts a flight to a row of a board of the given type with
+// times in loc. Arrivals of flights that have not landed yet are estimated
+// from their actual or estimated departure and the scheduled block time.
+func newBoardFlight(f models.FlightDetail, boardType string, loc *time.Location) models.BoardFlight {
+	row := models.BoardFlight{
+		FlightId:     f.Id,
+		FlightNumber: f.FlightNumber,
+		Status:       f.Status,
+		DivertedTo:   f.DivertedTo,
+		AircraftId:   f.AircraftId,
+	}
+	if f.Aircraft != nil {
+		row.Aircraft = f.Aircraft.Name
+	}
+	if f.Airframe != nil {
+		row.Registration = f.Airframe.Registration
+	}
+
+	local := func(t *time.Time) *time.Time {
+		if t == nil {
+			return nil
+		}
+		l := t.In(loc)
+		return &l
+	}
+
+	other := f.DestinationAirport
+	if boardType == models.BoardDepartures {
+		row.Airport = f.Destination
+		row.ScheduledTime = f.DepartureTime.In(loc)
+		row.ActualTime = local(f.ActualDepartureTime)
+		if f.ActualDepartureTime == nil && f.Status != models.FlightCancelled {
+			row.EstimatedTime = local(f.EstimatedDepartureTime)
+		}
+	} else {
+		other = f.OriginAirport
+		row.Airport = f.Origin
+		row.ScheduledTime = f.ArrivalTime.In(loc)
+		row.ActualTime = local(f.ActualArrivalTime)
+		if f.ActualArrivalTime == nil && f.Status != models.FlightCancelled {
+			departure := f.ActualDepartureTime
+			if departure == nil {
+				departure = f.EstimatedDepartureTime
+			}
+			if departure != nil {
+				eta := departure.Add(f.ArrivalTime.Sub(f.DepartureTime))
+				row.EstimatedTime = local(&eta)
+			}
+		}
+	}
+	if other != nil {
+		row.City = other.City
+	}
+
+	return row
+}
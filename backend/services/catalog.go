@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+// CatalogService serves the manufacturers and aircraft types aircraft are
+// validated against.
+type CatalogService struct {
+	db *bun.DB
+}
+
+func NewCatalogService(db *bun.DB) *CatalogService {
+	return &CatalogService{db: db}
+}
+
+// ListManufacturers returns the manufacturer catalog with the number of
+// aircraft of each manufacturer.
+func (cs *CatalogService) ListManufacturers(ctx context.Context) ([]models.ManufacturerEntry, error) {
+	manufacturers := []models.ManufacturerEntry{}
+	err := cs.db.NewSelect().Model(&manufacturers).
+		ColumnExpr("manufacturer.*").
+		ColumnExpr("(SELECT count(*) FROM aircrafts AS a WHERE a.manufacturer = manufacturer.name) AS aircraft_count").
+		Order("name").
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to list manufacturers: %w", err)
+	}
+
+	return manufacturers, nil
+}
+
+// CreateManufacturer adds a manufacturer to the catalog.
+func (cs *CatalogService) CreateManufacturer(ctx context.Context, m *models.ManufacturerEntry) error {
+	return cs.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		n, err := tx.NewSelect().Model((*models.ManufacturerEntry)(nil)).Where("lower(name) = lower(?)", m.Name).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check manufacturer: %w", err)
+		}
+		if n > 0 {
+			return ConflictError(fmt.Sprintf("manufacturer already exists: %s", m.Name))
+		}
+		if m.CountryCode != "" {
+			n, err := tx.NewSelect().Model((*models.Country)(nil)).Where("alpha2 = ?", m.CountryCode).Count(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to find country: %w", err)
+			}
+			if n == 0 {
+				return models.ValidationErrors{{Field: "country", Message: fmt.Sprintf("unknown country %s", m.CountryCode)}}
+			}
+		}
+		if _, err := tx.NewInsert().Model(m).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create manufacturer: %w", err)
+		}
+		return nil
+	})
+}
+
+// DeleteManufacturer removes a manufacturer from the catalog. Manufacturers
+// of stored aircraft cannot be deleted.
+func (cs *CatalogService) DeleteManufacturer(ctx context.Context, name string) error {
+	return cs.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		n, err := tx.NewSelect().Model((*models.Aircraft)(nil)).Where("manufacturer = ?", name).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check aircraft of manufacturer: %w", err)
+		}
+		if n > 0 {
+			return ConflictError(fmt.Sprintf("manufacturer %s is used by %d aircraft", name, n))
+		}
+
+		res, err := tx.NewDelete().Model((*models.ManufacturerEntry)(nil)).Where("name = ?", name).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete manufacturer: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("manufacturer not found: %s", name))
+		}
+		return nil
+	})
+}
+
+// ListAircraftTypes returns all aircraft types with the number of aircraft of each.
+func (cs *CatalogService) ListAircraftTypes(ctx context.Context) ([]models.AircraftTypeEntry, error) {
+	var counts []struct {
+		Type  models.AircraftType
+		Count int
+	}
+	if err := cs.db.NewSelect().Model((*models.Aircraft)(nil)).
+		Column("type").
+		ColumnExpr("count(*) AS count").
+		Group("type").
+		Scan(ctx, &counts); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to count aircraft types: %w", err)
+	}
+	byType := map[models.AircraftType]int{}
+	for _, c := range counts {
+		byType[c.Type] = c.Count
+	}
+
+	types := make([]models.AircraftTypeEntry, len(models.AircraftTypes))
+	for i, t := range models.AircraftTypes {
+		types[i] = models.AircraftTypeEntry{Type: t, AircraftCount: byType[t]}
+	}
+	return types, nil
+}
+
+// manufacturerNames returns the names of all catalog manufacturers.
+func manufacturerNames(ctx context.Context, db bun.IDB) ([]string, error) {
+	var names []string
+	if err := db.NewSelect().Model((*models.ManufacturerEntry)(nil)).Column("name").Order("name").Scan(ctx, &names); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to list manufacturers: %w", err)
+	}
+	return names, nil
+}
+
+// resolveManufacturer returns the catalog spelling of name, matched case
+// insensitively. ok is false for unknown manufacturers, valid then lists
+// all known ones.
+func resolveManufacturer(ctx context.Context, db bun.IDB, name string) (resolved models.Manufacturer, valid []string, ok bool, err error) {
+	valid, err = manufacturerNames(ctx, db)
+	if err != nil {
+		return "", nil, false, err
+	}
+	for _, v := range valid {
+		if strings.EqualFold(v, strings.TrimSpace(name)) {
+			return models.Manufacturer(v), valid, true, nil
+		}
+	}
+	return "", valid, false, nil
+}
+
+// aircraftTypeNames returns the names of all aircraft types.
+func aircraftTypeNames() []string {
+	names := make([]string, len(models.AircraftTypes))
+	for i, t := range models.AircraftTypes {
+		names[i] = string(t)
+	}
+	return names
+}
@@ -0,0 +1,371 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// DefaultMaxStops is the number of connections searched when a request
+	// does not set one.
+	DefaultMaxStops = 1
+	// MaxStops is the largest number of connections a request can search.
+	MaxStops = 3
+	// DefaultMaxLayover is the longest layover when a request does not set one.
+	DefaultMaxLayover = 6 * time.Hour
+	// MaxLayover is the longest layover a request can allow.
+	MaxLayover = 24 * time.Hour
+	// DefaultConnectionLimit is the number of itineraries returned when a
+	// request does not set a limit.
+	DefaultConnectionLimit = 20
+	// MaxConnectionLimit is the largest number of itineraries a request can get.
+	MaxConnectionLimit = 100
+)
+
+// Orders of connection search results.
+const (
+	ConnectionSortDuration = "duration" // shortest total elapsed time first
+	ConnectionSortDistance = "distance" // shortest total distance first
+	ConnectionSortStops    = "stops"    // fewest connections first
+)
+
+// ConnectionSorts lists all orders of connection search results.
+var ConnectionSorts = []string{ConnectionSortDuration, ConnectionSortDistance, ConnectionSortStops}
+
+// ConnectionQuery describes a connection search. From and To accept an
+// airport IATA code or a metro area code. Date is the departure date local
+// to each origin airport.
+type ConnectionQuery struct {
+	From       string
+	To         string
+	Date       time.Time
+	MaxStops   int
+	MaxLayover time.Duration
+	Sort       string
+	Limit      int
+}
+
+type ConnectionService struct {
+	db *bun.DB
+}
+
+func NewConnectionService(db *bun.DB) *ConnectionService {
+	return &ConnectionService{db: db}
+}
+
+// SearchConnections finds itineraries from q.From to q.To departing on
+// q.Date with at most q.MaxStops connections. A connection must leave at
+// least the minimum connection time of its airport and at most q.MaxLayover
+// after the previous leg arrived, and has to depart from the airport that leg
+// arrived at. No airport is visited twice and cancelled flights are skipped.
+//
+// Only Pareto optimal itineraries are returned: an itinerary is left out when
+// another one departs no earlier, arrives no later, has no more stops and is
+// no longer, e.g. the same first leg with a later second one.
+func (cs *ConnectionService) SearchConnections(ctx context.Context, q ConnectionQuery) (models.Connections, error) {
+	if q.MaxStops < 0 || q.MaxStops > MaxStops {
+		return models.Connections{}, BadRequestError(fmt.Sprintf("invalid maxStops %d: must be between 0 and %d", q.MaxStops, MaxStops))
+	}
+	if q.MaxLayover <= 0 || q.MaxLayover > MaxLayover {
+		return models.Connections{}, BadRequestError(fmt.Sprintf("invalid maxLayover: must be between 1 and %d minutes", int(MaxLayover.Minutes())))
+	}
+	if q.Sort == "" {
+		q.Sort = ConnectionSortDuration
+	}
+	if !slices.Contains(ConnectionSorts, q.Sort) {
+		return models.Connections{}, UnknownValueError{Param: "sort", Value: q.Sort, Valid: ConnectionSorts}
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultConnectionLimit
+	}
+	q.Limit = min(q.Limit, MaxConnectionLimit)
+
+	origins, err := routeAirports(ctx, cs.db, q.From)
+	if err != nil {
+		return models.Connections{}, err
+	}
+	destinations, err := routeAirports(ctx, cs.db, q.To)
+	if err != nil {
+		return models.Connections{}, err
+	}
+	isDestination := map[string]bool{}
+	for _, a := range destinations {
+		isDestination[a.IATA] = true
+	}
+
+	// The first leg departs on the local date at its origin.
+	windows := make(map[string][2]time.Time, len(origins))
+	var earliest, latest time.Time
+	for _, a := range origins {
+		if isDestination[a.IATA] {
+			return models.Connections{}, BadRequestError("from and to must not share an airport")
+		}
+		loc := time.UTC
+		if a.TimeZone != "" {
+			if loc, err = time.LoadLocation(a.TimeZone); err != nil {
+				return models.Connections{}, fmt.Errorf("invalid time zone of airport %s: %w", a.IATA, err)
+			}
+		}
+		start := time.Date(q.Date.Year(), q.Date.Month(), q.Date.Day(), 0, 0, 0, 0, loc)
+		end := start.AddDate(0, 0, 1)
+		windows[a.IATA] = [2]time.Time{start, end}
+		if earliest.IsZero() || start.Before(earliest) {
+			earliest = start
+		}
+		if end.After(latest) {
+			latest = end
+		}
+	}
+
+	// Every further leg departs within a layover of the previous arrival,
+	// which is at most a day after its departure.
+	horizon := latest.Add(time.Duration(q.MaxStops) * (q.MaxLayover + 24*time.Hour))
+	var flights []models.Flight
+	if err := cs.db.NewSelect().Model(&flights).
+		Where("departure_time >= ?", earliest.UTC()).
+		Where("departure_time < ?", horizon.UTC()).
+		Where("status != ?", models.FlightCancelled).
+		Order("departure_time", "id").
+		Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.Connections{}, fmt.Errorf("failed to load flights: %w", err)
+	}
+
+	airports, err := flightAirports(ctx, cs.db, flights)
+	if err != nil {
+		return models.Connections{}, err
+	}
+
+	itineraries := searchItineraries(flights, airports, windows, isDestination, q.MaxStops, q.MaxLayover)
+
+	sortItineraries(itineraries, q.Sort)
+	res := models.Connections{
+		From:        q.From,
+		To:          q.To,
+		Date:        q.Date.Format(time.DateOnly),
+		Sort:        q.Sort,
+		Total:       len(itineraries),
+		Itineraries: itineraries[:min(len(itineraries), q.Limit)],
+	}
+	if res.Itineraries == nil {
+		res.Itineraries = []models.Itinerary{}
+	}
+	return res, nil
+}
+
+// journey is a partial itinerary found by searchItineraries, ending with the
+// flight it arrives on.
+type journey struct {
+	flight     int      // index of the last leg
+	prev       *journey // journey the last leg connects from, nil for the first leg
+	departure  time.Time
+	arrival    time.Time
+	stops      int
+	distanceKm float64
+	airports   []string // airports visited in order
+}
+
+// dominates reports whether a departs no earlier, arrives no later, has no
+// more stops and is no longer than b.
+func (a *journey) dominates(b *journey) bool {
+	return !a.departure.Before(b.departure) && !a.arrival.After(b.arrival) &&
+		a.stops <= b.stops && a.distanceKm <= b.distanceKm
+}
+
+// supersedes reports whether a dominates b and can be continued wherever b
+// can, since a visited no airport b did not.
+func (a *journey) supersedes(b *journey) bool {
+	return a.dominates(b) && !slices.ContainsFunc(a.airports, func(code string) bool {
+		return !slices.Contains(b.airports, code)
+	})
+}
+
+// legs returns the flights of j from the first leg on.
+func (j *journey) legs(flights []models.Flight) []models.Flight {
+	legs := make([]models.Flight, j.stops+1)
+	for i := j.stops; j != nil; i, j = i-1, j.prev {
+		legs[i] = flights[j.flight]
+	}
+	return legs
+}
+
+// searchItineraries scans flights, ordered by departure, once. The journeys
+// ending with a flight are built from the journeys ending with the flights
+// arriving at its origin within the connection window, which depart and are
+// therefore scanned earlier, and from the flight itself when it departs from
+// an origin within its window. Journeys beaten by another journey on the
+// same flight are dropped right away, as are itineraries beaten by another
+// itinerary at the end.
+func searchItineraries(flights []models.Flight, airports map[string]models.Airport, windows map[string][2]time.Time, isDestination map[string]bool, maxStops int, maxLayover time.Duration) []models.Itinerary {
+	// Flights by the airport they arrive at, ordered by arrival.
+	arrivals := map[string][]int{}
+	for i, f := range flights {
+		arrivals[f.Destination] = append(arrivals[f.Destination], i)
+	}
+	for _, feeders := range arrivals {
+		slices.SortFunc(feeders, func(a, b int) int {
+			return flights[a].ArrivalTime.Compare(flights[b].ArrivalTime)
+		})
+	}
+
+	journeys := make([][]*journey, len(flights))
+	var found []*journey
+	for i, f := range flights {
+		from, to := airports[f.Origin], airports[f.Destination]
+		distance := distanceKm(
+			models.PointCoords{Lat: from.Latitude, Lng: from.Longitude},
+			models.PointCoords{Lat: to.Latitude, Lng: to.Longitude},
+		)
+
+		var candidates []*journey
+		if window, ok := windows[f.Origin]; ok && !f.DepartureTime.Before(window[0]) && f.DepartureTime.Before(window[1]) {
+			candidates = append(candidates, &journey{
+				flight:     i,
+				departure:  f.DepartureTime,
+				arrival:    f.ArrivalTime,
+				distanceKm: distance,
+				airports:   []string{f.Origin, f.Destination},
+			})
+		}
+
+		earliest := f.DepartureTime.Add(-maxLayover)
+		latest := f.DepartureTime.Add(-from.MinConnectionTime())
+		feeders := arrivals[f.Origin]
+		k := sort.Search(len(feeders), func(k int) bool { return !flights[feeders[k]].ArrivalTime.Before(earliest) })
+		for ; k < len(feeders) && !flights[feeders[k]].ArrivalTime.After(latest); k++ {
+			for _, prev := range journeys[feeders[k]] {
+				if prev.stops >= maxStops || slices.Contains(prev.airports, f.Destination) {
+					continue
+				}
+				candidates = append(candidates, &journey{
+					flight:     i,
+					prev:       prev,
+					departure:  prev.departure,
+					arrival:    f.ArrivalTime,
+					stops:      prev.stops + 1,
+					distanceKm: prev.distanceKm + distance,
+					airports:   append(slices.Clip(prev.airports), f.Destination),
+				})
+			}
+		}
+
+		candidates = paretoJourneys(candidates, (*journey).supersedes)
+		if isDestination[f.Destination] {
+			found = append(found, candidates...)
+		} else {
+			journeys[i] = candidates
+		}
+	}
+
+	found = paretoJourneys(found, (*journey).dominates)
+	itineraries := make([]models.Itinerary, len(found))
+	for i, j := range found {
+		itineraries[i] = newItinerary(j.legs(flights), airports)
+	}
+	return itineraries
+}
+
+// paretoJourneys returns the journeys no other journey beats. Of equal
+// journeys the first is kept.
+func paretoJourneys(journeys []*journey, beats func(a, b *journey) bool) []*journey {
+	var front []*journey
+	for _, j := range journeys {
+		if slices.ContainsFunc(front, func(f *journey) bool { return beats(f, j) }) {
+			continue
+		}
+		front = slices.DeleteFunc(front, func(f *journey) bool { return beats(j, f) })
+		front = append(front, j)
+	}
+	return front
+}
+
+// ItineraryAirports loads the airports the legs of itineraries depart from
+// and arrive at.
+func (cs *ConnectionService) ItineraryAirports(ctx context.Context, itineraries []models.Itinerary) (map[string]models.Airport, error) {
+	var legs []models.Flight
+	for _, it := range itineraries {
+		legs = append(legs, it.Legs...)
+	}
+	return flightAirports(ctx, cs.db, legs)
+}
+
+// flightAirports loads the origin and destination airports of flights.
+func flightAirports(ctx context.Context, db bun.IDB, flights []models.Flight) (map[string]models.Airport, error) {
+	codes := make([]string, 0, 2*len(flights))
+	for _, f := range flights {
+		codes = append(codes, f.Origin, f.Destination)
+	}
+	return airportsByCode(ctx, db, codes)
+}
+
+// airportsByCode loads the airports with the given IATA codes. Empty and
+// duplicate codes are ignored, unknown ones are missing from the result.
+func airportsByCode(ctx context.Context, db bun.IDB, codes []string) (map[string]models.Airport, error) {
+	res := map[string]models.Airport{}
+	iatas := slices.DeleteFunc(slices.Compact(slices.Sorted(slices.Values(codes))), func(code string) bool { return code == "" })
+	if len(iatas) == 0 {
+		return res, nil
+	}
+
+	var airports []models.Airport
+	if err := db.NewSelect().Model(&airports).Where("iata IN (?)", bun.In(iatas)).Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load airports: %w", err)
+	}
+	for _, a := range airports {
+		res[a.IATA] = a
+	}
+	return res, nil
+}
+
+func newItinerary(legs []models.Flight, airports map[string]models.Airport) models.Itinerary {
+	first, last := legs[0], legs[len(legs)-1]
+	it := models.Itinerary{
+		Departure:       first.Origin,
+		Destination:     last.Destination,
+		DepartureTime:   first.DepartureTime,
+		ArrivalTime:     last.ArrivalTime,
+		DurationMinutes: int(last.ArrivalTime.Sub(first.DepartureTime).Minutes()),
+		Stops:           len(legs) - 1,
+		Legs:            slices.Clone(legs),
+		Layovers:        []models.Layover{},
+	}
+	for i, leg := range legs {
+		from, to := airports[leg.Origin], airports[leg.Destination]
+		it.DistanceKm += distanceKm(
+			models.PointCoords{Lat: from.Latitude, Lng: from.Longitude},
+			models.PointCoords{Lat: to.Latitude, Lng: to.Longitude},
+		)
+		if i > 0 {
+			it.Layovers = append(it.Layovers, models.Layover{
+				Airport: leg.Origin,
+				Minutes: int(leg.DepartureTime.Sub(legs[i-1].ArrivalTime).Minutes()),
+			})
+		}
+	}
+	return it
+}
+
+// sortItineraries orders itineraries by the given sort, ties are broken by
+// the earlier arrival and then the earlier departure.
+func sortItineraries(itineraries []models.Itinerary, by string) {
+	slices.SortStableFunc(itineraries, func(a, b models.Itinerary) int {
+		var c int
+		switch by {
+		case ConnectionSortDistance:
+			c = cmp.Compare(a.DistanceKm, b.DistanceKm)
+		case ConnectionSortStops:
+			c = cmp.Or(cmp.Compare(a.Stops, b.Stops), cmp.Compare(a.DurationMinutes, b.DurationMinutes))
+		default:
+			c = cmp.Compare(a.DurationMinutes, b.DurationMinutes)
+		}
+		return cmp.Or(c, a.ArrivalTime.Compare(b.ArrivalTime), a.DepartureTime.Compare(b.DepartureTime))
+	})
+}
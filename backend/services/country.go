@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+type CountryService struct {
+	db *bun.DB
+}
+
+func NewCountryService(db *bun.DB) *CountryService {
+	return &CountryService{db: db}
+}
+
+// ListCountries returns all countries with the number of airports in each,
+// optionally narrowed down to a continent (code or name) and UN M49 region.
+func (cs *CountryService) ListCountries(ctx context.Context, continent, region string) ([]models.Country, error) {
+	countries := []models.Country{}
+	query := cs.db.NewSelect().Model(&countries).
+		ColumnExpr("country.*").
+		ColumnExpr("(SELECT count(*) FROM airports AS a WHERE a.country_code = country.alpha2) AS airport_count").
+		Order("alpha2")
+
+	if continent != "" {
+		query.Where("continent_code IN (?)", continentCodeQuery(cs.db, continent))
+	}
+	if region != "" {
+		query.Where("lower(region) = lower(?)", region)
+	}
+
+	if err := query.Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to list countries: %w", err)
+	}
+
+	return countries, nil
+}
+
+// ListContinents returns all continents with the number of airports on each.
+func (cs *CountryService) ListContinents(ctx context.Context) ([]models.Continent, error) {
+	continents := []models.Continent{}
+	err := cs.db.NewSelect().Model(&continents).
+		ColumnExpr("continent.*").
+		ColumnExpr("(SELECT count(*) FROM airports AS a WHERE a.continent_code = continent.code) AS airport_count").
+		Order("code").
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to list continents: %w", err)
+	}
+
+	return continents, nil
+}
+
+// ResolveCountries maps country names and ISO alpha-2 or alpha-3 codes to
+// alpha-2 codes. Unknown countries are reported as NotFoundError.
+func (cs *CountryService) ResolveCountries(ctx context.Context, countries []string) ([]string, error) {
+	return resolveCountries(ctx, cs.db, countries)
+}
+
+func resolveCountries(ctx context.Context, db bun.IDB, countries []string) ([]string, error) {
+	codes := make([]string, 0, len(countries))
+	for _, c := range countries {
+		var code string
+		err := db.NewSelect().Model((*models.Country)(nil)).Column("alpha2").
+			Where("alpha2 IN (?)", countryCodeQuery(db, c)).
+			Scan(ctx, &code)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, NotFoundError(fmt.Sprintf("country not found: %s", c))
+			}
+			return nil, fmt.Errorf("failed to find country: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// countryCodeQuery selects the alpha-2 code of the country identified by its
+// alpha-2 code, alpha-3 code or short or official name.
+func countryCodeQuery(db bun.IDB, country string) *bun.SelectQuery {
+	country = strings.TrimSpace(country)
+	return db.NewSelect().Model((*models.Country)(nil)).Column("alpha2").
+		WhereOr("alpha2 = ?", strings.ToUpper(country)).
+		WhereOr("alpha3 = ?", strings.ToUpper(country)).
+		WhereOr("lower(name) = ?", strings.ToLower(country)).
+		WhereOr("lower(official_name) = ?", strings.ToLower(country))
+}
+
+// continentCodeQuery selects the code of the continent identified by its code or name.
+func continentCodeQuery(db bun.IDB, continent string) *bun.SelectQuery {
+	continent = strings.TrimSpace(continent)
+	return db.NewSelect().Model((*models.Continent)(nil)).Column("code").
+		WhereOr("code = ?", strings.ToUpper(continent)).
+		WhereOr("lower(name) = ?", strings.ToLower(continent))
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+)
+
+// DefaultDelayHorizon is how long after the delayed flight departs a delay
+// scenario is followed when it does not set a horizon.
+const DefaultDelayHorizon = 24 * time.Hour
+
+// projection is the expected departure and arrival of a flight in a delay
+// simulation, and why it departs when it does.
+type projection struct {
+	departure time.Time
+	arrival   time.Time
+	cause     models.DelayCause
+	causedBy  int64
+	depth     int
+}
+
+// delayNetwork holds the flights a delay can propagate through and the links
+// between them.
+type delayNetwork struct {
+	flights    []models.Flight            // ordered by scheduled departure
+	arrivals   map[string][]models.Flight // by destination, ordered by scheduled arrival
+	airports   map[string]models.Airport
+	turnaround time.Duration
+	maxHold    time.Duration
+}
+
+// SimulateDelay projects how the delay of a scenario propagates to the flights
+// departing within its horizon, without changing any flight. A flight departs
+// no earlier than its airframe arrived from the previous flight plus the
+// minimum turnaround. Connections are the flights leaving the arrival airport
+// of another flight within DefaultMaxLayover, at least the minimum connection
+// time of the airport later. They carry passengers and crew alike; a flight is
+// held for a late connection for up to the maximum hold of the scenario,
+// otherwise the connection is missed. Without a maximum hold flights are never
+// held and delays only propagate through rotations.
+//
+// The flights are simulated with and without the injected delay, starting
+// from their current estimates, and only the differences are reported.
+func (fs *FleetService) SimulateDelay(ctx context.Context, scenario models.DelayScenario) (models.DelaySimulation, error) {
+	var delayed models.Flight
+	if err := fs.db.NewSelect().Model(&delayed).Where("id = ?", scenario.FlightId).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.DelaySimulation{}, NotFoundError(fmt.Sprintf("flight not found: %d", scenario.FlightId))
+		}
+		return models.DelaySimulation{}, fmt.Errorf("failed to load flight: %w", err)
+	}
+	switch {
+	case delayed.Status == models.FlightCancelled:
+		return models.DelaySimulation{}, ConflictError(fmt.Sprintf("flight %d is cancelled", delayed.Id))
+	case delayed.ActualDepartureTime != nil:
+		return models.DelaySimulation{}, ConflictError(fmt.Sprintf("flight %d has already departed", delayed.Id))
+	}
+
+	network := delayNetwork{turnaround: MinTurnaround, maxHold: time.Duration(scenario.MaxHoldMinutes) * time.Minute}
+	if scenario.MinTurnaroundMinutes > 0 {
+		network.turnaround = time.Duration(scenario.MinTurnaroundMinutes) * time.Minute
+	}
+	horizon := DefaultDelayHorizon
+	if scenario.HorizonHours > 0 {
+		horizon = time.Duration(scenario.HorizonHours) * time.Hour
+	}
+
+	injected := delayed.DepartureTime.Add(time.Duration(scenario.DelayMinutes) * time.Minute)
+	if current, _ := flightTimes(delayed); current.After(injected) {
+		injected = current
+	}
+	from, to := injected, injected.Add(horizon)
+
+	// Flights departing up to a day earlier are read as well, they may be the
+	// previous flight of an airframe or a connection of a flight in the
+	// horizon.
+	if err := fs.db.NewSelect().Model(&network.flights).
+		Where("status != ?", models.FlightCancelled).
+		Where("departure_time >= ?", delayed.DepartureTime.Add(-24*time.Hour).UTC()).
+		Where("departure_time < ?", to.UTC()).
+		Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.DelaySimulation{}, fmt.Errorf("failed to load flights: %w", err)
+	}
+	slices.SortFunc(network.flights, func(a, b models.Flight) int {
+		return cmp.Or(a.DepartureTime.Compare(b.DepartureTime), cmp.Compare(a.Id, b.Id))
+	})
+	network.arrivals = map[string][]models.Flight{}
+	for _, f := range network.flights {
+		network.arrivals[f.Destination] = append(network.arrivals[f.Destination], f)
+	}
+	for _, arrivals := range network.arrivals {
+		slices.SortStableFunc(arrivals, func(a, b models.Flight) int {
+			return a.ArrivalTime.Compare(b.ArrivalTime)
+		})
+	}
+
+	var err error
+	if network.airports, err = flightAirports(ctx, fs.db, network.flights); err != nil {
+		return models.DelaySimulation{}, err
+	}
+
+	baseline, baselineMissed := network.propagate(0, time.Time{})
+	projected, missed := network.propagate(delayed.Id, injected)
+
+	res := models.DelaySimulation{
+		Scenario:          scenario,
+		From:              from,
+		To:                to,
+		Flights:           []models.DelayedFlight{},
+		MissedConnections: []models.MissedConnection{},
+	}
+	for _, f := range network.flights {
+		p, b := projected[f.Id], baseline[f.Id]
+		if !p.departure.After(b.departure) {
+			continue
+		}
+		d := models.DelayedFlight{
+			FlightId:           f.Id,
+			FlightNumber:       f.FlightNumber,
+			Origin:             f.Origin,
+			Destination:        f.Destination,
+			AirframeId:         f.AirframeId,
+			ScheduledDeparture: f.DepartureTime,
+			ProjectedDeparture: p.departure,
+			ProjectedArrival:   p.arrival,
+			DelayMinutes:       int(p.departure.Sub(f.DepartureTime).Minutes()),
+			AddedDelayMinutes:  int(p.departure.Sub(b.departure).Minutes()),
+			Cause:              p.cause,
+			CausedBy:           p.causedBy,
+			Depth:              p.depth,
+		}
+		res.Flights = append(res.Flights, d)
+		res.TotalAddedDelayMinutes += d.AddedDelayMinutes
+	}
+	slices.SortStableFunc(res.Flights, func(a, b models.DelayedFlight) int {
+		return a.ProjectedDeparture.Compare(b.ProjectedDeparture)
+	})
+
+	for _, m := range missed {
+		if !slices.ContainsFunc(baselineMissed, func(b models.MissedConnection) bool {
+			return b.ArrivingFlightId == m.ArrivingFlightId && b.DepartingFlightId == m.DepartingFlightId
+		}) {
+			res.MissedConnections = append(res.MissedConnections, m)
+		}
+	}
+
+	return res, nil
+}
+
+// propagate projects the flights of the network in order of their scheduled
+// departure, so that the previous flight of an airframe and the connections
+// of a flight are always projected before it. The flight with id delayedId is
+// made to depart at injected, a zero id projects the current estimates.
+// Flights that already departed keep their times.
+func (n *delayNetwork) propagate(delayedId int64, injected time.Time) (map[int64]projection, []models.MissedConnection) {
+	projections := make(map[int64]projection, len(n.flights))
+	previous := map[int]models.Flight{} // latest arriving flight by airframe
+
+	// Connections are missed when the departing flight leaves before
+	// required, which is only known once all its connections are projected.
+	type connection struct {
+		models.MissedConnection
+		required time.Time
+	}
+	var connections []connection
+
+	for _, f := range n.flights {
+		departure, arrival := flightTimes(f)
+		block := f.ArrivalTime.Sub(f.DepartureTime)
+		p := projection{departure: departure}
+
+		if f.ActualDepartureTime == nil {
+			if f.Id == delayedId && injected.After(p.departure) {
+				p.departure, p.cause = injected, models.DelayInjected
+			}
+
+			if prev, ok := previous[f.AirframeId]; ok && f.AirframeId != 0 {
+				pp := projections[prev.Id]
+				if ready := pp.arrival.Add(n.turnaround); ready.After(p.departure) {
+					p.departure, p.cause, p.causedBy, p.depth = ready, models.DelayRotation, prev.Id, pp.depth+1
+				}
+			}
+
+			mct := models.DefaultMinConnectionMinutes * time.Minute
+			if a, ok := n.airports[f.Origin]; ok {
+				mct = a.MinConnectionTime()
+			}
+			for _, g := range n.connecting(f, mct) {
+				if !connects(g, f, mct) {
+					continue
+				}
+				gp := projections[g.Id]
+				required := gp.arrival.Add(mct)
+				if !required.After(p.departure) {
+					continue
+				}
+				if required.Sub(f.DepartureTime) <= n.maxHold {
+					p.departure, p.cause, p.causedBy, p.depth = required, models.DelayConnection, g.Id, gp.depth+1
+					continue
+				}
+				connections = append(connections, connection{
+					MissedConnection: models.MissedConnection{
+						Airport:               f.Origin,
+						ArrivingFlightId:      g.Id,
+						ArrivingFlightNumber:  g.FlightNumber,
+						DepartingFlightId:     f.Id,
+						DepartingFlightNumber: f.FlightNumber,
+						ProjectedArrival:      gp.arrival,
+					},
+					required: required,
+				})
+			}
+		}
+
+		p.arrival = arrival
+		if f.ActualArrivalTime == nil {
+			p.arrival = p.departure.Add(block)
+		}
+		projections[f.Id] = p
+
+		if f.AirframeId != 0 {
+			if prev, ok := previous[f.AirframeId]; !ok || p.arrival.After(projections[prev.Id].arrival) {
+				previous[f.AirframeId] = f
+			}
+		}
+	}
+
+	var missed []models.MissedConnection
+	for _, c := range connections {
+		departure := projections[c.DepartingFlightId].departure
+		if !c.required.After(departure) {
+			// The flight was held for a later connection after all.
+			continue
+		}
+		c.ProjectedDeparture = departure
+		c.ShortMinutes = int(c.required.Sub(departure).Minutes())
+		missed = append(missed, c.MissedConnection)
+	}
+
+	return projections, missed
+}
+
+// connecting returns the flights scheduled to arrive at the airport f departs
+// from at least mct and at most DefaultMaxLayover before f, ordered by their
+// arrival.
+func (n *delayNetwork) connecting(f models.Flight, mct time.Duration) []models.Flight {
+	arrivals := n.arrivals[f.Origin]
+	earliest, latest := f.DepartureTime.Add(-DefaultMaxLayover), f.DepartureTime.Add(-mct)
+	from := sort.Search(len(arrivals), func(i int) bool { return !arrivals[i].ArrivalTime.Before(earliest) })
+	to := sort.Search(len(arrivals), func(i int) bool { return arrivals[i].ArrivalTime.After(latest) })
+	return arrivals[from:max(from, to)]
+}
+
+// connects reports whether passengers or crew of g can connect to f: g is
+// scheduled to arrive at the airport f departs from at least mct and at most
+// DefaultMaxLayover before f. Flights of the same airframe are a rotation
+// rather than a connection.
+func connects(g, f models.Flight, mct time.Duration) bool {
+	if g.Id == f.Id || g.Destination != f.Origin || (f.AirframeId != 0 && g.AirframeId == f.AirframeId) {
+		return false
+	}
+	layover := f.DepartureTime.Sub(g.ArrivalTime)
+	return layover >= mct && layover <= DefaultMaxLayover
+}
@@ -2,11 +2,9 @@ package services
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 	"fmt"
 	"math"
-	"net/http"
+	"sort"
 
 	"github.com/leanderkunstmann/terraroute/backend/models"
 	"github.com/uptrace/bun"
@@ -29,53 +27,80 @@ const (
 	nauticalMilesPerKm = 0.539957
 )
 
-type NotFoundError string
-
-func (e NotFoundError) Error() string {
-	return string(e)
-}
-
-func (e NotFoundError) Code() int {
-	return http.StatusNotFound
-}
-
+// CalculateDistance calculates the route between departure and destination.
+// Either may be a metropolitan area code, in which case every combination of
+// their airports is considered and the closest pair is returned.
 func (dc *DistanceCalculator) CalculateDistance(ctx context.Context, req *models.DistanceRequest) (models.DistanceData, error) {
-	var departureAirport, destinationAirport models.Airport
-	if err := dc.db.NewSelect().Model(&departureAirport).Where("iata = ?", req.Departure).Scan(ctx); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return models.DistanceData{}, NotFoundError(fmt.Sprintf("departure airport not found: %s", req.Departure))
+	departures, err := routeAirports(ctx, dc.db, req.Departure)
+	if err != nil {
+		return models.DistanceData{}, fmt.Errorf("failed to find departure: %w", err)
+	}
+	destinations, err := routeAirports(ctx, dc.db, req.Destination)
+	if err != nil {
+		return models.DistanceData{}, fmt.Errorf("failed to find destination: %w", err)
+	}
+
+	if len(req.Borders) != 0 {
+		borders, err := resolveCountries(ctx, dc.db, req.Borders)
+		if err != nil {
+			return models.DistanceData{}, fmt.Errorf("failed to resolve borders: %w", err)
 		}
-		return models.DistanceData{}, fmt.Errorf("failed to find departure airport: %w", err)
+		req.Borders = borders
 	}
-	if err := dc.db.NewSelect().Model(&destinationAirport).Where("iata = ?", req.Destination).Scan(ctx); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return models.DistanceData{}, NotFoundError(fmt.Sprintf("destination airport not found: %s", req.Destination))
+
+	var pairs []models.AirportPair
+	for _, dep := range departures {
+		for _, dest := range destinations {
+			// Airports shared by both metro areas do not form a route.
+			if dep.IATA == dest.IATA && (len(departures) > 1 || len(destinations) > 1) {
+				continue
+			}
+			pairs = append(pairs, dc.calculatePair(dep, dest, req.Borders))
 		}
-		return models.DistanceData{}, fmt.Errorf("failed to find destination airport: %w", err)
 	}
+	if len(pairs) == 0 {
+		return models.DistanceData{}, BadRequestError("departure and destination have no distinct airports")
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Distances["km"] < pairs[j].Distances["km"]
+	})
+
+	closest := pairs[0]
+	data := models.DistanceData{
+		Route:       req,
+		Departure:   closest.Departure,
+		Destination: closest.Destination,
+		Distances:   closest.Distances,
+		Path:        closest.Path,
+		Midpoint:    closest.Midpoint,
+	}
+	if req.Pairs == models.PairsAll {
+		data.Pairs = pairs
+	}
+	return data, nil
+}
+
+// calculatePair calculates the route between two airports.
+func (dc *DistanceCalculator) calculatePair(departure, destination models.Airport, borders []string) models.AirportPair {
+	from := models.PointCoords{Lat: departure.Latitude, Lng: departure.Longitude}
+	to := models.PointCoords{Lat: destination.Latitude, Lng: destination.Longitude}
 
 	var distances map[string]float64
 	var path []models.PointCoords
-
-	if len(req.Borders) != 0 {
-		var path []models.PointCoords
-		distances, path = dc.calculateAdjustedDistance(models.PointCoords{Lat: departureAirport.Latitude, Lng: departureAirport.Longitude}, models.PointCoords{Lat: destinationAirport.Latitude, Lng: destinationAirport.Longitude}, req.Borders)
-		return models.DistanceData{
-			Route:     req,
-			Distances: distances,
-			Path:      path,
-			Midpoint:  dc.calculateMidPoint(path),
-		}, nil
+	if len(borders) != 0 {
+		distances, path = dc.calculateAdjustedDistance(from, to, borders)
+	} else {
+		distances = dc.calculateDirectDistance(from, to)
+		path = []models.PointCoords{from, to}
 	}
 
-	distances = dc.calculateDirectDistance(models.PointCoords{Lat: departureAirport.Latitude, Lng: departureAirport.Longitude}, models.PointCoords{Lat: destinationAirport.Latitude, Lng: destinationAirport.Longitude})
-	path = []models.PointCoords{{Lat: departureAirport.Latitude, Lng: departureAirport.Longitude}, {Lat: destinationAirport.Latitude, Lng: destinationAirport.Longitude}}
-	return models.DistanceData{
-		Route:     req,
-		Distances: distances,
-		Path:      path,
-		Midpoint:  dc.calculateMidPoint(path),
-	}, nil
+	return models.AirportPair{
+		Departure:   departure.IATA,
+		Destination: destination.IATA,
+		Distances:   distances,
+		Path:        path,
+		Midpoint:    dc.calculateMidPoint(path),
+	}
 }
 
 func (dc *DistanceCalculator) calculateAdjustedDistance(departure, destination models.PointCoords, borders []string) (map[string]float64, []models.PointCoords) {
@@ -100,6 +125,12 @@ func (dc *DistanceCalculator) calculateAdjustedDistance(departure, destination m
 }
 
 func (dc *DistanceCalculator) calculateDirectDistance(departure, destination models.PointCoords) map[string]float64 {
+	return dc.calculateDistanceValues(centralAngle(departure, destination))
+}
+
+// centralAngle returns the angle in radians between two points on the sphere
+// using the haversine formula.
+func centralAngle(departure, destination models.PointCoords) float64 {
 	// Convert latitude and longitude to radians
 	lat1Rad := departure.Lat * math.Pi / 180
 	lon1Rad := departure.Lng * math.Pi / 180
@@ -110,9 +141,12 @@ func (dc *DistanceCalculator) calculateDirectDistance(departure, destination mod
 	dlat := lat2Rad - lat1Rad
 	dlon := lon2Rad - lon1Rad
 	a := math.Sin(dlat/2)*math.Sin(dlat/2) + math.Cos(lat1Rad)*math.Cos(lat2Rad)*math.Sin(dlon/2)*math.Sin(dlon/2)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+	return 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
 
-	return dc.calculateDistanceValues(c)
+// distanceKm returns the great-circle distance between two points in kilometers.
+func distanceKm(departure, destination models.PointCoords) float64 {
+	return earthRadiusKm * centralAngle(departure, destination)
 }
 
 func (dc *DistanceCalculator) calculateMidPoint(coords []models.PointCoords) models.PointCoords {
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// MaxReserveFactor is the largest reserve factor accepted by EligibleAircraft.
+	MaxReserveFactor = 1
+	// MaxTechnicalStops is the largest number of technical stops
+	// EligibleAircraft plans for aircraft unable to fly a route nonstop.
+	MaxTechnicalStops = 3
+)
+
+// EligibilityService answers which aircraft of the fleet can fly a route.
+type EligibilityService struct {
+	aircraft *AircraftService
+	distance *DistanceCalculator
+}
+
+func NewEligibilityService(db *bun.DB) *EligibilityService {
+	return &EligibilityService{
+		aircraft: NewAircraftService(db),
+		distance: NewDistanceCalculator(db),
+	}
+}
+
+// EligibilityOptions are the requirements of a route beyond its distance.
+type EligibilityOptions struct {
+	Reserve   float64 // fraction of the distance added as reserve, 0.1 adds 10%
+	PayloadKg float64 // payload to carry, 0 uses the nominal range of the aircraft
+	MaxStops  int     // technical stops allowed for aircraft without the range to fly nonstop
+}
+
+// EligibleAircraft lists every aircraft matching filter whose range covers
+// the great-circle distance between departure and destination plus reserve.
+// With a payload the range at that payload is read from the payload-range
+// diagram, and aircraft without a diagram are left out. Departure and
+// destination may be metropolitan areas, the closest airport pair is used then.
+//
+// With opts.MaxStops, aircraft that cannot fly the route nonstop are planned
+// with the fewest technical stops at known airports, each leg plus reserve
+// within the range at the payload. Among the plans with the fewest stops the
+// one with the shortest total distance is chosen.
+func (es *EligibilityService) EligibleAircraft(ctx context.Context, departure, destination string, opts EligibilityOptions, filter AircraftFilter) (models.RouteAircraft, error) {
+	if opts.Reserve < 0 || opts.Reserve > MaxReserveFactor || math.IsNaN(opts.Reserve) {
+		return models.RouteAircraft{}, BadRequestError(fmt.Sprintf("reserve factor must be between 0 and %d", MaxReserveFactor))
+	}
+	if opts.PayloadKg < 0 || math.IsNaN(opts.PayloadKg) {
+		return models.RouteAircraft{}, BadRequestError("payload must not be negative")
+	}
+	if opts.MaxStops < 0 || opts.MaxStops > MaxTechnicalStops {
+		return models.RouteAircraft{}, BadRequestError(fmt.Sprintf("maxStops must be between 0 and %d", MaxTechnicalStops))
+	}
+
+	route, err := es.distance.CalculateDistance(ctx, &models.DistanceRequest{Departure: departure, Destination: destination})
+	if err != nil {
+		return models.RouteAircraft{}, err
+	}
+
+	res := models.RouteAircraft{
+		Departure:     route.Departure,
+		Destination:   route.Destination,
+		DistanceNm:    route.Distances["nm"],
+		ReserveFactor: opts.Reserve,
+		PayloadKg:     opts.PayloadKg,
+		MaxStops:      opts.MaxStops,
+		Aircraft:      []models.EligibleAircraft{},
+	}
+	res.RequiredRangeNm = res.DistanceNm * (1 + opts.Reserve)
+
+	// The nominal range says nothing about the range at a given payload, so
+	// the database can only narrow down the candidates without a payload.
+	// Aircraft with too little range may still fly the route with stops.
+	if opts.PayloadKg == 0 && opts.MaxStops == 0 {
+		filter.MinRangeNm = max(filter.MinRangeNm, int(math.Ceil(res.RequiredRangeNm)))
+	}
+
+	var candidates []models.Aircraft
+	listOpts := ListOptions{Limit: MaxPageLimit}
+	for {
+		page, err := es.aircraft.ListAircraft(ctx, filter, listOpts)
+		if err != nil {
+			return models.RouteAircraft{}, err
+		}
+		candidates = append(candidates, page.Data...)
+		if page.NextCursor == "" {
+			break
+		}
+		listOpts.Cursor = page.NextCursor
+	}
+
+	var envelopes map[int]models.PayloadRange
+	if opts.PayloadKg > 0 {
+		ids := make([]int, len(candidates))
+		for i, a := range candidates {
+			ids[i] = a.Id
+		}
+		if envelopes, err = payloadRanges(ctx, es.aircraft.db, ids); err != nil {
+			return models.RouteAircraft{}, err
+		}
+	}
+
+	var planner *stopPlanner
+	for _, a := range candidates {
+		eligible := models.EligibleAircraft{Aircraft: a}
+		usable := float64(a.RangeNm)
+		if opts.PayloadKg > 0 {
+			pr, ok := envelopes[a.Id]
+			if !ok {
+				continue
+			}
+			if usable, ok = pr.MaxRangeNm(opts.PayloadKg); !ok {
+				continue
+			}
+			eligible.RangeAtPayloadNm = usable
+		}
+
+		required := res.RequiredRangeNm
+		if usable < required {
+			if opts.MaxStops == 0 {
+				continue
+			}
+			if planner == nil {
+				if planner, err = es.newStopPlanner(ctx, res.Departure, res.Destination, opts.MaxStops); err != nil {
+					return models.RouteAircraft{}, err
+				}
+			}
+			stops, longestNm, ok := planner.plan(usable / (1 + opts.Reserve))
+			if !ok {
+				continue
+			}
+			eligible.Stops, eligible.LongestLegNm = stops, longestNm
+			required = longestNm * (1 + opts.Reserve)
+		}
+		if opts.PayloadKg > 0 {
+			eligible.MaxPayloadKg, _ = envelopes[a.Id].MaxPayloadKg(required)
+		}
+		eligible.MarginNm = usable - required
+		eligible.MarginPercent = 100 * eligible.MarginNm / usable
+		res.Aircraft = append(res.Aircraft, eligible)
+	}
+
+	// The best fit for a route is the aircraft with the fewest stops and the
+	// least range to spare.
+	sort.SliceStable(res.Aircraft, func(i, j int) bool {
+		a, b := res.Aircraft[i], res.Aircraft[j]
+		if len(a.Stops) != len(b.Stops) {
+			return len(a.Stops) < len(b.Stops)
+		}
+		return a.MarginNm < b.MarginNm
+	})
+
+	return res, nil
+}
+
+// stopPlanner plans technical stops between two airports.
+type stopPlanner struct {
+	departure, destination models.Airport
+	airports               []models.Airport // possible stops
+	maxStops               int
+}
+
+// newStopPlanner loads the airports that can be a stop on the way from
+// departure to destination. Stops are only planned for legs shorter than the
+// route, so a stop is less than maxStops times the route distance away from
+// the departure.
+func (es *EligibilityService) newStopPlanner(ctx context.Context, departure, destination string, maxStops int) (*stopPlanner, error) {
+	var ends []models.Airport
+	if err := es.aircraft.db.NewSelect().Model(&ends).Where("iata IN (?)", bun.In([]string{departure, destination})).Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to load route airports: %w", err)
+	}
+	p := &stopPlanner{maxStops: maxStops}
+	for _, a := range ends {
+		if a.IATA == departure {
+			p.departure = a
+		}
+		if a.IATA == destination {
+			p.destination = a
+		}
+	}
+
+	from := models.PointCoords{Lat: p.departure.Latitude, Lng: p.departure.Longitude}
+	to := models.PointCoords{Lat: p.destination.Latitude, Lng: p.destination.Longitude}
+	bbox, global := bboxAround(from, float64(maxStops)*distanceKm(from, to))
+	query := es.aircraft.db.NewSelect().Model(&p.airports).Where("iata NOT IN (?)", bun.In([]string{departure, destination}))
+	if !global {
+		whereInBBox(query, &bbox)
+	}
+	if err := query.Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load airports: %w", err)
+	}
+	return p, nil
+}
+
+// plan returns the fewest technical stops needed to fly the route with legs
+// of at most legNm, and the longest leg of that plan. Of the plans with the
+// fewest stops the one with the shortest total distance is returned.
+func (p *stopPlanner) plan(legNm float64) (stops []string, longestNm float64, ok bool) {
+	point := func(a models.Airport) models.PointCoords {
+		return models.PointCoords{Lat: a.Latitude, Lng: a.Longitude}
+	}
+	legs := func(a, b models.Airport) float64 {
+		return distanceKm(point(a), point(b)) * nauticalMilesPerKm
+	}
+
+	// reach[i] is the shortest distance to airport i with the current
+	// number of legs, prev the airport each leg started from.
+	n := len(p.airports)
+	reach := make([]float64, n)
+	prev := make([][]int, p.maxStops)
+	for i, a := range p.airports {
+		reach[i] = math.Inf(1)
+		if d := legs(p.departure, a); d <= legNm {
+			reach[i] = d
+		}
+	}
+	prev[0] = make([]int, n)
+	for i := range prev[0] {
+		prev[0][i] = -1
+	}
+
+	for k := 0; k < p.maxStops; k++ {
+		best, last := math.Inf(1), -1
+		for i, a := range p.airports {
+			if math.IsInf(reach[i], 1) {
+				continue
+			}
+			if d := legs(a, p.destination); d <= legNm && reach[i]+d < best {
+				best, last = reach[i]+d, i
+			}
+		}
+		if last >= 0 {
+			// Walk back from the last stop, collecting the legs.
+			stops = make([]string, k+1)
+			longestNm = legs(p.airports[last], p.destination)
+			for j, i := k, last; j >= 0; j, i = j-1, prev[j][i] {
+				stops[j] = p.airports[i].IATA
+				from := p.departure
+				if j > 0 {
+					from = p.airports[prev[j][i]]
+				}
+				longestNm = max(longestNm, legs(from, p.airports[i]))
+			}
+			return stops, longestNm, true
+		}
+		if k+1 == p.maxStops {
+			break
+		}
+
+		next := make([]float64, n)
+		prev[k+1] = make([]int, n)
+		for j, b := range p.airports {
+			next[j], prev[k+1][j] = math.Inf(1), -1
+			for i, a := range p.airports {
+				if i == j || math.IsInf(reach[i], 1) {
+					continue
+				}
+				if d := legs(a, b); d <= legNm && reach[i]+d < next[j] {
+					next[j], prev[k+1][j] = reach[i]+d, i
+				}
+			}
+		}
+		reach = next
+	}
+	return nil, 0, false
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+)
+
+type NotFoundError string
+
+func (e NotFoundError) Error() string {
+	return string(e)
+}
+
+func (e NotFoundError) Code() int {
+	return http.StatusNotFound
+}
+
+type BadRequestError string
+
+func (e BadRequestError) Error() string {
+	return string(e)
+}
+
+func (e BadRequestError) Code() int {
+	return http.StatusBadRequest
+}
+
+type ConflictError string
+
+func (e ConflictError) Error() string {
+	return string(e)
+}
+
+func (e ConflictError) Code() int {
+	return http.StatusConflict
+}
+
+// NotAcceptableError rejects a request none of whose acceptable media types
+// can be served.
+type NotAcceptableError string
+
+func (e NotAcceptableError) Error() string {
+	return string(e)
+}
+
+func (e NotAcceptableError) Code() int {
+	return http.StatusNotAcceptable
+}
+
+// UnavailableError rejects a request the service has no capacity for right
+// now. It can be retried later.
+type UnavailableError string
+
+func (e UnavailableError) Error() string {
+	return string(e)
+}
+
+func (e UnavailableError) Code() int {
+	return http.StatusServiceUnavailable
+}
+
+// UnknownValueError rejects a query parameter value that is not one of the
+// valid options. The options are listed in the response details.
+type UnknownValueError struct {
+	Param string
+	Value string
+	Valid []string
+}
+
+func (e UnknownValueError) Error() string {
+	return fmt.Sprintf("unknown %s %q, valid values are: %s", e.Param, e.Value, strings.Join(e.Valid, ", "))
+}
+
+func (e UnknownValueError) Code() int {
+	return http.StatusBadRequest
+}
+
+func (e UnknownValueError) Details() any {
+	return map[string]any{"param": e.Param, "valid": e.Valid}
+}
+
+// RotationConflictError rejects a flight its airframe cannot operate between
+// its other flights. The conflicts are listed in the response details.
+type RotationConflictError []models.FleetConflict
+
+func (e RotationConflictError) Error() string {
+	msgs := make([]string, len(e))
+	for i, c := range e {
+		msgs[i] = c.Message
+	}
+	return "flight conflicts with the rotation of its airframe: " + strings.Join(msgs, "; ")
+}
+
+func (e RotationConflictError) Code() int {
+	return http.StatusConflict
+}
+
+func (e RotationConflictError) Details() any {
+	return []models.FleetConflict(e)
+}
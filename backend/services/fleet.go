@@ -0,0 +1,199 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+// MinTurnaround is the shortest time an airframe needs on the ground between
+// two flights.
+const MinTurnaround = 30 * time.Minute
+
+type FleetService struct {
+	db *bun.DB
+
+	mu            sync.Mutex
+	jobs          map[string]*models.RotationJob
+	maxActiveJobs int
+}
+
+func NewFleetService(db *bun.DB) *FleetService {
+	return &FleetService{db: db, jobs: map[string]*models.RotationJob{}, maxActiveJobs: DefaultMaxActiveRotationJobs}
+}
+
+// SetMaxActiveRotationJobs sets the number of rotation jobs that can be
+// pending or running at the same time, zero disables rotation jobs.
+func (fs *FleetService) SetMaxActiveRotationJobs(n int) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.maxActiveJobs = n
+}
+
+// FleetConflictFilter narrows down the conflicts returned by Conflicts to an
+// airframe and to flights departing from From until before To.
+type FleetConflictFilter struct {
+	AirframeId int
+	From       time.Time
+	To         time.Time
+}
+
+// Conflicts reports the flights their airframes cannot operate: flights
+// departing before the previous flight of the airframe arrived, from another
+// airport than it arrived at or within less than MinTurnaround. Aircraft ids
+// of flights are aircraft types, so rotations are checked per airframe and
+// flights without an airframe are never in conflict. Cancelled flights are
+// ignored.
+func (fs *FleetService) Conflicts(ctx context.Context, filter FleetConflictFilter) (models.FleetConflicts, error) {
+	var flights []models.Flight
+	query := fs.db.NewSelect().Model(&flights).
+		Where("airframe_id IS NOT NULL").
+		Where("status != ?", models.FlightCancelled)
+	if filter.AirframeId > 0 {
+		query.Where("airframe_id = ?", filter.AirframeId)
+	}
+	// Earlier flights are needed to find the previous flight of the first
+	// one in the window, later ones never conflict with it.
+	if !filter.To.IsZero() {
+		query.Where("departure_time < ?", filter.To.UTC())
+	}
+
+	if err := query.Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.FleetConflicts{}, fmt.Errorf("failed to load flights: %w", err)
+	}
+
+	conflicts := slices.DeleteFunc(rotationConflicts(flights), func(c models.FleetConflict) bool {
+		return (!filter.From.IsZero() && c.DepartureTime.Before(filter.From)) ||
+			(!filter.To.IsZero() && !c.DepartureTime.Before(filter.To))
+	})
+	if err := setConflictRegistrations(ctx, fs.db, conflicts); err != nil {
+		return models.FleetConflicts{}, err
+	}
+
+	if conflicts == nil {
+		conflicts = []models.FleetConflict{}
+	}
+	return models.FleetConflicts{Total: len(conflicts), Conflicts: conflicts}, nil
+}
+
+// checkFlightRotation verifies that the airframe of flight can operate it
+// between its other flights. It returns a RotationConflictError listing the
+// conflicts flight causes.
+func checkFlightRotation(ctx context.Context, db bun.IDB, flight *models.Flight) error {
+	if flight.AirframeId == 0 || flight.Status == models.FlightCancelled {
+		return nil
+	}
+
+	var flights []models.Flight
+	if err := db.NewSelect().Model(&flights).
+		Where("airframe_id = ?", flight.AirframeId).
+		Where("id != ?", flight.Id).
+		Where("status != ?", models.FlightCancelled).
+		Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to load flights of airframe: %w", err)
+	}
+
+	conflicts := slices.DeleteFunc(rotationConflicts(append(flights, *flight)), func(c models.FleetConflict) bool {
+		return c.FlightId != flight.Id && c.PreviousFlightId != flight.Id
+	})
+	if len(conflicts) == 0 {
+		return nil
+	}
+	if err := setConflictRegistrations(ctx, db, conflicts); err != nil {
+		return err
+	}
+	return RotationConflictError(conflicts)
+}
+
+// rotationConflicts orders flights by airframe and departure and compares
+// every flight with the previous one of its airframe, which is the one
+// arriving last. Estimated and actual times take precedence over the
+// scheduled ones.
+func rotationConflicts(flights []models.Flight) []models.FleetConflict {
+	flights = slices.Clone(flights)
+	slices.SortStableFunc(flights, func(a, b models.Flight) int {
+		depA, _ := flightTimes(a)
+		depB, _ := flightTimes(b)
+		return cmp.Or(cmp.Compare(a.AirframeId, b.AirframeId), depA.Compare(depB), cmp.Compare(a.Id, b.Id))
+	})
+
+	var conflicts []models.FleetConflict
+	var prev models.Flight
+	for i, f := range flights {
+		if i == 0 || f.AirframeId != prev.AirframeId {
+			prev = f
+			continue
+		}
+
+		departure, arrival := flightTimes(f)
+		_, prevArrival := flightTimes(prev)
+		at := prev.Destination
+		if prev.DivertedTo != "" {
+			at = prev.DivertedTo
+		}
+		c := models.FleetConflict{
+			AirframeId:           f.AirframeId,
+			FlightId:             f.Id,
+			FlightNumber:         f.FlightNumber,
+			DepartureTime:        departure,
+			PreviousFlightId:     prev.Id,
+			PreviousFlightNumber: prev.FlightNumber,
+			PreviousArrivalTime:  prevArrival,
+		}
+		switch {
+		case departure.Before(prevArrival):
+			c.Kind = models.ConflictOverlap
+			c.Message = fmt.Sprintf("%s departs at %s before %s arrives at %s",
+				f.FlightNumber, departure.Format(time.RFC3339), prev.FlightNumber, prevArrival.Format(time.RFC3339))
+		case f.Origin != at:
+			c.Kind = models.ConflictPosition
+			c.Message = fmt.Sprintf("%s departs from %s but %s arrives at %s", f.FlightNumber, f.Origin, prev.FlightNumber, at)
+		case departure.Sub(prevArrival) < MinTurnaround:
+			c.Kind = models.ConflictTurnaround
+			c.Message = fmt.Sprintf("%s leaves %d minutes to turn around after %s, at least %d are required",
+				f.FlightNumber, int(departure.Sub(prevArrival).Minutes()), prev.FlightNumber, int(MinTurnaround.Minutes()))
+		}
+		if c.Kind != "" {
+			conflicts = append(conflicts, c)
+		}
+
+		if arrival.After(prevArrival) {
+			prev = f
+		}
+	}
+	return conflicts
+}
+
+// setConflictRegistrations fills in the registrations of the airframes of
+// conflicts.
+func setConflictRegistrations(ctx context.Context, db bun.IDB, conflicts []models.FleetConflict) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(conflicts))
+	for _, c := range conflicts {
+		ids = append(ids, c.AirframeId)
+	}
+	var airframes []models.Airframe
+	if err := db.NewSelect().Model(&airframes).Column("id", "registration").Where("id IN (?)", bun.In(ids)).Scan(ctx); err != nil {
+		return fmt.Errorf("failed to load airframes: %w", err)
+	}
+
+	registrations := make(map[int]string, len(airframes))
+	for _, a := range airframes {
+		registrations[a.Id] = a.Registration
+	}
+	for i := range conflicts {
+		conflicts[i].Registration = registrations[conflicts[i].AirframeId]
+	}
+	return nil
+}
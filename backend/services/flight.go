@@ -0,0 +1,485 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+type FlightService struct {
+	db  *bun.DB
+	hub *FlightHub
+}
+
+func NewFlightService(db *bun.DB) *FlightService {
+	return &FlightService{db: db, hub: NewFlightHub()}
+}
+
+// FlightFilter narrows down the flights returned by ListFlights. Origin and
+// Destination accept an airport IATA code or a metro area code. From and To
+// bound the departure time, From inclusive and To exclusive.
+type FlightFilter struct {
+	Origin       string
+	Destination  string
+	AircraftId   int
+	AirframeId   int
+	FlightNumber string
+	From         time.Time
+	To           time.Time
+}
+
+// flightListSpec lists the flight fields that can be sorted and selected.
+var flightListSpec = listSpec{
+	columns: map[string]string{
+		"id":             "id",
+		"flight_number":  "flight_number",
+		"aircraft_id":    "aircraft_id",
+		"airframe_id":    "airframe_id",
+		"origin":         "origin",
+		"destination":    "destination",
+		"departure_time": "departure_time",
+		"arrival_time":   "arrival_time",
+	},
+	pk: "id",
+}
+
+// ListFlights lists flights with their airports, aircraft and airframe. The
+// related rows are left out when opts selects a sparse fieldset.
+func (fs *FlightService) ListFlights(ctx context.Context, filter FlightFilter, opts ListOptions) (models.Page[models.FlightDetail], error) {
+	query := fs.db.NewSelect().Model((*models.Flight)(nil))
+
+	if filter.Origin != "" {
+		code := strings.ToUpper(filter.Origin)
+		query.Where("origin IN (SELECT iata FROM airports WHERE iata = ? OR metro_code = ?)", code, code)
+	}
+	if filter.Destination != "" {
+		code := strings.ToUpper(filter.Destination)
+		query.Where("destination IN (SELECT iata FROM airports WHERE iata = ? OR metro_code = ?)", code, code)
+	}
+	if filter.AircraftId > 0 {
+		query.Where("aircraft_id = ?", filter.AircraftId)
+	}
+	if filter.AirframeId > 0 {
+		query.Where("airframe_id = ?", filter.AirframeId)
+	}
+	if filter.FlightNumber != "" {
+		query.Where("flight_number = ?", strings.ToUpper(filter.FlightNumber))
+	}
+	if !filter.From.IsZero() {
+		query.Where("departure_time >= ?", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		query.Where("departure_time < ?", filter.To.UTC())
+	}
+
+	page, err := paginate[models.Flight](ctx, query, opts, flightListSpec)
+	if err != nil {
+		return models.Page[models.FlightDetail]{}, fmt.Errorf("failed to list flights: %w", err)
+	}
+
+	details := models.Page[models.FlightDetail]{
+		Data:       make([]models.FlightDetail, len(page.Data)),
+		Total:      page.Total,
+		NextCursor: page.NextCursor,
+	}
+	for i, flight := range page.Data {
+		details.Data[i].Flight = flight
+	}
+	if len(opts.Fields) == 0 {
+		if err := fs.loadFlightDetails(ctx, details.Data); err != nil {
+			return models.Page[models.FlightDetail]{}, err
+		}
+	}
+
+	return details, nil
+}
+
+// FindFlight returns the flight with the given id together with its airports,
+// aircraft and airframe.
+func (fs *FlightService) FindFlight(ctx context.Context, id int64) (models.FlightDetail, error) {
+	var detail models.FlightDetail
+	if err := fs.db.NewSelect().Model(&detail.Flight).Where("id = ?", id).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.FlightDetail{}, NotFoundError(fmt.Sprintf("flight not found: %d", id))
+		}
+		return models.FlightDetail{}, fmt.Errorf("failed to find flight: %w", err)
+	}
+
+	details := []models.FlightDetail{detail}
+	if err := fs.loadFlightDetails(ctx, details); err != nil {
+		return models.FlightDetail{}, err
+	}
+
+	return details[0], nil
+}
+
+// CreateFlight validates and stores a new flight. Unless force is set, a
+// flight its airframe cannot operate between its other flights is rejected
+// with a RotationConflictError.
+func (fs *FlightService) CreateFlight(ctx context.Context, flight *models.Flight, force bool) error {
+	if err := flight.Validate(); err != nil {
+		return err
+	}
+	flight.Id = 0
+	resetFlightStatus(flight)
+
+	return fs.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkFlightReferences(ctx, tx, flight); err != nil {
+			return err
+		}
+		if !force {
+			if err := checkFlightRotation(ctx, tx, flight); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.NewInsert().Model(flight).Returning("id").Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create flight: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateFlight replaces the flight with the given id. Its operational status
+// is kept, it is only changed by RecordFlightEvent. The rotation of its
+// airframe is checked like in CreateFlight.
+func (fs *FlightService) UpdateFlight(ctx context.Context, id int64, flight *models.Flight, force bool) error {
+	if flight.Id != 0 && flight.Id != id {
+		return models.ValidationErrors{{Field: "id", Message: fmt.Sprintf("cannot be changed from %d", id)}}
+	}
+	flight.Id = id
+	if err := flight.Validate(); err != nil {
+		return err
+	}
+
+	return fs.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := tx.NewSelect().Model(flight).Column(flightStatusColumns...).WherePK().Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return NotFoundError(fmt.Sprintf("flight not found: %d", id))
+			}
+			return fmt.Errorf("failed to read flight status: %w", err)
+		}
+		if err := checkFlightReferences(ctx, tx, flight); err != nil {
+			return err
+		}
+		if !force {
+			if err := checkFlightRotation(ctx, tx, flight); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.NewUpdate().Model(flight).ExcludeColumn(flightStatusColumns...).WherePK().Exec(ctx); err != nil {
+			return fmt.Errorf("failed to update flight: %w", err)
+		}
+		return nil
+	})
+}
+
+// FlightEvents returns the status history of the flight with the given id,
+// oldest first.
+func (fs *FlightService) FlightEvents(ctx context.Context, id int64) ([]models.FlightEvent, error) {
+	n, err := fs.db.NewSelect().Model((*models.Flight)(nil)).Where("id = ?", id).Count(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find flight: %w", err)
+	}
+	if n == 0 {
+		return nil, NotFoundError(fmt.Sprintf("flight not found: %d", id))
+	}
+
+	events := []models.FlightEvent{}
+	if err := fs.db.NewSelect().Model(&events).Where("flight_id = ?", id).Order("time", "id").Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to list flight events: %w", err)
+	}
+	return events, nil
+}
+
+// FlightIdByNumber returns the id of the flight with the given number
+// departing on date, a local date at its origin. Flight numbers repeat every
+// day, a number departing more than once on the same day is a ConflictError.
+func (fs *FlightService) FlightIdByNumber(ctx context.Context, number string, date time.Time) (int64, error) {
+	number = strings.ToUpper(number)
+	day := date.Format(time.DateOnly)
+
+	// Local dates are at most 14 hours off UTC.
+	var flights []models.Flight
+	if err := fs.db.NewSelect().Model(&flights).
+		Where("flight_number = ?", number).
+		Where("departure_time >= ?", date.AddDate(0, 0, -1).UTC()).
+		Where("departure_time < ?", date.AddDate(0, 0, 2).UTC()).
+		Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("failed to find flight: %w", err)
+	}
+	airports, err := flightAirports(ctx, fs.db, flights)
+	if err != nil {
+		return 0, err
+	}
+
+	var ids []int64
+	for _, f := range flights {
+		loc, err := airportLocation(airports[f.Origin])
+		if err != nil {
+			loc = time.UTC
+		}
+		if f.DepartureTime.In(loc).Format(time.DateOnly) == day {
+			ids = append(ids, f.Id)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return 0, NotFoundError(fmt.Sprintf("flight not found: %s on %s", number, day))
+	case 1:
+		return ids[0], nil
+	default:
+		return 0, ConflictError(fmt.Sprintf("flight %s departs %d times on %s, use the flight id", number, len(ids), day))
+	}
+}
+
+// RecordFlightEvent moves the flight with the given id to the status of event
+// and appends event to its history. Transitions the status machine does not
+// allow are rejected with a ConflictError. The actual off-block, takeoff,
+// landing and on-block times of the flight are taken from the time of the
+// departed, airborne, landed and arrived events, which defaults to now.
+func (fs *FlightService) RecordFlightEvent(ctx context.Context, id int64, event *models.FlightEvent) error {
+	if err := event.Validate(); err != nil {
+		return err
+	}
+	event.Id = 0
+	event.FlightId = id
+	event.RecordedAt = time.Now().UTC().Truncate(time.Second)
+	if event.Time.IsZero() {
+		event.Time = event.RecordedAt
+	}
+	event.Time = event.Time.UTC()
+
+	var flight models.Flight
+	err := fs.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := tx.NewSelect().Model(&flight).Where("id = ?", id).Scan(ctx); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return NotFoundError(fmt.Sprintf("flight not found: %d", id))
+			}
+			return fmt.Errorf("failed to find flight: %w", err)
+		}
+		if !flight.Status.CanTransition(event.Status) {
+			return ConflictError(fmt.Sprintf("flight %d cannot change from %s to %s", id, flight.Status, event.Status))
+		}
+		event.PreviousStatus = flight.Status
+
+		var errs models.ValidationErrors
+		var last models.FlightEvent
+		err := tx.NewSelect().Model(&last).Where("flight_id = ?", id).Order("time DESC").Limit(1).Scan(ctx)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to find last flight event: %w", err)
+		}
+		if err == nil && event.Time.Before(last.Time) {
+			errs = append(errs, models.ValidationError{Field: "time", Message: fmt.Sprintf("must not be before the previous event at %s", last.Time.UTC().Format(time.RFC3339))})
+		}
+		if event.DivertedTo != "" {
+			n, err := tx.NewSelect().Model((*models.Airport)(nil)).Where("iata = ?", event.DivertedTo).Count(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to find airport: %w", err)
+			}
+			if n == 0 {
+				errs = append(errs, models.ValidationError{Field: "diverted_to", Message: fmt.Sprintf("unknown airport %s", event.DivertedTo)})
+			} else if event.DivertedTo == flight.Destination {
+				errs = append(errs, models.ValidationError{Field: "diverted_to", Message: "must differ from the destination"})
+			}
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+
+		flight.Status = event.Status
+		switch event.Status {
+		case models.FlightDelayed:
+			estimate := event.EstimatedDepartureTime.UTC()
+			event.EstimatedDepartureTime = &estimate
+			flight.EstimatedDepartureTime = &estimate
+		case models.FlightDeparted:
+			flight.ActualDepartureTime = &event.Time
+		case models.FlightAirborne:
+			flight.ActualTakeoffTime = &event.Time
+		case models.FlightLanded:
+			flight.ActualLandingTime = &event.Time
+		case models.FlightDiverted:
+			flight.DivertedTo = event.DivertedTo
+		case models.FlightArrived:
+			flight.ActualArrivalTime = &event.Time
+		}
+
+		if _, err := tx.NewUpdate().Model(&flight).Column(flightStatusColumns...).WherePK().Exec(ctx); err != nil {
+			return fmt.Errorf("failed to update flight status: %w", err)
+		}
+		if _, err := tx.NewInsert().Model(event).Returning("id").Exec(ctx); err != nil {
+			return fmt.Errorf("failed to record flight event: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fs.publishStatus(flight, *event)
+	return nil
+}
+
+// flightStatusColumns are the columns of the operational state of a flight.
+var flightStatusColumns = []string{"status", "estimated_departure_time", "actual_departure_time", "actual_takeoff_time", "actual_landing_time", "actual_arrival_time", "diverted_to"}
+
+// resetFlightStatus clears the operational state of a new flight.
+func resetFlightStatus(flight *models.Flight) {
+	flight.Status = models.FlightScheduled
+	flight.EstimatedDepartureTime = nil
+	flight.ActualDepartureTime = nil
+	flight.ActualTakeoffTime = nil
+	flight.ActualLandingTime = nil
+	flight.ActualArrivalTime = nil
+	flight.DivertedTo = ""
+}
+
+// DeleteFlight removes a flight together with its status history.
+func (fs *FlightService) DeleteFlight(ctx context.Context, id int64) error {
+	return fs.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*models.FlightEvent)(nil)).Where("flight_id = ?", id).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to delete flight events: %w", err)
+		}
+
+		res, err := tx.NewDelete().Model((*models.Flight)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete flight: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("flight not found: %d", id))
+		}
+		return nil
+	})
+}
+
+// checkFlightReferences verifies that the airports, aircraft, airframe and
+// schedule of flight exist, that the airframe is of the flight's aircraft
+// type and that the leg is within the range of the aircraft.
+func checkFlightReferences(ctx context.Context, tx bun.Tx, flight *models.Flight) error {
+	var errs models.ValidationErrors
+
+	var airports []models.Airport
+	if err := tx.NewSelect().Model(&airports).Where("iata IN (?)", bun.In([]string{flight.Origin, flight.Destination})).Scan(ctx); err != nil {
+		return fmt.Errorf("failed to find airports: %w", err)
+	}
+	byIata := make(map[string]models.Airport, len(airports))
+	for _, a := range airports {
+		byIata[a.IATA] = a
+	}
+	origin, hasOrigin := byIata[flight.Origin]
+	if !hasOrigin {
+		errs = append(errs, models.ValidationError{Field: "origin", Message: fmt.Sprintf("unknown airport %s", flight.Origin)})
+	}
+	destination, hasDestination := byIata[flight.Destination]
+	if !hasDestination {
+		errs = append(errs, models.ValidationError{Field: "destination", Message: fmt.Sprintf("unknown airport %s", flight.Destination)})
+	}
+
+	var aircraft models.Aircraft
+	hasAircraft := true
+	if err := tx.NewSelect().Model(&aircraft).Where("id = ?", flight.AircraftId).Scan(ctx); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to find aircraft: %w", err)
+		}
+		hasAircraft = false
+		errs = append(errs, models.ValidationError{Field: "aircraft_id", Message: fmt.Sprintf("unknown aircraft %d", flight.AircraftId)})
+	}
+
+	if flight.AirframeId != 0 {
+		var airframe models.Airframe
+		if err := tx.NewSelect().Model(&airframe).Where("id = ?", flight.AirframeId).Scan(ctx); err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("failed to find airframe: %w", err)
+			}
+			errs = append(errs, models.ValidationError{Field: "airframe_id", Message: fmt.Sprintf("unknown airframe %d", flight.AirframeId)})
+		} else if airframe.AircraftId != flight.AircraftId {
+			errs = append(errs, models.ValidationError{Field: "airframe_id", Message: fmt.Sprintf("airframe %s is not an aircraft %d", airframe.Registration, flight.AircraftId)})
+		}
+	}
+
+	if flight.ScheduleId != 0 {
+		n, err := tx.NewSelect().Model((*models.Schedule)(nil)).Where("id = ?", flight.ScheduleId).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to find schedule: %w", err)
+		}
+		if n == 0 {
+			errs = append(errs, models.ValidationError{Field: "schedule_id", Message: fmt.Sprintf("unknown schedule %d", flight.ScheduleId)})
+		}
+	}
+
+	if hasOrigin && hasDestination && hasAircraft {
+		from := models.PointCoords{Lat: origin.Latitude, Lng: origin.Longitude}
+		to := models.PointCoords{Lat: destination.Latitude, Lng: destination.Longitude}
+		if nm := distanceKm(from, to) * nauticalMilesPerKm; nm > float64(aircraft.RangeNm) {
+			errs = append(errs, models.ValidationError{Field: "aircraft_id", Message: fmt.Sprintf("leg of %.0f nm exceeds the range of %s (%d nm)", nm, aircraft.Name, aircraft.RangeNm)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// loadFlightDetails fills in the airports, aircraft and airframes of flights.
+// They are read with one query per table rather than joined, so that the
+// flight columns stay unambiguous for keyset pagination.
+func (fs *FlightService) loadFlightDetails(ctx context.Context, flights []models.FlightDetail) error {
+	if len(flights) == 0 {
+		return nil
+	}
+
+	var iatas []string
+	var aircraftIds, airframeIds []int
+	for _, f := range flights {
+		iatas = append(iatas, f.Origin, f.Destination)
+		aircraftIds = append(aircraftIds, f.AircraftId)
+		if f.AirframeId != 0 {
+			airframeIds = append(airframeIds, f.AirframeId)
+		}
+	}
+
+	var airports []models.Airport
+	if err := fs.db.NewSelect().Model(&airports).Where("iata IN (?)", bun.In(iatas)).Scan(ctx); err != nil {
+		return fmt.Errorf("failed to load flight airports: %w", err)
+	}
+	airportsByIata := make(map[string]*models.Airport, len(airports))
+	for i := range airports {
+		airportsByIata[airports[i].IATA] = &airports[i]
+	}
+
+	var aircraft []models.Aircraft
+	if err := fs.db.NewSelect().Model(&aircraft).Where("id IN (?)", bun.In(aircraftIds)).Scan(ctx); err != nil {
+		return fmt.Errorf("failed to load flight aircraft: %w", err)
+	}
+	aircraftById := make(map[int]*models.Aircraft, len(aircraft))
+	for i := range aircraft {
+		aircraftById[aircraft[i].Id] = &aircraft[i]
+	}
+
+	airframeById := map[int]*models.Airframe{}
+	if len(airframeIds) > 0 {
+		var airframes []models.Airframe
+		if err := fs.db.NewSelect().Model(&airframes).Where("id IN (?)", bun.In(airframeIds)).Scan(ctx); err != nil {
+			return fmt.Errorf("failed to load flight airframes: %w", err)
+		}
+		for i := range airframes {
+			airframeById[airframes[i].Id] = &airframes[i]
+		}
+	}
+
+	for i := range flights {
+		f := &flights[i]
+		f.OriginAirport = airportsByIata[f.Origin]
+		f.DestinationAirport = airportsByIata[f.Destination]
+		f.Aircraft = aircraftById[f.AircraftId]
+		f.Airframe = airframeById[f.AirframeId]
+	}
+	return nil
+}
@@ -0,0 +1,246 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+type MetroAreaService struct {
+	db *bun.DB
+}
+
+func NewMetroAreaService(db *bun.DB) *MetroAreaService {
+	return &MetroAreaService{db: db}
+}
+
+// ListMetroAreas returns all metropolitan areas with their airports,
+// optionally narrowed down to a country (code or name).
+func (ms *MetroAreaService) ListMetroAreas(ctx context.Context, country string) ([]models.MetroArea, error) {
+	metros := []models.MetroArea{}
+	query := ms.db.NewSelect().Model(&metros).Order("code")
+	if country != "" {
+		query.Where("country_code IN (?)", countryCodeQuery(ms.db, country))
+	}
+	if err := query.Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to list metro areas: %w", err)
+	}
+
+	var members []struct {
+		IATA      string
+		MetroCode string
+	}
+	if err := ms.db.NewSelect().Model((*models.Airport)(nil)).
+		Column("iata", "metro_code").
+		Where("metro_code IS NOT NULL").
+		Order("iata").
+		Scan(ctx, &members); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to list metro area airports: %w", err)
+	}
+
+	airports := map[string][]string{}
+	for _, m := range members {
+		airports[m.MetroCode] = append(airports[m.MetroCode], m.IATA)
+	}
+	for i := range metros {
+		metros[i].Airports = airports[metros[i].Code]
+		if metros[i].Airports == nil {
+			metros[i].Airports = []string{}
+		}
+	}
+
+	return metros, nil
+}
+
+// FindMetroArea returns the metropolitan area with the given code.
+func (ms *MetroAreaService) FindMetroArea(ctx context.Context, code string) (models.MetroArea, error) {
+	code = strings.ToUpper(code)
+
+	var metro models.MetroArea
+	if err := ms.db.NewSelect().Model(&metro).Where("code = ?", code).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.MetroArea{}, NotFoundError(fmt.Sprintf("metro area not found: %s", code))
+		}
+		return models.MetroArea{}, fmt.Errorf("failed to find metro area: %w", err)
+	}
+
+	metro.Airports = []string{}
+	if err := ms.db.NewSelect().Model((*models.Airport)(nil)).
+		Column("iata").
+		Where("metro_code = ?", code).
+		Order("iata").
+		Scan(ctx, &metro.Airports); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.MetroArea{}, fmt.Errorf("failed to list metro area airports: %w", err)
+	}
+
+	return metro, nil
+}
+
+// CreateMetroArea validates and stores a new metropolitan area and assigns
+// its airports to it.
+func (ms *MetroAreaService) CreateMetroArea(ctx context.Context, metro *models.MetroArea) error {
+	if err := metro.Validate(); err != nil {
+		return err
+	}
+
+	return ms.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		n, err := tx.NewSelect().Model((*models.MetroArea)(nil)).Where("code = ?", metro.Code).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to check metro area code: %w", err)
+		}
+		if n > 0 {
+			return ConflictError(fmt.Sprintf("metro area already exists: %s", metro.Code))
+		}
+		if err := checkMetroAreaCountry(ctx, tx, metro); err != nil {
+			return err
+		}
+		if _, err := tx.NewInsert().Model(metro).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create metro area: %w", err)
+		}
+		return assignMetroAreaAirports(ctx, tx, metro)
+	})
+}
+
+// UpdateMetroArea replaces the metropolitan area stored under code including
+// its list of airports. The code cannot be changed.
+func (ms *MetroAreaService) UpdateMetroArea(ctx context.Context, code string, metro *models.MetroArea) error {
+	code = strings.ToUpper(code)
+	if metro.Code != code {
+		return models.ValidationErrors{{Field: "code", Message: fmt.Sprintf("cannot be changed from %s", code)}}
+	}
+	if err := metro.Validate(); err != nil {
+		return err
+	}
+
+	return ms.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkMetroAreaCountry(ctx, tx, metro); err != nil {
+			return err
+		}
+		res, err := tx.NewUpdate().Model(metro).WherePK().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update metro area: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("metro area not found: %s", code))
+		}
+		if err := clearMetroAreaAirports(ctx, tx, code); err != nil {
+			return err
+		}
+		return assignMetroAreaAirports(ctx, tx, metro)
+	})
+}
+
+// DeleteMetroArea removes a metropolitan area. Its airports are kept and no
+// longer belong to any metro area.
+func (ms *MetroAreaService) DeleteMetroArea(ctx context.Context, code string) error {
+	code = strings.ToUpper(code)
+
+	return ms.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := clearMetroAreaAirports(ctx, tx, code); err != nil {
+			return err
+		}
+		res, err := tx.NewDelete().Model((*models.MetroArea)(nil)).Where("code = ?", code).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete metro area: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("metro area not found: %s", code))
+		}
+		return nil
+	})
+}
+
+func checkMetroAreaCountry(ctx context.Context, tx bun.Tx, metro *models.MetroArea) error {
+	if metro.CountryCode == "" {
+		return nil
+	}
+	n, err := tx.NewSelect().Model((*models.Country)(nil)).Where("alpha2 = ?", metro.CountryCode).Count(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to find country: %w", err)
+	}
+	if n == 0 {
+		return models.ValidationErrors{{Field: "country", Message: fmt.Sprintf("unknown country %s", metro.CountryCode)}}
+	}
+	return nil
+}
+
+func clearMetroAreaAirports(ctx context.Context, tx bun.Tx, code string) error {
+	if _, err := tx.NewUpdate().Model((*models.Airport)(nil)).
+		Set("metro_code = NULL").
+		Where("metro_code = ?", code).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove airports from metro area: %w", err)
+	}
+	return nil
+}
+
+// assignMetroAreaAirports links the airports listed in metro to it. Unknown
+// airports are rejected, as are airports belonging to another metro area.
+func assignMetroAreaAirports(ctx context.Context, tx bun.Tx, metro *models.MetroArea) error {
+	if len(metro.Airports) == 0 {
+		metro.Airports = []string{}
+		return nil
+	}
+
+	var airports []models.Airport
+	if err := tx.NewSelect().Model(&airports).
+		Where("iata IN (?)", bun.In(metro.Airports)).
+		Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to find airports: %w", err)
+	}
+	byIATA := make(map[string]models.Airport, len(airports))
+	for _, a := range airports {
+		byIATA[a.IATA] = a
+	}
+
+	var errs models.ValidationErrors
+	for i, iata := range metro.Airports {
+		a, ok := byIATA[iata]
+		if !ok {
+			errs = append(errs, models.ValidationError{Field: fmt.Sprintf("airports[%d]", i), Message: fmt.Sprintf("unknown airport %s", iata)})
+			continue
+		}
+		if a.MetroCode != "" && a.MetroCode != metro.Code {
+			return ConflictError(fmt.Sprintf("airport %s already belongs to metro area %s", iata, a.MetroCode))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if _, err := tx.NewUpdate().Model((*models.Airport)(nil)).
+		Set("metro_code = ?", metro.Code).
+		Where("iata IN (?)", bun.In(metro.Airports)).
+		Exec(ctx); err != nil {
+		return fmt.Errorf("failed to assign airports to metro area: %w", err)
+	}
+	return nil
+}
+
+// routeAirports resolves the departure or destination of a route. An airport
+// IATA code always denotes that airport; otherwise the code is looked up as a
+// metropolitan area and resolves to all of its airports.
+func routeAirports(ctx context.Context, db bun.IDB, code string) ([]models.Airport, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	var airports []models.Airport
+	if err := db.NewSelect().Model(&airports).Where("iata = ?", code).Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to find airport: %w", err)
+	}
+	if len(airports) > 0 {
+		return airports, nil
+	}
+
+	if err := db.NewSelect().Model(&airports).Where("metro_code = ?", code).Order("iata").Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to find metro area airports: %w", err)
+	}
+	if len(airports) == 0 {
+		return nil, NotFoundError(fmt.Sprintf("airport or metro area not found: %s", code))
+	}
+	return airports, nil
+}
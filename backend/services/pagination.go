@@ -0,0 +1,313 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/schema"
+)
+
+const (
+	// DefaultPageLimit is the page size used when a request does not set one.
+	DefaultPageLimit = 100
+	// MaxPageLimit is the largest page size a client can request.
+	MaxPageLimit = 1000
+)
+
+// ListOptions controls pagination, ordering and field selection of list queries.
+type ListOptions struct {
+	Limit  int      // page size, defaults to DefaultPageLimit
+	Cursor string   // opaque cursor returned as NextCursor by the previous page
+	Sort   string   // comma separated fields, prefixed with "-" for descending order, missing values come last
+	Fields []string // sparse fieldset, empty selects every field
+}
+
+// listSpec describes the sortable and selectable fields of a model. Field
+// names are the JSON names exposed by the API and map to their column.
+type listSpec struct {
+	columns map[string]string
+	pk      string // unique field used as the final tie breaker
+}
+
+type sortKey struct {
+	field  string
+	desc   bool
+	column *schema.Field // model field of the column, set by paginate
+}
+
+// nullable reports whether the column of k is NULL for nil or zero values.
+func (k sortKey) nullable() bool {
+	return k.column.IsPtr || k.column.NullZero
+}
+
+// cursor is the decoded form of ListOptions.Cursor. It holds the sort key
+// values of the last row of the previous page and the sort it was issued for.
+type cursor struct {
+	Sort   string `json:"s"`
+	Values []any  `json:"v"`
+}
+
+// paginate applies keyset pagination to q and scans the requested page.
+// q must carry the filters only: ordering, limit and columns are added here.
+func paginate[T any](ctx context.Context, q *bun.SelectQuery, opts ListOptions, spec listSpec) (models.Page[T], error) {
+	keys, err := spec.parseSort(opts.Sort)
+	if err != nil {
+		return models.Page[T]{}, err
+	}
+	table := q.DB().Table(reflect.TypeFor[T]())
+	for i, k := range keys {
+		if keys[i].column = table.FieldMap[spec.columns[k.field]]; keys[i].column == nil {
+			return models.Page[T]{}, fmt.Errorf("sort field %q has no column in %s", k.field, table.Name)
+		}
+	}
+	if err := spec.validateFields(opts.Fields); err != nil {
+		return models.Page[T]{}, err
+	}
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultPageLimit
+	}
+	limit = min(limit, MaxPageLimit)
+
+	total, err := q.Count(ctx)
+	if err != nil {
+		return models.Page[T]{}, fmt.Errorf("failed to count rows: %w", err)
+	}
+
+	sortSpec := formatSort(keys)
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor, sortSpec, keys)
+		if err != nil {
+			return models.Page[T]{}, BadRequestError("invalid cursor")
+		}
+		cond, args := spec.keysetCondition(keys, c.Values)
+		q.Where(cond, args...)
+	}
+
+	if len(opts.Fields) > 0 {
+		columns := map[string]bool{}
+		for _, f := range opts.Fields {
+			columns[spec.columns[f]] = true
+		}
+		for _, k := range keys {
+			columns[spec.columns[k.field]] = true
+		}
+		for _, c := range slices.Sorted(maps.Keys(columns)) {
+			q.Column(c)
+		}
+	}
+
+	for _, k := range keys {
+		order := "? ASC"
+		if k.desc {
+			order = "? DESC"
+		}
+		// SQLite and PostgreSQL disagree on where NULLs go by default.
+		if k.nullable() {
+			order += " NULLS LAST"
+		}
+		q.OrderExpr(order, bun.Ident(spec.columns[k.field]))
+	}
+
+	q.Limit(limit + 1)
+
+	var items []T
+	if err := q.Scan(ctx, &items); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.Page[T]{}, fmt.Errorf("failed to scan rows: %w", err)
+	}
+
+	page := models.Page[T]{Data: items, Total: total}
+	if len(items) > limit {
+		page.Data = items[:limit]
+		next, err := encodeCursor(sortSpec, keys, page.Data[limit-1])
+		if err != nil {
+			return models.Page[T]{}, err
+		}
+		page.NextCursor = next
+	}
+	if page.Data == nil {
+		page.Data = []T{}
+	}
+
+	return page, nil
+}
+
+// parseSort parses a sort parameter such as "name,-latitude". The primary key
+// is always appended so that the order, and therefore the cursor, is total.
+func (s listSpec) parseSort(sortParam string) ([]sortKey, error) {
+	var keys []sortKey
+	seen := map[string]bool{}
+	for _, part := range strings.Split(sortParam, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key := sortKey{field: strings.TrimPrefix(part, "-"), desc: strings.HasPrefix(part, "-")}
+		if _, ok := s.columns[key.field]; !ok {
+			return nil, BadRequestError(fmt.Sprintf("unknown sort field %q, valid fields are: %s", key.field, s.fieldList()))
+		}
+		if seen[key.field] {
+			continue
+		}
+		seen[key.field] = true
+		keys = append(keys, key)
+	}
+	if !seen[s.pk] {
+		keys = append(keys, sortKey{field: s.pk})
+	}
+	return keys, nil
+}
+
+func (s listSpec) validateFields(fields []string) error {
+	for _, f := range fields {
+		if _, ok := s.columns[f]; !ok {
+			return BadRequestError(fmt.Sprintf("unknown field %q, valid fields are: %s", f, s.fieldList()))
+		}
+	}
+	return nil
+}
+
+func (s listSpec) fieldList() string {
+	return strings.Join(slices.Sorted(maps.Keys(s.columns)), ", ")
+}
+
+// keysetCondition builds the WHERE clause selecting the rows after the cursor:
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... with the comparison flipped for
+// descending keys. NULLs of nullable keys sort last, so they come after any
+// value and nothing but NULL comes after a NULL.
+func (s listSpec) keysetCondition(keys []sortKey, values []any) (string, []any) {
+	var (
+		ors  []string
+		args []any
+	)
+	for i, k := range keys {
+		if values[i] == nil {
+			continue
+		}
+		var ands []string
+		for j := range i {
+			if values[j] == nil {
+				ands = append(ands, "? IS NULL")
+				args = append(args, bun.Ident(s.columns[keys[j].field]))
+			} else {
+				ands = append(ands, "? = ?")
+				args = append(args, bun.Ident(s.columns[keys[j].field]), values[j])
+			}
+		}
+		op := ">"
+		if k.desc {
+			op = "<"
+		}
+		if k.nullable() {
+			ands = append(ands, "(? "+op+" ? OR ? IS NULL)")
+			args = append(args, bun.Ident(s.columns[k.field]), values[i], bun.Ident(s.columns[k.field]))
+		} else {
+			ands = append(ands, "? "+op+" ?")
+			args = append(args, bun.Ident(s.columns[k.field]), values[i])
+		}
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+func formatSort(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = k.field
+		if k.desc {
+			parts[i] = "-" + k.field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// encodeCursor captures the sort key values of item, read from the model
+// fields of the sort columns. Values stored as NULL are captured as nil.
+func encodeCursor(sortSpec string, keys []sortKey, item any) (string, error) {
+	v := reflect.Indirect(reflect.ValueOf(item))
+	c := cursor{Sort: sortSpec}
+	for _, k := range keys {
+		switch {
+		case k.column.IsPtr && k.column.HasNilValue(v), k.column.NullZero && k.column.HasZeroValue(v):
+			c.Values = append(c.Values, nil)
+		default:
+			c.Values = append(c.Values, reflect.Indirect(k.column.Value(v)).Interface())
+		}
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// decodeCursor decodes a cursor issued for sortSpec and converts its values
+// back to the types of the sort columns. The values have to be bound with
+// their type: SQLite compares times as text, and the format JSON gives them
+// does not sort like the one they are stored in.
+func decodeCursor(s string, sortSpec string, keys []sortKey) (cursor, error) {
+	var c cursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&c); err != nil {
+		return c, err
+	}
+	if c.Sort != sortSpec || len(c.Values) != len(keys) {
+		return c, errors.New("cursor does not match the sort")
+	}
+	for i, k := range keys {
+		if c.Values[i] == nil && !k.nullable() {
+			return c, fmt.Errorf("cursor value of %s is missing", k.field)
+		}
+		if c.Values[i], err = cursorValue(k.column, c.Values[i]); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// cursorValue converts a value decoded from JSON to the type of column.
+func cursorValue(column *schema.Field, v any) (any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	typ := column.IndirectType
+	switch {
+	case typ == reflect.TypeFor[time.Time]():
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid time %v", v)
+		}
+		return time.Parse(time.RFC3339Nano, s)
+	case typ.Kind() == reflect.String:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Uint64:
+		if n, ok := v.(json.Number); ok {
+			return n.Int64()
+		}
+	case typ.Kind() == reflect.Float32 || typ.Kind() == reflect.Float64:
+		if n, ok := v.(json.Number); ok {
+			return n.Float64()
+		}
+	}
+	return nil, fmt.Errorf("invalid %s value %v", typ, v)
+}
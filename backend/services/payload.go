@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+// PayloadRange returns the payload-range envelope of the aircraft with the
+// given id. It is empty if no diagram has been stored for the aircraft.
+func (as *AircraftService) PayloadRange(ctx context.Context, id int) (models.PayloadRange, error) {
+	if _, err := as.FindAircraft(ctx, id); err != nil {
+		return nil, err
+	}
+
+	envelopes, err := payloadRanges(ctx, as.db, []int{id})
+	if err != nil {
+		return nil, err
+	}
+	if pr, ok := envelopes[id]; ok {
+		return pr, nil
+	}
+	return models.PayloadRange{}, nil
+}
+
+// SetPayloadRange replaces the payload-range envelope of an aircraft.
+func (as *AircraftService) SetPayloadRange(ctx context.Context, id int, pr models.PayloadRange) error {
+	if err := pr.Validate(); err != nil {
+		return err
+	}
+
+	return as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		n, err := tx.NewSelect().Model((*models.Aircraft)(nil)).Where("id = ?", id).Count(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to find aircraft: %w", err)
+		}
+		if n == 0 {
+			return NotFoundError(fmt.Sprintf("aircraft not found: %d", id))
+		}
+
+		if _, err := tx.NewDelete().Model((*models.PayloadRangePoint)(nil)).Where("aircraft_id = ?", id).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to delete payload-range: %w", err)
+		}
+		for i := range pr {
+			pr[i].Id = 0
+			pr[i].AircraftId = id
+		}
+		if _, err := tx.NewInsert().Model(&pr).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to store payload-range: %w", err)
+		}
+		return nil
+	})
+}
+
+// payloadRanges loads the payload-range envelopes of the given aircraft.
+// Aircraft without a diagram are missing from the result.
+func payloadRanges(ctx context.Context, db bun.IDB, ids []int) (map[int]models.PayloadRange, error) {
+	envelopes := map[int]models.PayloadRange{}
+	if len(ids) == 0 {
+		return envelopes, nil
+	}
+
+	var points []models.PayloadRangePoint
+	if err := db.NewSelect().Model(&points).
+		Where("aircraft_id IN (?)", bun.In(ids)).
+		Order("aircraft_id", "range_nm").
+		Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to load payload-range: %w", err)
+	}
+	for _, p := range points {
+		envelopes[p.AircraftId] = append(envelopes[p.AircraftId], p)
+	}
+	return envelopes, nil
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// defaultCruiseAltitudeFt is assumed for aircraft without a cruise altitude.
+	defaultCruiseAltitudeFt = 35000
+	// maxClimb and maxDescent bound the climb and descent phases, which take
+	// a fifth of the block time on shorter flights.
+	maxClimb   = 25 * time.Minute
+	maxDescent = 30 * time.Minute
+	// maxPositionDelay is the longest delay considered when looking for the
+	// flights airborne at a point in time.
+	maxPositionDelay = 24 * time.Hour
+)
+
+// Positions returns the simulated positions of all flights airborne at at,
+// which may lie in the past or the future to play back a schedule. Flights
+// follow their actual or estimated departure time and arrive after their
+// scheduled block time unless they already arrived. Cancelled flights are
+// left out, diverted flights fly to their alternate airport.
+func (fs *FlightService) Positions(ctx context.Context, at time.Time) (models.FlightPositions, error) {
+	at = at.UTC()
+	res := models.FlightPositions{At: at, Flights: []models.FlightPosition{}}
+
+	var flights []models.Flight
+	if err := fs.db.NewSelect().Model(&flights).
+		Where("departure_time <= ?", at.Add(maxPositionDelay)).
+		Where("arrival_time >= ?", at.Add(-maxPositionDelay)).
+		Where("status != ?", models.FlightCancelled).
+		Order("departure_time", "id").
+		Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return res, fmt.Errorf("failed to load flights: %w", err)
+	}
+
+	airborne := flights[:0]
+	for _, f := range flights {
+		if departure, arrival := flightTimes(f); !at.Before(departure) && at.Before(arrival) {
+			airborne = append(airborne, f)
+		}
+	}
+	if len(airborne) == 0 {
+		return res, nil
+	}
+
+	for i := range airborne {
+		if airborne[i].DivertedTo != "" {
+			airborne[i].Destination = airborne[i].DivertedTo
+		}
+	}
+	airports, err := flightAirports(ctx, fs.db, airborne)
+	if err != nil {
+		return res, err
+	}
+	var aircraftIds []int
+	for _, f := range airborne {
+		aircraftIds = append(aircraftIds, f.AircraftId)
+	}
+	var aircraft []models.Aircraft
+	if err := fs.db.NewSelect().Model(&aircraft).Where("id IN (?)", bun.In(aircraftIds)).Scan(ctx); err != nil {
+		return res, fmt.Errorf("failed to load flight aircraft: %w", err)
+	}
+	cruiseAltitudes := map[int]int{}
+	for _, a := range aircraft {
+		cruiseAltitudes[a.Id] = a.CruiseAltitudeFt
+	}
+
+	for _, f := range airborne {
+		origin, okOrigin := airports[f.Origin]
+		destination, okDestination := airports[f.Destination]
+		if !okOrigin || !okDestination {
+			continue
+		}
+		res.Flights = append(res.Flights, flightPosition(f, origin, destination, cruiseAltitudes[f.AircraftId], at))
+	}
+	return res, nil
+}
+
+// flightTimes returns the departure and arrival time a flight is expected to
+// fly at: its actual or estimated departure and the scheduled block time
+// after it, or its actual arrival.
+func flightTimes(f models.Flight) (time.Time, time.Time) {
+	departure := f.DepartureTime
+	if f.ActualDepartureTime != nil {
+		departure = *f.ActualDepartureTime
+	} else if f.EstimatedDepartureTime != nil {
+		departure = *f.EstimatedDepartureTime
+	}
+	arrival := departure.Add(f.ArrivalTime.Sub(f.DepartureTime))
+	if f.ActualArrivalTime != nil {
+		arrival = *f.ActualArrivalTime
+	}
+	return departure, arrival
+}
+
+// flightPosition interpolates the position of f at at along the great circle
+// from origin to destination.
+func flightPosition(f models.Flight, origin, destination models.Airport, cruiseAltitudeFt int, at time.Time) models.FlightPosition {
+	departure, arrival := flightTimes(f)
+	block := arrival.Sub(departure)
+	elapsed := at.Sub(departure)
+	progress := math.Min(math.Max(float64(elapsed)/float64(block), 0), 1)
+
+	from := models.PointCoords{Lat: origin.Latitude, Lng: origin.Longitude}
+	to := models.PointCoords{Lat: destination.Latitude, Lng: destination.Longitude}
+	totalKm := distanceKm(from, to)
+	position := intermediatePoint(from, to, progress)
+
+	heading := initialBearing(position, to)
+
+	if cruiseAltitudeFt <= 0 {
+		cruiseAltitudeFt = defaultCruiseAltitudeFt
+	}
+	climb := min(block/5, maxClimb)
+	descent := min(block/5, maxDescent)
+	phase, altitude := models.PhaseCruise, float64(cruiseAltitudeFt)
+	switch {
+	case elapsed < climb:
+		phase, altitude = models.PhaseClimb, altitude*float64(elapsed)/float64(climb)
+	case block-elapsed < descent:
+		phase, altitude = models.PhaseDescent, altitude*float64(block-elapsed)/float64(descent)
+	}
+
+	return models.FlightPosition{
+		FlightId:            f.Id,
+		FlightNumber:        f.FlightNumber,
+		Origin:              f.Origin,
+		Destination:         f.Destination,
+		Status:              f.Status,
+		Position:            position,
+		Heading:             heading,
+		Progress:            progress,
+		Phase:               phase,
+		AltitudeFt:          int(math.Round(altitude)),
+		GroundSpeedKts:      totalKm * nauticalMilesPerKm / block.Hours(),
+		DistanceFlownKm:     totalKm * progress,
+		DistanceRemainingKm: totalKm * (1 - progress),
+		DepartureTime:       departure,
+		ArrivalTime:         arrival,
+	}
+}
+
+// intermediatePoint returns the point at fraction f of the great circle from
+// a to b.
+func intermediatePoint(a, b models.PointCoords, f float64) models.PointCoords {
+	delta := centralAngle(a, b)
+	if delta == 0 {
+		return a
+	}
+	lat1, lng1 := a.Lat*math.Pi/180, a.Lng*math.Pi/180
+	lat2, lng2 := b.Lat*math.Pi/180, b.Lng*math.Pi/180
+
+	wa := math.Sin((1-f)*delta) / math.Sin(delta)
+	wb := math.Sin(f*delta) / math.Sin(delta)
+	x := wa*math.Cos(lat1)*math.Cos(lng1) + wb*math.Cos(lat2)*math.Cos(lng2)
+	y := wa*math.Cos(lat1)*math.Sin(lng1) + wb*math.Cos(lat2)*math.Sin(lng2)
+	z := wa*math.Sin(lat1) + wb*math.Sin(lat2)
+
+	return models.PointCoords{
+		Lat: math.Atan2(z, math.Hypot(x, y)) * 180 / math.Pi,
+		Lng: math.Atan2(y, x) * 180 / math.Pi,
+	}
+}
+
+// initialBearing returns the initial true course from a to b in degrees.
+func initialBearing(a, b models.PointCoords) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+
+	y := math.Sin(dLng) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLng)
+	return math.Mod(math.Atan2(y, x)*180/math.Pi+360, 360)
+}
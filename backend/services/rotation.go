@@ -0,0 +1,463 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+const (
+	// rotationJobTimeout bounds the time a rotation job may run.
+	rotationJobTimeout = time.Minute
+	// rotationJobTTL is how long finished rotation jobs can be retrieved.
+	rotationJobTTL = time.Hour
+	// RotationJobPruneInterval is how often finished rotation jobs are checked
+	// for expiry.
+	RotationJobPruneInterval = time.Minute
+	// DefaultMaxActiveRotationJobs is the number of rotation jobs that can
+	// be pending or running at the same time, unless changed with
+	// SetMaxActiveRotationJobs.
+	DefaultMaxActiveRotationJobs = 4
+	// maxRotationJobs is the number of rotation jobs kept in memory,
+	// including finished ones that have not expired yet.
+	maxRotationJobs = 1000
+	// ferryOverhead is added to the cruise time of ferry legs for taxiing,
+	// climb and descent.
+	ferryOverhead = 20 * time.Minute
+)
+
+// StartRotationJob validates req and starts computing its rotation plan in
+// the background. The returned job is pending, its progress is available
+// from RotationJob. An UnavailableError is returned while the maximum of
+// active jobs are unfinished or maxRotationJobs are kept.
+func (fs *FleetService) StartRotationJob(ctx context.Context, req *models.RotationRequest) (models.RotationJob, error) {
+	if err := req.Validate(); err != nil {
+		return models.RotationJob{}, err
+	}
+	if err := checkRotationRequest(ctx, fs.db, req); err != nil {
+		return models.RotationJob{}, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return models.RotationJob{}, fmt.Errorf("failed to generate job id: %w", err)
+	}
+	job := &models.RotationJob{
+		Id:        hex.EncodeToString(id),
+		Status:    models.RotationJobPending,
+		Request:   *req,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	fs.mu.Lock()
+	fs.pruneRotationJobs(time.Now())
+	active := 0
+	for _, j := range fs.jobs {
+		if j.FinishedAt == nil {
+			active++
+		}
+	}
+	if active >= fs.maxActiveJobs || len(fs.jobs) >= maxRotationJobs {
+		fs.mu.Unlock()
+		return models.RotationJob{}, UnavailableError("too many rotation jobs, retry later")
+	}
+	fs.jobs[job.Id] = job
+	res := *job
+	fs.mu.Unlock()
+
+	go fs.runRotationJob(job)
+	return res, nil
+}
+
+// PruneRotationJobs removes finished rotation jobs once they expired, every
+// interval until ctx is done.
+func (fs *FleetService) PruneRotationJobs(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			fs.mu.Lock()
+			fs.pruneRotationJobs(now)
+			fs.mu.Unlock()
+		}
+	}
+}
+
+// pruneRotationJobs removes the jobs that finished more than rotationJobTTL
+// before now. fs.mu must be held.
+func (fs *FleetService) pruneRotationJobs(now time.Time) {
+	for id, j := range fs.jobs {
+		if j.FinishedAt != nil && now.Sub(*j.FinishedAt) > rotationJobTTL {
+			delete(fs.jobs, id)
+		}
+	}
+}
+
+// RotationJob returns the rotation job with the given id.
+func (fs *FleetService) RotationJob(id string) (models.RotationJob, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	job, ok := fs.jobs[id]
+	if !ok {
+		return models.RotationJob{}, NotFoundError(fmt.Sprintf("rotation job not found: %s", id))
+	}
+	return *job, nil
+}
+
+func (fs *FleetService) runRotationJob(job *models.RotationJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), rotationJobTimeout)
+	defer cancel()
+
+	fs.mu.Lock()
+	started := time.Now().UTC()
+	job.Status = models.RotationJobRunning
+	job.StartedAt = &started
+	req := job.Request
+	fs.mu.Unlock()
+
+	plan, err := planRotations(ctx, fs.db, req)
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	finished := time.Now().UTC()
+	job.FinishedAt = &finished
+	if err != nil {
+		log.Printf("rotation job %s failed: %v", job.Id, err)
+		job.Status = models.RotationJobFailed
+		job.Error = err.Error()
+		return
+	}
+	job.Status = models.RotationJobSucceeded
+	job.Plan = &plan
+}
+
+// checkRotationRequest verifies that the airframes of req exist and are
+// active and that their starting positions are known airports.
+func checkRotationRequest(ctx context.Context, db bun.IDB, req *models.RotationRequest) error {
+	ids := slices.Clone(req.AirframeIds)
+	codes := make([]string, 0, len(req.Positions))
+	for id, iata := range req.Positions {
+		ids = append(ids, id)
+		codes = append(codes, iata)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var airframes []models.Airframe
+	if err := db.NewSelect().Model(&airframes).Where("id IN (?)", bun.In(ids)).Scan(ctx); err != nil {
+		return fmt.Errorf("failed to load airframes: %w", err)
+	}
+	byId := make(map[int]models.Airframe, len(airframes))
+	for _, a := range airframes {
+		byId[a.Id] = a
+	}
+	airports, err := airportsByCode(ctx, db, codes)
+	if err != nil {
+		return err
+	}
+
+	var errs models.ValidationErrors
+	for _, id := range req.AirframeIds {
+		if a, ok := byId[id]; !ok {
+			errs = append(errs, models.ValidationError{Field: "airframe_ids", Message: fmt.Sprintf("unknown airframe %d", id)})
+		} else if a.Status != models.AirframeActive {
+			errs = append(errs, models.ValidationError{Field: "airframe_ids", Message: fmt.Sprintf("airframe %d is %s", id, a.Status)})
+		}
+	}
+	for id, iata := range req.Positions {
+		field := "positions." + strconv.Itoa(id)
+		switch {
+		case len(req.AirframeIds) > 0 && !slices.Contains(req.AirframeIds, id):
+			errs = append(errs, models.ValidationError{Field: field, Message: "airframe is not in airframe_ids"})
+		case len(req.AirframeIds) == 0 && byId[id].Status != models.AirframeActive:
+			errs = append(errs, models.ValidationError{Field: field, Message: fmt.Sprintf("unknown or inactive airframe %d", id)})
+		}
+		if _, ok := airports[iata]; !ok {
+			errs = append(errs, models.ValidationError{Field: field, Message: fmt.Sprintf("unknown airport %s", iata)})
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// tail is the state of an airframe while its rotation is planned.
+type tail struct {
+	airframe models.Airframe
+	aircraft models.Aircraft
+	rotation *models.Rotation
+	pos      string    // current airport, empty if unknown
+	ready    time.Time // time the airframe arrived at pos
+	landed   bool      // whether a turnaround is due before the next leg
+}
+
+// inRange reports whether the airframe can fly km without refueling.
+func (t *tail) inRange(km float64) bool {
+	return km*nauticalMilesPerKm <= float64(t.aircraft.RangeNm)
+}
+
+// ferryTime estimates the block time of a ferry leg of km.
+func (t *tail) ferryTime(km float64) time.Duration {
+	kts := float64(t.aircraft.CruiseSpeedKts)
+	if kts <= 0 {
+		kts = 450
+	}
+	return (time.Duration(km*nauticalMilesPerKm/kts*float64(time.Hour)) + ferryOverhead).Round(time.Minute)
+}
+
+// planRotations assigns the airframes of req to the flights departing on its
+// date, which is a greedy heuristic: flights are assigned in order of
+// departure, each to an airframe of its aircraft type that is already at the
+// origin and has turned around, the one that became available last to keep
+// the others free. Only if there is none, the airframe with the shortest
+// ferry leg that still makes the departure is positioned. Every leg must be
+// within the range of the airframe and, for airframes with a home base, so
+// must the way back to it, which is flown as a ferry leg at the end of the
+// day. Airframes without a known position take their first flight wherever
+// it departs.
+func planRotations(ctx context.Context, db bun.IDB, req models.RotationRequest) (models.RotationPlan, error) {
+	day, err := time.Parse(time.DateOnly, req.Date)
+	if err != nil {
+		return models.RotationPlan{}, BadRequestError(fmt.Sprintf("invalid date %q", req.Date))
+	}
+	turnaround := MinTurnaround
+	if req.MinTurnaroundMinutes > 0 {
+		turnaround = time.Duration(req.MinTurnaroundMinutes) * time.Minute
+	}
+
+	var airframes []models.Airframe
+	query := db.NewSelect().Model(&airframes).Order("id")
+	if len(req.AirframeIds) > 0 {
+		query.Where("id IN (?)", bun.In(req.AirframeIds))
+	} else {
+		query.Where("status = ?", models.AirframeActive)
+	}
+	if err := query.Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.RotationPlan{}, fmt.Errorf("failed to load airframes: %w", err)
+	}
+
+	var flights []models.Flight
+	if err := db.NewSelect().Model(&flights).
+		Where("departure_time >= ?", day).
+		Where("departure_time < ?", day.AddDate(0, 0, 1)).
+		Where("status != ?", models.FlightCancelled).
+		Order("departure_time", "id").
+		Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return models.RotationPlan{}, fmt.Errorf("failed to load flights: %w", err)
+	}
+
+	tails := make([]*tail, 0, len(airframes))
+	codes := []string{}
+	if len(airframes) > 0 {
+		typeIds := make([]int, 0, len(airframes))
+		for _, a := range airframes {
+			typeIds = append(typeIds, a.AircraftId)
+		}
+		var aircraft []models.Aircraft
+		if err := db.NewSelect().Model(&aircraft).Where("id IN (?)", bun.In(typeIds)).Scan(ctx); err != nil {
+			return models.RotationPlan{}, fmt.Errorf("failed to load aircraft: %w", err)
+		}
+		types := make(map[int]models.Aircraft, len(aircraft))
+		for _, a := range aircraft {
+			types[a.Id] = a
+		}
+
+		for _, a := range airframes {
+			t := &tail{airframe: a, aircraft: types[a.AircraftId], ready: day}
+			if iata, ok := req.Positions[a.Id]; ok {
+				t.pos = iata
+			} else if err := lastPosition(ctx, db, t, day, turnaround); err != nil {
+				return models.RotationPlan{}, err
+			}
+			t.rotation = &models.Rotation{
+				AirframeId:   a.Id,
+				Registration: a.Registration,
+				AircraftId:   a.AircraftId,
+				HomeBase:     a.HomeBase,
+				Start:        t.pos,
+				Legs:         []models.RotationLeg{},
+			}
+			tails = append(tails, t)
+			codes = append(codes, t.pos, a.HomeBase)
+		}
+	}
+	for _, f := range flights {
+		codes = append(codes, f.Origin, f.Destination)
+	}
+	airports, err := airportsByCode(ctx, db, codes)
+	if err != nil {
+		return models.RotationPlan{}, err
+	}
+	dist := func(from, to string) float64 {
+		if from == to {
+			return 0
+		}
+		a, ok := airports[from]
+		b, ok2 := airports[to]
+		if !ok || !ok2 {
+			return math.Inf(1)
+		}
+		return distanceKm(
+			models.PointCoords{Lat: a.Latitude, Lng: a.Longitude},
+			models.PointCoords{Lat: b.Latitude, Lng: b.Longitude},
+		)
+	}
+
+	plan := models.RotationPlan{
+		Date:      req.Date,
+		Flights:   len(flights),
+		Rotations: []models.Rotation{},
+		Uncovered: []models.UncoveredFlight{},
+	}
+	for _, f := range flights {
+		if err := ctx.Err(); err != nil {
+			return models.RotationPlan{}, err
+		}
+		departure, arrival := flightTimes(f)
+		flightKm := dist(f.Origin, f.Destination)
+
+		var best *tail
+		var bestFerryKm float64
+		var bestReady time.Time
+		sameType := false
+		for _, t := range tails {
+			if t.airframe.AircraftId != f.AircraftId {
+				continue
+			}
+			sameType = true
+			if !t.inRange(flightKm) || (t.airframe.HomeBase != "" && !t.inRange(dist(f.Destination, t.airframe.HomeBase))) {
+				continue
+			}
+
+			ready := t.ready
+			if t.landed {
+				ready = ready.Add(turnaround)
+			}
+			var ferryKm float64
+			if t.pos != "" && t.pos != f.Origin {
+				ferryKm = dist(t.pos, f.Origin)
+				if !t.inRange(ferryKm) {
+					continue
+				}
+				ready = ready.Add(t.ferryTime(ferryKm) + turnaround)
+			}
+			if ready.After(departure) {
+				continue
+			}
+
+			if best == nil || ferryKm < bestFerryKm || (ferryKm == bestFerryKm && t.ready.After(bestReady)) {
+				best, bestFerryKm, bestReady = t, ferryKm, t.ready
+			}
+		}
+
+		if best == nil {
+			reason := fmt.Sprintf("no airframe can reach %s by %s", f.Origin, departure.Format(time.RFC3339))
+			if !sameType {
+				reason = fmt.Sprintf("no airframe of aircraft type %d", f.AircraftId)
+			}
+			plan.Uncovered = append(plan.Uncovered, models.UncoveredFlight{
+				FlightId:      f.Id,
+				FlightNumber:  f.FlightNumber,
+				AircraftId:    f.AircraftId,
+				Origin:        f.Origin,
+				Destination:   f.Destination,
+				DepartureTime: departure,
+				Reason:        reason,
+			})
+			continue
+		}
+
+		if best.pos != "" && best.pos != f.Origin {
+			best.ferry(f.Origin, bestFerryKm, turnaround)
+		}
+		best.rotation.Legs = append(best.rotation.Legs, models.RotationLeg{
+			FlightId:      f.Id,
+			FlightNumber:  f.FlightNumber,
+			Origin:        f.Origin,
+			Destination:   f.Destination,
+			DepartureTime: departure,
+			ArrivalTime:   arrival,
+			DistanceKm:    flightKm,
+		})
+		best.rotation.Flights++
+		best.pos, best.ready, best.landed = f.Destination, arrival, true
+		plan.Covered++
+	}
+
+	for _, t := range tails {
+		if base := t.airframe.HomeBase; base != "" && t.rotation.Flights > 0 && t.pos != base {
+			t.ferry(base, dist(t.pos, base), turnaround)
+		}
+		t.rotation.End = t.pos
+		plan.FerryLegs += t.rotation.FerryLegs
+		plan.Rotations = append(plan.Rotations, *t.rotation)
+	}
+
+	return plan, nil
+}
+
+// ferry positions the airframe empty at to, departing once it turned around.
+func (t *tail) ferry(to string, km float64, turnaround time.Duration) {
+	departure := t.ready
+	if t.landed {
+		departure = departure.Add(turnaround)
+	}
+	arrival := departure.Add(t.ferryTime(km))
+	t.rotation.Legs = append(t.rotation.Legs, models.RotationLeg{
+		Ferry:         true,
+		Origin:        t.pos,
+		Destination:   to,
+		DepartureTime: departure,
+		ArrivalTime:   arrival,
+		DistanceKm:    km,
+	})
+	t.rotation.FerryLegs++
+	t.pos, t.ready, t.landed = to, arrival, true
+}
+
+// lastPosition places the airframe of t where its last flight before day
+// arrived, or at its home base if it has none.
+func lastPosition(ctx context.Context, db bun.IDB, t *tail, day time.Time, turnaround time.Duration) error {
+	var last models.Flight
+	err := db.NewSelect().Model(&last).
+		Where("airframe_id = ?", t.airframe.Id).
+		Where("departure_time < ?", day).
+		Where("status != ?", models.FlightCancelled).
+		Order("departure_time DESC", "id DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		t.pos = t.airframe.HomeBase
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to find last flight of airframe %d: %w", t.airframe.Id, err)
+	}
+
+	t.pos = last.Destination
+	if last.DivertedTo != "" {
+		t.pos = last.DivertedTo
+	}
+	// Airframes that turned around before the day are ready at its start.
+	if _, arrival := flightTimes(last); arrival.Add(turnaround).After(t.ready) {
+		t.ready, t.landed = arrival, true
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+// MaxScheduleWindowDays is the longest date window schedules are expanded for
+// at once.
+const MaxScheduleWindowDays = 366
+
+type ScheduleService struct {
+	db *bun.DB
+}
+
+func NewScheduleService(db *bun.DB) *ScheduleService {
+	return &ScheduleService{db: db}
+}
+
+// ScheduleFilter narrows down the schedules returned by ListSchedules.
+type ScheduleFilter struct {
+	FlightNumber string
+	Origin       string
+	Destination  string
+	AircraftId   int
+}
+
+func (f ScheduleFilter) apply(query *bun.SelectQuery) {
+	if f.FlightNumber != "" {
+		query.Where("flight_number = ?", strings.ToUpper(f.FlightNumber))
+	}
+	if f.Origin != "" {
+		query.Where("origin = ?", strings.ToUpper(f.Origin))
+	}
+	if f.Destination != "" {
+		query.Where("destination = ?", strings.ToUpper(f.Destination))
+	}
+	if f.AircraftId > 0 {
+		query.Where("aircraft_id = ?", f.AircraftId)
+	}
+}
+
+// scheduleListSpec lists the schedule fields that can be sorted and selected.
+var scheduleListSpec = listSpec{
+	columns: map[string]string{
+		"id":              "id",
+		"flight_number":   "flight_number",
+		"aircraft_id":     "aircraft_id",
+		"origin":          "origin",
+		"destination":     "destination",
+		"days_of_week":    "days_of_week",
+		"departure_local": "departure_local",
+		"block_minutes":   "block_minutes",
+		"effective_from":  "effective_from",
+		"effective_to":    "effective_to",
+	},
+	pk: "id",
+}
+
+func (ss *ScheduleService) ListSchedules(ctx context.Context, filter ScheduleFilter, opts ListOptions) (models.Page[models.Schedule], error) {
+	query := ss.db.NewSelect().Model((*models.Schedule)(nil))
+	filter.apply(query)
+
+	page, err := paginate[models.Schedule](ctx, query, opts, scheduleListSpec)
+	if err != nil {
+		return page, fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	return page, nil
+}
+
+// FindSchedule returns the schedule with the given id.
+func (ss *ScheduleService) FindSchedule(ctx context.Context, id int) (models.Schedule, error) {
+	return findSchedule(ctx, ss.db, id)
+}
+
+// CreateSchedule validates and stores a new schedule. Its flights are not
+// created until the schedule is materialized.
+func (ss *ScheduleService) CreateSchedule(ctx context.Context, schedule *models.Schedule) error {
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+	schedule.Id = 0
+
+	return ss.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkScheduleReferences(ctx, tx, schedule); err != nil {
+			return err
+		}
+		if _, err := tx.NewInsert().Model(schedule).Returning("id").Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create schedule: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateSchedule replaces the schedule with the given id. Flights already
+// materialized from it are left unchanged.
+func (ss *ScheduleService) UpdateSchedule(ctx context.Context, id int, schedule *models.Schedule) error {
+	if schedule.Id != 0 && schedule.Id != id {
+		return models.ValidationErrors{{Field: "id", Message: fmt.Sprintf("cannot be changed from %d", id)}}
+	}
+	schedule.Id = id
+	if err := schedule.Validate(); err != nil {
+		return err
+	}
+
+	return ss.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if err := checkScheduleReferences(ctx, tx, schedule); err != nil {
+			return err
+		}
+		res, err := tx.NewUpdate().Model(schedule).WherePK().Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update schedule: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("schedule not found: %d", id))
+		}
+		return nil
+	})
+}
+
+// DeleteSchedule removes a schedule. Flights materialized from it are kept
+// as individual flights.
+func (ss *ScheduleService) DeleteSchedule(ctx context.Context, id int) error {
+	return ss.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewUpdate().Model((*models.Flight)(nil)).
+			Set("schedule_id = NULL").
+			Where("schedule_id = ?", id).
+			Exec(ctx); err != nil {
+			return fmt.Errorf("failed to detach flights of schedule: %w", err)
+		}
+
+		res, err := tx.NewDelete().Model((*models.Schedule)(nil)).Where("id = ?", id).Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to delete schedule: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return NotFoundError(fmt.Sprintf("schedule not found: %d", id))
+		}
+		return nil
+	})
+}
+
+// PreviewFlights expands the schedule with the given id into the flights it
+// operates between the local dates from and to (inclusive) without storing
+// them.
+func (ss *ScheduleService) PreviewFlights(ctx context.Context, id int, from, to time.Time) (models.ScheduleFlights, error) {
+	if err := checkScheduleWindow(from, to); err != nil {
+		return models.ScheduleFlights{}, err
+	}
+
+	schedule, err := findSchedule(ctx, ss.db, id)
+	if err != nil {
+		return models.ScheduleFlights{}, err
+	}
+	flights, err := expandSchedule(ctx, ss.db, schedule, from, to)
+	if err != nil {
+		return models.ScheduleFlights{}, err
+	}
+
+	return models.ScheduleFlights{
+		ScheduleId: id,
+		From:       from.Format(time.DateOnly),
+		To:         to.Format(time.DateOnly),
+		Flights:    flights,
+	}, nil
+}
+
+// MaterializeFlights stores the flights PreviewFlights returns. Flights that
+// already exist with the same flight number and departure time are skipped,
+// so materializing a window twice creates its flights once.
+func (ss *ScheduleService) MaterializeFlights(ctx context.Context, id int, from, to time.Time) (models.ScheduleFlights, error) {
+	if err := checkScheduleWindow(from, to); err != nil {
+		return models.ScheduleFlights{}, err
+	}
+
+	res := models.ScheduleFlights{
+		ScheduleId: id,
+		From:       from.Format(time.DateOnly),
+		To:         to.Format(time.DateOnly),
+		Flights:    []models.Flight{},
+	}
+	err := ss.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		schedule, err := findSchedule(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		res.Flights, res.Skipped, err = materializeFlights(ctx, tx, schedule, from, to)
+		return err
+	})
+	if err != nil {
+		return models.ScheduleFlights{}, err
+	}
+
+	return res, nil
+}
+
+// materializeFlights stores the flights of schedule between the local dates
+// from and to that do not exist yet and returns them together with the
+// number of existing ones.
+func materializeFlights(ctx context.Context, tx bun.Tx, schedule models.Schedule, from, to time.Time) ([]models.Flight, int, error) {
+	flights, err := expandSchedule(ctx, tx, schedule, from, to)
+	if err != nil {
+		return nil, 0, err
+	}
+	created := []models.Flight{}
+	if len(flights) == 0 {
+		return created, 0, nil
+	}
+
+	var existing []time.Time
+	if err := tx.NewSelect().Model((*models.Flight)(nil)).
+		Column("departure_time").
+		Where("flight_number = ?", schedule.FlightNumber).
+		Where("departure_time >= ?", flights[0].DepartureTime).
+		Where("departure_time <= ?", flights[len(flights)-1].DepartureTime).
+		Scan(ctx, &existing); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, 0, fmt.Errorf("failed to find existing flights: %w", err)
+	}
+	exists := make(map[time.Time]bool, len(existing))
+	for _, t := range existing {
+		exists[t.UTC()] = true
+	}
+
+	skipped := 0
+	for _, f := range flights {
+		if exists[f.DepartureTime] {
+			skipped++
+			continue
+		}
+		created = append(created, f)
+	}
+	if len(created) == 0 {
+		return created, skipped, nil
+	}
+	if _, err := tx.NewInsert().Model(&created).Returning("id").Exec(ctx); err != nil {
+		return nil, 0, fmt.Errorf("failed to create flights: %w", err)
+	}
+	return created, skipped, nil
+}
+
+func findSchedule(ctx context.Context, db bun.IDB, id int) (models.Schedule, error) {
+	var schedule models.Schedule
+	if err := db.NewSelect().Model(&schedule).Where("id = ?", id).Scan(ctx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.Schedule{}, NotFoundError(fmt.Sprintf("schedule not found: %d", id))
+		}
+		return models.Schedule{}, fmt.Errorf("failed to find schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+// expandSchedule returns the flights of schedule in the time zone of its
+// origin airport.
+func expandSchedule(ctx context.Context, db bun.IDB, schedule models.Schedule, from, to time.Time) ([]models.Flight, error) {
+	var timeZone string
+	if err := db.NewSelect().Model((*models.Airport)(nil)).Column("time_zone").Where("iata = ?", schedule.Origin).Scan(ctx, &timeZone); err != nil {
+		return nil, fmt.Errorf("failed to find origin of schedule: %w", err)
+	}
+	if timeZone == "" {
+		return nil, ConflictError(fmt.Sprintf("origin airport %s has no time zone", schedule.Origin))
+	}
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone of airport %s: %w", schedule.Origin, err)
+	}
+
+	return schedule.Flights(loc, from, to), nil
+}
+
+func checkScheduleWindow(from, to time.Time) error {
+	if to.Before(from) {
+		return BadRequestError("invalid date window: from must not be after to")
+	}
+	if to.Sub(from) >= MaxScheduleWindowDays*24*time.Hour {
+		return BadRequestError(fmt.Sprintf("invalid date window: must not exceed %d days", MaxScheduleWindowDays))
+	}
+	return nil
+}
+
+// checkScheduleReferences verifies the airports and aircraft of schedule like
+// those of a flight, and that the origin has a time zone to expand the
+// schedule in.
+func checkScheduleReferences(ctx context.Context, tx bun.Tx, schedule *models.Schedule) error {
+	var errs models.ValidationErrors
+	err := checkFlightReferences(ctx, tx, &models.Flight{
+		FlightNumber: schedule.FlightNumber,
+		AircraftId:   schedule.AircraftId,
+		Origin:       schedule.Origin,
+		Destination:  schedule.Destination,
+	})
+	if err != nil && !errors.As(err, &errs) {
+		return err
+	}
+
+	var timeZone string
+	if err := tx.NewSelect().Model((*models.Airport)(nil)).Column("time_zone").Where("iata = ?", schedule.Origin).Scan(ctx, &timeZone); err == nil && timeZone == "" {
+		errs = append(errs, models.ValidationError{Field: "origin", Message: fmt.Sprintf("airport %s has no time zone", schedule.Origin)})
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
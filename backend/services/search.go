@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/leanderkunstmann/terraroute/backend/database"
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+const (
+	// DefaultSearchLimit is the number of matches returned when no limit is given.
+	DefaultSearchLimit = 10
+	// MaxSearchLimit caps the number of matches a single search can return.
+	MaxSearchLimit = 50
+	// searchCandidateFactor widens the candidate set fetched from the database
+	// so that ranking in Go can still reorder the best matches.
+	searchCandidateFactor = 5
+	// fuzzyPrefixLength is how many leading characters of each token the
+	// SQLite index is searched by when the whole tokens match nothing.
+	fuzzyPrefixLength = 2
+	// fuzzyWordSimilarity is the pg_trgm word similarity threshold of the
+	// second search, the default of 0.6 misses most typos.
+	fuzzyWordSimilarity = 0.3
+	// maxSearchScan caps the airports ranked when there is no index, on
+	// SQLite builds without FTS5.
+	maxSearchScan = 5000
+)
+
+// SearchAirports returns airports matching q ranked by relevance.
+// Codes are matched by prefix, names and cities by word prefix with tolerance
+// for accents and small typos, so "zurich" and "zuirch" both find "Zürich".
+func (as *AirportService) SearchAirports(ctx context.Context, q string, limit int) ([]models.AirportMatch, error) {
+	tokens := searchTokens(q)
+	if len(tokens) == 0 {
+		return nil, BadRequestError("search query must contain at least one letter or digit")
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	candidates, err := as.searchCandidates(ctx, q, tokens, limit*searchCandidateFactor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search airports: %w", err)
+	}
+
+	matches := rankAirports(candidates, tokens)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches, nil
+}
+
+// searchCandidates fetches the airports worth ranking using the full-text
+// facilities of the current dialect. Typos defeat prefix matching, so when the
+// index yields nothing it is searched again more loosely: by the first
+// fuzzyPrefixLength characters of any token on SQLite, with a lower word
+// similarity threshold on PostgreSQL. Without an index at most maxSearchScan
+// airports are ranked.
+func (as *AirportService) searchCandidates(ctx context.Context, q string, tokens []string, limit int) ([]models.Airport, error) {
+	var airports []models.Airport
+	switch {
+	case as.db.Dialect().Name() == dialect.SQLite && as.hasSearchTable(ctx):
+		exact, fuzzy := make([]string, len(tokens)), make([]string, len(tokens))
+		for i, token := range tokens {
+			exact[i] = fmt.Sprintf("\"%s\"*", token)
+			if r := []rune(token); len(r) > fuzzyPrefixLength {
+				token = string(r[:fuzzyPrefixLength])
+			}
+			fuzzy[i] = fmt.Sprintf("\"%s\"*", token)
+		}
+		for _, match := range []string{strings.Join(exact, " "), strings.Join(fuzzy, " OR ")} {
+			err := as.db.NewSelect().Model(&airports).
+				Join("JOIN "+database.AirportSearchTable+" AS s ON s.rowid = airport.rowid").
+				Where("s."+database.AirportSearchTable+" MATCH ?", match).
+				OrderExpr("s.rank").
+				Limit(limit).
+				Scan(ctx)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return nil, err
+			}
+			if len(airports) > 0 {
+				break
+			}
+		}
+	case as.db.Dialect().Name() == dialect.PG:
+		term := strings.ToLower(strings.TrimSpace(q))
+		if err := trigramSearch(ctx, as.db, &airports, term, limit); err != nil {
+			return nil, err
+		}
+		if len(airports) > 0 {
+			break
+		}
+		// SET LOCAL only lasts until the end of the transaction.
+		if err := as.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf("SET LOCAL pg_trgm.word_similarity_threshold = %g", fuzzyWordSimilarity)); err != nil {
+				return err
+			}
+			return trigramSearch(ctx, tx, &airports, term, limit)
+		}); err != nil {
+			return nil, err
+		}
+	default:
+		if err := as.db.NewSelect().Model(&airports).Limit(maxSearchScan).Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+	return airports, nil
+}
+
+// trigramSearch matches codes by prefix and names and cities by trigram word
+// similarity on PostgreSQL, best matches first.
+func trigramSearch(ctx context.Context, db bun.IDB, airports *[]models.Airport, term string, limit int) error {
+	// The expressions match the trigram indexes.
+	name := database.SearchUnaccent + "(lower(name))"
+	city := database.SearchUnaccent + "(lower(city))"
+	err := db.NewSelect().Model(airports).
+		WhereOr("iata ILIKE ?", term+"%").
+		WhereOr("icao ILIKE ?", term+"%").
+		WhereOr(database.SearchUnaccent+"(?) <% "+name, term).
+		WhereOr(database.SearchUnaccent+"(?) <% "+city, term).
+		OrderExpr("greatest(word_similarity("+database.SearchUnaccent+"(?), "+name+"), word_similarity("+database.SearchUnaccent+"(?), "+city+")) DESC", term, term).
+		Limit(limit).
+		Scan(ctx)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	return nil
+}
+
+// hasSearchTable reports whether the SQLite FTS5 index exists. It is missing
+// when go-sqlite3 was built without the sqlite_fts5 tag.
+func (as *AirportService) hasSearchTable(ctx context.Context) bool {
+	return as.searchTable.get(func() (bool, error) {
+		n, err := as.db.NewSelect().
+			Table("sqlite_master").
+			Where("type = 'table' AND name = ?", database.AirportSearchTable).
+			Count(ctx)
+		return n > 0, err
+	})
+}
+
+// rankAirports scores every airport against the query tokens and returns the
+// matching ones ordered by descending score.
+func rankAirports(airports []models.Airport, tokens []string) []models.AirportMatch {
+	var matches []models.AirportMatch
+	for _, airport := range airports {
+		fields := []struct {
+			name   string
+			value  string
+			code   bool
+			weight float64
+		}{
+			{"iata", airport.IATA, true, 1},
+			{"icao", airport.ICAO, true, 0.98},
+			{"city", airport.City, false, 1},
+			{"name", airport.Name, false, 0.95},
+		}
+
+		best := models.AirportMatch{Airport: airport}
+		for _, f := range fields {
+			var score float64
+			if f.code {
+				score = scoreCode(f.value, tokens)
+			} else {
+				score = scoreText(f.value, tokens)
+			}
+			if score*f.weight > best.Score {
+				best.Score = score * f.weight
+				best.MatchedOn = f.name
+			}
+		}
+
+		if best.Score > 0 {
+			matches = append(matches, best)
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].IATA < matches[j].IATA
+	})
+
+	return matches
+}
+
+// scoreCode matches a single-token query against an airport code by prefix.
+func scoreCode(code string, tokens []string) float64 {
+	if code == "" || len(tokens) != 1 {
+		return 0
+	}
+	code = strings.ToLower(code)
+	switch {
+	case tokens[0] == code:
+		return 1
+	case strings.HasPrefix(code, tokens[0]):
+		return 0.9
+	}
+	return 0
+}
+
+// scoreText matches every query token against the words of a free text field.
+// A field only matches when every token matches one of its words; the score
+// is the average of the per-token scores, with a bonus for an exact match of
+// the whole field.
+func scoreText(text string, tokens []string) float64 {
+	words := searchTokens(text)
+	if len(words) == 0 {
+		return 0
+	}
+	if strings.Join(words, " ") == strings.Join(tokens, " ") {
+		return 0.97
+	}
+
+	var total float64
+	for _, token := range tokens {
+		var best float64
+		for _, word := range words {
+			best = max(best, scoreWord(word, token))
+		}
+		if best == 0 {
+			return 0
+		}
+		total += best
+	}
+
+	return total / float64(len(tokens)) * 0.95
+}
+
+// scoreWord compares a query token with a single word. Exact matches score
+// highest, followed by prefix matches and finally matches within a small edit
+// distance, which also covers typos in a partially typed word.
+func scoreWord(word, token string) float64 {
+	switch {
+	case word == token:
+		return 1
+	case strings.HasPrefix(word, token):
+		return 0.85
+	}
+
+	allowed := allowedEdits(token)
+	if allowed == 0 {
+		return 0
+	}
+
+	distance := editDistance(word, token)
+	if prefix := []rune(word); len(prefix) > len([]rune(token)) {
+		distance = min(distance, editDistance(string(prefix[:len([]rune(token))]), token))
+	}
+	if distance > allowed {
+		return 0
+	}
+
+	return 0.7 - 0.1*float64(distance-1)
+}
+
+// allowedEdits returns how many typos are tolerated for a token of the given
+// length. Very short tokens must match exactly to keep results meaningful.
+func allowedEdits(token string) int {
+	switch n := len([]rune(token)); {
+	case n <= 3:
+		return 0
+	case n <= 6:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// editDistance computes the optimal string alignment distance between a and
+// b, i.e. the Levenshtein distance where adjacent transpositions count once.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
+// searchTokens lowercases s, folds accented letters to their ASCII base and
+// splits the result into words of letters and digits.
+func searchTokens(s string) []string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if folded, ok := foldedRunes[r]; ok {
+			b.WriteString(folded)
+			continue
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			continue
+		}
+		b.WriteRune(' ')
+	}
+	return strings.Fields(b.String())
+}
+
+// foldedRunes maps lowercase accented Latin letters to their ASCII spelling.
+var foldedRunes = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'ç': "c", 'ć': "c", 'ĉ': "c", 'č': "c",
+	'ď': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ğ': "g", 'ģ': "g",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'į': "i", 'ı': "i",
+	'ķ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o",
+	'ŕ': "r", 'ř': "r",
+	'ś': "s", 'š': "s", 'ş': "s", 'ș': "s",
+	'ť': "t", 'ţ': "t", 'ț': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ý': "y", 'ÿ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'þ': "th",
+}
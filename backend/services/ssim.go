@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+	"github.com/uptrace/bun"
+)
+
+// ImportSSIM reads an IATA SSIM Chapter 7 file and stores a schedule for
+// every flight leg, together with its flights over the period of operation.
+// Aircraft are identified by their IATA type code. The file is imported as a
+// whole: if any record is invalid or references an unknown airport or
+// aircraft type, nothing is stored and all problems are reported by line.
+// Legs matching a stored schedule and existing flights are skipped, so
+// importing a file twice stores it once.
+func (ss *ScheduleService) ImportSSIM(ctx context.Context, r io.Reader) (models.SSIMImport, error) {
+	legs, err := models.ParseSSIM(r)
+	if err != nil {
+		return models.SSIMImport{}, err
+	}
+
+	res := models.SSIMImport{Legs: len(legs)}
+	if len(legs) == 0 {
+		return res, nil
+	}
+	err = ss.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		codes := make([]string, 0, len(legs))
+		var points []string
+		for _, leg := range legs {
+			codes = append(codes, leg.AircraftType)
+			for _, seg := range leg.Segments {
+				points = append(points, seg.BoardPoint, seg.OffPoint)
+			}
+		}
+		var aircraft []models.Aircraft
+		if err := tx.NewSelect().Model(&aircraft).Where("iata_code IN (?)", bun.In(codes)).Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to load aircraft: %w", err)
+		}
+		aircraftIds := make(map[string]int, len(aircraft))
+		for _, a := range aircraft {
+			aircraftIds[a.IATACode] = a.Id
+		}
+		airports, err := airportsByCode(ctx, tx, points)
+		if err != nil {
+			return err
+		}
+
+		var errs models.ValidationErrors
+		schedules := make([]models.Schedule, 0, len(legs))
+		for _, leg := range legs {
+			line := fmt.Sprintf("line %d", leg.Line)
+			for _, seg := range leg.Segments {
+				res.Segments++
+				for _, point := range []string{seg.BoardPoint, seg.OffPoint} {
+					if _, ok := airports[point]; !ok {
+						errs = append(errs, models.ValidationError{Field: fmt.Sprintf("line %d", seg.Line), Message: fmt.Sprintf("unknown airport %s", point)})
+					}
+				}
+			}
+
+			aircraftId, ok := aircraftIds[leg.AircraftType]
+			if !ok {
+				errs = append(errs, models.ValidationError{Field: line, Message: fmt.Sprintf("unknown aircraft type %q", leg.AircraftType)})
+				continue
+			}
+			schedule := leg.Schedule(aircraftId)
+			if err := schedule.Validate(); err != nil {
+				if err := appendLineErrors(&errs, line, err); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := checkScheduleReferences(ctx, tx, &schedule); err != nil {
+				if err := appendLineErrors(&errs, line, err); err != nil {
+					return err
+				}
+				continue
+			}
+			from, _ := time.Parse(time.DateOnly, schedule.EffectiveFrom)
+			to, _ := time.Parse(time.DateOnly, schedule.EffectiveTo)
+			if err := checkScheduleWindow(from, to); err != nil {
+				errs = append(errs, models.ValidationError{Field: line, Message: err.Error()})
+				continue
+			}
+			schedules = append(schedules, schedule)
+		}
+		if len(errs) > 0 {
+			return errs
+		}
+
+		for _, schedule := range schedules {
+			err := tx.NewSelect().Model((*models.Schedule)(nil)).
+				Column("id").
+				Where("flight_number = ?", schedule.FlightNumber).
+				Where("aircraft_id = ?", schedule.AircraftId).
+				Where("origin = ?", schedule.Origin).
+				Where("destination = ?", schedule.Destination).
+				Where("days_of_week = ?", schedule.DaysOfWeek).
+				Where("departure_local = ?", schedule.DepartureLocal).
+				Where("block_minutes = ?", schedule.BlockMinutes).
+				Where("effective_from = ?", schedule.EffectiveFrom).
+				Where("effective_to = ?", schedule.EffectiveTo).
+				Limit(1).
+				Scan(ctx, &schedule.Id)
+			switch {
+			case err == nil:
+				res.SchedulesExisting++
+			case errors.Is(err, sql.ErrNoRows):
+				if _, err := tx.NewInsert().Model(&schedule).Returning("id").Exec(ctx); err != nil {
+					return fmt.Errorf("failed to create schedule: %w", err)
+				}
+				res.SchedulesCreated++
+			default:
+				return fmt.Errorf("failed to find schedule: %w", err)
+			}
+
+			from, _ := time.Parse(time.DateOnly, schedule.EffectiveFrom)
+			to, _ := time.Parse(time.DateOnly, schedule.EffectiveTo)
+			created, skipped, err := materializeFlights(ctx, tx, schedule, from, to)
+			if err != nil {
+				return err
+			}
+			res.FlightsCreated += len(created)
+			res.FlightsExisting += skipped
+		}
+		return nil
+	})
+	if err != nil {
+		return models.SSIMImport{}, err
+	}
+
+	return res, nil
+}
+
+// ExportSSIM writes the schedules matching filter to w as an SSIM Chapter 7
+// file in local time. Schedules whose aircraft has no IATA type code or whose
+// destination has no time zone cannot be exported and fail the export with a
+// ConflictError before anything is written.
+func (ss *ScheduleService) ExportSSIM(ctx context.Context, w io.Writer, filter ScheduleFilter) error {
+	var schedules []models.Schedule
+	query := ss.db.NewSelect().Model(&schedules).Order("flight_number", "effective_from", "id")
+	filter.apply(query)
+	if err := query.Scan(ctx); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to list schedules: %w", err)
+	}
+
+	ids := make([]int, 0, len(schedules))
+	codes := make([]string, 0, 2*len(schedules))
+	for _, s := range schedules {
+		ids = append(ids, s.AircraftId)
+		codes = append(codes, s.Origin, s.Destination)
+	}
+	aircraft := map[int]models.Aircraft{}
+	if len(ids) > 0 {
+		var rows []models.Aircraft
+		if err := ss.db.NewSelect().Model(&rows).Where("id IN (?)", bun.In(ids)).Scan(ctx); err != nil {
+			return fmt.Errorf("failed to load aircraft: %w", err)
+		}
+		for _, a := range rows {
+			aircraft[a.Id] = a
+		}
+	}
+	airports, err := airportsByCode(ctx, ss.db, codes)
+	if err != nil {
+		return err
+	}
+
+	var problems []string
+	var legs []models.SSIMLeg
+	for _, s := range schedules {
+		a := aircraft[s.AircraftId]
+		if a.IATACode == "" {
+			problems = append(problems, fmt.Sprintf("aircraft %q of %s has no IATA code", a.Name, s.FlightNumber))
+			continue
+		}
+		origin, err := airportLocation(airports[s.Origin])
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		destination, err := airportLocation(airports[s.Destination])
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		scheduleLegs, err := s.SSIMLegs(origin, destination, a.IATACode)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		legs = append(legs, scheduleLegs...)
+	}
+	if len(problems) > 0 {
+		return ConflictError("cannot export schedules: " + strings.Join(slices.Compact(problems), "; "))
+	}
+
+	return models.WriteSSIM(w, legs, time.Now().UTC())
+}
+
+// airportLocation returns the time zone of an airport.
+func airportLocation(a models.Airport) (*time.Location, error) {
+	if a.TimeZone == "" {
+		return nil, fmt.Errorf("airport %s has no time zone", a.IATA)
+	}
+	loc, err := time.LoadLocation(a.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone of airport %s: %w", a.IATA, err)
+	}
+	return loc, nil
+}
+
+// appendLineErrors adds the field errors of err to errs, prefixed with the
+// line of the record they belong to. Other errors are returned.
+func appendLineErrors(errs *models.ValidationErrors, line string, err error) error {
+	var fields models.ValidationErrors
+	if !errors.As(err, &fields) {
+		return err
+	}
+	for _, f := range fields {
+		*errs = append(*errs, models.ValidationError{Field: line, Message: f.Field + " " + f.Message})
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/leanderkunstmann/terraroute/backend/models"
+)
+
+const (
+	// DefaultStreamBuffer is the number of messages buffered per subscriber.
+	DefaultStreamBuffer = 256
+	// DefaultPositionInterval is how often positions are pushed to the
+	// subscribers of the flight stream.
+	DefaultPositionInterval = time.Second
+)
+
+// ErrStreamClosed is returned when subscribing to a closed stream.
+var ErrStreamClosed = errors.New("flight stream is shutting down")
+
+// StreamFilter selects the messages a subscriber of the flight stream
+// receives. Empty fields match everything. BBox applies to positions only,
+// Airport matches the origin or destination, or the airport a flight was
+// diverted to.
+type StreamFilter struct {
+	Types        []string
+	BBox         *models.BBox
+	Airport      string
+	FlightNumber string
+}
+
+func (f StreamFilter) matches(msg models.StreamMessage) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, msg.Type) {
+		return false
+	}
+
+	var number, origin, destination, diverted string
+	switch {
+	case msg.Position != nil:
+		if f.BBox != nil && !f.BBox.Contains(msg.Position.Position) {
+			return false
+		}
+		number, origin, destination = msg.Position.FlightNumber, msg.Position.Origin, msg.Position.Destination
+	case msg.Status != nil:
+		number, origin, destination = msg.Status.FlightNumber, msg.Status.Origin, msg.Status.Destination
+		diverted = msg.Status.DivertedTo
+	}
+
+	if f.FlightNumber != "" && !strings.EqualFold(f.FlightNumber, number) {
+		return false
+	}
+	if f.Airport != "" && !strings.EqualFold(f.Airport, origin) && !strings.EqualFold(f.Airport, destination) &&
+		!strings.EqualFold(f.Airport, diverted) {
+		return false
+	}
+	return true
+}
+
+// Subscription receives the messages of the flight stream matching its
+// filter on C. C is closed when the subscription ends.
+type Subscription struct {
+	C <-chan models.StreamMessage
+
+	c       chan models.StreamMessage
+	filter  StreamFilter
+	dropped atomic.Int64
+}
+
+// Dropped returns the number of messages discarded because the subscriber
+// did not keep up.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// FlightHub fans out flight stream messages to its subscribers. Every
+// subscriber has its own buffer; when a slow subscriber's buffer is full its
+// oldest message is discarded, so publishing never blocks and subscribers
+// always see the most recent state.
+type FlightHub struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewFlightHub() *FlightHub {
+	return &FlightHub{subs: map[*Subscription]struct{}{}}
+}
+
+// Subscribe registers a subscriber with a buffer of the given size, or
+// DefaultStreamBuffer if it is not positive.
+func (h *FlightHub) Subscribe(filter StreamFilter, buffer int) (*Subscription, error) {
+	if buffer <= 0 {
+		buffer = DefaultStreamBuffer
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, ErrStreamClosed
+	}
+
+	c := make(chan models.StreamMessage, buffer)
+	sub := &Subscription{C: c, c: c, filter: filter}
+	h.subs[sub] = struct{}{}
+	return sub, nil
+}
+
+// Unsubscribe ends a subscription and closes its channel.
+func (h *FlightHub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subs[sub]; ok {
+		delete(h.subs, sub)
+		close(sub.c)
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (h *FlightHub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// Publish delivers msg to every subscriber whose filter matches it.
+func (h *FlightHub) Publish(msg models.StreamMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs {
+		if !sub.filter.matches(msg) {
+			continue
+		}
+		for {
+			select {
+			case sub.c <- msg:
+			default:
+				// Make room by discarding the oldest message. The subscriber
+				// may have read it concurrently, then the send is retried.
+				select {
+				case <-sub.c:
+					sub.dropped.Add(1)
+				default:
+				}
+				continue
+			}
+			break
+		}
+	}
+}
+
+// Close ends all subscriptions and rejects new ones.
+func (h *FlightHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for sub := range h.subs {
+		delete(h.subs, sub)
+		close(sub.c)
+	}
+}
+
+// Subscribe subscribes to the position updates and status changes of flights.
+func (fs *FlightService) Subscribe(filter StreamFilter, buffer int) (*Subscription, error) {
+	return fs.hub.Subscribe(filter, buffer)
+}
+
+// Unsubscribe ends a subscription returned by Subscribe.
+func (fs *FlightService) Unsubscribe(sub *Subscription) {
+	fs.hub.Unsubscribe(sub)
+}
+
+// CloseStreams ends all stream subscriptions, e.g. on shutdown.
+func (fs *FlightService) CloseStreams() {
+	fs.hub.Close()
+}
+
+// StreamPositions publishes the positions of all airborne flights every
+// interval while there are subscribers, until ctx is done.
+func (fs *FlightService) StreamPositions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if fs.hub.Subscribers() == 0 {
+				continue
+			}
+			if err := fs.publishPositions(ctx, now); err != nil {
+				log.Printf("failed to stream flight positions: %v", err)
+			}
+		}
+	}
+}
+
+func (fs *FlightService) publishPositions(ctx context.Context, at time.Time) error {
+	positions, err := fs.Positions(ctx, at)
+	if err != nil {
+		return err
+	}
+	for i := range positions.Flights {
+		fs.hub.Publish(models.StreamMessage{Type: models.StreamPosition, Time: positions.At, Position: &positions.Flights[i]})
+	}
+	return nil
+}
+
+// publishStatus pushes a recorded flight event to the stream.
+func (fs *FlightService) publishStatus(flight models.Flight, event models.FlightEvent) {
+	fs.hub.Publish(models.StreamMessage{
+		Type: models.StreamStatus,
+		Time: event.RecordedAt,
+		Status: &models.FlightStatusChange{
+			FlightEvent:  event,
+			FlightNumber: flight.FlightNumber,
+			Origin:       flight.Origin,
+			Destination:  flight.Destination,
+		},
+	})
+}
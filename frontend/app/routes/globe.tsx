@@ -8,7 +8,7 @@ import TextField from '@mui/material/TextField'
 import Autocomplete from '@mui/material/Autocomplete'
 import axios from 'axios'
 
-import type { Airport, DistanceData, GeoLabel, GeoPath } from '~/types'
+import type { Airport, DistanceData, GeoLabel, GeoPath, Page } from '~/types'
 
 export function meta({}: Route.MetaArgs) {
   return [
@@ -49,8 +49,17 @@ export default function Routes() {
     handleResize() // Set initial dimensions
 
     const getAirports = async () => {
-      const res = await axios.get('http://192.168.0.178:8080/api/v1/airports')
-      setAirports(res.data)
+      // The airport list is paginated, follow the next links to the last page
+      const loaded: Airport[] = []
+      let next: string | undefined = '/api/v1/airports?limit=1000'
+      while (next) {
+        const res: { data: Page<Airport> } = await axios.get(
+          'http://192.168.0.178:8080' + next
+        )
+        loaded.push(...res.data.data)
+        next = res.data.links.next
+      }
+      setAirports(loaded)
     }
     getAirports()
 
@@ -5,11 +5,27 @@ export interface Airport {
   name: string
   city: string
   country: string
+  country_code: string // ISO 3166-1 alpha-2 code
   continent: string
+  continent_code: string
   latitude: number
   longitude: number
 }
 
+// Represents a page of a paginated list endpoint.
+export interface Page<T> {
+  data: T[]
+  total: number // number of items matching the filters
+  next_cursor?: string // empty on the last page
+  links: PageLinks
+}
+
+// Represents the links of a page, relative to the API host.
+export interface PageLinks {
+  self: string
+  next?: string // missing on the last page
+}
+
 // Represents the request structure for distance calculation.
 export interface DistanceRequest {
   departure: string // The starting point for the route.